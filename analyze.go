@@ -9,6 +9,7 @@ package crossplane
 
 // Upgrade for .gen.go files. If you don't have access to some private modules,
 // please use -skip options to skip them. e.g. go generate -skip="nap".
+// To generate them from local checkouts instead, see scripts/generate/manifest.json.
 
 // Update for headersmore
 //go:generate sh -c "sh ./scripts/generate/generate.sh --url https://github.com/openresty/headers-more-nginx-module.git --config-path ./scripts/generate/configs/headersmore_config.json > ./analyze_headersMore_directives.gen.go"
@@ -52,6 +53,9 @@ package crossplane
 //go:generate sh -c "sh ./scripts/generate/generate.sh --url $NPLUS_URL --config-path ./scripts/generate/configs/nplus_R33_config.json --branch $NPLUS_BRANCH --path ./src > analyze_nplus_R33_directives.gen.go"
 //go:generate sh -c "sh ./scripts/generate/generate.sh --url $NPLUS_URL --config-path ./scripts/generate/configs/nplus_R34_config.json --branch $NPLUS_BRANCH --path ./src > analyze_nplus_R34_directives.gen.go"
 
+// Update the documentation of directives from the XML sources of nginx.org.
+//go:generate sh -c "sh ./scripts/generate/generate.sh --url https://github.com/nginx/nginx.org.git --config-path ./scripts/generate/configs/docs_config.json --docs --path ./xml/en/docs > ./directive_docs.gen.go"
+
 import (
 	"fmt"
 )
@@ -65,13 +69,13 @@ const (
 	ngxConfTake4  = 0x00000010 // 4 args
 	ngxConfTake5  = 0x00000020 // 5 args
 	ngxConfTake6  = 0x00000040 // 6 args
-	// ngxConfTake7  = 0x00000080 // 7 args (currently unused).
-	ngxConfBlock = 0x00000100 // followed by block
-	ngxConfExpr  = 0x00000200 // directive followed by expression in parentheses `()`
-	ngxConfFlag  = 0x00000400 // 'on' or 'off'
-	ngxConfAny   = 0x00000800 // >=0 args
-	ngxConf1More = 0x00001000 // >=1 args
-	ngxConf2More = 0x00002000 // >=2 args
+	ngxConfTake7  = 0x00000080 // 7 args
+	ngxConfBlock  = 0x00000100 // followed by block
+	ngxConfExpr   = 0x00000200 // directive followed by expression in parentheses `()`
+	ngxConfFlag   = 0x00000400 // 'on' or 'off'
+	ngxConfAny    = 0x00000800 // >=0 args
+	ngxConf1More  = 0x00001000 // >=1 args
+	ngxConf2More  = 0x00002000 // >=2 args
 
 	// some helpful argument style aliases.
 	ngxConfTake12   = ngxConfTake1 | ngxConfTake2
@@ -141,24 +145,15 @@ func enterBlockCtx(stmt *Directive, ctx blockCtx) blockCtx {
 
 //nolint:gocyclo,funlen,gocognit
 func analyze(fname string, stmt *Directive, term string, ctx blockCtx, options *ParseOptions) error {
-	var masks []uint
-	knownDirective := false
-
-	currCtx, knownContext := contexts[ctx.key()]
-	directiveName := stmt.Directive
-
-	// Find all bitmasks from the sources invoker provides.
-	for _, matchFn := range options.DirectiveSources {
-		if masksInFn, found := matchFn(directiveName); found {
-			masks = append(masks, masksInFn...)
-			knownDirective = true
-		}
+	key := ctx.key()
+	currCtx, knownContext := contexts[key]
+	if !knownContext {
+		currCtx, knownContext = options.BlockContexts.context(key)
 	}
-
-	// If DirectiveSources was not provided, DefaultDirectivesMatchFunc will be used
-	// for validation
-	if len(options.DirectiveSources) == 0 {
-		masks, knownDirective = DefaultDirectivesMatchFunc(directiveName)
+	masks, knownDirective := matchDirective(stmt.Directive, options.DirectiveSources)
+	if custom, ok := options.BlockContexts.match(stmt.Directive); ok {
+		masks = append(masks[:len(masks):len(masks)], custom...)
+		knownDirective = true
 	}
 
 	// if strict and directive isn't recognized then throw error
@@ -208,8 +203,14 @@ func analyze(fname string, stmt *Directive, term string, ctx blockCtx, options *
 	var what string
 	for i := 0; i < len(ctxMasks); i++ {
 		mask := ctxMasks[i]
-		// if the directive is an expression type, there must be '(' 'expr' ')' args
-		if (mask&ngxConfExpr) > 0 && !validExpr(stmt) {
+		if options.PythonCompat {
+			// Python crossplane really does go in reverse, which changes the reported error
+			mask = ctxMasks[len(ctxMasks)-1-i]
+		}
+
+		// if the directive is an expression type, there must be '(' 'expr' ')' args.
+		// Python crossplane doesn't check expressions.
+		if (mask&ngxConfExpr) > 0 && !options.PythonCompat && !validExpr(stmt) {
 			what = fmt.Sprintf(`directive "%s"'s is not enclosed in parentheses`, stmt.Directive)
 			continue
 		}
@@ -250,6 +251,25 @@ func analyze(fname string, stmt *Directive, term string, ctx blockCtx, options *
 	}
 }
 
+// matchDirective returns the bitmasks of the directive in all of the sources.
+func matchDirective(directive string, sources []MatchFunc) ([]uint, bool) {
+	// If no sources were provided, DefaultDirectivesMatchFunc will be used
+	// for validation
+	if len(sources) == 0 {
+		return DefaultDirectivesMatchFunc(directive)
+	}
+
+	var masks []uint
+	known := false
+	for _, matchFn := range sources {
+		if masksInFn, found := matchFn(directive); found {
+			masks = append(masks, masksInFn...)
+			known = true
+		}
+	}
+	return masks, known
+}
+
 func unionBitmaskMaps(maps ...map[string][]uint) map[string][]uint {
 	union := make(map[string][]uint)
 
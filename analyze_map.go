@@ -9,52 +9,60 @@ package crossplane
 
 import "fmt"
 
-// mapParameterMasks holds bit masks that define the behavior of the body of map-like directives.
-// Some map directives have "special parameter" with different behaviors than the default.
-type mapParameterMasks struct {
-	specialParameterMasks map[string]uint
-	defaultMasks          uint
+// MapBody describes the body of a map-like block directive. The body of a map-like block
+// is made of parameters instead of nginx directives, like the "key value;" pairs of a map,
+// and therefore can't be analyzed in the same way as other blocks.
+type MapBody struct {
+	// Params are the arguments that the parameters take after their name.
+	Params ArgStyle
+	// SpecialParams are the parameters that take other arguments than Params,
+	// e.g. "hostnames" in a map, which takes none.
+	SpecialParams map[string]ArgStyle
 }
 
 //nolint:gochecknoglobals
-var mapBodies = map[string]mapParameterMasks{
+var mapBodies = map[string]MapBody{
 	"charset_map": {
-		defaultMasks: ngxConfTake1,
+		Params: ngxConfTake1,
 	},
 	"geo": {
-		specialParameterMasks: map[string]uint{"ranges": ngxConfNoArgs, "proxy_recursive": ngxConfNoArgs},
-		defaultMasks:          ngxConfTake1,
+		SpecialParams: map[string]ArgStyle{"ranges": ngxConfNoArgs, "proxy_recursive": ngxConfNoArgs},
+		Params:        ngxConfTake1,
 	},
 	"map": {
-		specialParameterMasks: map[string]uint{"volatile": ngxConfNoArgs, "hostnames": ngxConfNoArgs},
-		defaultMasks:          ngxConfTake1,
+		SpecialParams: map[string]ArgStyle{"volatile": ngxConfNoArgs, "hostnames": ngxConfNoArgs},
+		Params:        ngxConfTake1,
 	},
 	"match": {
-		defaultMasks: ngxConf1More,
+		Params: ngxConf1More,
 	},
 	"types": {
-		defaultMasks: ngxConf1More,
+		Params: ngxConf1More,
 	},
 	"split_clients": {
-		defaultMasks: ngxConfTake1,
+		Params: ngxConfTake1,
 	},
 	"geoip2": {
-		specialParameterMasks: map[string]uint{"auto_reload": ngxConfTake1},
-		defaultMasks: ngxConf1More,
+		SpecialParams: map[string]ArgStyle{"auto_reload": ngxConfTake1},
+		Params:        ngxConf1More,
 	},
 	"otel_exporter": {
-		defaultMasks: ngxConfTake1,
+		Params: ngxConfTake1,
 	},
 }
 
-// analyzeMapBody validates the body of a map-like directive. Map-like directives are block directives
-// that don't contain nginx directives, and therefore cannot be analyzed in the same way as other blocks.
-func analyzeMapBody(fname string, parameter *Directive, term string, mapCtx string) error {
-	masks, known := mapBodies[mapCtx]
-	// if we're not inside a known map-like directive, don't bother analyzing
-	if !known {
-		return nil
+// lookupMapBody returns the body of the map-like block directive, from the options
+// or else the bundled ones.
+func lookupMapBody(directive string, options *ParseOptions) (MapBody, bool) {
+	if body, ok := options.MapBodies[directive]; ok {
+		return body, true
 	}
+	body, ok := mapBodies[directive]
+	return body, ok
+}
+
+// analyzeMapBody validates a parameter in the body of a map-like directive.
+func analyzeMapBody(fname string, parameter *Directive, term string, mapCtx string, body MapBody) error {
 	if term != ";" {
 		return &ParseError{
 			What:      fmt.Sprintf(`unexpected "%s"`, term),
@@ -65,9 +73,9 @@ func analyzeMapBody(fname string, parameter *Directive, term string, mapCtx stri
 		}
 	}
 
-	if mask, ok := masks.specialParameterMasks[parameter.Directive]; ok {
+	if mask, ok := body.SpecialParams[parameter.Directive]; ok {
 		// use mask to check the parameter's arguments
-		if hasValidArguments(mask, parameter.Args) {
+		if hasValidArguments(uint(mask), parameter.Args) {
 			return nil
 		}
 
@@ -80,7 +88,7 @@ func analyzeMapBody(fname string, parameter *Directive, term string, mapCtx stri
 		}
 	}
 
-	mask := masks.defaultMasks
+	mask := uint(body.Params)
 
 	// use mask to check the parameter's arguments
 	if hasValidArguments(mask, parameter.Args) {
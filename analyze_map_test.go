@@ -8,6 +8,8 @@
 package crossplane
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -246,7 +248,7 @@ func TestAnalyzeMapBody(t *testing.T) {
 		t.Run(name, func(t *testing.T) {
 			t.Parallel()
 
-			err := analyzeMapBody("nginx.conf", tc.parameter, tc.term, tc.mapDirective)
+			err := analyzeMapBody("nginx.conf", tc.parameter, tc.term, tc.mapDirective, mapBodies[tc.mapDirective])
 			if tc.wantErr == nil {
 				require.NoError(t, err)
 				return
@@ -261,3 +263,54 @@ func TestAnalyzeMapBody(t *testing.T) {
 		})
 	}
 }
+
+func TestParse_mapBodies(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "nginx.conf")
+	require.NoError(t, os.WriteFile(path, []byte(`
+http {
+    vendor_table backends {
+        default 127.0.0.1;
+        one 127.0.0.1 weight=2;
+        strict;
+        missing;
+    }
+    types {
+        text/html html htm;
+    }
+    map $host $name {
+        hostnames;
+        default 0;
+    }
+}
+`), 0o600))
+
+	options := &ParseOptions{MapBodies: map[string]MapBody{
+		"vendor_table": {
+			Params:        ArgsTake1 | ArgsTake2,
+			SpecialParams: map[string]ArgStyle{"strict": ArgsNone},
+		},
+		"types": {Params: ArgsTake1},
+	}}
+	payload, err := Parse(path, options)
+	require.NoError(t, err)
+
+	var lines []int
+	for _, e := range payload.Errors {
+		require.Equal(t, "invalid number of parameters", e.Error.(*ParseError).What)
+		lines = append(lines, *e.Line)
+	}
+	require.Equal(t, []int{7, 10}, lines)
+
+	table := payload.Config[0].Parsed[0].Block[0]
+	require.Len(t, table.Block, 3)
+	for _, param := range table.Block {
+		require.True(t, param.IsMapBlockParameter)
+	}
+
+	// without the option the body is parsed as directives
+	payload, err = Parse(path, &ParseOptions{})
+	require.NoError(t, err)
+	require.Empty(t, payload.Errors)
+	require.False(t, payload.Config[0].Parsed[0].Block[0].Block[0].IsMapBlockParameter)
+}
@@ -0,0 +1,180 @@
+/**
+ * Copyright (c) F5, Inc.
+ *
+ * This source code is licensed under the Apache License, Version 2.0 license found in the
+ * LICENSE file in the root directory of this source tree.
+ */
+
+package crossplane
+
+import (
+	"strings"
+	"sync"
+)
+
+const (
+	directiveSlabSize = 256
+	stringSlabSize    = 1024
+
+	// the number of bytes of a config per directive and per string at least, which
+	// size the first slabs of the files that are smaller than the ones of the pools
+	bytesPerDirective = 16
+	bytesPerString    = 4
+
+	// maxInternedLen is the length of the longest string that's interned.
+	maxInternedLen = 64
+)
+
+//nolint:gochecknoglobals
+var (
+	directiveSlabs = sync.Pool{New: func() interface{} { return new([directiveSlabSize]Directive) }}
+	stringSlabs    = sync.Pool{New: func() interface{} { return new([stringSlabSize]string) }}
+)
+
+// knownDirectiveName returns the name of the directive as it is in the directive
+// tables, so that it's the same string for all of the directives that have it.
+func knownDirectiveName(name string) (string, bool) {
+	known, ok := bundledDirectives().names[name]
+	return known, ok
+}
+
+// arena allocates the directives of a config file and their arguments in slabs,
+// which are reused by later parses once the arena is released.
+type arena struct {
+	directiveSlabs []*[directiveSlabSize]Directive
+	stringSlabs    []*[stringSlabSize]string
+	// the first slabs of a small file, which are sized from its length instead of
+	// being taken from the pools
+	firstDirectives []Directive
+	firstStrs       []string
+	// the parts of the last slabs that are free
+	directives []Directive
+	strs       []string
+	released   bool
+}
+
+// newArena returns an arena for a config file of size bytes. Small files get
+// first slabs that fit them, so that they don't hold on to full slabs.
+func newArena(size int) *arena {
+	a := &arena{}
+	if n := size/bytesPerDirective + 1; n < directiveSlabSize {
+		a.firstDirectives = make([]Directive, n)
+		a.directives = a.firstDirectives
+	}
+	if n := size/bytesPerString + 1; n < stringSlabSize {
+		a.firstStrs = make([]string, n)
+		a.strs = a.firstStrs
+	}
+	return a
+}
+
+func (a *arena) newDirective() *Directive {
+	if len(a.directives) == 0 {
+		slab, _ := directiveSlabs.Get().(*[directiveSlabSize]Directive)
+		a.directiveSlabs = append(a.directiveSlabs, slab)
+		a.directives = slab[:]
+	}
+	d := &a.directives[0]
+	a.directives = a.directives[1:]
+	return d
+}
+
+// strings returns a copy of ss, which can be appended to without changing
+// the strings of other directives.
+func (a *arena) strings(ss []string) []string {
+	if len(ss) == 0 {
+		return []string{}
+	}
+	if len(ss) > stringSlabSize {
+		return append(make([]string, 0, len(ss)), ss...)
+	}
+	if len(ss) > len(a.strs) {
+		slab, _ := stringSlabs.Get().(*[stringSlabSize]string)
+		a.stringSlabs = append(a.stringSlabs, slab)
+		a.strs = slab[:]
+	}
+	c := a.strs[:len(ss):len(ss)]
+	copy(c, ss)
+	a.strs = a.strs[len(ss):]
+	return c
+}
+
+// release clears the slabs of a and returns them to their pools.
+func (a *arena) release() {
+	if a.released {
+		return
+	}
+	a.released = true
+	for _, slab := range a.directiveSlabs {
+		*slab = [directiveSlabSize]Directive{}
+		directiveSlabs.Put(slab)
+	}
+	for _, slab := range a.stringSlabs {
+		*slab = [stringSlabSize]string{}
+		stringSlabs.Put(slab)
+	}
+	for i := range a.firstDirectives {
+		a.firstDirectives[i] = Directive{}
+	}
+	for i := range a.firstStrs {
+		a.firstStrs[i] = ""
+	}
+	a.directiveSlabs, a.stringSlabs = nil, nil
+	a.firstDirectives, a.firstStrs = nil, nil
+	a.directives, a.strs = nil, nil
+}
+
+// newDirective returns a new directive of f, allocated in its arena if it has one.
+func (f *parsedFile) newDirective(name string, line int, file string) *Directive {
+	var d *Directive
+	if f.arena != nil {
+		d = f.arena.newDirective()
+	} else {
+		d = &Directive{}
+	}
+	d.Directive = f.internName(name)
+	d.Line = line
+	d.Args = f.copyArgs(nil)
+	d.File = file
+	return d
+}
+
+// copyArgs returns a copy of args, allocated in the arena of f if it has one.
+func (f *parsedFile) copyArgs(args []string) []string {
+	if f.arena != nil {
+		return f.arena.strings(args)
+	}
+	return append(make([]string, 0, len(args)), args...)
+}
+
+// internName returns the string f uses for the name of a directive.
+func (f *parsedFile) internName(name string) string {
+	// comments aren't kept as names
+	if f.interned == nil || strings.HasPrefix(name, "#") {
+		return name
+	}
+	if known, ok := knownDirectiveName(name); ok {
+		return known
+	}
+	return f.intern(name)
+}
+
+// intern returns the string f uses for s, a copy of s so that it doesn't keep the
+// rest of the config that was read in memory. Strings of up to maxInternedLen
+// bytes are copied the first time f sees them, and the copy is shared.
+func (f *parsedFile) intern(s string) string {
+	if f.interned == nil {
+		return s
+	}
+	if interned, ok := f.interned[s]; ok {
+		return interned
+	}
+	var sb strings.Builder
+	sb.Grow(len(s))
+	sb.WriteString(s)
+	c := sb.String()
+	if len(s) <= maxInternedLen {
+		f.interned[c] = c
+	}
+	return c
+}
@@ -0,0 +1,121 @@
+/**
+ * Copyright (c) F5, Inc.
+ *
+ * This source code is licensed under the Apache License, Version 2.0 license found in the
+ * LICENSE file in the root directory of this source tree.
+ */
+
+package crossplane
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"unsafe"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse_internStringsAndArena(t *testing.T) {
+	t.Parallel()
+	forEachFixture(t, func(t *testing.T, fixture parseFixture, path string, expected *Payload) {
+		options := fixture.options
+		options.InternStrings = true
+		options.UseArena = true
+		payload, err := Parse(path, &options)
+		require.NoError(t, err)
+		require.Equal(t, expected.Config, payload.Config)
+		require.Equal(t, expected.Errors, payload.Errors)
+
+		// the arguments of a directive are its own
+		for _, config := range payload.Config {
+			for _, stmt := range config.Parsed {
+				stmt.Args = append(stmt.Args, "changed")
+			}
+		}
+		for i, config := range payload.Config {
+			for j, stmt := range config.Parsed {
+				require.Equal(t, expected.Config[i].Parsed[j].Args, stmt.Args[:len(stmt.Args)-1])
+			}
+		}
+
+		payload.Release()
+		require.Nil(t, payload.Config)
+		payload.Release()
+	})
+}
+
+func TestParse_internStrings(t *testing.T) {
+	t.Parallel()
+	conf := "events {}\nhttp {\n    custom_directive on;\n    custom_directive on;\n    server_name example.com;\n}\n"
+	payload, err := Parse("nginx.conf", &ParseOptions{
+		InternStrings:             true,
+		SkipDirectiveContextCheck: true,
+		SkipDirectiveArgsCheck:    true,
+		Open: func(string) (io.ReadCloser, error) {
+			return io.NopCloser(strings.NewReader(conf)), nil
+		},
+	})
+	require.NoError(t, err)
+	http := payload.Config[0].Parsed[1].Block
+	require.Len(t, http, 3)
+
+	same := func(a, b string) bool {
+		return *(*uintptr)(unsafe.Pointer(&a)) == *(*uintptr)(unsafe.Pointer(&b))
+	}
+	// known names are the ones of the directive tables
+	name, ok := knownDirectiveName("server_name")
+	require.True(t, ok)
+	require.True(t, same(name, http[2].Directive))
+	// other names and arguments are shared within a file
+	require.True(t, same(http[0].Directive, http[1].Directive))
+	require.True(t, same(http[0].Args[0], http[1].Args[0]))
+	// and don't point into the config that was read
+	require.False(t, same(http[2].Args[0], conf[strings.Index(conf, "example.com"):]))
+}
+
+func TestPayload_release(t *testing.T) {
+	t.Parallel()
+	path := getTestConfigPath("includes-regular", "nginx.conf")
+	payload, err := Parse(path, &ParseOptions{UseArena: true})
+	require.NoError(t, err)
+	combined, err := payload.Combined()
+	require.NoError(t, err)
+	require.NotEmpty(t, payload.arenas)
+	require.Equal(t, payload.arenas, combined.arenas)
+
+	stmt := payload.Config[0].Parsed[0]
+	combined.Release()
+	payload.Release()
+	require.Nil(t, payload.Config)
+	require.Nil(t, combined.Config)
+	// the directives were cleared before their slab went back to the pool
+	require.Equal(t, Directive{}, *stmt)
+
+	// payloads that don't use an arena are left alone
+	payload, err = Parse(path, &ParseOptions{})
+	require.NoError(t, err)
+	payload.Release()
+	require.NotEmpty(t, payload.Config)
+}
+
+func TestNewArena(t *testing.T) {
+	t.Parallel()
+	small := newArena(100)
+	require.Len(t, small.directives, 7)
+	require.Len(t, small.strs, 26)
+	for i := 0; i < 7; i++ {
+		small.newDirective()
+	}
+	require.Empty(t, small.directiveSlabs)
+	// the directives that don't fit are allocated in the slabs of the pools
+	small.newDirective()
+	require.Len(t, small.directiveSlabs, 1)
+	small.release()
+
+	// files as large as the slabs of the pools use them from the start
+	large := newArena(directiveSlabSize * bytesPerDirective)
+	require.Empty(t, large.directives)
+	require.Empty(t, large.strs)
+	large.release()
+}
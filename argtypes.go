@@ -0,0 +1,159 @@
+/**
+ * Copyright (c) F5, Inc.
+ *
+ * This source code is licensed under the Apache License, Version 2.0 license found in the
+ * LICENSE file in the root directory of this source tree.
+ */
+
+package crossplane
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ArgKind is the kind of the values that a directive takes, from the setter that
+// nginx sets the directive with, e.g. ArgKindSize for ngx_conf_set_size_slot.
+type ArgKind string
+
+// Kinds of the values of arguments.
+const (
+	ArgKindFlag    ArgKind = "flag"
+	ArgKindStr     ArgKind = "str"
+	ArgKindKeyval  ArgKind = "keyval"
+	ArgKindNum     ArgKind = "num"
+	ArgKindSize    ArgKind = "size"
+	ArgKindOffset  ArgKind = "offset"
+	ArgKindMsec    ArgKind = "msec"
+	ArgKindSec     ArgKind = "sec"
+	ArgKindBufs    ArgKind = "bufs"
+	ArgKindEnum    ArgKind = "enum"
+	ArgKindBitmask ArgKind = "bitmask"
+	ArgKindPath    ArgKind = "path"
+	ArgKindAccess  ArgKind = "access"
+)
+
+// ArgType is the type of the arguments of a directive, as generated from the
+// ngx_command_t definitions of a module.
+type ArgType struct {
+	Kind ArgKind
+	// Values are the members of an enum or a bitmask.
+	Values []string
+}
+
+// Check returns an error if nginx doesn't accept args, the arguments of a directive
+// of the type. Flags, numbers, sizes, offsets, times, bufs, enums and bitmasks are
+// checked. Arguments of other kinds and arguments with variables are accepted.
+func (t ArgType) Check(args []string) error {
+	for i, arg := range args {
+		if strings.Contains(arg, "$") {
+			continue
+		}
+		kind := t.Kind
+		if kind == ArgKindBufs {
+			kind = ArgKindSize
+			if i == 0 {
+				kind = ArgKindNum
+			}
+		}
+		if !t.valid(kind, arg) {
+			return fmt.Errorf(`invalid %s value "%s"`, kind, arg)
+		}
+	}
+	return nil
+}
+
+func (t ArgType) valid(kind ArgKind, arg string) bool {
+	switch kind {
+	case ArgKindFlag:
+		return strings.EqualFold(arg, "on") || strings.EqualFold(arg, "off")
+	case ArgKindNum:
+		return isDigits(arg)
+	case ArgKindSize:
+		return isDigits(trimUnit(arg, "kKmM"))
+	case ArgKindOffset:
+		return isDigits(trimUnit(arg, "kKmMgG"))
+	case ArgKindMsec:
+		return validTime(arg, true)
+	case ArgKindSec:
+		return validTime(arg, false)
+	case ArgKindEnum, ArgKindBitmask:
+		for _, v := range t.Values {
+			if strings.EqualFold(arg, v) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// isDigits returns whether s is a non-empty string of decimal digits.
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// trimUnit removes the unit at the end of s if it's one of units.
+func trimUnit(s string, units string) string {
+	if s != "" && strings.ContainsRune(units, rune(s[len(s)-1])) {
+		return s[:len(s)-1]
+	}
+	return s
+}
+
+// validTime returns whether s is a time like "1h 30m" or "1h30m", which is in
+// seconds if it has no units. Like ngx_parse_time, the units have to go from
+// the largest to the smallest, and a number without a unit has to be the last.
+// Milliseconds are only allowed if ms is set.
+func validTime(s string, ms bool) bool {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return false
+	}
+	last := -1
+	for s != "" {
+		n := 0
+		for n < len(s) && s[n] >= '0' && s[n] <= '9' {
+			n++
+		}
+		if n == 0 {
+			return false
+		}
+		s = s[n:]
+		unit := -1
+		if s != "" {
+			unit = strings.IndexByte(timeUnits, s[0])
+		}
+		switch {
+		case strings.HasPrefix(s, "ms"):
+			if !ms {
+				return false
+			}
+			unit = len(timeUnits)
+			s = s[2:]
+		case unit >= 0:
+			s = s[1:]
+		default:
+			// a number without a unit ends the time
+			return strings.TrimLeft(s, " ") == ""
+		}
+		if unit <= last {
+			return false
+		}
+		last = unit
+		s = strings.TrimLeft(s, " ")
+	}
+	return true
+}
+
+// timeUnits are the units of validTime from the largest to the smallest, which
+// milliseconds come after.
+const timeUnits = "yMwdhms"
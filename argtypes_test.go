@@ -0,0 +1,66 @@
+/**
+ * Copyright (c) F5, Inc.
+ *
+ * This source code is licensed under the Apache License, Version 2.0 license found in the
+ * LICENSE file in the root directory of this source tree.
+ */
+
+package crossplane
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestArgType_Check(t *testing.T) {
+	t.Parallel()
+	proxied := []string{"off", "expired", "any"}
+	tests := map[string]struct {
+		argType ArgType
+		args    []string
+		wantErr bool
+	}{
+		"flag":                 {ArgType{Kind: ArgKindFlag}, []string{"On"}, false},
+		"invalid flag":         {ArgType{Kind: ArgKindFlag}, []string{"yes"}, true},
+		"num":                  {ArgType{Kind: ArgKindNum}, []string{"100"}, false},
+		"invalid num":          {ArgType{Kind: ArgKindNum}, []string{"-1"}, true},
+		"size":                 {ArgType{Kind: ArgKindSize}, []string{"8k"}, false},
+		"invalid size":         {ArgType{Kind: ArgKindSize}, []string{"1g"}, true},
+		"offset":               {ArgType{Kind: ArgKindOffset}, []string{"1g"}, false},
+		"msec":                 {ArgType{Kind: ArgKindMsec}, []string{"1h 30m 500ms"}, false},
+		"msec without units":   {ArgType{Kind: ArgKindMsec}, []string{"60"}, false},
+		"invalid msec":         {ArgType{Kind: ArgKindMsec}, []string{"10x"}, true},
+		"sec":                  {ArgType{Kind: ArgKindSec}, []string{"1d"}, false},
+		"sec with ms":          {ArgType{Kind: ArgKindSec}, []string{"500ms"}, true},
+		"sec without spaces":   {ArgType{Kind: ArgKindSec}, []string{"1h30m"}, false},
+		"days and hours":       {ArgType{Kind: ArgKindSec}, []string{"1d12h"}, false},
+		"msec without spaces":  {ArgType{Kind: ArgKindMsec}, []string{"1s500ms"}, false},
+		"units out of order":   {ArgType{Kind: ArgKindSec}, []string{"30m1h"}, true},
+		"repeated unit":        {ArgType{Kind: ArgKindSec}, []string{"1h 1h"}, true},
+		"number before units":  {ArgType{Kind: ArgKindSec}, []string{"1 30m"}, true},
+		"bufs":                 {ArgType{Kind: ArgKindBufs}, []string{"8", "4k"}, false},
+		"invalid bufs":         {ArgType{Kind: ArgKindBufs}, []string{"4k", "8"}, true},
+		"enum":                 {ArgType{Kind: ArgKindEnum, Values: []string{"on", "off", "clean"}}, []string{"clean"}, false},
+		"invalid enum":         {ArgType{Kind: ArgKindEnum, Values: []string{"on", "off", "clean"}}, []string{"dirty"}, true},
+		"bitmask":              {ArgType{Kind: ArgKindBitmask, Values: proxied}, []string{"expired", "any"}, false},
+		"invalid bitmask":      {ArgType{Kind: ArgKindBitmask, Values: proxied}, []string{"expired", "never"}, true},
+		"variables":            {ArgType{Kind: ArgKindSize}, []string{"$size"}, false},
+		"unchecked kind":       {ArgType{Kind: ArgKindStr}, []string{"anything"}, false},
+		"empty time":           {ArgType{Kind: ArgKindSec}, []string{""}, true},
+		"size with two units":  {ArgType{Kind: ArgKindSize}, []string{"1kk"}, true},
+		"time with unit first": {ArgType{Kind: ArgKindSec}, []string{"s"}, true},
+	}
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			err := tc.argType.Check(tc.args)
+			if tc.wantErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
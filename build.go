@@ -18,11 +18,14 @@ import (
 )
 
 type BuildOptions struct {
-	Indent      int
-	Tabs        bool
-	Header      bool
-	Builders    []RegisterBuilder // handle specific directives
-	extBuilders map[string]Builder
+	Indent   int
+	Tabs     bool
+	Header   bool
+	Builders []RegisterBuilder // handle specific directives
+	// If true, the output matches Python crossplane's: arguments are quoted
+	// like Python's repr, so single quotes are preferred over double quotes.
+	PythonCompat bool
+	extBuilders  map[string]Builder
 }
 
 // RegisterBuilder is an option that can be used to add a builder to build NGINX configuration for custom directives.
@@ -157,8 +160,13 @@ func Build(w io.Writer, config Config, options *BuildOptions) error {
 
 //nolint:gocognit
 func buildBlock(sb io.StringWriter, parent *Directive, block Directives, depth int, lastLine int, options *BuildOptions) {
+	enquote := Enquote
+	if options.PythonCompat {
+		enquote = pythonEnquote
+	}
+
 	for i, stmt := range block {
-		directive := Enquote(stmt.Directive)
+		directive := enquote(stmt.Directive)
 		// if the this statement is a comment on the same line as the preview, do not emit EOL for this stmt
 		if stmt.Line == lastLine && stmt.IsComment() {
 			_, _ = sb.WriteString(" #")
@@ -176,6 +184,9 @@ func buildBlock(sb io.StringWriter, parent *Directive, block Directives, depth i
 		if options.extBuilders != nil {
 			if ext, ok := options.extBuilders[directive]; ok {
 				_, _ = sb.WriteString(ext.Build(stmt))
+				if options.PythonCompat {
+					lastLine = stmt.Line
+				}
 				continue
 			}
 		}
@@ -193,13 +204,13 @@ func buildBlock(sb io.StringWriter, parent *Directive, block Directives, depth i
 					if i > 0 {
 						_, _ = sb.WriteString(" ")
 					}
-					_, _ = sb.WriteString(Enquote(arg))
+					_, _ = sb.WriteString(enquote(arg))
 				}
 				_, _ = sb.WriteString(")")
 			} else {
 				for _, arg := range stmt.Args {
 					_, _ = sb.WriteString(" ")
-					_, _ = sb.WriteString(Enquote(arg))
+					_, _ = sb.WriteString(enquote(arg))
 				}
 			}
 
@@ -434,11 +434,18 @@ var compareFixtures = []compareFixture{
 	{"quoted-right-brace", ParseOptions{}},
 	{"directive-with-space", ParseOptions{ErrorOnUnknownDirectives: true}},
 	{"empty-config", ParseOptions{}},
+	{"lua-block-brackets", ParseOptions{
+		ErrorOnUnknownDirectives: true,
+		DirectiveSources:         []MatchFunc{MatchNginxPlusLatest, MatchLuaLatest},
+		LexOptions:               LexOptions{Lexers: []RegisterLexer{lua.RegisterLexer()}},
+	}},
 }
 
 //nolint:gocognit
 func TestCompareParsedAndBuilt(t *testing.T) {
 	t.Parallel()
+	// the Lua builder only builds Lua blocks, so it's fine for every fixture
+	buildOptions := BuildOptions{Builders: []RegisterBuilder{lua.RegisterBuilder()}}
 	for _, fixture := range compareFixtures {
 		fixture := fixture
 		t.Run(fixture.name, func(t *testing.T) {
@@ -451,7 +458,7 @@ func TestCompareParsedAndBuilt(t *testing.T) {
 			}
 
 			var build1Buffer bytes.Buffer
-			if err := Build(&build1Buffer, origPayload.Config[0], &BuildOptions{}); err != nil {
+			if err := Build(&build1Buffer, origPayload.Config[0], &buildOptions); err != nil {
 				t.Fatal(err)
 			}
 			build1File := filepath.Join(tmpdir, "build1.conf")
@@ -473,7 +480,7 @@ func TestCompareParsedAndBuilt(t *testing.T) {
 			}
 
 			var build2Buffer bytes.Buffer
-			if err := Build(&build2Buffer, build1Payload.Config[0], &BuildOptions{}); err != nil {
+			if err := Build(&build2Buffer, build1Payload.Config[0], &buildOptions); err != nil {
 				t.Fatal(err)
 			}
 			build2File := filepath.Join(tmpdir, "build2.conf")
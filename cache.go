@@ -0,0 +1,171 @@
+/**
+ * Copyright (c) F5, Inc.
+ *
+ * This source code is licensed under the Apache License, Version 2.0 license found in the
+ * LICENSE file in the root directory of this source tree.
+ */
+
+package crossplane
+
+import (
+	"crypto/sha256"
+	"sort"
+	"sync"
+)
+
+// Parser parses NGINX configs like Parse and keeps what it parsed of every file.
+// Parsing a config again only parses the files that changed since the last time
+// and reuses the directives of the others.
+type Parser struct {
+	options ParseOptions
+	mu      sync.Mutex
+	files   map[string]*cacheEntry
+}
+
+// cacheEntry is a file as parsed on its own, before its includes got their indices.
+type cacheEntry struct {
+	key  fileKey
+	file *parsedFile
+}
+
+// fileKey is what the parse of a file depends on besides the options of the Parser.
+type fileKey struct {
+	path string
+	hash [sha256.Size]byte
+	ctx  string
+	// relative include patterns are joined to the directory of the main config file
+	configDir string
+	// the directive sources depend on the modules loaded by the config
+	modules string
+}
+
+// NewParser returns a Parser that parses configs with the given options.
+func NewParser(options *ParseOptions) *Parser {
+	return &Parser{
+		options: *options,
+		files:   map[string]*cacheEntry{},
+	}
+}
+
+// Parse parses an NGINX configuration file. The Payload is the same as the one
+// returned by the Parse function, but only the files that changed since the last
+// call are parsed again: the ones whose content or context changed, or whose include
+// directives match other files. These are returned in the order of the Payload's
+// configs, followed by the files that aren't part of the config anymore. Calls to
+// Parse are run one at a time.
+func (p *Parser) Parse(filename string) (*Payload, []string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	options := p.options
+	return parseFiles(filename, &options, p)
+}
+
+// key returns the key of the file of incl, whose content is src.
+func (p *Parser) key(parser *parser, incl fileCtx, src string) fileKey {
+	return fileKey{
+		path:      incl.path,
+		hash:      sha256.Sum256([]byte(src)),
+		ctx:       incl.ctx.key(),
+		configDir: parser.configDir,
+		modules:   parser.modules,
+	}
+}
+
+// lookup returns a copy of the file parsed with key if the files its include
+// directives match haven't changed either, or nil.
+func (p *Parser) lookup(parser *parser, key fileKey) *parsedFile {
+	entry, ok := p.files[key.path]
+	if !ok || entry.key != key {
+		return nil
+	}
+	for _, found := range entry.file.found {
+		fnames, err := parser.findIncludes(found.pattern)
+		if !equals(fnames, found.fnames) || !sameError(err, found.err) {
+			return nil
+		}
+	}
+
+	f := entry.file.clone()
+	f.entry = entry
+	f.reused = true
+	return f
+}
+
+// update replaces the files of p with the ones of the last parse, and adds the
+// files that were removed from the config to changed.
+func (p *Parser) update(entries map[string]*cacheEntry, changed []string) []string {
+	var removed []string
+	for path := range p.files {
+		if _, ok := entries[path]; !ok {
+			removed = append(removed, path)
+		}
+	}
+	sort.Strings(removed)
+	p.files = entries
+	return append(changed, removed...)
+}
+
+func sameError(a, b error) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Error() == b.Error()
+}
+
+// clone returns a deep copy of f. The directives of its includes are the ones of
+// the copy.
+func (f *parsedFile) clone() *parsedFile {
+	stmts := map[*Directive]*Directive{}
+	c := &parsedFile{
+		Config: Config{
+			File:   f.File,
+			Status: f.Status,
+			Errors: append([]ConfigError{}, f.Errors...),
+			Parsed: cloneDirectives(f.Parsed, stmts),
+		},
+		openErr:  f.openErr,
+		parseErr: f.parseErr,
+		errs:     append([]error(nil), f.errs...),
+		includes: make([]includeStmt, 0, len(f.includes)),
+		found:    f.found,
+	}
+	for _, incl := range f.includes {
+		stmt, ok := stmts[incl.stmt]
+		if !ok {
+			// the directive was dropped along with its block because of an error
+			stmt = cloneDirective(incl.stmt, stmts)
+		}
+		c.includes = append(c.includes, includeStmt{stmt: stmt, fnames: incl.fnames, ctx: incl.ctx})
+	}
+	return c
+}
+
+// cloneDirectives returns a deep copy of ds and maps the directives to their copy in stmts.
+func cloneDirectives(ds Directives, stmts map[*Directive]*Directive) Directives {
+	if ds == nil {
+		return nil
+	}
+	c := make(Directives, 0, len(ds))
+	for _, d := range ds {
+		c = append(c, cloneDirective(d, stmts))
+	}
+	return c
+}
+
+func cloneDirective(d *Directive, stmts map[*Directive]*Directive) *Directive {
+	c := *d
+	if d.Args != nil {
+		c.Args = append(make([]string, 0, len(d.Args)), d.Args...)
+	}
+	if d.Includes != nil {
+		c.Includes = append(make([]int, 0, len(d.Includes)), d.Includes...)
+	}
+	if d.Comment != nil {
+		comment := *d.Comment
+		c.Comment = &comment
+	}
+	c.Block = cloneDirectives(d.Block, stmts)
+	stmts[d] = &c
+	return &c
+}
@@ -0,0 +1,103 @@
+/**
+ * Copyright (c) F5, Inc.
+ *
+ * This source code is licensed under the Apache License, Version 2.0 license found in the
+ * LICENSE file in the root directory of this source tree.
+ */
+
+package crossplane
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParser_fixtures(t *testing.T) {
+	t.Parallel()
+	forEachFixture(t, func(t *testing.T, fixture parseFixture, path string, expected *Payload) {
+		options := fixture.options
+		files := make([]string, 0, len(expected.Config))
+		for _, config := range expected.Config {
+			files = append(files, config.File)
+		}
+
+		parser := NewParser(&options)
+		payload, changed, err := parser.Parse(path)
+		require.NoError(t, err)
+		require.Equal(t, expected, payload)
+		if !options.CombineConfigs {
+			require.Equal(t, files, changed)
+		}
+
+		// the payloads don't share anything with the cache
+		for _, config := range payload.Config {
+			for _, stmt := range config.Parsed {
+				stmt.Args = append(stmt.Args, "changed")
+			}
+		}
+
+		payload, changed, err = parser.Parse(path)
+		require.NoError(t, err)
+		require.Equal(t, expected, payload)
+		require.Empty(t, changed)
+	})
+}
+
+func TestParser_changes(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	write := func(name, conf string) {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, []byte(conf), 0o600))
+	}
+	write("nginx.conf", "events {}\nhttp {\n    include mime.types;\n    include sites/*.conf;\n}\n")
+	write("mime.types", "types {\n    text/html html;\n}\n")
+	write("sites/a.conf", "server {\n    listen 80;\n    include snippets/common.conf;\n}\n")
+	write("sites/b.conf", "server {\n    listen 81;\n    include snippets/common.conf;\n}\n")
+	write("snippets/common.conf", "location / {\n    return 200;\n}\n")
+
+	path := filepath.Join(dir, "nginx.conf")
+	file := func(name string) string {
+		return filepath.Join(dir, name)
+	}
+	options := &ParseOptions{ErrorOnUnknownDirectives: true}
+	parser := NewParser(options)
+	check := func(changed ...string) {
+		t.Helper()
+		expected, err := Parse(path, &ParseOptions{ErrorOnUnknownDirectives: true})
+		require.NoError(t, err)
+		payload, actual, err := parser.Parse(path)
+		require.NoError(t, err)
+		require.Equal(t, expected, payload)
+		require.Equal(t, changed, actual)
+	}
+
+	check(file("nginx.conf"), file("mime.types"), file("sites/a.conf"), file("sites/b.conf"), file("snippets/common.conf"))
+	check()
+
+	// the content of a file changes
+	write("snippets/common.conf", "location / {\n    return 204;\n}\n")
+	check(file("snippets/common.conf"))
+
+	// a file matching an include pattern is added, which moves the index of the snippet
+	write("sites/aa.conf", "server {\n    listen 82;\n}\n")
+	check(file("nginx.conf"), file("sites/aa.conf"))
+
+	// errors of unchanged files are reported again
+	write("sites/b.conf", "server {\n    listen 81;\n    unknown on;\n}\n")
+	check(file("sites/b.conf"))
+	check()
+
+	// a file matching an include pattern is removed, along with the file it was the only one to include
+	require.NoError(t, os.Remove(file("sites/a.conf")))
+	require.NoError(t, os.Remove(file("sites/aa.conf")))
+	check(file("nginx.conf"), file("sites/a.conf"), file("sites/aa.conf"), file("snippets/common.conf"))
+
+	// an explicitly included file goes missing
+	require.NoError(t, os.Remove(file("mime.types")))
+	check(file("nginx.conf"), file("mime.types"))
+}
@@ -0,0 +1,181 @@
+/**
+ * Copyright (c) F5, Inc.
+ *
+ * This source code is licensed under the Apache License, Version 2.0 license found in the
+ * LICENSE file in the root directory of this source tree.
+ */
+
+package main
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+
+	"github.com/nginxinc/nginx-go-crossplane"
+)
+
+// analysis is the parse of a config that includes a document.
+type analysis struct {
+	payload *crossplane.Payload
+	// err is the error that stopped the parse
+	err error
+	// dir is the directory that relative includes are found in
+	dir     string
+	configs map[string]*crossplane.Config
+	// blocks are the names of the blocks that enclose the include of every file,
+	// outermost first
+	blocks map[string][]string
+}
+
+// analyze returns the analysis of the config that includes the document at path:
+// the main config from the initialization options if it does, or else the document
+// on its own.
+func (s *server) analyze(path string) *analysis {
+	if a, ok := s.analyses[path]; ok {
+		return a
+	}
+	var a *analysis
+	if s.options.Config != "" {
+		a = s.parse(s.options.Config)
+		if _, ok := a.configs[path]; !ok {
+			a = nil
+		}
+	}
+	if a == nil {
+		a = s.parse(path)
+	}
+	s.analyses[path] = a
+	return a
+}
+
+func (s *server) parse(path string) *analysis {
+	a := &analysis{
+		dir:     filepath.Dir(path),
+		configs: map[string]*crossplane.Config{},
+		blocks:  map[string][]string{path: {}},
+	}
+	a.payload, a.err = crossplane.Parse(path, s.parseOptions())
+	if a.err != nil {
+		return a
+	}
+	for i := range a.payload.Config {
+		a.configs[a.payload.Config[i].File] = &a.payload.Config[i]
+	}
+	if len(a.payload.Config) > 0 {
+		a.walkIncludes(a.payload.Config[0].Parsed, []string{})
+	}
+	return a
+}
+
+// walkIncludes finds the blocks that enclose the includes of the files included by ds.
+func (a *analysis) walkIncludes(ds crossplane.Directives, blocks []string) {
+	for _, d := range ds {
+		for _, index := range d.Includes {
+			config := &a.payload.Config[index]
+			if _, ok := a.blocks[config.File]; ok {
+				continue
+			}
+			a.blocks[config.File] = append([]string{}, blocks...)
+			a.walkIncludes(config.Parsed, blocks)
+		}
+		if d.IsBlock() {
+			a.walkIncludes(d.Block, append(blocks[:len(blocks):len(blocks)], d.Directive))
+		}
+	}
+}
+
+// each calls fn with every directive of the analysis and the file it's in.
+func (a *analysis) each(fn func(file string, d *crossplane.Directive)) {
+	if a.payload == nil {
+		return
+	}
+	var walk func(file string, ds crossplane.Directives)
+	walk = func(file string, ds crossplane.Directives) {
+		for _, d := range ds {
+			fn(file, d)
+			walk(file, d.Block)
+		}
+	}
+	for _, config := range a.payload.Config {
+		walk(config.File, config.Parsed)
+	}
+}
+
+// diagnostics returns the errors of the document at path.
+func (s *server) diagnostics(path string) []diagnostic {
+	text := s.docs[path]
+	a := s.analyze(path)
+	diagnostics := []diagnostic{}
+	if a.err != nil {
+		return append(diagnostics, newDiagnostic(text, nil, a.err))
+	}
+	if config, ok := a.configs[path]; ok {
+		for _, e := range config.Errors {
+			diagnostics = append(diagnostics, newDiagnostic(text, e.Line, e.Error))
+		}
+	}
+	return diagnostics
+}
+
+func newDiagnostic(text string, line *int, err error) diagnostic {
+	d := diagnostic{
+		Severity: diagnosticSeverityError,
+		Source:   "crossplane",
+		Message:  err.Error(),
+	}
+	var perr *crossplane.ParseError
+	if errors.As(err, &perr) {
+		d.Message = perr.What
+		if line == nil {
+			line = perr.Line
+		}
+	}
+	if line != nil && *line > 0 {
+		d.Range = lineRange(text, *line-1)
+	}
+	return d
+}
+
+// statement is where an offset of a document is in its config.
+type statement struct {
+	// blocks are the names of the blocks the statement is in, outermost first
+	blocks []string
+	// tokens are the tokens of the statement up to the offset
+	tokens []crossplane.NgxToken
+	// inComment is true if the offset is in a comment
+	inComment bool
+}
+
+// statementAt returns the statement of the document at path that offset is in.
+func (s *server) statementAt(path string, offset int) statement {
+	text := s.docs[path]
+	st := statement{blocks: append([]string{}, s.analyze(path).blocks[path]...)}
+	lines := strings.Count(text[:offset], "\n") + 1
+
+	tokens := crossplane.NewTokenizer(strings.NewReader(text[:offset]), s.lexOptions())
+	for t, ok := tokens.Next(); ok && t.Error == nil; t, ok = tokens.Next() {
+		st.inComment = false
+		switch {
+		case t.IsQuoted:
+			st.tokens = append(st.tokens, t)
+		case strings.HasPrefix(t.Value, "#"):
+			st.inComment = t.Line == lines
+		case t.Value == ";":
+			st.tokens = nil
+		case t.Value == "{":
+			if len(st.tokens) > 0 {
+				st.blocks = append(st.blocks, st.tokens[0].Value)
+			}
+			st.tokens = nil
+		case t.Value == "}":
+			if len(st.blocks) > 0 {
+				st.blocks = st.blocks[:len(st.blocks)-1]
+			}
+			st.tokens = nil
+		default:
+			st.tokens = append(st.tokens, t)
+		}
+	}
+	return st
+}
@@ -0,0 +1,338 @@
+/**
+ * Copyright (c) F5, Inc.
+ *
+ * This source code is licensed under the Apache License, Version 2.0 license found in the
+ * LICENSE file in the root directory of this source tree.
+ */
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/nginxinc/nginx-go-crossplane"
+)
+
+// completion returns the directives allowed where a directive starts at pos.
+func (s *server) completion(path string, pos position) []completionItem {
+	items := []completionItem{}
+	text, ok := s.docs[path]
+	if !ok {
+		return items
+	}
+	offset := offsetOf(text, pos)
+	start, _ := wordAt(text, offset)
+	prefix := text[start:offset]
+
+	st := s.statementAt(path, offset)
+	args := len(st.tokens)
+	if prefix != "" {
+		// the token being typed
+		args--
+	}
+	if st.inComment || args != 0 {
+		return items
+	}
+
+	for _, name := range crossplane.AllowedDirectives(st.blocks) {
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		usages, _ := crossplane.DirectiveUsages(name)
+		items = append(items, completionItem{
+			Label:  name,
+			Kind:   completionItemKindKeyword,
+			Detail: describeUsages(usages),
+		})
+	}
+	return items
+}
+
+// describeUsages describes the arguments of the usages of a directive in short,
+// e.g. "args: 1 or 2 | args: 0 { }".
+func describeUsages(usages []crossplane.DirectiveUsage) string {
+	var descs []string
+	seen := map[string]bool{}
+	for _, usage := range usages {
+		desc := "args: " + usage.Args
+		if usage.Block {
+			desc += " { }"
+		}
+		if !seen[desc] {
+			seen[desc] = true
+			descs = append(descs, desc)
+		}
+	}
+	return strings.Join(descs, " | ")
+}
+
+// hover describes the directive whose name is at pos.
+func (s *server) hover(path string, pos position) *hover {
+	text, ok := s.docs[path]
+	if !ok {
+		return nil
+	}
+	start, end := wordAt(text, offsetOf(text, pos))
+	if start == end {
+		return nil
+	}
+	st := s.statementAt(path, end)
+	if st.inComment || len(st.tokens) != 1 {
+		return nil
+	}
+	name := st.tokens[0].Value
+	usages, known := crossplane.DirectiveUsages(name)
+	if !known {
+		return nil
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "**%s**\n", name)
+	for _, usage := range usages {
+		contexts := make([]string, 0, len(usage.Contexts))
+		for _, ctx := range usage.Contexts {
+			if len(ctx) == 0 {
+				ctx = []string{"main"}
+			}
+			contexts = append(contexts, "`"+strings.Join(ctx, " > ")+"`")
+		}
+		fmt.Fprintf(&sb, "\n- arguments: %s", usage.Args)
+		if usage.Block {
+			sb.WriteString(", followed by a block")
+		}
+		fmt.Fprintf(&sb, "; contexts: %s", strings.Join(contexts, ", "))
+	}
+	if docs, ok := crossplane.LookupDirective(name); ok {
+		sb.WriteString(describeDocs(docs))
+	}
+	return &hover{
+		Contents: markupContent{Kind: "markdown", Value: sb.String()},
+		Range:    &lspRange{Start: positionOf(text, start), End: positionOf(text, end)},
+	}
+}
+
+// describeDocs describes the documentation of a directive in every module that has it.
+func describeDocs(docs []crossplane.DirectiveDoc) string {
+	var sb strings.Builder
+	for _, doc := range docs {
+		sb.WriteString("\n\n---\n")
+		for _, syntax := range doc.Syntax {
+			fmt.Fprintf(&sb, "\n`%s`  ", syntax)
+		}
+		if doc.Default != "" {
+			fmt.Fprintf(&sb, "\ndefault: `%s`  ", doc.Default)
+		}
+		fmt.Fprintf(&sb, "\nmodule: %s", doc.Module)
+		if doc.AppearedIn != "" {
+			fmt.Fprintf(&sb, ", since %s", doc.AppearedIn)
+		}
+		if doc.Description != "" {
+			fmt.Fprintf(&sb, "\n\n%s", doc.Description)
+		}
+	}
+	return sb.String()
+}
+
+// variableSetters are the directives that set variables, and the index of
+// the argument that is the variable.
+//
+//nolint:gochecknoglobals
+var variableSetters = map[string]int{
+	"auth_request_set": 0,
+	"js_set":           0,
+	"js_var":           0,
+	"map":              1,
+	"perl_set":         0,
+	"set":              0,
+	"set_by_lua":       0,
+	"set_by_lua_block": 0,
+	"set_by_lua_file":  0,
+	"split_clients":    1,
+}
+
+// upstreamUsers are the directives whose argument may be the name of an upstream.
+//
+//nolint:gochecknoglobals
+var upstreamUsers = map[string]bool{
+	"fastcgi_pass":   true,
+	"grpc_pass":      true,
+	"memcached_pass": true,
+	"proxy_pass":     true,
+	"scgi_pass":      true,
+	"uwsgi_pass":     true,
+}
+
+// definition returns where the include file, upstream, variable or cache zone
+// at pos are defined.
+func (s *server) definition(path string, pos position) []location {
+	locations := []location{}
+	text, ok := s.docs[path]
+	if !ok {
+		return locations
+	}
+	offset := offsetOf(text, pos)
+	a := s.analyze(path)
+
+	if name := variableAt(text, offset); name != "" {
+		variable := "$" + name
+		a.each(func(file string, d *crossplane.Directive) {
+			if definesVariable(d, variable) {
+				locations = append(locations, s.locate(file, d.Line, variable))
+			}
+		})
+		return locations
+	}
+
+	_, end := wordAt(text, offset)
+	st := s.statementAt(path, end)
+	if st.inComment || len(st.tokens) < 2 {
+		return locations
+	}
+	directive, arg := st.tokens[0].Value, st.tokens[len(st.tokens)-1].Value
+
+	switch {
+	case directive == "include":
+		for _, file := range findIncludes(a.dir, arg) {
+			locations = append(locations, location{URI: pathToURI(file)})
+		}
+	case upstreamUsers[directive]:
+		name := upstreamName(arg)
+		a.each(func(file string, d *crossplane.Directive) {
+			if d.Directive == "upstream" && len(d.Args) > 0 && d.Args[0] == name {
+				locations = append(locations, s.locate(file, d.Line, name))
+			}
+		})
+	case strings.HasSuffix(directive, "_cache") && arg != "off":
+		zone := "keys_zone=" + arg
+		a.each(func(file string, d *crossplane.Directive) {
+			if d.Directive != directive+"_path" {
+				return
+			}
+			for _, darg := range d.Args {
+				if strings.HasPrefix(darg, zone+":") {
+					loc := s.locate(file, d.Line, zone)
+					loc.Range.Start.Character += len("keys_zone=")
+					locations = append(locations, loc)
+				}
+			}
+		})
+	}
+	return locations
+}
+
+// definesVariable reports whether d sets the variable.
+func definesVariable(d *crossplane.Directive, variable string) bool {
+	switch d.Directive {
+	case "geo":
+		// the variable is the last of one or two arguments
+		return len(d.Args) > 0 && len(d.Args) <= 2 && d.Args[len(d.Args)-1] == variable
+	case variable:
+		// the variables of geoip2 blocks
+		return true
+	}
+	i, ok := variableSetters[d.Directive]
+	return ok && i < len(d.Args) && d.Args[i] == variable
+}
+
+// upstreamName returns the host of the address of a *_pass directive.
+func upstreamName(addr string) string {
+	if i := strings.Index(addr, "://"); i >= 0 {
+		addr = addr[i+len("://"):]
+	}
+	if i := strings.IndexByte(addr, '/'); i >= 0 {
+		addr = addr[:i]
+	}
+	return addr
+}
+
+// findIncludes returns the files matched by the pattern of an include directive.
+func findIncludes(dir, pattern string) []string {
+	if !filepath.IsAbs(pattern) {
+		pattern = filepath.Join(dir, pattern)
+	}
+	if strings.ContainsAny(pattern, "*?[") {
+		files, _ := filepath.Glob(pattern)
+		sort.Strings(files)
+		return files
+	}
+	if _, err := os.Stat(pattern); err != nil {
+		return nil
+	}
+	return []string{pattern}
+}
+
+// locate returns the location of the first needle on the line of a file, counted
+// from 1, or of the start of the line if the needle isn't on it.
+func (s *server) locate(file string, line int, needle string) location {
+	text := lineText(s.text(file), line-1)
+	loc := location{URI: pathToURI(file)}
+	loc.Range.Start.Line, loc.Range.End.Line = line-1, line-1
+	if i := indexWord(text, needle); i >= 0 {
+		loc.Range.Start.Character = utf16Len(text[:i])
+		loc.Range.End.Character = loc.Range.Start.Character + utf16Len(needle)
+	}
+	return loc
+}
+
+// indexWord returns the index of the first needle in text that isn't part of a
+// longer word, or -1.
+func indexWord(text, needle string) int {
+	for offset := 0; offset < len(text); {
+		i := strings.Index(text[offset:], needle)
+		if i < 0 {
+			return -1
+		}
+		start, end := offset+i, offset+i+len(needle)
+		if (start == 0 || !isVariableByte(text[start-1])) && (end == len(text) || !isVariableByte(text[end])) {
+			return start
+		}
+		offset = start + 1
+	}
+	return -1
+}
+
+// formatting returns the edit that replaces the document with the config built
+// from it. Documents with syntax errors aren't formatted.
+func (s *server) formatting(path string, p *documentFormattingParams) []textEdit {
+	edits := []textEdit{}
+	text, ok := s.docs[path]
+	if !ok {
+		return edits
+	}
+	options := s.parseOptions()
+	options.SingleFile = true
+	options.SkipDirectiveContextCheck = true
+	options.SkipDirectiveArgsCheck = true
+	payload, err := crossplane.Parse(path, options)
+	if err != nil || len(payload.Errors) > 0 || len(payload.Config) == 0 {
+		return edits
+	}
+
+	buildOptions := &crossplane.BuildOptions{
+		Indent: p.Options.TabSize,
+		Tabs:   !p.Options.InsertSpaces,
+	}
+	if s.options.Lua {
+		lua := &crossplane.Lua{}
+		buildOptions.Builders = append(buildOptions.Builders, lua.RegisterBuilder())
+	}
+	var sb strings.Builder
+	if err := crossplane.Build(&sb, payload.Config[0], buildOptions); err != nil {
+		return edits
+	}
+	formatted := sb.String()
+	if formatted != "" && !strings.HasSuffix(formatted, "\n") {
+		formatted += "\n"
+	}
+	if formatted == text {
+		return edits
+	}
+	return append(edits, textEdit{
+		Range:   lspRange{End: positionOf(text, len(text))},
+		NewText: formatted,
+	})
+}
@@ -0,0 +1,37 @@
+/**
+ * Copyright (c) F5, Inc.
+ *
+ * This source code is licensed under the Apache License, Version 2.0 license found in the
+ * LICENSE file in the root directory of this source tree.
+ */
+
+// Command crossplane-lsp is a language server for NGINX configurations. It speaks
+// the Language Server Protocol over stdin and stdout and provides diagnostics,
+// completion of directive names, hover, go to definition and formatting.
+//
+// The client can set the main config file in the initialization options,
+// {"config": "nginx.conf"}, so that the files it includes are checked in the
+// context they're included in. Set "lua" to true to handle *_by_lua_block directives.
+package main
+
+import (
+	"errors"
+	"io"
+	"log"
+	"os"
+)
+
+func main() {
+	log.SetFlags(0)
+	s := newServer(os.Stdin, os.Stdout)
+	err := s.run()
+	switch {
+	case errors.Is(err, errExit) && s.shutdown:
+		os.Exit(0)
+	case errors.Is(err, errExit), errors.Is(err, io.EOF):
+		// exiting without a shutdown request is an error
+		os.Exit(1)
+	default:
+		log.Fatal(err)
+	}
+}
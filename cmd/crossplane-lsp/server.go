@@ -0,0 +1,243 @@
+/**
+ * Copyright (c) F5, Inc.
+ *
+ * This source code is licensed under the Apache License, Version 2.0 license found in the
+ * LICENSE file in the root directory of this source tree.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/nginxinc/nginx-go-crossplane"
+)
+
+// errExit is returned by run when the client sends the exit notification.
+var errExit = errors.New("exit") //nolint:gochecknoglobals
+
+// server is a language server for NGINX configs. It handles one message at a time.
+type server struct {
+	conn     *conn
+	options  initializeOptions
+	rootDir  string
+	shutdown bool
+
+	// docs are the texts of the open documents, by path. They're used instead
+	// of the files on disk, which may not have been saved.
+	docs map[string]string
+	// analyses are the parses of the documents, which are dropped when a document changes.
+	analyses map[string]*analysis
+}
+
+func newServer(r io.Reader, w io.Writer) *server {
+	return &server{
+		conn:     newConn(r, w),
+		docs:     map[string]string{},
+		analyses: map[string]*analysis{},
+	}
+}
+
+// run handles messages until the client exits or closes the input.
+func (s *server) run() error {
+	for {
+		msg, err := s.conn.read()
+		if err != nil {
+			var rerr *responseError
+			if errors.As(err, &rerr) {
+				if werr := s.conn.reply(nil, nil, rerr); werr != nil {
+					return werr
+				}
+				continue
+			}
+			return err
+		}
+
+		if msg.ID == nil {
+			if err := s.handleNotification(msg.Method, msg.Params); err != nil {
+				return err
+			}
+			continue
+		}
+		result, rerr := s.handleRequest(msg.Method, msg.Params)
+		if err := s.conn.reply(msg.ID, result, rerr); err != nil {
+			return err
+		}
+	}
+}
+
+func decodeParams(params json.RawMessage, v interface{}) error {
+	if err := json.Unmarshal(params, v); err != nil {
+		return &responseError{Code: codeInvalidParams, Message: err.Error()}
+	}
+	return nil
+}
+
+//nolint:gocyclo
+func (s *server) handleRequest(method string, params json.RawMessage) (interface{}, error) {
+	switch method {
+	case "initialize":
+		var p initializeParams
+		if err := decodeParams(params, &p); err != nil {
+			return nil, err
+		}
+		return s.initialize(&p), nil
+	case "shutdown":
+		s.shutdown = true
+		return nil, nil //nolint:nilnil // the result of shutdown is null
+	case "textDocument/completion":
+		var p textDocumentPositionParams
+		if err := decodeParams(params, &p); err != nil {
+			return nil, err
+		}
+		return s.completion(uriToPath(p.TextDocument.URI), p.Position), nil
+	case "textDocument/hover":
+		var p textDocumentPositionParams
+		if err := decodeParams(params, &p); err != nil {
+			return nil, err
+		}
+		return s.hover(uriToPath(p.TextDocument.URI), p.Position), nil
+	case "textDocument/definition":
+		var p textDocumentPositionParams
+		if err := decodeParams(params, &p); err != nil {
+			return nil, err
+		}
+		return s.definition(uriToPath(p.TextDocument.URI), p.Position), nil
+	case "textDocument/formatting":
+		var p documentFormattingParams
+		if err := decodeParams(params, &p); err != nil {
+			return nil, err
+		}
+		return s.formatting(uriToPath(p.TextDocument.URI), &p), nil
+	default:
+		return nil, &responseError{Code: codeMethodNotFound, Message: fmt.Sprintf("method not found: %s", method)}
+	}
+}
+
+func (s *server) handleNotification(method string, params json.RawMessage) error {
+	switch method {
+	case "exit":
+		return errExit
+	case "textDocument/didOpen":
+		var p didOpenParams
+		if err := json.Unmarshal(params, &p); err != nil {
+			return nil //nolint:nilerr // notifications have no response to report errors in
+		}
+		s.docs[uriToPath(p.TextDocument.URI)] = p.TextDocument.Text
+		return s.changed("")
+	case "textDocument/didChange":
+		var p didChangeParams
+		if err := json.Unmarshal(params, &p); err != nil || len(p.ContentChanges) == 0 {
+			return nil //nolint:nilerr // notifications have no response to report errors in
+		}
+		s.docs[uriToPath(p.TextDocument.URI)] = p.ContentChanges[len(p.ContentChanges)-1].Text
+		return s.changed("")
+	case "textDocument/didClose":
+		var p didCloseParams
+		if err := json.Unmarshal(params, &p); err != nil {
+			return nil //nolint:nilerr // notifications have no response to report errors in
+		}
+		path := uriToPath(p.TextDocument.URI)
+		delete(s.docs, path)
+		return s.changed(path)
+	case "textDocument/didSave":
+		return s.changed("")
+	default:
+		// initialized, $/cancelRequest and other notifications don't need anything
+		return nil
+	}
+}
+
+func (s *server) initialize(p *initializeParams) *initializeResult {
+	s.options = p.InitializationOptions
+	if p.RootURI != "" {
+		s.rootDir = uriToPath(p.RootURI)
+	}
+	if s.options.Config != "" && !filepath.IsAbs(s.options.Config) {
+		s.options.Config = filepath.Join(s.rootDir, s.options.Config)
+	}
+	return &initializeResult{
+		Capabilities: serverCapabilities{
+			TextDocumentSync:           textDocumentSyncFull,
+			CompletionProvider:         completionOptions{},
+			HoverProvider:              true,
+			DefinitionProvider:         true,
+			DocumentFormattingProvider: true,
+		},
+		ServerInfo: serverInfo{Name: "crossplane-lsp"},
+	}
+}
+
+// changed drops the analyses and publishes the diagnostics of the open documents,
+// and clears the ones of closed, if it isn't empty.
+func (s *server) changed(closed string) error {
+	s.analyses = map[string]*analysis{}
+	if closed != "" {
+		err := s.conn.notify("textDocument/publishDiagnostics", &publishDiagnosticsParams{
+			URI:         pathToURI(closed),
+			Diagnostics: []diagnostic{},
+		})
+		if err != nil {
+			return err
+		}
+	}
+	paths := make([]string, 0, len(s.docs))
+	for path := range s.docs {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		err := s.conn.notify("textDocument/publishDiagnostics", &publishDiagnosticsParams{
+			URI:         pathToURI(path),
+			Diagnostics: s.diagnostics(path),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// open opens config files for the parser, preferring the text of open documents.
+func (s *server) open(path string) (io.ReadCloser, error) {
+	if text, ok := s.docs[path]; ok {
+		return io.NopCloser(strings.NewReader(text)), nil
+	}
+	return os.Open(path)
+}
+
+// text returns the text of a config file, or "" if it can't be read.
+func (s *server) text(path string) string {
+	if text, ok := s.docs[path]; ok {
+		return text
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return string(b)
+}
+
+func (s *server) lexOptions() crossplane.LexOptions {
+	var options crossplane.LexOptions
+	if s.options.Lua {
+		lua := &crossplane.Lua{}
+		options.Lexers = append(options.Lexers, lua.RegisterLexer())
+	}
+	return options
+}
+
+func (s *server) parseOptions() *crossplane.ParseOptions {
+	return &crossplane.ParseOptions{
+		ParseComments: true,
+		Open:          s.open,
+		LexOptions:    s.lexOptions(),
+	}
+}
@@ -0,0 +1,355 @@
+/**
+ * Copyright (c) F5, Inc.
+ *
+ * This source code is licensed under the Apache License, Version 2.0 license found in the
+ * LICENSE file in the root directory of this source tree.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/nginxinc/nginx-go-crossplane"
+	"github.com/stretchr/testify/require"
+)
+
+// client is a scripted JSON-RPC client of a server running over pipes.
+type client struct {
+	t      *testing.T
+	conn   *conn
+	nextID int
+	// msgs are the messages from the server, read as they come so that the
+	// server never waits for the client
+	msgs chan *message
+	done chan error
+	// notifications are the ones received so far, by method
+	notifications map[string][]json.RawMessage
+}
+
+func newClient(t *testing.T) *client {
+	t.Helper()
+	serverIn, clientOut := io.Pipe()
+	clientIn, serverOut := io.Pipe()
+	c := &client{
+		t:             t,
+		conn:          newConn(clientIn, clientOut),
+		msgs:          make(chan *message, 100),
+		done:          make(chan error, 1),
+		notifications: map[string][]json.RawMessage{},
+	}
+	s := newServer(serverIn, serverOut)
+	go func() {
+		err := s.run()
+		serverOut.Close()
+		c.done <- err
+	}()
+	go func() {
+		defer close(c.msgs)
+		for {
+			msg, err := c.conn.read()
+			if err != nil {
+				return
+			}
+			c.msgs <- msg
+		}
+	}()
+	t.Cleanup(func() { clientOut.Close() })
+	return c
+}
+
+// call sends a request and returns the error of its response, after reading its
+// result into result. The notifications the server sent before are kept.
+func (c *client) call(method string, params, result interface{}) *responseError {
+	c.t.Helper()
+	c.nextID++
+	id := json.RawMessage(strconv.Itoa(c.nextID))
+	b, err := json.Marshal(params)
+	require.NoError(c.t, err)
+	require.NoError(c.t, c.conn.write(&message{ID: &id, Method: method, Params: b}))
+
+	for msg := range c.msgs {
+		if msg.ID == nil {
+			c.notifications[msg.Method] = append(c.notifications[msg.Method], msg.Params)
+			continue
+		}
+		require.Equal(c.t, string(id), string(*msg.ID))
+		if msg.Error != nil {
+			return msg.Error
+		}
+		if result != nil {
+			require.NoError(c.t, json.Unmarshal(msg.Result, result))
+		}
+		return nil
+	}
+	require.FailNow(c.t, "the server closed the connection")
+	return nil
+}
+
+func (c *client) notify(method string, params interface{}) {
+	c.t.Helper()
+	require.NoError(c.t, c.conn.notify(method, params))
+}
+
+// diagnostics returns the last diagnostics published for a file.
+func (c *client) diagnostics(path string) []diagnostic {
+	c.t.Helper()
+	var last []diagnostic
+	for _, raw := range c.notifications["textDocument/publishDiagnostics"] {
+		var p publishDiagnosticsParams
+		require.NoError(c.t, json.Unmarshal(raw, &p))
+		if p.URI == pathToURI(path) {
+			last = p.Diagnostics
+		}
+	}
+	return last
+}
+
+func TestConnRead_invalidLength(t *testing.T) {
+	t.Parallel()
+	tests := map[string]struct {
+		input string
+		want  string
+	}{
+		"negative": {
+			input: "Content-Length: -1\r\n\r\n",
+			want:  "invalid Content-Length: -1",
+		},
+		"too large": {
+			input: "Content-Length: 67108865\r\n\r\n" + strings.Repeat(" ", maxMessageSize+1),
+			want:  "Content-Length 67108865 is larger than 67108864",
+		},
+	}
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			next := `{"jsonrpc":"2.0","method":"exit"}`
+			input := tc.input + "Content-Length: " + strconv.Itoa(len(next)) + "\r\n\r\n" + next
+			c := newConn(strings.NewReader(input), io.Discard)
+
+			_, err := c.read()
+			var rerr *responseError
+			require.ErrorAs(t, err, &rerr)
+			require.Equal(t, codeInvalidRequest, rerr.Code)
+			require.Equal(t, tc.want, rerr.Message)
+
+			// the next message is still read
+			msg, err := c.read()
+			require.NoError(t, err)
+			require.Equal(t, "exit", msg.Method)
+		})
+	}
+}
+
+func at(path string, line, character int) textDocumentPositionParams {
+	return textDocumentPositionParams{
+		TextDocument: textDocumentIdentifier{URI: pathToURI(path)},
+		Position:     position{Line: line, Character: character},
+	}
+}
+
+func TestServer(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	write := func(name, conf string) string {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, []byte(conf), 0o600))
+		return path
+	}
+	main := write("nginx.conf", strings.Join([]string{
+		"events {}",
+		"http {",
+		"    upstream backend {",
+		"        server 127.0.0.1:8080;",
+		"    }",
+		"    map $host $name {",
+		"        default 0;",
+		"    }",
+		"    proxy_cache_path /tmp/cache keys_zone=cache:10m;",
+		"    include conf.d/*.conf;",
+		"}",
+		"",
+	}, "\n"))
+	site := write("conf.d/site.conf", "server {\n}\n")
+
+	c := newClient(t)
+	var initResult initializeResult
+	require.Nil(t, c.call("initialize", map[string]interface{}{
+		"rootUri":               pathToURI(dir),
+		"initializationOptions": map[string]interface{}{"config": "nginx.conf"},
+	}, &initResult))
+	require.True(t, initResult.Capabilities.HoverProvider)
+	require.Equal(t, textDocumentSyncFull, initResult.Capabilities.TextDocumentSync)
+	c.notify("initialized", struct{}{})
+
+	// the unsaved text of the site is checked in the http context it's included in
+	siteText := strings.Join([]string{
+		"server {",
+		"    location / {",
+		"        proxy_pass http://backend/;",
+		"        proxy_cache cache;",
+		"        add_header X-Name $name;",
+		"        server_name_in_redirect maybe;",
+		"        ",
+		"    }",
+		"}",
+		"",
+	}, "\n")
+	c.notify("textDocument/didOpen", map[string]interface{}{
+		"textDocument": textDocumentItem{URI: pathToURI(site), Version: 1, Text: siteText},
+	})
+	c.notify("textDocument/didOpen", map[string]interface{}{
+		"textDocument": textDocumentItem{URI: pathToURI(main), Version: 1, Text: c.readFile(main)},
+	})
+
+	var items []completionItem
+	require.Nil(t, c.call("textDocument/completion", at(site, 6, 8), &items))
+	labels := make([]string, 0, len(items))
+	for _, item := range items {
+		labels = append(labels, item.Label)
+	}
+	require.Contains(t, labels, "proxy_pass")
+	require.Contains(t, labels, "if")
+	require.NotContains(t, labels, "server_name")
+	require.NotContains(t, labels, "worker_connections")
+
+	require.Equal(t, []diagnostic{{
+		Range:    lspRange{Start: position{Line: 5}, End: position{Line: 5, Character: 38}},
+		Severity: diagnosticSeverityError,
+		Source:   "crossplane",
+		Message:  `invalid value "maybe" in "server_name_in_redirect" directive, it must be "on" or "off"`,
+	}}, c.diagnostics(site))
+	require.Empty(t, c.diagnostics(main))
+
+	// completion of a directive name being typed
+	siteText = strings.Replace(siteText, "        \n", "        proxy_read\n", 1)
+	c.notify("textDocument/didChange", map[string]interface{}{
+		"textDocument":   textDocumentIdentifier{URI: pathToURI(site)},
+		"contentChanges": []map[string]string{{"text": siteText}},
+	})
+	items = nil
+	require.Nil(t, c.call("textDocument/completion", at(site, 6, 18), &items))
+	require.Equal(t, []completionItem{{Label: "proxy_read_timeout", Kind: completionItemKindKeyword, Detail: "args: 1"}}, items)
+	// no completion of arguments
+	items = nil
+	require.Nil(t, c.call("textDocument/completion", at(site, 2, 20), &items))
+	require.Empty(t, items)
+
+	var h hover
+	require.Nil(t, c.call("textDocument/hover", at(site, 2, 12), &h))
+	require.Equal(t, "markdown", h.Contents.Kind)
+	require.Contains(t, h.Contents.Value, "**proxy_pass**")
+	require.Contains(t, h.Contents.Value, "`http > location > if`")
+	require.Equal(t, &lspRange{Start: position{Line: 2, Character: 8}, End: position{Line: 2, Character: 18}}, h.Range)
+
+	definition := func(path string, line, character int) []location {
+		t.Helper()
+		var locations []location
+		require.Nil(t, c.call("textDocument/definition", at(path, line, character), &locations))
+		return locations
+	}
+	loc := func(path string, line, start, end int) location {
+		return location{
+			URI:   pathToURI(path),
+			Range: lspRange{Start: position{Line: line, Character: start}, End: position{Line: line, Character: end}},
+		}
+	}
+	require.Equal(t, []location{loc(main, 2, 13, 20)}, definition(site, 2, 30))
+	require.Equal(t, []location{loc(main, 8, 42, 47)}, definition(site, 3, 22))
+	require.Equal(t, []location{loc(main, 5, 14, 19)}, definition(site, 4, 30))
+	require.Equal(t, []location{{URI: pathToURI(site)}}, definition(main, 9, 16))
+	require.Empty(t, definition(site, 0, 2))
+
+	// formatting the unsaved text
+	c.notify("textDocument/didChange", map[string]interface{}{
+		"textDocument":   textDocumentIdentifier{URI: pathToURI(site)},
+		"contentChanges": []map[string]string{{"text": "server { # site\nlocation / {\nproxy_pass   http://backend/;  }\n}"}},
+	})
+	var edits []textEdit
+	require.Nil(t, c.call("textDocument/formatting", map[string]interface{}{
+		"textDocument": textDocumentIdentifier{URI: pathToURI(site)},
+		"options":      map[string]interface{}{"tabSize": 2, "insertSpaces": true},
+	}, &edits))
+	require.Equal(t, []textEdit{{
+		Range: lspRange{End: position{Line: 3, Character: 1}},
+		NewText: strings.Join([]string{
+			"server { # site",
+			"  location / {",
+			"    proxy_pass http://backend/;",
+			"  }",
+			"}",
+			"",
+		}, "\n"),
+	}}, edits)
+
+	// documents with syntax errors aren't formatted
+	c.notify("textDocument/didChange", map[string]interface{}{
+		"textDocument":   textDocumentIdentifier{URI: pathToURI(site)},
+		"contentChanges": []map[string]string{{"text": "server {\n"}},
+	})
+	edits = nil
+	require.Nil(t, c.call("textDocument/formatting", map[string]interface{}{
+		"textDocument": textDocumentIdentifier{URI: pathToURI(site)},
+	}, &edits))
+	require.Empty(t, edits)
+
+	// closing a document clears its diagnostics
+	c.notify("textDocument/didClose", map[string]interface{}{"textDocument": textDocumentIdentifier{URI: pathToURI(site)}})
+	require.Equal(t, &responseError{Code: codeMethodNotFound, Message: "method not found: unknown"}, c.call("unknown", nil, nil))
+	require.Empty(t, c.diagnostics(site))
+
+	require.Nil(t, c.call("shutdown", nil, nil))
+	c.notify("exit", nil)
+	require.ErrorIs(t, <-c.done, errExit)
+}
+
+func (c *client) readFile(path string) string {
+	c.t.Helper()
+	b, err := os.ReadFile(path)
+	require.NoError(c.t, err)
+	return string(b)
+}
+
+func TestDescribeDocs(t *testing.T) {
+	t.Parallel()
+	require.Equal(t, strings.Join([]string{
+		"",
+		"",
+		"---",
+		"",
+		"`example_timeout time;`  ",
+		"default: `example_timeout 60s;`  ",
+		"module: ngx_http_example_module, since 1.25.1",
+		"",
+		"Sets a timeout.",
+		"",
+		"---",
+		"",
+		"`example_timeout time;`  ",
+		"module: ngx_stream_example_module",
+	}, "\n"), describeDocs([]crossplane.DirectiveDoc{
+		{
+			Name:        "example_timeout",
+			Module:      "ngx_http_example_module",
+			Syntax:      []string{"example_timeout time;"},
+			Default:     "example_timeout 60s;",
+			Contexts:    []string{"http", "server"},
+			AppearedIn:  "1.25.1",
+			Description: "Sets a timeout.",
+		},
+		{
+			Name:     "example_timeout",
+			Module:   "ngx_stream_example_module",
+			Syntax:   []string{"example_timeout time;"},
+			Contexts: []string{"stream"},
+		},
+	}))
+}
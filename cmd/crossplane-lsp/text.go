@@ -0,0 +1,133 @@
+/**
+ * Copyright (c) F5, Inc.
+ *
+ * This source code is licensed under the Apache License, Version 2.0 license found in the
+ * LICENSE file in the root directory of this source tree.
+ */
+
+package main
+
+import (
+	"net/url"
+	"path/filepath"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// Positions of the Language Server Protocol count lines from 0 and characters in
+// UTF-16 code units.
+
+// offsetOf returns the byte offset of pos in text.
+func offsetOf(text string, pos position) int {
+	offset := 0
+	for line := 0; line < pos.Line; line++ {
+		i := strings.IndexByte(text[offset:], '\n')
+		if i < 0 {
+			return len(text)
+		}
+		offset += i + 1
+	}
+	for units := 0; offset < len(text) && text[offset] != '\n' && units < pos.Character; {
+		r, size := utf8.DecodeRuneInString(text[offset:])
+		units += utf16.RuneLen(r)
+		offset += size
+	}
+	return offset
+}
+
+// positionOf returns the position of the byte offset in text.
+func positionOf(text string, offset int) position {
+	if offset > len(text) {
+		offset = len(text)
+	}
+	start := strings.LastIndexByte(text[:offset], '\n') + 1
+	return position{
+		Line:      strings.Count(text[:offset], "\n"),
+		Character: utf16Len(text[start:offset]),
+	}
+}
+
+func utf16Len(s string) int {
+	n := 0
+	for _, r := range s {
+		n += utf16.RuneLen(r)
+	}
+	return n
+}
+
+// lineText returns the line of text with the number line, counted from 0.
+func lineText(text string, line int) string {
+	for ; line > 0; line-- {
+		i := strings.IndexByte(text, '\n')
+		if i < 0 {
+			return ""
+		}
+		text = text[i+1:]
+	}
+	if i := strings.IndexByte(text, '\n'); i >= 0 {
+		text = text[:i]
+	}
+	return strings.TrimSuffix(text, "\r")
+}
+
+// lineRange returns the range of the whole line of text with the number line.
+func lineRange(text string, line int) lspRange {
+	return lspRange{
+		Start: position{Line: line},
+		End:   position{Line: line, Character: utf16Len(lineText(text, line))},
+	}
+}
+
+// isWordByte reports whether c can be part of a word of a config that isn't quoted.
+func isWordByte(c byte) bool {
+	return !strings.ContainsRune(" \t\r\n;{}\"'", rune(c))
+}
+
+// wordAt returns the byte offsets of the word of text at offset.
+func wordAt(text string, offset int) (start, end int) {
+	start, end = offset, offset
+	for start > 0 && isWordByte(text[start-1]) {
+		start--
+	}
+	for end < len(text) && isWordByte(text[end]) {
+		end++
+	}
+	return start, end
+}
+
+func isVariableByte(c byte) bool {
+	return c == '_' || ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || ('0' <= c && c <= '9')
+}
+
+// variableAt returns the name of the variable of text at offset, without its "$",
+// e.g. "host" for "$host" or "${host}".
+func variableAt(text string, offset int) string {
+	start, end := offset, offset
+	for start > 0 && (isVariableByte(text[start-1]) || text[start-1] == '{') {
+		start--
+	}
+	for end < len(text) && isVariableByte(text[end]) {
+		end++
+	}
+	if start == 0 || text[start-1] != '$' {
+		// the cursor may be on the "$"
+		if start < len(text) && text[start] == '$' {
+			return variableAt(text, start+1)
+		}
+		return ""
+	}
+	return strings.TrimPrefix(text[start:end], "{")
+}
+
+func uriToPath(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" {
+		return uri
+	}
+	return filepath.Clean(filepath.FromSlash(u.Path))
+}
+
+func pathToURI(path string) string {
+	return (&url.URL{Scheme: "file", Path: filepath.ToSlash(path)}).String()
+}
@@ -0,0 +1,37 @@
+/**
+ * Copyright (c) F5, Inc.
+ *
+ * This source code is licensed under the Apache License, Version 2.0 license found in the
+ * LICENSE file in the root directory of this source tree.
+ */
+
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPositions(t *testing.T) {
+	t.Parallel()
+	text := "events {}\nhttp { # 😀 é\n    set $a \"${b}c\";\n}"
+	for offset := 0; offset <= len(text); offset++ {
+		pos := positionOf(text, offset)
+		if offset < len(text) && text[offset]&0xC0 == 0x80 {
+			// in the middle of a rune
+			continue
+		}
+		require.Equal(t, offset, offsetOf(text, pos), pos)
+	}
+	// the emoji is two UTF-16 code units
+	require.Equal(t, position{Line: 1, Character: 12}, positionOf(text, len("events {}\nhttp { # 😀 ")))
+	require.Equal(t, len(text), offsetOf(text, position{Line: 9}))
+	require.Equal(t, len("events {}"), offsetOf(text, position{Line: 0, Character: 99}))
+
+	require.Equal(t, "    set $a \"${b}c\";", lineText(text, 2))
+	require.Equal(t, "a", variableAt(text, offsetOf(text, position{Line: 2, Character: 8})))
+	require.Equal(t, "a", variableAt(text, offsetOf(text, position{Line: 2, Character: 9})))
+	require.Equal(t, "b", variableAt(text, offsetOf(text, position{Line: 2, Character: 14})))
+	require.Equal(t, "", variableAt(text, offsetOf(text, position{Line: 2, Character: 5})))
+}
@@ -0,0 +1,95 @@
+/**
+ * Copyright (c) F5, Inc.
+ *
+ * This source code is licensed under the Apache License, Version 2.0 license found in the
+ * LICENSE file in the root directory of this source tree.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/nginxinc/nginx-go-crossplane"
+)
+
+func compat(args []string) error {
+	fs := flag.NewFlagSet("compat", flag.ExitOnError)
+	var (
+		asJSON  = fs.Bool("json", false, "write the report as json")
+		single  = fs.Bool("single-file", false, "do not include other config files")
+		withLua = fs.Bool("lua", false, "parse *_by_lua_block directives as lua")
+	)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: crossplane compat [options] <filename>")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2) //nolint:mnd
+	}
+
+	// directives are checked against every version, not the default directive sources
+	parseOptions := crossplane.ParseOptions{
+		SingleFile:                *single,
+		SkipDirectiveContextCheck: true,
+		SkipDirectiveArgsCheck:    true,
+	}
+	if *withLua {
+		lua := &crossplane.Lua{}
+		parseOptions.LexOptions.Lexers = append(parseOptions.LexOptions.Lexers, lua.RegisterLexer())
+	}
+
+	payload, err := crossplane.Parse(fs.Arg(0), &parseOptions)
+	if err != nil {
+		return err
+	}
+	report := crossplane.Compatibility(payload)
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(report)
+	}
+	writeReport(os.Stdout, report)
+	return nil
+}
+
+// writeReport writes the report for people to read.
+func writeReport(w io.Writer, report *crossplane.CompatibilityReport) {
+	fmt.Fprintf(w, "nginx:      %s\n", describeRanges(report.OSS))
+	fmt.Fprintf(w, "nginx plus: %s\n", describeRanges(report.Plus))
+	if len(report.Modules) > 0 {
+		fmt.Fprintf(w, "modules:    %s\n", strings.Join(report.Modules, ", "))
+	}
+	for _, v := range report.Versions {
+		if v.Compatible {
+			continue
+		}
+		fmt.Fprintf(w, "\n%s %s is incompatible:\n", v.Product, v.Version)
+		for _, b := range v.Blocking {
+			fmt.Fprintf(w, "    %s:%d: %s\n", b.File, b.Line, b.Reason)
+		}
+	}
+}
+
+func describeRanges(ranges []crossplane.VersionRange) string {
+	if len(ranges) == 0 {
+		return "none"
+	}
+	described := make([]string, 0, len(ranges))
+	for _, r := range ranges {
+		if r.Min == r.Max {
+			described = append(described, r.Min)
+		} else {
+			described = append(described, r.Min+" to "+r.Max)
+		}
+	}
+	return strings.Join(described, ", ")
+}
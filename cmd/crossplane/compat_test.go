@@ -0,0 +1,49 @@
+/**
+ * Copyright (c) F5, Inc.
+ *
+ * This source code is licensed under the Apache License, Version 2.0 license found in the
+ * LICENSE file in the root directory of this source tree.
+ */
+
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/nginxinc/nginx-go-crossplane"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteReport(t *testing.T) {
+	t.Parallel()
+	var sb strings.Builder
+	writeReport(&sb, &crossplane.CompatibilityReport{
+		OSS:     []crossplane.VersionRange{{Min: "1.26", Max: "latest"}},
+		Plus:    []crossplane.VersionRange{{Min: "R30", Max: "R30"}, {Min: "R34", Max: "latest"}},
+		Modules: []string{crossplane.ModuleLua, crossplane.ModuleNjs},
+		Versions: []crossplane.VersionCompatibility{
+			{
+				Product: crossplane.ProductOSS,
+				Version: "1.24",
+				Blocking: []crossplane.BlockingDirective{
+					{Directive: "http2", File: "nginx.conf", Line: 3, Reason: `unknown directive "http2"`},
+				},
+			},
+			{Product: crossplane.ProductOSS, Version: "1.26", Compatible: true},
+		},
+	})
+	require.Equal(t, strings.Join([]string{
+		"nginx:      1.26 to latest",
+		"nginx plus: R30, R34 to latest",
+		"modules:    lua, njs",
+		"",
+		"nginx 1.24 is incompatible:",
+		`    nginx.conf:3: unknown directive "http2"`,
+		"",
+	}, "\n"), sb.String())
+
+	sb.Reset()
+	writeReport(&sb, &crossplane.CompatibilityReport{})
+	require.Equal(t, "nginx:      none\nnginx plus: none\n", sb.String())
+}
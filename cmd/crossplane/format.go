@@ -0,0 +1,115 @@
+/**
+ * Copyright (c) F5, Inc.
+ *
+ * This source code is licensed under the Apache License, Version 2.0 license found in the
+ * LICENSE file in the root directory of this source tree.
+ */
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/nginxinc/nginx-go-crossplane"
+	"github.com/nginxinc/nginx-go-crossplane/crossplanepb"
+	"github.com/nginxinc/nginx-go-crossplane/crossplanetoml"
+	"github.com/nginxinc/nginx-go-crossplane/crossplaneyaml"
+)
+
+const (
+	formatJSON = "json"
+	formatYAML = "yaml"
+	formatTOML = "toml"
+	formatPB   = "protobuf"
+)
+
+// formatFromPath guesses the payload format from the extension of path, defaulting to JSON.
+func formatFromPath(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return formatYAML
+	case ".toml":
+		return formatTOML
+	case ".pb", ".binpb":
+		return formatPB
+	default:
+		return formatJSON
+	}
+}
+
+func encodePayload(w io.Writer, payload *crossplane.Payload, format string, indent int) error {
+	switch format {
+	case formatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetEscapeHTML(false)
+		if indent > 0 {
+			enc.SetIndent("", strings.Repeat(" ", indent))
+		}
+		return enc.Encode(payload)
+	case formatYAML:
+		return crossplaneyaml.Encode(w, payload)
+	case formatTOML:
+		return crossplanetoml.Encode(w, payload)
+	case formatPB:
+		b, err := crossplanepb.Marshal(payload)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(b)
+		return err
+	default:
+		return fmt.Errorf("unknown format %q, must be one of json, yaml, toml or protobuf", format)
+	}
+}
+
+// encodePythonJSON writes payload like the crossplane command of Python crossplane does.
+func encodePythonJSON(w io.Writer, payload *crossplane.Payload, indent int) error {
+	b, err := crossplane.MarshalPythonJSON(payload)
+	if err != nil {
+		return err
+	}
+	if indent > 0 {
+		var buf bytes.Buffer
+		if err = json.Indent(&buf, b, "", strings.Repeat(" ", indent)); err != nil {
+			return err
+		}
+		b = buf.Bytes()
+	}
+	_, err = w.Write(append(b, '\n'))
+	return err
+}
+
+func decodePayload(r io.Reader, format string) (*crossplane.Payload, error) {
+	switch format {
+	case formatJSON:
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return nil, err
+		}
+		if err := crossplane.Validate(data); err != nil {
+			return nil, err
+		}
+		var payload crossplane.Payload
+		if err := json.Unmarshal(data, &payload); err != nil {
+			return nil, err
+		}
+		return &payload, nil
+	case formatYAML:
+		return crossplaneyaml.Decode(r)
+	case formatTOML:
+		return crossplanetoml.Decode(r)
+	case formatPB:
+		b, err := io.ReadAll(r)
+		if err != nil {
+			return nil, err
+		}
+		return crossplanepb.Unmarshal(b)
+	default:
+		return nil, fmt.Errorf("unknown format %q, must be one of json, yaml, toml or protobuf", format)
+	}
+}
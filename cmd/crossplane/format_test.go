@@ -0,0 +1,69 @@
+/**
+ * Copyright (c) F5, Inc.
+ *
+ * This source code is licensed under the Apache License, Version 2.0 license found in the
+ * LICENSE file in the root directory of this source tree.
+ */
+
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/nginxinc/nginx-go-crossplane"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormatFromPath(t *testing.T) {
+	t.Parallel()
+	require.Equal(t, formatYAML, formatFromPath("nginx.yaml"))
+	require.Equal(t, formatYAML, formatFromPath("nginx.YML"))
+	require.Equal(t, formatTOML, formatFromPath("nginx.toml"))
+	require.Equal(t, formatPB, formatFromPath("nginx.pb"))
+	require.Equal(t, formatJSON, formatFromPath("nginx.json"))
+	require.Equal(t, formatJSON, formatFromPath("payload"))
+}
+
+func TestEncodeDecodePayload(t *testing.T) {
+	t.Parallel()
+	payload, err := crossplane.Parse("../../testdata/configs/with-comments/nginx.conf", &crossplane.ParseOptions{ParseComments: true})
+	require.NoError(t, err)
+
+	for _, format := range []string{formatJSON, formatYAML, formatTOML, formatPB} {
+		format := format
+		t.Run(format, func(t *testing.T) {
+			t.Parallel()
+			var buf bytes.Buffer
+			require.NoError(t, encodePayload(&buf, payload, format, 2))
+			decoded, err := decodePayload(&buf, format)
+			require.NoError(t, err)
+
+			var want, got bytes.Buffer
+			require.NoError(t, crossplane.Build(&want, payload.Config[0], &crossplane.BuildOptions{}))
+			require.NoError(t, crossplane.Build(&got, decoded.Config[0], &crossplane.BuildOptions{}))
+			require.Equal(t, want.String(), got.String())
+		})
+	}
+
+	_, err = decodePayload(&bytes.Buffer{}, "xml")
+	require.Error(t, err)
+}
+
+func TestEncodePythonJSON(t *testing.T) {
+	t.Parallel()
+	payload, err := crossplane.Parse("../../testdata/configs/includes-globbed/http.conf", &crossplane.ParseOptions{
+		PythonCompat: true,
+		SingleFile:   true,
+	})
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, encodePythonJSON(&buf, payload, 0))
+	require.True(t, bytes.HasPrefix(buf.Bytes(), []byte(`{"status":"ok","errors":[],"config":[{"file":"`)), buf.String())
+	require.True(t, bytes.HasSuffix(buf.Bytes(), []byte("}]}\n")), buf.String())
+
+	buf.Reset()
+	require.NoError(t, encodePythonJSON(&buf, payload, 2))
+	require.True(t, bytes.HasPrefix(buf.Bytes(), []byte("{\n  \"status\": \"ok\",\n  \"errors\": [],")), buf.String())
+}
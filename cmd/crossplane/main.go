@@ -0,0 +1,194 @@
+/**
+ * Copyright (c) F5, Inc.
+ *
+ * This source code is licensed under the Apache License, Version 2.0 license found in the
+ * LICENSE file in the root directory of this source tree.
+ */
+
+// Command crossplane converts NGINX configurations to payloads in JSON, YAML or TOML
+// and builds NGINX configurations from them.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/nginxinc/nginx-go-crossplane"
+)
+
+const usage = `usage: crossplane <command> [options] <filename>
+
+commands:
+  parse    parses an nginx config file and writes the payload
+  build    builds nginx config files from a payload
+  compat   reports the nginx versions and modules a config is compatible with
+
+Run "crossplane <command> -h" for the options of a command.
+`
+
+func main() {
+	log.SetFlags(0)
+	if len(os.Args) < 2 { //nolint:mnd
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2) //nolint:mnd
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "parse":
+		err = parse(os.Args[2:])
+	case "build":
+		err = build(os.Args[2:])
+	case "compat":
+		err = compat(os.Args[2:])
+	case "-h", "--help", "help":
+		fmt.Fprint(os.Stdout, usage)
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", os.Args[1], usage)
+		os.Exit(2) //nolint:mnd
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func parse(args []string) error {
+	fs := flag.NewFlagSet("parse", flag.ExitOnError)
+	var (
+		out          = fs.String("o", "", "write output to a file instead of stdout")
+		format       = fs.String("format", formatJSON, "output format: json, yaml, toml or protobuf")
+		indent       = fs.Int("indent", 0, "number of spaces to indent json output")
+		ignore       = fs.String("ignore", "", "comma-separated list of directives to exclude")
+		noCatch      = fs.Bool("no-catch", false, "only collect the first error in file")
+		combine      = fs.Bool("combine", false, "use includes to create one single file")
+		markIncl     = fs.Bool("mark-includes", false, "with -combine, mark where included files begin and end")
+		single       = fs.Bool("single-file", false, "do not include other config files")
+		comments     = fs.Bool("include-comments", false, "include comments in json")
+		strict       = fs.Bool("strict", false, "raise errors for unknown directives")
+		withLua      = fs.Bool("lua", false, "parse *_by_lua_block directives as lua")
+		python       = fs.Bool("python", false, "match the output of Python crossplane")
+		autoSources  = fs.Bool("auto-sources", false, "select the known directives from load_module directives and -nginx-v")
+		nginxV       = fs.String("nginx-v", "", "with -auto-sources, a file with the output of \"nginx -V\"")
+		jobs         = fs.Int("j", 1, "number of files to parse at the same time")
+		parseOptions crossplane.ParseOptions
+	)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: crossplane parse [options] <filename>")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2) //nolint:mnd
+	}
+
+	if *ignore != "" {
+		parseOptions.IgnoreDirectives = strings.Split(*ignore, ",")
+	}
+	parseOptions.StopParsingOnError = *noCatch
+	parseOptions.CombineConfigs = *combine
+	parseOptions.CombineOptions.MarkIncludes = *markIncl
+	parseOptions.SingleFile = *single
+	parseOptions.ParseComments = *comments
+	parseOptions.ErrorOnUnknownDirectives = *strict
+	parseOptions.PythonCompat = *python
+	parseOptions.Concurrency = *jobs
+	parseOptions.AutoDirectiveSources = *autoSources
+	if *nginxV != "" {
+		b, err := os.ReadFile(*nginxV)
+		if err != nil {
+			return err
+		}
+		parseOptions.NginxBuild = string(b)
+	}
+	if *withLua {
+		lua := &crossplane.Lua{}
+		parseOptions.LexOptions.Lexers = append(parseOptions.LexOptions.Lexers, lua.RegisterLexer())
+	}
+
+	payload, err := crossplane.Parse(fs.Arg(0), &parseOptions)
+	if err != nil {
+		return err
+	}
+
+	var w io.Writer = os.Stdout
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	if *python && *format == formatJSON {
+		return encodePythonJSON(w, payload, *indent)
+	}
+	return encodePayload(w, payload, *format, *indent)
+}
+
+func build(args []string) error {
+	fs := flag.NewFlagSet("build", flag.ExitOnError)
+	var (
+		dir       = fs.String("d", "", "the base directory to build in (default: the current directory)")
+		format    = fs.String("format", "", "input format: json, yaml, toml or protobuf (default: from the file extension)")
+		indent    = fs.Int("indent", 4, "number of spaces to indent output") //nolint:mnd
+		tabs      = fs.Bool("tabs", false, "indent with tabs instead of spaces")
+		noHeaders = fs.Bool("no-headers", false, "do not write header to configs")
+		stdout    = fs.Bool("stdout", false, "write configs to stdout instead")
+		withLua   = fs.Bool("lua", false, "build *_by_lua_block directives as lua")
+		python    = fs.Bool("python", false, "match the output of Python crossplane")
+	)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: crossplane build [options] <filename>")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2) //nolint:mnd
+	}
+
+	path := fs.Arg(0)
+	if *format == "" {
+		*format = formatFromPath(path)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	payload, err := decodePayload(f, *format)
+	if err != nil {
+		return err
+	}
+
+	buildOptions := &crossplane.BuildOptions{
+		Indent:       *indent,
+		Tabs:         *tabs,
+		Header:       !*noHeaders,
+		PythonCompat: *python,
+	}
+	if *withLua {
+		lua := &crossplane.Lua{}
+		buildOptions.Builders = append(buildOptions.Builders, lua.RegisterBuilder())
+	}
+
+	if !*stdout {
+		return crossplane.BuildFiles(*payload, *dir, buildOptions)
+	}
+
+	for _, config := range payload.Config {
+		fmt.Fprintf(os.Stdout, "# %s\n", config.File)
+		if err := crossplane.Build(os.Stdout, config, buildOptions); err != nil {
+			return err
+		}
+		fmt.Fprint(os.Stdout, "\n\n")
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+/**
+ * Copyright (c) F5, Inc.
+ *
+ * This source code is licensed under the Apache License, Version 2.0 license found in the
+ * LICENSE file in the root directory of this source tree.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/nginxinc/nginx-go-crossplane/internal/generator"
+)
+
+const diffUsage = `usage: generate diff [-format markdown|json] [-config-path config.json] <old> <new>
+
+Compares the directives of two versions of a module, like nginx plus R36 and R37.
+<old> and <new> are either paths of source code like -src-path, or generated .go files.
+The report lists the added, removed and changed directives, and the filter and override
+that keep the directives of the old version when the new one is generated.
+`
+
+// runDiff runs the diff command with its arguments, writing the report to stdout.
+func runDiff(args []string, stdout, stderr io.Writer) error {
+	flags := flag.NewFlagSet("diff", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprint(stderr, diffUsage)
+		flags.PrintDefaults()
+	}
+	format := flags.String("format", "markdown", "The format of the report: markdown or json.")
+	configPath := flags.String("config-path", "", "The path of json config file, whose filter, override and macros are "+
+		"used to extract the directives of source code. (optional)")
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+		return err
+	}
+	if flags.NArg() != 2 {
+		flags.Usage()
+		return errors.New("diff needs the paths of the old and the new version")
+	}
+	if *format != "markdown" && *format != "json" {
+		return fmt.Errorf("unknown format %q", *format)
+	}
+
+	var config generator.GenerateConfig
+	if *configPath != "" {
+		var err error
+		if config, err = configFromFile(*configPath); err != nil {
+			return err
+		}
+	}
+
+	report, err := generator.Diff(flags.Arg(0), flags.Arg(1), config)
+	if err != nil {
+		return err
+	}
+	if *format == "json" {
+		enc := json.NewEncoder(stdout)
+		enc.SetIndent("", "    ")
+		return enc.Encode(report)
+	}
+	return report.WriteMarkdown(stdout)
+}
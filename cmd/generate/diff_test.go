@@ -0,0 +1,37 @@
+/**
+ * Copyright (c) F5, Inc.
+ *
+ * This source code is licensed under the Apache License, Version 2.0 license found in the
+ * LICENSE file in the root directory of this source tree.
+ */
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"testing"
+
+	"github.com/nginxinc/nginx-go-crossplane/internal/generator"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunDiff(t *testing.T) {
+	t.Parallel()
+	oldPath := "../../internal/generator/testdata/source_codes/diffOld"
+	newPath := "../../internal/generator/testdata/source_codes/diffNew"
+
+	var stdout bytes.Buffer
+	require.NoError(t, runDiff([]string{"-format", "json", oldPath, newPath}, &stdout, io.Discard))
+	var report generator.DiffReport
+	require.NoError(t, json.Unmarshal(stdout.Bytes(), &report))
+	require.Equal(t, []string{"my_added"}, report.Config.Filter)
+
+	stdout.Reset()
+	require.NoError(t, runDiff([]string{oldPath, newPath}, &stdout, io.Discard))
+	require.Contains(t, stdout.String(), "## Changed (1)")
+
+	require.Error(t, runDiff([]string{oldPath}, &stdout, io.Discard))
+	require.Error(t, runDiff([]string{"-format", "xml", oldPath, newPath}, &stdout, io.Discard))
+}
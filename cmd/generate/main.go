@@ -8,7 +8,6 @@
 package main
 
 import (
-	"encoding/json"
 	"flag"
 	"log"
 	"os"
@@ -17,28 +16,34 @@ import (
 )
 
 func configFromFile(path string) (generator.GenerateConfig, error) {
-	var config generator.GenerateConfig
-	f, err := os.Open(path)
-	if err != nil {
-		return config, err
-	}
-
-	defer f.Close()
-
-	if err := json.NewDecoder(f).Decode(&config); err != nil {
-		return config, err
-	}
-
-	return config, nil
+	return generator.LoadConfig(path)
 }
 
 //nolint:funlen
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "diff" {
+		if err := runDiff(os.Args[2:], os.Stdout, os.Stderr); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "manifest" {
+		if err := runManifest(os.Args[2:], os.Stdout, os.Stderr); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
 	var (
 		sourceCodePath = flag.String("src-path", "",
-			"The path of source code your want to generate support from, it can be either a file or a directory. (required)")
+			"The path of source code your want to generate support from, it can be either a file or a directory.\n"+
+				"You should provide it or docs-path.")
+		docsPath = flag.String("docs-path", "",
+			"The path of the XML sources of the nginx documentation, e.g. xml/en/docs of nginx.org, to generate\n"+
+				"the documentation of directives from instead of support. Only directive-map-name and filter are used with it.")
 		configPath = flag.String("config-path", "", "The path of json config file.\n"+
 			"The file can contain directiveMapName, matchFuncName, matchFuncComment, filter, and override.\n"+
+			"It can also contain mapBodies, mapBodiesFuncName, macros, detectBlocks, contextsFuncName, and argTypesFuncName, which are only available in json config.\n"+
 			"They provide same functions as other arguments directive-map-name, match-func-name, match-func-comment, filter, and override.\n"+
 			"It will unmarsh to generator.GenerateConfig. (optional)")
 		directiveMapName = flag.String("directive-map-name", "", "Name of the generated map variable."+
@@ -50,6 +55,9 @@ func main() {
 		matchFnComment = flag.String("match-func-comment", "", "The code comment for generated matchFunc."+
 			"You can add some explanations like which modules included in it. Normally it should start with match-func-name.\n"+
 			"If this is provided, the matchFuncComment in json config will be ignored. (optional)")
+		format = flag.String("format", "go", "The format of the output: go for a directive map and matchFunc,\n"+
+			"or json for directive definitions that crossplane.LoadDirectives reads at runtime.\n"+
+			"Only filter and override are used with json.")
 		filterflags       filterFlag
 		directiveOverride override
 	)
@@ -96,14 +104,35 @@ func main() {
 		config.MatchFuncComment = *matchFnComment
 	}
 
-	if *sourceCodePath == "" {
-		log.Fatal("src-path can't be empty")
+	if *format == "json" {
+		if *sourceCodePath == "" {
+			log.Fatal("src-path can't be empty")
+		}
+		err = generator.GenerateDefinitions(*sourceCodePath, os.Stdout, config)
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	} else if *format != "go" {
+		log.Fatalf("unknown format %q", *format)
 	}
 
 	if config.DirectiveMapName == "" {
 		log.Fatal("directiveMapName can't be empty")
 	}
 
+	if *docsPath != "" {
+		err = generator.GenerateDocs(*docsPath, os.Stdout, config)
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	if *sourceCodePath == "" {
+		log.Fatal("src-path can't be empty")
+	}
+
 	if config.MatchFuncName == "" {
 		log.Fatal("matchFuncName can't be empty")
 	}
@@ -0,0 +1,73 @@
+/**
+ * Copyright (c) F5, Inc.
+ *
+ * This source code is licensed under the Apache License, Version 2.0 license found in the
+ * LICENSE file in the root directory of this source tree.
+ */
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/nginxinc/nginx-go-crossplane/internal/generator"
+)
+
+const manifestUsage = `usage: generate manifest [-check] <manifest.json>
+
+Generates all the .gen.go files listed in a manifest from local checkouts of the
+source code of modules, without fetching anything, and prints a summary of the
+directives that changed. See scripts/generate/manifest.json.
+With -check, the files aren't written, and the command fails if any of them isn't
+up to date with the checkouts, or is skipped because its checkout isn't set.
+`
+
+// runManifest runs the manifest command with its arguments, writing the summary to stdout.
+func runManifest(args []string, stdout, stderr io.Writer) error {
+	flags := flag.NewFlagSet("manifest", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprint(stderr, manifestUsage)
+		flags.PrintDefaults()
+	}
+	check := flags.Bool("check", false, "Check that the generated files are up to date instead of writing them.")
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+		return err
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return errors.New("manifest needs the path of a manifest")
+	}
+
+	results, err := generator.GenerateManifest(flags.Arg(0), *check)
+	if err != nil {
+		return err
+	}
+	if err = generator.WriteSummary(stdout, results); err != nil {
+		return err
+	}
+
+	outdated, skipped := 0, 0
+	for _, r := range results {
+		switch r.Status { //nolint:exhaustive
+		case generator.TableOutdated:
+			outdated++
+		case generator.TableSkipped:
+			skipped++
+		}
+	}
+	switch {
+	case outdated > 0:
+		return fmt.Errorf("%d of %d generated files are out of date", outdated, len(results))
+	case skipped > 0 && *check:
+		// a check without the checkouts checks nothing
+		return fmt.Errorf("%d of %d generated files are skipped since their checkout isn't set", skipped, len(results))
+	}
+	return nil
+}
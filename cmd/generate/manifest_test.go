@@ -0,0 +1,54 @@
+/**
+ * Copyright (c) F5, Inc.
+ *
+ * This source code is licensed under the Apache License, Version 2.0 license found in the
+ * LICENSE file in the root directory of this source tree.
+ */
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunManifest(t *testing.T) {
+	t.Parallel()
+	srcPath, err := filepath.Abs("../../internal/generator/testdata/source_codes/diffNew")
+	require.NoError(t, err)
+	dir := t.TempDir()
+	config := `{"directiveMapName": "myDirectives", "matchFuncName": "MatchMy"}`
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "config.json"), []byte(config), 0o600))
+	manifestPath := filepath.Join(dir, "manifest.json")
+	manifest := fmt.Sprintf(`{"tables": [{"output": "my.gen.go", "source": %q, "config": "config.json"}]}`, srcPath)
+	require.NoError(t, os.WriteFile(manifestPath, []byte(manifest), 0o600))
+
+	var stdout bytes.Buffer
+	require.Error(t, runManifest([]string{"-check", manifestPath}, &stdout, io.Discard))
+	require.Contains(t, stdout.String(), "my.gen.go: out of date")
+
+	stdout.Reset()
+	require.NoError(t, runManifest([]string{manifestPath}, &stdout, io.Discard))
+	require.Contains(t, stdout.String(), "my.gen.go: created")
+
+	stdout.Reset()
+	require.NoError(t, runManifest([]string{"-check", manifestPath}, &stdout, io.Discard))
+	require.Contains(t, stdout.String(), "my.gen.go: unchanged")
+
+	// tables whose checkout isn't set fail the check, but are only skipped otherwise
+	manifest = `{"tables": [{"output": "my.gen.go", "source": "${CROSSPLANE_TEST_UNSET_SRC}", "config": "config.json"}]}`
+	require.NoError(t, os.WriteFile(manifestPath, []byte(manifest), 0o600))
+	stdout.Reset()
+	err = runManifest([]string{"-check", manifestPath}, &stdout, io.Discard)
+	require.EqualError(t, err, "1 of 1 generated files are skipped since their checkout isn't set")
+	require.Contains(t, stdout.String(), "my.gen.go: skipped")
+	require.NoError(t, runManifest([]string{manifestPath}, &stdout, io.Discard))
+
+	require.Error(t, runManifest([]string{}, &stdout, io.Discard))
+}
@@ -0,0 +1,278 @@
+/**
+ * Copyright (c) F5, Inc.
+ *
+ * This source code is licensed under the Apache License, Version 2.0 license found in the
+ * LICENSE file in the root directory of this source tree.
+ */
+
+package crossplane
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Products of nginx that a config can be compatible with.
+const (
+	ProductOSS  = "nginx"
+	ProductPlus = "nginx-plus"
+)
+
+// Modules that aren't built into nginx but that a config can need.
+const (
+	ModuleNjs         = "njs"
+	ModuleLua         = "lua"
+	ModuleOtel        = "otel"
+	ModuleGeoip2      = "geoip2"
+	ModuleHeadersMore = "headers-more"
+	ModuleAppProtect  = "app-protect"
+)
+
+type compatVersion struct {
+	product string
+	version string
+	match   MatchFunc
+}
+
+// compatVersions are the versions that have bundled directive sources, oldest first.
+//
+//nolint:gochecknoglobals
+var compatVersions = []compatVersion{
+	{ProductOSS, "1.24", MatchOss124},
+	{ProductOSS, "1.26", MatchOss126},
+	{ProductOSS, "latest", MatchOssLatest},
+	{ProductPlus, "R30", MatchNginxPlusR30},
+	{ProductPlus, "R31", MatchNginxPlusR31},
+	{ProductPlus, "R33", MatchNginxPlusR33},
+	{ProductPlus, "R34", MatchNginxPlusR34},
+	{ProductPlus, "R35", MatchNginxPlusR35},
+	{ProductPlus, "R36", MatchNginxPlusR36},
+	{ProductPlus, "R37", MatchNginxPlusR37},
+	{ProductPlus, "latest", MatchNginxPlusLatest},
+}
+
+type compatModule struct {
+	name    string
+	matches []MatchFunc
+}
+
+//nolint:gochecknoglobals
+var compatModules = []compatModule{
+	{ModuleNjs, []MatchFunc{MatchNjsLatest}},
+	{ModuleLua, []MatchFunc{MatchLuaLatest}},
+	{ModuleOtel, []MatchFunc{MatchOtelLatest}},
+	{ModuleGeoip2, []MatchFunc{MatchGeoip2Latest}},
+	{ModuleHeadersMore, []MatchFunc{MatchHeadersMoreLatest}},
+	{ModuleAppProtect, []MatchFunc{MatchAppProtectWAFv4, MatchAppProtectWAFv5}},
+}
+
+// CompatibilityReport tells which versions of nginx and nginx plus accept a config.
+type CompatibilityReport struct {
+	// OSS and Plus are the ranges of consecutive versions of nginx and nginx plus
+	// that accept the config, oldest first. A directive that was removed from a
+	// version and added back in a later one splits them into several ranges.
+	OSS  []VersionRange `json:"oss,omitempty"`
+	Plus []VersionRange `json:"plus,omitempty"`
+	// Modules are the modules the config needs in any of the versions, like njs or lua.
+	Modules []string `json:"modules,omitempty"`
+	// Versions are the reports of every version, oldest first, nginx before nginx plus.
+	Versions []VersionCompatibility `json:"versions"`
+}
+
+// VersionRange is a range of consecutive versions of a product, from Min to Max.
+type VersionRange struct {
+	Min string `json:"min"`
+	Max string `json:"max"`
+}
+
+// VersionCompatibility tells whether a version of nginx or nginx plus accepts a config.
+type VersionCompatibility struct {
+	// Product is ProductOSS or ProductPlus.
+	Product string `json:"product"`
+	// Version is e.g. "1.26" for nginx or "R33" for nginx plus, or "latest".
+	Version    string `json:"version"`
+	Compatible bool   `json:"compatible"`
+	// Modules are the modules the config needs with this version.
+	Modules []string `json:"modules,omitempty"`
+	// Blocking are the directives that neither the version nor any of the modules accept.
+	Blocking []BlockingDirective `json:"blocking,omitempty"`
+}
+
+// BlockingDirective is a directive that makes a config incompatible with a version.
+type BlockingDirective struct {
+	Directive string `json:"directive"`
+	File      string `json:"file"`
+	Line      int    `json:"line"`
+	// Reason is why the version doesn't accept the directive, e.g.
+	// `unknown directive "js_import"`.
+	Reason string `json:"reason"`
+}
+
+// Compatibility evaluates the directives of payload against the bundled directive
+// sources of every version of nginx and nginx plus, and of the modules that aren't
+// built into them, and reports which versions accept the config. A directive is
+// accepted if the version knows it, or one of the modules does, and it's allowed
+// in its block with its arguments.
+//
+// Parse the config with SkipDirectiveContextCheck and SkipDirectiveArgsCheck so that
+// the directives that the default directive sources reject are evaluated too.
+func Compatibility(payload *Payload) *CompatibilityReport {
+	report := &CompatibilityReport{Versions: make([]VersionCompatibility, 0, len(compatVersions))}
+	modules := map[string]bool{}
+
+	for _, v := range compatVersions {
+		c := &compatChecker{payload: payload, version: v, modules: map[string]bool{}, walking: map[int]bool{}}
+		if len(payload.Config) > 0 {
+			c.walk(0, blockCtx{})
+		}
+		vc := VersionCompatibility{
+			Product:    v.product,
+			Version:    v.version,
+			Compatible: len(c.blocking) == 0,
+			Modules:    sortedKeys(c.modules),
+			Blocking:   c.blocking,
+		}
+		for module := range c.modules {
+			modules[module] = true
+		}
+		report.Versions = append(report.Versions, vc)
+	}
+	report.OSS = compatibleRanges(report.Versions, ProductOSS)
+	report.Plus = compatibleRanges(report.Versions, ProductPlus)
+	report.Modules = sortedKeys(modules)
+	return report
+}
+
+// compatibleRanges returns the ranges of consecutive versions of product that are
+// compatible in versions, which are sorted oldest first.
+func compatibleRanges(versions []VersionCompatibility, product string) []VersionRange {
+	var ranges []VersionRange
+	extend := false
+	for _, v := range versions {
+		if v.Product != product {
+			continue
+		}
+		switch {
+		case !v.Compatible:
+			extend = false
+		case extend:
+			ranges[len(ranges)-1].Max = v.Version
+		default:
+			ranges = append(ranges, VersionRange{Min: v.Version, Max: v.Version})
+			extend = true
+		}
+	}
+	return ranges
+}
+
+type compatChecker struct {
+	payload  *Payload
+	version  compatVersion
+	modules  map[string]bool
+	blocking []BlockingDirective
+	// walking are the configs being walked, so that includes that include
+	// themselves aren't walked forever
+	walking map[int]bool
+}
+
+func (c *compatChecker) walk(index int, ctx blockCtx) {
+	if c.walking[index] {
+		return
+	}
+	c.walking[index] = true
+	defer delete(c.walking, index)
+
+	config := &c.payload.Config[index]
+	c.walkDirectives(config.File, config.Parsed, ctx)
+}
+
+func (c *compatChecker) walkDirectives(file string, ds Directives, ctx blockCtx) {
+	for _, d := range ds {
+		if d.IsComment() || d.IsMapBlockParameter {
+			continue
+		}
+		c.check(file, d, ctx)
+		for _, index := range d.Includes {
+			if index >= 0 && index < len(c.payload.Config) {
+				c.walk(index, ctx)
+			}
+		}
+		if d.IsBlock() {
+			c.walkDirectives(file, d.Block, enterBlockCtx(d, ctx))
+		}
+	}
+}
+
+// check records whether the version, or the version with one of the modules, accepts d.
+func (c *compatChecker) check(file string, d *Directive, ctx blockCtx) {
+	stmt, term := compatStatement(d)
+
+	reason := checkCompat(file, stmt, term, ctx, []MatchFunc{c.version.match})
+	if reason == "" {
+		return
+	}
+	for _, module := range compatModules {
+		if _, known := matchDirective(d.Directive, module.matches); !known {
+			continue
+		}
+		sources := append([]MatchFunc{c.version.match}, module.matches...)
+		if checkCompat(file, stmt, term, ctx, sources) == "" {
+			c.modules[module.name] = true
+			return
+		}
+	}
+	c.blocking = append(c.blocking, BlockingDirective{
+		Directive: d.Directive,
+		File:      file,
+		Line:      d.Line,
+		Reason:    reason,
+	})
+}
+
+// checkCompat returns why the sources don't accept stmt, or an empty string if they do.
+func checkCompat(file string, stmt *Directive, term string, ctx blockCtx, sources []MatchFunc) string {
+	if _, known := matchDirective(stmt.Directive, sources); !known {
+		return fmt.Sprintf(`unknown directive "%s"`, stmt.Directive)
+	}
+	if err := analyze(file, stmt, term, ctx, &ParseOptions{DirectiveSources: sources}); err != nil {
+		var perr *ParseError
+		if errors.As(err, &perr) {
+			return perr.What
+		}
+		return err.Error()
+	}
+	return ""
+}
+
+// compatStatement returns d as the parser analyzed it: with the parentheses of
+// an "if" that the parser removed, and the token that terminated it.
+func compatStatement(d *Directive) (*Directive, string) {
+	term := ";"
+	if d.IsBlock() {
+		term = "{"
+	}
+	if d.Directive != "if" || len(d.Args) == 0 {
+		return d, term
+	}
+	stmt := *d
+	stmt.Args = append([]string{}, d.Args...)
+	if !strings.HasPrefix(stmt.Args[0], "(") {
+		stmt.Args[0] = "(" + stmt.Args[0]
+		stmt.Args[len(stmt.Args)-1] += ")"
+	}
+	return &stmt, term
+}
+
+func sortedKeys(m map[string]bool) []string {
+	if len(m) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,144 @@
+/**
+ * Copyright (c) F5, Inc.
+ *
+ * This source code is licensed under the Apache License, Version 2.0 license found in the
+ * LICENSE file in the root directory of this source tree.
+ */
+
+package crossplane
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompatibility(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+
+	tcs := map[string]struct {
+		config  string
+		oss     []VersionRange
+		plus    []VersionRange
+		modules []string
+		// blocking are the blocking directives of the versions that have any
+		blocking map[string][]BlockingDirective
+	}{
+		"every version": {
+			config: "events {}\nhttp {\n    server {\n        listen 80;\n        if ($host) {\n            return 404;\n        }\n    }\n}\n",
+			oss:    []VersionRange{{Min: "1.24", Max: "latest"}},
+			plus:   []VersionRange{{Min: "R30", Max: "latest"}},
+		},
+		"newer oss": {
+			config: "http {\n    server {\n        http2 on;\n    }\n}\n",
+			oss:    []VersionRange{{Min: "1.26", Max: "latest"}},
+			plus:   []VersionRange{{Min: "R30", Max: "latest"}},
+			blocking: map[string][]BlockingDirective{
+				"nginx 1.24": {{Directive: "http2", Line: 3, Reason: `unknown directive "http2"`}},
+			},
+		},
+		"plus only": {
+			config: "http {\n    server {\n        location /api {\n            api;\n        }\n    }\n}\n",
+			plus:   []VersionRange{{Min: "R30", Max: "latest"}},
+			blocking: map[string][]BlockingDirective{
+				"nginx 1.24":   {{Directive: "api", Line: 4, Reason: `unknown directive "api"`}},
+				"nginx 1.26":   {{Directive: "api", Line: 4, Reason: `unknown directive "api"`}},
+				"nginx latest": {{Directive: "api", Line: 4, Reason: `unknown directive "api"`}},
+			},
+		},
+		"modules": {
+			config:  "http {\n    js_import main.js;\n    server {\n        more_set_headers 'Server: test';\n    }\n}\n",
+			oss:     []VersionRange{{Min: "1.24", Max: "latest"}},
+			plus:    []VersionRange{{Min: "R30", Max: "latest"}},
+			modules: []string{ModuleHeadersMore, ModuleNjs},
+		},
+		"not allowed here": {
+			config: "events {}\nhttp {\n    listen 80;\n}\n",
+			blocking: func() map[string][]BlockingDirective {
+				blocking := map[string][]BlockingDirective{}
+				for _, v := range compatVersions {
+					blocking[v.product+" "+v.version] = []BlockingDirective{
+						{Directive: "listen", Line: 3, Reason: `"listen" directive is not allowed here`},
+					}
+				}
+				return blocking
+			}(),
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			path := filepath.Join(dir, name+".conf")
+			require.NoError(t, os.WriteFile(path, []byte(tc.config), 0o600))
+			payload, err := Parse(path, &ParseOptions{SkipDirectiveContextCheck: true, SkipDirectiveArgsCheck: true})
+			require.NoError(t, err)
+
+			report := Compatibility(payload)
+			require.Equal(t, tc.oss, report.OSS)
+			require.Equal(t, tc.plus, report.Plus)
+			require.Equal(t, tc.modules, report.Modules)
+			require.Len(t, report.Versions, len(compatVersions))
+
+			blocking := map[string][]BlockingDirective{}
+			for _, v := range report.Versions {
+				require.Equal(t, len(v.Blocking) == 0, v.Compatible)
+				require.Equal(t, tc.modules, v.Modules)
+				for _, b := range v.Blocking {
+					require.Equal(t, path, b.File)
+					b.File = ""
+					blocking[v.Product+" "+v.Version] = append(blocking[v.Product+" "+v.Version], b)
+				}
+			}
+			if tc.blocking == nil {
+				tc.blocking = map[string][]BlockingDirective{}
+			}
+			require.Equal(t, tc.blocking, blocking)
+		})
+	}
+}
+
+func TestCompatibility_includes(t *testing.T) {
+	t.Parallel()
+	// the locations of the included files are checked in the server blocks they're included in
+	payload, err := Parse(getTestConfigPath("includes-cycle", "valid", "nginx.conf"), &ParseOptions{})
+	require.NoError(t, err)
+
+	report := Compatibility(payload)
+	require.Equal(t, []VersionRange{{Min: "1.24", Max: "latest"}}, report.OSS)
+	require.Equal(t, []VersionRange{{Min: "R30", Max: "latest"}}, report.Plus)
+
+	// a payload whose files include each other is checked once through
+	payload.Config[2].Parsed[0].Block = append(payload.Config[2].Parsed[0].Block, &Directive{
+		Directive: "include",
+		Line:      3,
+		Args:      []string{"location1.conf"},
+		Includes:  []int{1},
+	})
+	report = Compatibility(payload)
+	require.Equal(t, []VersionRange{{Min: "1.24", Max: "latest"}}, report.OSS)
+}
+
+func TestCompatibleRanges(t *testing.T) {
+	t.Parallel()
+	versions := []VersionCompatibility{
+		{Product: ProductOSS, Version: "1.24", Compatible: true},
+		{Product: ProductOSS, Version: "1.26", Compatible: true},
+		{Product: ProductOSS, Version: "latest", Compatible: true},
+		{Product: ProductPlus, Version: "R30", Compatible: true},
+		{Product: ProductPlus, Version: "R31"},
+		{Product: ProductPlus, Version: "R33"},
+		{Product: ProductPlus, Version: "R34", Compatible: true},
+		{Product: ProductPlus, Version: "R35", Compatible: true},
+		{Product: ProductPlus, Version: "latest"},
+	}
+
+	// the versions that don't accept the config aren't in any range
+	require.Equal(t, []VersionRange{{Min: "1.24", Max: "latest"}}, compatibleRanges(versions, ProductOSS))
+	require.Equal(t, []VersionRange{{Min: "R30", Max: "R30"}, {Min: "R34", Max: "R35"}}, compatibleRanges(versions, ProductPlus))
+	require.Nil(t, compatibleRanges(versions[4:6], ProductPlus))
+}
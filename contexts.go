@@ -0,0 +1,125 @@
+/**
+ * Copyright (c) F5, Inc.
+ *
+ * This source code is licensed under the Apache License, Version 2.0 license found in the
+ * LICENSE file in the root directory of this source tree.
+ */
+
+package crossplane
+
+import (
+	"errors"
+	"fmt"
+	"math/bits"
+)
+
+// ArgStyle is a bitmask of the arguments a directive takes, e.g. ArgsTake1|ArgsTake2
+// for one or two arguments.
+type ArgStyle uint
+
+// Argument styles of directives.
+const (
+	ArgsNone  ArgStyle = ngxConfNoArgs
+	ArgsTake1 ArgStyle = ngxConfTake1
+	ArgsTake2 ArgStyle = ngxConfTake2
+	ArgsTake3 ArgStyle = ngxConfTake3
+	ArgsTake4 ArgStyle = ngxConfTake4
+	ArgsTake5 ArgStyle = ngxConfTake5
+	ArgsTake6 ArgStyle = ngxConfTake6
+	// ArgsFlag is one argument that's either "on" or "off".
+	ArgsFlag  ArgStyle = ngxConfFlag
+	ArgsAny   ArgStyle = ngxConfAny
+	Args1More ArgStyle = ngxConf1More
+	Args2More ArgStyle = ngxConf2More
+	// ArgsBlock is set for directives that are followed by a block.
+	ArgsBlock ArgStyle = ngxConfBlock
+)
+
+// argStyleMask are the bits of the bitmasks that aren't contexts.
+const argStyleMask = ngxConfNoArgs | ngxConfTake1 | ngxConfTake2 | ngxConfTake3 | ngxConfTake4 |
+	ngxConfTake5 | ngxConfTake6 | ngxConfTake7 | ngxConfBlock | ngxConfExpr | ngxConfFlag | ngxConfAny | ngxConf1More | ngxConf2More
+
+// firstCustomContext is the bitmask of the first block context that isn't built in.
+const firstCustomContext = ngxHTTPOIDCConf << 1
+
+// BlockContexts are block contexts of third-party modules, like a foo_zone { ... }
+// block in http, along with the directives allowed in them. Set ParseOptions.BlockContexts
+// to check the directives of configs that have them. Register must not be called while
+// configs are parsed with it.
+type BlockContexts struct {
+	contexts   map[string]uint
+	directives map[string][]uint
+	next       uint
+}
+
+// NewBlockContexts returns a set of block contexts without any context.
+func NewBlockContexts() *BlockContexts {
+	return &BlockContexts{
+		contexts:   map[string]uint{},
+		directives: map[string][]uint{},
+		next:       firstCustomContext,
+	}
+}
+
+// Register adds the block context at path, the names of the blocks that enclose it
+// followed by the name of the directive that opens it, e.g. []string{"http", "foo_zone"}.
+// Locations are a single level, so a block in any location is at []string{"http",
+// "location", "foo"}. The directive that opens the block takes args in the enclosing
+// context, which is either built in or registered before. directives are the directives
+// allowed in the block and the arguments they take. Up to 31 block contexts can be
+// registered.
+func (bc *BlockContexts) Register(path []string, args ArgStyle, directives map[string]ArgStyle) error {
+	if len(path) == 0 {
+		return errors.New("the path of a block context can't be empty")
+	}
+	ctx := blockCtx(path)
+	if _, ok := bc.lookup(ctx); ok {
+		return fmt.Errorf("block context %q is already known", ctx.key())
+	}
+	parent, ok := bc.lookup(ctx[:len(ctx)-1])
+	if !ok {
+		return fmt.Errorf("the enclosing context of block context %q is unknown", ctx.key())
+	}
+	if bc.next == 0 {
+		return fmt.Errorf("can't register block context %q, %d contexts are registered already",
+			ctx.key(), bits.UintSize-bits.TrailingZeros(firstCustomContext))
+	}
+
+	mask := bc.next
+	bc.next <<= 1
+	bc.contexts[ctx.key()] = mask
+
+	name := path[len(path)-1]
+	bc.directives[name] = append(bc.directives[name], parent|ngxConfBlock|uint(args)&argStyleMask)
+	for directive, style := range directives {
+		bc.directives[directive] = append(bc.directives[directive], mask|uint(style)&argStyleMask)
+	}
+	return nil
+}
+
+// lookup returns the bitmask of a context that's either built in or registered.
+func (bc *BlockContexts) lookup(ctx blockCtx) (uint, bool) {
+	if mask, ok := contexts[ctx.key()]; ok {
+		return mask, true
+	}
+	mask, ok := bc.contexts[ctx.key()]
+	return mask, ok
+}
+
+// match returns the masks of the directive in the registered contexts.
+func (bc *BlockContexts) match(directive string) ([]uint, bool) {
+	if bc == nil {
+		return nil, false
+	}
+	masks, ok := bc.directives[directive]
+	return masks, ok
+}
+
+// context returns the bitmask of a registered context.
+func (bc *BlockContexts) context(key string) (uint, bool) {
+	if bc == nil {
+		return 0, false
+	}
+	mask, ok := bc.contexts[key]
+	return mask, ok
+}
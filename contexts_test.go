@@ -0,0 +1,97 @@
+/**
+ * Copyright (c) F5, Inc.
+ *
+ * This source code is licensed under the Apache License, Version 2.0 license found in the
+ * LICENSE file in the root directory of this source tree.
+ */
+
+package crossplane
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBlockContexts_register(t *testing.T) {
+	t.Parallel()
+	bc := NewBlockContexts()
+	require.NoError(t, bc.Register([]string{"http", "foo_zone"}, ArgsTake1, map[string]ArgStyle{"foo_size": ArgsTake1}))
+	require.NoError(t, bc.Register([]string{"http", "foo_zone", "foo_peer"}, ArgsNone, nil))
+	require.NoError(t, bc.Register([]string{"foo"}, ArgsNone, nil))
+
+	require.EqualError(t, bc.Register(nil, ArgsNone, nil), "the path of a block context can't be empty")
+	require.EqualError(t, bc.Register([]string{"http", "server"}, ArgsNone, nil), `block context "http>server" is already known`)
+	require.EqualError(t, bc.Register([]string{"http", "foo_zone"}, ArgsNone, nil), `block context "http>foo_zone" is already known`)
+	require.EqualError(t, bc.Register([]string{"http", "bar", "baz"}, ArgsNone, nil),
+		`the enclosing context of block context "http>bar>baz" is unknown`)
+
+	// up to 31 contexts can be registered
+	for i := 3; i < 31; i++ {
+		require.NoError(t, bc.Register([]string{fmt.Sprintf("foo%d", i)}, ArgsNone, nil))
+	}
+	require.EqualError(t, bc.Register([]string{"foo31"}, ArgsNone, nil),
+		`can't register block context "foo31", 31 contexts are registered already`)
+}
+
+func TestParse_blockContexts(t *testing.T) {
+	t.Parallel()
+	bc := NewBlockContexts()
+	require.NoError(t, bc.Register([]string{"http", "foo_zone"}, ArgsTake1, map[string]ArgStyle{
+		"foo_size":    ArgsTake1,
+		"foo_enabled": ArgsFlag,
+		// a directive that's also built in
+		"server": Args1More,
+	}))
+	require.NoError(t, bc.Register([]string{"http", "foo_zone", "foo_peer"}, ArgsNone|ArgsTake1, map[string]ArgStyle{
+		"address": ArgsTake1,
+	}))
+	require.NoError(t, bc.Register([]string{"http", "location", "foo_rules"}, ArgsNone, map[string]ArgStyle{
+		"allow": ArgsTake1,
+	}))
+
+	path := filepath.Join(t.TempDir(), "nginx.conf")
+	require.NoError(t, os.WriteFile(path, []byte(`
+http {
+    foo_zone one {
+        foo_size 10m;
+        foo_enabled maybe;
+        server 127.0.0.1:8080 weight=2;
+        foo_peer {
+            address 127.0.0.1;
+        }
+        listen 80;
+    }
+    server {
+        foo_size 1m;
+        location / {
+            location /nested {
+                foo_rules {
+                    allow all;
+                }
+            }
+        }
+    }
+}
+`), 0o600))
+
+	payload, err := Parse(path, &ParseOptions{BlockContexts: bc, ErrorOnUnknownDirectives: true})
+	require.NoError(t, err)
+	whats := []string{}
+	for _, e := range payload.Errors {
+		whats = append(whats, e.Error.(*ParseError).What)
+	}
+	require.Equal(t, []string{
+		`invalid value "maybe" in "foo_enabled" directive, it must be "on" or "off"`,
+		`"listen" directive is not allowed here`,
+		`"foo_size" directive is not allowed here`,
+	}, whats)
+
+	// the registered contexts aren't known without the option
+	payload, err = Parse(path, &ParseOptions{ErrorOnUnknownDirectives: true})
+	require.NoError(t, err)
+	require.Equal(t, `unknown directive "foo_zone"`, payload.Errors[0].Error.(*ParseError).What)
+}
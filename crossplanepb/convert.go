@@ -0,0 +1,235 @@
+/**
+ * Copyright (c) F5, Inc.
+ *
+ * This source code is licensed under the Apache License, Version 2.0 license found in the
+ * LICENSE file in the root directory of this source tree.
+ */
+
+// Package crossplanepb provides the Protocol Buffers encoding of crossplane payloads.
+//
+// The messages are defined in crossplane.proto. Regenerate crossplane.pb.go with
+// protoc and protoc-gen-go after changing it:
+//
+//	protoc --go_out=. --go_opt=paths=source_relative crossplane.proto
+package crossplanepb
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/nginxinc/nginx-go-crossplane"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// Marshal returns the Protocol Buffers encoding of payload.
+func Marshal(payload *crossplane.Payload) ([]byte, error) {
+	pb, err := FromPayload(payload)
+	if err != nil {
+		return nil, err
+	}
+	return proto.Marshal(pb)
+}
+
+// Unmarshal parses the Protocol Buffers encoding of a payload.
+func Unmarshal(b []byte) (*crossplane.Payload, error) {
+	var pb Payload
+	if err := proto.Unmarshal(b, &pb); err != nil {
+		return nil, err
+	}
+	return ToPayload(&pb), nil
+}
+
+// FromPayload converts a crossplane.Payload into its Protocol Buffers message. It fails
+// if an error callback result can't be represented as a google.protobuf.Value.
+func FromPayload(payload *crossplane.Payload) (*Payload, error) {
+	pb := &Payload{
+		Status: payload.Status,
+		Errors: make([]*PayloadError, 0, len(payload.Errors)),
+		Config: make([]*Config, 0, len(payload.Config)),
+	}
+	for _, e := range payload.Errors {
+		callback, err := callbackValue(e.Callback)
+		if err != nil {
+			return nil, err
+		}
+		pb.Errors = append(pb.Errors, &PayloadError{
+			File:       e.File,
+			Line:       int32Ptr(e.Line),
+			Error:      crossplane.ErrorMessage(e.Error),
+			ParseError: fromParseError(e.Error),
+			Callback:   callback,
+		})
+	}
+	for _, c := range payload.Config {
+		config := &Config{
+			File:   c.File,
+			Status: c.Status,
+			Errors: make([]*ConfigError, 0, len(c.Errors)),
+			Parsed: fromDirectives(c.Parsed),
+		}
+		for _, e := range c.Errors {
+			config.Errors = append(config.Errors, &ConfigError{
+				Line:       int32Ptr(e.Line),
+				Error:      crossplane.ErrorMessage(e.Error),
+				ParseError: fromParseError(e.Error),
+			})
+		}
+		pb.Config = append(pb.Config, config)
+	}
+	return pb, nil
+}
+
+// ToPayload converts a Protocol Buffers message into a crossplane.Payload.
+func ToPayload(pb *Payload) *crossplane.Payload {
+	payload := &crossplane.Payload{
+		Status: pb.GetStatus(),
+		Errors: make([]crossplane.PayloadError, 0, len(pb.GetErrors())),
+		Config: make([]crossplane.Config, 0, len(pb.GetConfig())),
+	}
+	for _, e := range pb.GetErrors() {
+		perr := crossplane.PayloadError{
+			File:  e.GetFile(),
+			Line:  intPtr(e.Line),
+			Error: toError(e.Error, e.GetParseError()),
+		}
+		if e.GetCallback() != nil {
+			perr.Callback = e.GetCallback().AsInterface()
+		}
+		payload.Errors = append(payload.Errors, perr)
+	}
+	for _, c := range pb.GetConfig() {
+		config := crossplane.Config{
+			File:   c.GetFile(),
+			Status: c.GetStatus(),
+			Errors: make([]crossplane.ConfigError, 0, len(c.GetErrors())),
+			Parsed: toDirectives(c.GetParsed()),
+		}
+		for _, e := range c.GetErrors() {
+			config.Errors = append(config.Errors, crossplane.ConfigError{
+				Line:  intPtr(e.Line),
+				Error: toError(e.Error, e.GetParseError()),
+			})
+		}
+		payload.Config = append(payload.Config, config)
+	}
+	return payload
+}
+
+func fromDirectives(block crossplane.Directives) []*Directive {
+	pbs := make([]*Directive, 0, len(block))
+	for _, stmt := range block {
+		d := &Directive{
+			Directive:         stmt.Directive,
+			Line:              int32(stmt.Line), //nolint:gosec
+			Args:              stmt.Args,
+			File:              stmt.File,
+			Comment:           stmt.Comment,
+			MapBlockParameter: stmt.IsMapBlockParameter,
+			IsBlock:           stmt.IsBlock(),
+		}
+		if stmt.Includes != nil {
+			d.Includes = make([]int32, 0, len(stmt.Includes))
+			for _, idx := range stmt.Includes {
+				d.Includes = append(d.Includes, int32(idx)) //nolint:gosec
+			}
+		}
+		if stmt.IsBlock() {
+			d.Block = fromDirectives(stmt.Block)
+		}
+		pbs = append(pbs, d)
+	}
+	return pbs
+}
+
+func toDirectives(pbs []*Directive) crossplane.Directives {
+	block := make(crossplane.Directives, 0, len(pbs))
+	for _, d := range pbs {
+		stmt := &crossplane.Directive{
+			Directive:           d.GetDirective(),
+			Line:                int(d.GetLine()),
+			Args:                d.GetArgs(),
+			File:                d.GetFile(),
+			Comment:             d.Comment,
+			IsMapBlockParameter: d.GetMapBlockParameter(),
+		}
+		if stmt.Args == nil {
+			stmt.Args = []string{}
+		}
+		if len(d.GetIncludes()) > 0 {
+			stmt.Includes = make([]int, 0, len(d.GetIncludes()))
+			for _, idx := range d.GetIncludes() {
+				stmt.Includes = append(stmt.Includes, int(idx))
+			}
+		}
+		if d.GetIsBlock() {
+			stmt.Block = toDirectives(d.GetBlock())
+		}
+		block = append(block, stmt)
+	}
+	return block
+}
+
+func fromParseError(err error) *ParseError {
+	var perr *crossplane.ParseError
+	if !errors.As(err, &perr) {
+		return nil
+	}
+	return &ParseError{
+		What:      perr.What,
+		File:      perr.File,
+		Line:      int32Ptr(perr.Line),
+		Statement: perr.Statement,
+		BlockCtx:  perr.BlockCtx,
+	}
+}
+
+func toError(msg *string, pe *ParseError) error {
+	switch {
+	case pe != nil:
+		return &crossplane.ParseError{
+			What:      pe.GetWhat(),
+			File:      pe.File,
+			Line:      intPtr(pe.Line),
+			Statement: pe.GetStatement(),
+			BlockCtx:  pe.GetBlockCtx(),
+		}
+	case msg != nil:
+		return errors.New(*msg)
+	default:
+		return nil
+	}
+}
+
+// callbackValue converts the result of an error callback into a google.protobuf.Value
+// by way of its JSON encoding, which is how it's written in JSON payloads.
+func callbackValue(callback interface{}) (*structpb.Value, error) {
+	if callback == nil {
+		return nil, nil
+	}
+	b, err := json.Marshal(callback)
+	if err != nil {
+		return nil, err
+	}
+	var v interface{}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return nil, err
+	}
+	return structpb.NewValue(v)
+}
+
+func int32Ptr(i *int) *int32 {
+	if i == nil {
+		return nil
+	}
+	v := int32(*i) //nolint:gosec
+	return &v
+}
+
+func intPtr(i *int32) *int {
+	if i == nil {
+		return nil
+	}
+	v := int(*i)
+	return &v
+}
@@ -0,0 +1,146 @@
+/**
+ * Copyright (c) F5, Inc.
+ *
+ * This source code is licensed under the Apache License, Version 2.0 license found in the
+ * LICENSE file in the root directory of this source tree.
+ */
+
+package crossplanepb
+
+import (
+	"bytes"
+	"compress/bzip2"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/nginxinc/nginx-go-crossplane"
+	"github.com/stretchr/testify/require"
+)
+
+func buildPayload(t testing.TB, payload *crossplane.Payload) []string {
+	t.Helper()
+	out := make([]string, 0, len(payload.Config))
+	for _, config := range payload.Config {
+		var buf bytes.Buffer
+		require.NoError(t, crossplane.Build(&buf, config, &crossplane.BuildOptions{}))
+		out = append(out, buf.String())
+	}
+	return out
+}
+
+func TestRoundTrip(t *testing.T) {
+	t.Parallel()
+	paths, err := filepath.Glob(filepath.Join("..", "testdata", "configs", "*", "nginx.conf"))
+	require.NoError(t, err)
+	require.NotEmpty(t, paths)
+
+	for _, path := range paths {
+		path := path
+		t.Run(filepath.Base(filepath.Dir(path)), func(t *testing.T) {
+			t.Parallel()
+			payload, err := crossplane.Parse(path, &crossplane.ParseOptions{
+				ParseComments: true,
+				ErrorCallback: func(err error) interface{} { return map[string]interface{}{"message": err.Error()} },
+			})
+			if err != nil {
+				t.Skipf("config can't be parsed: %v", err)
+			}
+
+			b, err := Marshal(payload)
+			require.NoError(t, err)
+			decoded, err := Unmarshal(b)
+			require.NoError(t, err)
+
+			require.Equal(t, buildPayload(t, payload), buildPayload(t, decoded))
+
+			// every field, including errors and empty blocks, must survive the round trip
+			// except for the difference between nil and empty configs
+			for i := range payload.Config {
+				if payload.Config[i].Parsed == nil {
+					payload.Config[i].Parsed = crossplane.Directives{}
+				}
+			}
+			want, err := json.Marshal(payload)
+			require.NoError(t, err)
+			got, err := json.Marshal(decoded)
+			require.NoError(t, err)
+			require.JSONEq(t, string(want), string(got))
+		})
+	}
+}
+
+func TestFromPayload_wrappedParseError(t *testing.T) {
+	t.Parallel()
+	file, line := "nginx.conf", 3
+	err := fmt.Errorf("included config: %w", &crossplane.ParseError{What: `unknown directive "foo"`, File: &file, Line: &line})
+	payload := &crossplane.Payload{
+		Errors: []crossplane.PayloadError{{File: file, Line: &line, Error: err}},
+		Config: []crossplane.Config{{File: file, Errors: []crossplane.ConfigError{{Line: &line, Error: err}}, Parsed: crossplane.Directives{}}},
+	}
+
+	pb, err := FromPayload(payload)
+	require.NoError(t, err)
+	for _, perr := range []*ParseError{pb.GetErrors()[0].GetParseError(), pb.GetConfig()[0].GetErrors()[0].GetParseError()} {
+		require.Equal(t, `unknown directive "foo"`, perr.GetWhat())
+		require.Equal(t, file, perr.GetFile())
+		require.Equal(t, int32(line), perr.GetLine())
+	}
+}
+
+func largeConfig(b *testing.B) *crossplane.Payload {
+	b.Helper()
+	f, err := os.Open(filepath.Join("..", "testdata", "configs", "large-config", "nginx.conf.bz2"))
+	if err != nil {
+		b.Skip("cannot open input file")
+	}
+	defer f.Close()
+
+	path := filepath.Join(b.TempDir(), "nginx.conf")
+	of, err := os.Create(path)
+	require.NoError(b, err)
+	_, err = io.Copy(of, bzip2.NewReader(f))
+	require.NoError(b, err)
+	require.NoError(b, of.Close())
+
+	payload, err := crossplane.Parse(path, &crossplane.ParseOptions{SingleFile: true, StopParsingOnError: true})
+	require.NoError(b, err)
+	return payload
+}
+
+func BenchmarkLargeConfig(b *testing.B) {
+	payload := largeConfig(b)
+
+	b.Run("json", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			data, err := json.Marshal(payload)
+			require.NoError(b, err)
+			var decoded crossplane.Payload
+			require.NoError(b, json.Unmarshal(data, &decoded))
+			b.ReportMetric(float64(len(data)), "bytes")
+		}
+	})
+
+	b.Run("protobuf", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			data, err := Marshal(payload)
+			require.NoError(b, err)
+			_, err = Unmarshal(data)
+			require.NoError(b, err)
+			b.ReportMetric(float64(len(data)), "bytes")
+		}
+	})
+
+	b.Run("identical", func(b *testing.B) {
+		data, err := Marshal(payload)
+		require.NoError(b, err)
+		decoded, err := Unmarshal(data)
+		require.NoError(b, err)
+		require.Equal(b, buildPayload(b, payload), buildPayload(b, decoded))
+	})
+}
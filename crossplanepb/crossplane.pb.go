@@ -0,0 +1,730 @@
+// Copyright (c) F5, Inc.
+//
+// This source code is licensed under the Apache License, Version 2.0 license found in the
+// LICENSE file in the root directory of this source tree.
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.34.2
+// 	protoc        (unknown)
+// source: crossplane.proto
+
+package crossplanepb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	structpb "google.golang.org/protobuf/types/known/structpb"
+	reflect "reflect"
+	sync "sync"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// Payload is the result of parsing an NGINX configuration, see crossplane.Payload.
+type Payload struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Status string          `protobuf:"bytes,1,opt,name=status,proto3" json:"status,omitempty"`
+	Errors []*PayloadError `protobuf:"bytes,2,rep,name=errors,proto3" json:"errors,omitempty"`
+	Config []*Config       `protobuf:"bytes,3,rep,name=config,proto3" json:"config,omitempty"`
+}
+
+func (x *Payload) Reset() {
+	*x = Payload{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_crossplane_proto_msgTypes[0]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Payload) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Payload) ProtoMessage() {}
+
+func (x *Payload) ProtoReflect() protoreflect.Message {
+	mi := &file_crossplane_proto_msgTypes[0]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Payload.ProtoReflect.Descriptor instead.
+func (*Payload) Descriptor() ([]byte, []int) {
+	return file_crossplane_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Payload) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *Payload) GetErrors() []*PayloadError {
+	if x != nil {
+		return x.Errors
+	}
+	return nil
+}
+
+func (x *Payload) GetConfig() []*Config {
+	if x != nil {
+		return x.Config
+	}
+	return nil
+}
+
+// PayloadError is an error found while parsing one of the files of a Payload.
+type PayloadError struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	File string `protobuf:"bytes,1,opt,name=file,proto3" json:"file,omitempty"`
+	Line *int32 `protobuf:"varint,2,opt,name=line,proto3,oneof" json:"line,omitempty"`
+	// error is the error message, it is unset if there was no error.
+	Error *string `protobuf:"bytes,3,opt,name=error,proto3,oneof" json:"error,omitempty"`
+	// parse_error is set if the error was a crossplane.ParseError.
+	ParseError *ParseError `protobuf:"bytes,4,opt,name=parse_error,json=parseError,proto3" json:"parse_error,omitempty"`
+	// callback is the result of crossplane.ParseOptions.ErrorCallback.
+	Callback *structpb.Value `protobuf:"bytes,5,opt,name=callback,proto3" json:"callback,omitempty"`
+}
+
+func (x *PayloadError) Reset() {
+	*x = PayloadError{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_crossplane_proto_msgTypes[1]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *PayloadError) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PayloadError) ProtoMessage() {}
+
+func (x *PayloadError) ProtoReflect() protoreflect.Message {
+	mi := &file_crossplane_proto_msgTypes[1]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PayloadError.ProtoReflect.Descriptor instead.
+func (*PayloadError) Descriptor() ([]byte, []int) {
+	return file_crossplane_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *PayloadError) GetFile() string {
+	if x != nil {
+		return x.File
+	}
+	return ""
+}
+
+func (x *PayloadError) GetLine() int32 {
+	if x != nil && x.Line != nil {
+		return *x.Line
+	}
+	return 0
+}
+
+func (x *PayloadError) GetError() string {
+	if x != nil && x.Error != nil {
+		return *x.Error
+	}
+	return ""
+}
+
+func (x *PayloadError) GetParseError() *ParseError {
+	if x != nil {
+		return x.ParseError
+	}
+	return nil
+}
+
+func (x *PayloadError) GetCallback() *structpb.Value {
+	if x != nil {
+		return x.Callback
+	}
+	return nil
+}
+
+// ConfigError is an error found while parsing a Config.
+type ConfigError struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Line       *int32      `protobuf:"varint,1,opt,name=line,proto3,oneof" json:"line,omitempty"`
+	Error      *string     `protobuf:"bytes,2,opt,name=error,proto3,oneof" json:"error,omitempty"`
+	ParseError *ParseError `protobuf:"bytes,3,opt,name=parse_error,json=parseError,proto3" json:"parse_error,omitempty"`
+}
+
+func (x *ConfigError) Reset() {
+	*x = ConfigError{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_crossplane_proto_msgTypes[2]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ConfigError) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ConfigError) ProtoMessage() {}
+
+func (x *ConfigError) ProtoReflect() protoreflect.Message {
+	mi := &file_crossplane_proto_msgTypes[2]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ConfigError.ProtoReflect.Descriptor instead.
+func (*ConfigError) Descriptor() ([]byte, []int) {
+	return file_crossplane_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *ConfigError) GetLine() int32 {
+	if x != nil && x.Line != nil {
+		return *x.Line
+	}
+	return 0
+}
+
+func (x *ConfigError) GetError() string {
+	if x != nil && x.Error != nil {
+		return *x.Error
+	}
+	return ""
+}
+
+func (x *ConfigError) GetParseError() *ParseError {
+	if x != nil {
+		return x.ParseError
+	}
+	return nil
+}
+
+// ParseError holds the fields of a crossplane.ParseError.
+type ParseError struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	What      string  `protobuf:"bytes,1,opt,name=what,proto3" json:"what,omitempty"`
+	File      *string `protobuf:"bytes,2,opt,name=file,proto3,oneof" json:"file,omitempty"`
+	Line      *int32  `protobuf:"varint,3,opt,name=line,proto3,oneof" json:"line,omitempty"`
+	Statement string  `protobuf:"bytes,4,opt,name=statement,proto3" json:"statement,omitempty"`
+	BlockCtx  string  `protobuf:"bytes,5,opt,name=block_ctx,json=blockCtx,proto3" json:"block_ctx,omitempty"`
+}
+
+func (x *ParseError) Reset() {
+	*x = ParseError{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_crossplane_proto_msgTypes[3]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ParseError) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ParseError) ProtoMessage() {}
+
+func (x *ParseError) ProtoReflect() protoreflect.Message {
+	mi := &file_crossplane_proto_msgTypes[3]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ParseError.ProtoReflect.Descriptor instead.
+func (*ParseError) Descriptor() ([]byte, []int) {
+	return file_crossplane_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *ParseError) GetWhat() string {
+	if x != nil {
+		return x.What
+	}
+	return ""
+}
+
+func (x *ParseError) GetFile() string {
+	if x != nil && x.File != nil {
+		return *x.File
+	}
+	return ""
+}
+
+func (x *ParseError) GetLine() int32 {
+	if x != nil && x.Line != nil {
+		return *x.Line
+	}
+	return 0
+}
+
+func (x *ParseError) GetStatement() string {
+	if x != nil {
+		return x.Statement
+	}
+	return ""
+}
+
+func (x *ParseError) GetBlockCtx() string {
+	if x != nil {
+		return x.BlockCtx
+	}
+	return ""
+}
+
+// Config is a parsed NGINX configuration file.
+type Config struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	File   string         `protobuf:"bytes,1,opt,name=file,proto3" json:"file,omitempty"`
+	Status string         `protobuf:"bytes,2,opt,name=status,proto3" json:"status,omitempty"`
+	Errors []*ConfigError `protobuf:"bytes,3,rep,name=errors,proto3" json:"errors,omitempty"`
+	Parsed []*Directive   `protobuf:"bytes,4,rep,name=parsed,proto3" json:"parsed,omitempty"`
+}
+
+func (x *Config) Reset() {
+	*x = Config{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_crossplane_proto_msgTypes[4]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Config) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Config) ProtoMessage() {}
+
+func (x *Config) ProtoReflect() protoreflect.Message {
+	mi := &file_crossplane_proto_msgTypes[4]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Config.ProtoReflect.Descriptor instead.
+func (*Config) Descriptor() ([]byte, []int) {
+	return file_crossplane_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *Config) GetFile() string {
+	if x != nil {
+		return x.File
+	}
+	return ""
+}
+
+func (x *Config) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *Config) GetErrors() []*ConfigError {
+	if x != nil {
+		return x.Errors
+	}
+	return nil
+}
+
+func (x *Config) GetParsed() []*Directive {
+	if x != nil {
+		return x.Parsed
+	}
+	return nil
+}
+
+// Directive is a directive, a map-like block parameter or a comment in an NGINX configuration.
+type Directive struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Directive         string   `protobuf:"bytes,1,opt,name=directive,proto3" json:"directive,omitempty"`
+	Line              int32    `protobuf:"varint,2,opt,name=line,proto3" json:"line,omitempty"`
+	Args              []string `protobuf:"bytes,3,rep,name=args,proto3" json:"args,omitempty"`
+	File              string   `protobuf:"bytes,4,opt,name=file,proto3" json:"file,omitempty"`
+	Includes          []int32  `protobuf:"varint,5,rep,packed,name=includes,proto3" json:"includes,omitempty"`
+	Comment           *string  `protobuf:"bytes,6,opt,name=comment,proto3,oneof" json:"comment,omitempty"`
+	MapBlockParameter bool     `protobuf:"varint,7,opt,name=map_block_parameter,json=mapBlockParameter,proto3" json:"map_block_parameter,omitempty"`
+	// is_block tells an empty block apart from a directive without a block.
+	IsBlock bool         `protobuf:"varint,8,opt,name=is_block,json=isBlock,proto3" json:"is_block,omitempty"`
+	Block   []*Directive `protobuf:"bytes,9,rep,name=block,proto3" json:"block,omitempty"`
+}
+
+func (x *Directive) Reset() {
+	*x = Directive{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_crossplane_proto_msgTypes[5]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Directive) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Directive) ProtoMessage() {}
+
+func (x *Directive) ProtoReflect() protoreflect.Message {
+	mi := &file_crossplane_proto_msgTypes[5]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Directive.ProtoReflect.Descriptor instead.
+func (*Directive) Descriptor() ([]byte, []int) {
+	return file_crossplane_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *Directive) GetDirective() string {
+	if x != nil {
+		return x.Directive
+	}
+	return ""
+}
+
+func (x *Directive) GetLine() int32 {
+	if x != nil {
+		return x.Line
+	}
+	return 0
+}
+
+func (x *Directive) GetArgs() []string {
+	if x != nil {
+		return x.Args
+	}
+	return nil
+}
+
+func (x *Directive) GetFile() string {
+	if x != nil {
+		return x.File
+	}
+	return ""
+}
+
+func (x *Directive) GetIncludes() []int32 {
+	if x != nil {
+		return x.Includes
+	}
+	return nil
+}
+
+func (x *Directive) GetComment() string {
+	if x != nil && x.Comment != nil {
+		return *x.Comment
+	}
+	return ""
+}
+
+func (x *Directive) GetMapBlockParameter() bool {
+	if x != nil {
+		return x.MapBlockParameter
+	}
+	return false
+}
+
+func (x *Directive) GetIsBlock() bool {
+	if x != nil {
+		return x.IsBlock
+	}
+	return false
+}
+
+func (x *Directive) GetBlock() []*Directive {
+	if x != nil {
+		return x.Block
+	}
+	return nil
+}
+
+var File_crossplane_proto protoreflect.FileDescriptor
+
+var file_crossplane_proto_rawDesc = []byte{
+	0x0a, 0x10, 0x63, 0x72, 0x6f, 0x73, 0x73, 0x70, 0x6c, 0x61, 0x6e, 0x65, 0x2e, 0x70, 0x72, 0x6f,
+	0x74, 0x6f, 0x12, 0x13, 0x6e, 0x67, 0x69, 0x6e, 0x78, 0x2e, 0x63, 0x72, 0x6f, 0x73, 0x73, 0x70,
+	0x6c, 0x61, 0x6e, 0x65, 0x2e, 0x76, 0x31, 0x1a, 0x1c, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2f,
+	0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2f, 0x73, 0x74, 0x72, 0x75, 0x63, 0x74, 0x2e,
+	0x70, 0x72, 0x6f, 0x74, 0x6f, 0x22, 0x91, 0x01, 0x0a, 0x07, 0x50, 0x61, 0x79, 0x6c, 0x6f, 0x61,
+	0x64, 0x12, 0x16, 0x0a, 0x06, 0x73, 0x74, 0x61, 0x74, 0x75, 0x73, 0x18, 0x01, 0x20, 0x01, 0x28,
+	0x09, 0x52, 0x06, 0x73, 0x74, 0x61, 0x74, 0x75, 0x73, 0x12, 0x39, 0x0a, 0x06, 0x65, 0x72, 0x72,
+	0x6f, 0x72, 0x73, 0x18, 0x02, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x21, 0x2e, 0x6e, 0x67, 0x69, 0x6e,
+	0x78, 0x2e, 0x63, 0x72, 0x6f, 0x73, 0x73, 0x70, 0x6c, 0x61, 0x6e, 0x65, 0x2e, 0x76, 0x31, 0x2e,
+	0x50, 0x61, 0x79, 0x6c, 0x6f, 0x61, 0x64, 0x45, 0x72, 0x72, 0x6f, 0x72, 0x52, 0x06, 0x65, 0x72,
+	0x72, 0x6f, 0x72, 0x73, 0x12, 0x33, 0x0a, 0x06, 0x63, 0x6f, 0x6e, 0x66, 0x69, 0x67, 0x18, 0x03,
+	0x20, 0x03, 0x28, 0x0b, 0x32, 0x1b, 0x2e, 0x6e, 0x67, 0x69, 0x6e, 0x78, 0x2e, 0x63, 0x72, 0x6f,
+	0x73, 0x73, 0x70, 0x6c, 0x61, 0x6e, 0x65, 0x2e, 0x76, 0x31, 0x2e, 0x43, 0x6f, 0x6e, 0x66, 0x69,
+	0x67, 0x52, 0x06, 0x63, 0x6f, 0x6e, 0x66, 0x69, 0x67, 0x22, 0xdf, 0x01, 0x0a, 0x0c, 0x50, 0x61,
+	0x79, 0x6c, 0x6f, 0x61, 0x64, 0x45, 0x72, 0x72, 0x6f, 0x72, 0x12, 0x12, 0x0a, 0x04, 0x66, 0x69,
+	0x6c, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x66, 0x69, 0x6c, 0x65, 0x12, 0x17,
+	0x0a, 0x04, 0x6c, 0x69, 0x6e, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x05, 0x48, 0x00, 0x52, 0x04,
+	0x6c, 0x69, 0x6e, 0x65, 0x88, 0x01, 0x01, 0x12, 0x19, 0x0a, 0x05, 0x65, 0x72, 0x72, 0x6f, 0x72,
+	0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x48, 0x01, 0x52, 0x05, 0x65, 0x72, 0x72, 0x6f, 0x72, 0x88,
+	0x01, 0x01, 0x12, 0x40, 0x0a, 0x0b, 0x70, 0x61, 0x72, 0x73, 0x65, 0x5f, 0x65, 0x72, 0x72, 0x6f,
+	0x72, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1f, 0x2e, 0x6e, 0x67, 0x69, 0x6e, 0x78, 0x2e,
+	0x63, 0x72, 0x6f, 0x73, 0x73, 0x70, 0x6c, 0x61, 0x6e, 0x65, 0x2e, 0x76, 0x31, 0x2e, 0x50, 0x61,
+	0x72, 0x73, 0x65, 0x45, 0x72, 0x72, 0x6f, 0x72, 0x52, 0x0a, 0x70, 0x61, 0x72, 0x73, 0x65, 0x45,
+	0x72, 0x72, 0x6f, 0x72, 0x12, 0x32, 0x0a, 0x08, 0x63, 0x61, 0x6c, 0x6c, 0x62, 0x61, 0x63, 0x6b,
+	0x18, 0x05, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x16, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e,
+	0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x56, 0x61, 0x6c, 0x75, 0x65, 0x52, 0x08,
+	0x63, 0x61, 0x6c, 0x6c, 0x62, 0x61, 0x63, 0x6b, 0x42, 0x07, 0x0a, 0x05, 0x5f, 0x6c, 0x69, 0x6e,
+	0x65, 0x42, 0x08, 0x0a, 0x06, 0x5f, 0x65, 0x72, 0x72, 0x6f, 0x72, 0x22, 0x96, 0x01, 0x0a, 0x0b,
+	0x43, 0x6f, 0x6e, 0x66, 0x69, 0x67, 0x45, 0x72, 0x72, 0x6f, 0x72, 0x12, 0x17, 0x0a, 0x04, 0x6c,
+	0x69, 0x6e, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x05, 0x48, 0x00, 0x52, 0x04, 0x6c, 0x69, 0x6e,
+	0x65, 0x88, 0x01, 0x01, 0x12, 0x19, 0x0a, 0x05, 0x65, 0x72, 0x72, 0x6f, 0x72, 0x18, 0x02, 0x20,
+	0x01, 0x28, 0x09, 0x48, 0x01, 0x52, 0x05, 0x65, 0x72, 0x72, 0x6f, 0x72, 0x88, 0x01, 0x01, 0x12,
+	0x40, 0x0a, 0x0b, 0x70, 0x61, 0x72, 0x73, 0x65, 0x5f, 0x65, 0x72, 0x72, 0x6f, 0x72, 0x18, 0x03,
+	0x20, 0x01, 0x28, 0x0b, 0x32, 0x1f, 0x2e, 0x6e, 0x67, 0x69, 0x6e, 0x78, 0x2e, 0x63, 0x72, 0x6f,
+	0x73, 0x73, 0x70, 0x6c, 0x61, 0x6e, 0x65, 0x2e, 0x76, 0x31, 0x2e, 0x50, 0x61, 0x72, 0x73, 0x65,
+	0x45, 0x72, 0x72, 0x6f, 0x72, 0x52, 0x0a, 0x70, 0x61, 0x72, 0x73, 0x65, 0x45, 0x72, 0x72, 0x6f,
+	0x72, 0x42, 0x07, 0x0a, 0x05, 0x5f, 0x6c, 0x69, 0x6e, 0x65, 0x42, 0x08, 0x0a, 0x06, 0x5f, 0x65,
+	0x72, 0x72, 0x6f, 0x72, 0x22, 0x9f, 0x01, 0x0a, 0x0a, 0x50, 0x61, 0x72, 0x73, 0x65, 0x45, 0x72,
+	0x72, 0x6f, 0x72, 0x12, 0x12, 0x0a, 0x04, 0x77, 0x68, 0x61, 0x74, 0x18, 0x01, 0x20, 0x01, 0x28,
+	0x09, 0x52, 0x04, 0x77, 0x68, 0x61, 0x74, 0x12, 0x17, 0x0a, 0x04, 0x66, 0x69, 0x6c, 0x65, 0x18,
+	0x02, 0x20, 0x01, 0x28, 0x09, 0x48, 0x00, 0x52, 0x04, 0x66, 0x69, 0x6c, 0x65, 0x88, 0x01, 0x01,
+	0x12, 0x17, 0x0a, 0x04, 0x6c, 0x69, 0x6e, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x05, 0x48, 0x01,
+	0x52, 0x04, 0x6c, 0x69, 0x6e, 0x65, 0x88, 0x01, 0x01, 0x12, 0x1c, 0x0a, 0x09, 0x73, 0x74, 0x61,
+	0x74, 0x65, 0x6d, 0x65, 0x6e, 0x74, 0x18, 0x04, 0x20, 0x01, 0x28, 0x09, 0x52, 0x09, 0x73, 0x74,
+	0x61, 0x74, 0x65, 0x6d, 0x65, 0x6e, 0x74, 0x12, 0x1b, 0x0a, 0x09, 0x62, 0x6c, 0x6f, 0x63, 0x6b,
+	0x5f, 0x63, 0x74, 0x78, 0x18, 0x05, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08, 0x62, 0x6c, 0x6f, 0x63,
+	0x6b, 0x43, 0x74, 0x78, 0x42, 0x07, 0x0a, 0x05, 0x5f, 0x66, 0x69, 0x6c, 0x65, 0x42, 0x07, 0x0a,
+	0x05, 0x5f, 0x6c, 0x69, 0x6e, 0x65, 0x22, 0xa6, 0x01, 0x0a, 0x06, 0x43, 0x6f, 0x6e, 0x66, 0x69,
+	0x67, 0x12, 0x12, 0x0a, 0x04, 0x66, 0x69, 0x6c, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x04, 0x66, 0x69, 0x6c, 0x65, 0x12, 0x16, 0x0a, 0x06, 0x73, 0x74, 0x61, 0x74, 0x75, 0x73, 0x18,
+	0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x73, 0x74, 0x61, 0x74, 0x75, 0x73, 0x12, 0x38, 0x0a,
+	0x06, 0x65, 0x72, 0x72, 0x6f, 0x72, 0x73, 0x18, 0x03, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x20, 0x2e,
+	0x6e, 0x67, 0x69, 0x6e, 0x78, 0x2e, 0x63, 0x72, 0x6f, 0x73, 0x73, 0x70, 0x6c, 0x61, 0x6e, 0x65,
+	0x2e, 0x76, 0x31, 0x2e, 0x43, 0x6f, 0x6e, 0x66, 0x69, 0x67, 0x45, 0x72, 0x72, 0x6f, 0x72, 0x52,
+	0x06, 0x65, 0x72, 0x72, 0x6f, 0x72, 0x73, 0x12, 0x36, 0x0a, 0x06, 0x70, 0x61, 0x72, 0x73, 0x65,
+	0x64, 0x18, 0x04, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x1e, 0x2e, 0x6e, 0x67, 0x69, 0x6e, 0x78, 0x2e,
+	0x63, 0x72, 0x6f, 0x73, 0x73, 0x70, 0x6c, 0x61, 0x6e, 0x65, 0x2e, 0x76, 0x31, 0x2e, 0x44, 0x69,
+	0x72, 0x65, 0x63, 0x74, 0x69, 0x76, 0x65, 0x52, 0x06, 0x70, 0x61, 0x72, 0x73, 0x65, 0x64, 0x22,
+	0xad, 0x02, 0x0a, 0x09, 0x44, 0x69, 0x72, 0x65, 0x63, 0x74, 0x69, 0x76, 0x65, 0x12, 0x1c, 0x0a,
+	0x09, 0x64, 0x69, 0x72, 0x65, 0x63, 0x74, 0x69, 0x76, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09,
+	0x52, 0x09, 0x64, 0x69, 0x72, 0x65, 0x63, 0x74, 0x69, 0x76, 0x65, 0x12, 0x12, 0x0a, 0x04, 0x6c,
+	0x69, 0x6e, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x05, 0x52, 0x04, 0x6c, 0x69, 0x6e, 0x65, 0x12,
+	0x12, 0x0a, 0x04, 0x61, 0x72, 0x67, 0x73, 0x18, 0x03, 0x20, 0x03, 0x28, 0x09, 0x52, 0x04, 0x61,
+	0x72, 0x67, 0x73, 0x12, 0x12, 0x0a, 0x04, 0x66, 0x69, 0x6c, 0x65, 0x18, 0x04, 0x20, 0x01, 0x28,
+	0x09, 0x52, 0x04, 0x66, 0x69, 0x6c, 0x65, 0x12, 0x1a, 0x0a, 0x08, 0x69, 0x6e, 0x63, 0x6c, 0x75,
+	0x64, 0x65, 0x73, 0x18, 0x05, 0x20, 0x03, 0x28, 0x05, 0x52, 0x08, 0x69, 0x6e, 0x63, 0x6c, 0x75,
+	0x64, 0x65, 0x73, 0x12, 0x1d, 0x0a, 0x07, 0x63, 0x6f, 0x6d, 0x6d, 0x65, 0x6e, 0x74, 0x18, 0x06,
+	0x20, 0x01, 0x28, 0x09, 0x48, 0x00, 0x52, 0x07, 0x63, 0x6f, 0x6d, 0x6d, 0x65, 0x6e, 0x74, 0x88,
+	0x01, 0x01, 0x12, 0x2e, 0x0a, 0x13, 0x6d, 0x61, 0x70, 0x5f, 0x62, 0x6c, 0x6f, 0x63, 0x6b, 0x5f,
+	0x70, 0x61, 0x72, 0x61, 0x6d, 0x65, 0x74, 0x65, 0x72, 0x18, 0x07, 0x20, 0x01, 0x28, 0x08, 0x52,
+	0x11, 0x6d, 0x61, 0x70, 0x42, 0x6c, 0x6f, 0x63, 0x6b, 0x50, 0x61, 0x72, 0x61, 0x6d, 0x65, 0x74,
+	0x65, 0x72, 0x12, 0x19, 0x0a, 0x08, 0x69, 0x73, 0x5f, 0x62, 0x6c, 0x6f, 0x63, 0x6b, 0x18, 0x08,
+	0x20, 0x01, 0x28, 0x08, 0x52, 0x07, 0x69, 0x73, 0x42, 0x6c, 0x6f, 0x63, 0x6b, 0x12, 0x34, 0x0a,
+	0x05, 0x62, 0x6c, 0x6f, 0x63, 0x6b, 0x18, 0x09, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x1e, 0x2e, 0x6e,
+	0x67, 0x69, 0x6e, 0x78, 0x2e, 0x63, 0x72, 0x6f, 0x73, 0x73, 0x70, 0x6c, 0x61, 0x6e, 0x65, 0x2e,
+	0x76, 0x31, 0x2e, 0x44, 0x69, 0x72, 0x65, 0x63, 0x74, 0x69, 0x76, 0x65, 0x52, 0x05, 0x62, 0x6c,
+	0x6f, 0x63, 0x6b, 0x42, 0x0a, 0x0a, 0x08, 0x5f, 0x63, 0x6f, 0x6d, 0x6d, 0x65, 0x6e, 0x74, 0x42,
+	0x36, 0x5a, 0x34, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x6e, 0x67,
+	0x69, 0x6e, 0x78, 0x69, 0x6e, 0x63, 0x2f, 0x6e, 0x67, 0x69, 0x6e, 0x78, 0x2d, 0x67, 0x6f, 0x2d,
+	0x63, 0x72, 0x6f, 0x73, 0x73, 0x70, 0x6c, 0x61, 0x6e, 0x65, 0x2f, 0x63, 0x72, 0x6f, 0x73, 0x73,
+	0x70, 0x6c, 0x61, 0x6e, 0x65, 0x70, 0x62, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+}
+
+var (
+	file_crossplane_proto_rawDescOnce sync.Once
+	file_crossplane_proto_rawDescData = file_crossplane_proto_rawDesc
+)
+
+func file_crossplane_proto_rawDescGZIP() []byte {
+	file_crossplane_proto_rawDescOnce.Do(func() {
+		file_crossplane_proto_rawDescData = protoimpl.X.CompressGZIP(file_crossplane_proto_rawDescData)
+	})
+	return file_crossplane_proto_rawDescData
+}
+
+var file_crossplane_proto_msgTypes = make([]protoimpl.MessageInfo, 6)
+var file_crossplane_proto_goTypes = []any{
+	(*Payload)(nil),        // 0: nginx.crossplane.v1.Payload
+	(*PayloadError)(nil),   // 1: nginx.crossplane.v1.PayloadError
+	(*ConfigError)(nil),    // 2: nginx.crossplane.v1.ConfigError
+	(*ParseError)(nil),     // 3: nginx.crossplane.v1.ParseError
+	(*Config)(nil),         // 4: nginx.crossplane.v1.Config
+	(*Directive)(nil),      // 5: nginx.crossplane.v1.Directive
+	(*structpb.Value)(nil), // 6: google.protobuf.Value
+}
+var file_crossplane_proto_depIdxs = []int32{
+	1, // 0: nginx.crossplane.v1.Payload.errors:type_name -> nginx.crossplane.v1.PayloadError
+	4, // 1: nginx.crossplane.v1.Payload.config:type_name -> nginx.crossplane.v1.Config
+	3, // 2: nginx.crossplane.v1.PayloadError.parse_error:type_name -> nginx.crossplane.v1.ParseError
+	6, // 3: nginx.crossplane.v1.PayloadError.callback:type_name -> google.protobuf.Value
+	3, // 4: nginx.crossplane.v1.ConfigError.parse_error:type_name -> nginx.crossplane.v1.ParseError
+	2, // 5: nginx.crossplane.v1.Config.errors:type_name -> nginx.crossplane.v1.ConfigError
+	5, // 6: nginx.crossplane.v1.Config.parsed:type_name -> nginx.crossplane.v1.Directive
+	5, // 7: nginx.crossplane.v1.Directive.block:type_name -> nginx.crossplane.v1.Directive
+	8, // [8:8] is the sub-list for method output_type
+	8, // [8:8] is the sub-list for method input_type
+	8, // [8:8] is the sub-list for extension type_name
+	8, // [8:8] is the sub-list for extension extendee
+	0, // [0:8] is the sub-list for field type_name
+}
+
+func init() { file_crossplane_proto_init() }
+func file_crossplane_proto_init() {
+	if File_crossplane_proto != nil {
+		return
+	}
+	if !protoimpl.UnsafeEnabled {
+		file_crossplane_proto_msgTypes[0].Exporter = func(v any, i int) any {
+			switch v := v.(*Payload); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_crossplane_proto_msgTypes[1].Exporter = func(v any, i int) any {
+			switch v := v.(*PayloadError); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_crossplane_proto_msgTypes[2].Exporter = func(v any, i int) any {
+			switch v := v.(*ConfigError); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_crossplane_proto_msgTypes[3].Exporter = func(v any, i int) any {
+			switch v := v.(*ParseError); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_crossplane_proto_msgTypes[4].Exporter = func(v any, i int) any {
+			switch v := v.(*Config); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_crossplane_proto_msgTypes[5].Exporter = func(v any, i int) any {
+			switch v := v.(*Directive); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+	}
+	file_crossplane_proto_msgTypes[1].OneofWrappers = []any{}
+	file_crossplane_proto_msgTypes[2].OneofWrappers = []any{}
+	file_crossplane_proto_msgTypes[3].OneofWrappers = []any{}
+	file_crossplane_proto_msgTypes[5].OneofWrappers = []any{}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: file_crossplane_proto_rawDesc,
+			NumEnums:      0,
+			NumMessages:   6,
+			NumExtensions: 0,
+			NumServices:   0,
+		},
+		GoTypes:           file_crossplane_proto_goTypes,
+		DependencyIndexes: file_crossplane_proto_depIdxs,
+		MessageInfos:      file_crossplane_proto_msgTypes,
+	}.Build()
+	File_crossplane_proto = out.File
+	file_crossplane_proto_rawDesc = nil
+	file_crossplane_proto_goTypes = nil
+	file_crossplane_proto_depIdxs = nil
+}
@@ -0,0 +1,194 @@
+/**
+ * Copyright (c) F5, Inc.
+ *
+ * This source code is licensed under the Apache License, Version 2.0 license found in the
+ * LICENSE file in the root directory of this source tree.
+ */
+
+// Package crossplanetoml provides the TOML encoding of crossplane payloads.
+//
+// The TOML encoding of a Payload mirrors the JSON encoding. Since TOML tables are
+// unordered, directives are written as arrays of tables to keep their order:
+//
+//	[[config]]
+//	file = "nginx.conf"
+//
+//	  [[config.parsed]]
+//	  directive = "http"
+//	  line = 1
+//	  args = []
+//
+//	    [[config.parsed.block]]
+//	    directive = "server_tokens"
+//	    line = 2
+//	    args = ["off"]
+//
+// TOML has no null, so missing lines and errors are omitted.
+package crossplanetoml
+
+import (
+	"io"
+
+	"github.com/BurntSushi/toml"
+	"github.com/nginxinc/nginx-go-crossplane"
+)
+
+type tomlPayload struct {
+	Status string       `toml:"status"`
+	Errors []tomlError  `toml:"errors"`
+	Config []tomlConfig `toml:"config"`
+}
+
+type tomlError struct {
+	File       string                       `toml:"file,omitempty"`
+	Line       *int                         `toml:"line,omitempty"`
+	Error      *string                      `toml:"error,omitempty"`
+	Callback   interface{}                  `toml:"callback,omitempty"`
+	ParseError *crossplane.ParseErrorDetail `toml:"parseError,omitempty"`
+}
+
+type tomlConfig struct {
+	File   string          `toml:"file"`
+	Status string          `toml:"status"`
+	Errors []tomlError     `toml:"errors"`
+	Parsed []tomlDirective `toml:"parsed"`
+}
+
+type tomlDirective struct {
+	Directive         string   `toml:"directive"`
+	Line              int      `toml:"line,omitempty"`
+	Args              []string `toml:"args"`
+	File              string   `toml:"file,omitempty"`
+	Includes          []int    `toml:"includes,omitempty"`
+	Comment           *string  `toml:"comment,omitempty"`
+	MapBlockParameter bool     `toml:"mapBlockParameter,omitempty"`
+	// Block is a pointer so that an empty block can be told apart from no block.
+	Block *[]tomlDirective `toml:"block,omitempty"`
+}
+
+// Encode writes the TOML encoding of payload to w.
+func Encode(w io.Writer, payload *crossplane.Payload) error {
+	tp := tomlPayload{
+		Status: payload.Status,
+		Errors: make([]tomlError, 0, len(payload.Errors)),
+		Config: make([]tomlConfig, 0, len(payload.Config)),
+	}
+	for _, e := range payload.Errors {
+		tp.Errors = append(tp.Errors, tomlError{
+			File:       e.File,
+			Line:       e.Line,
+			Error:      crossplane.ErrorMessage(e.Error),
+			Callback:   e.Callback,
+			ParseError: crossplane.NewParseErrorDetail(e.Error),
+		})
+	}
+	for _, c := range payload.Config {
+		tc := tomlConfig{
+			File:   c.File,
+			Status: c.Status,
+			Errors: make([]tomlError, 0, len(c.Errors)),
+			Parsed: tomlBlock(c.Parsed),
+		}
+		for _, e := range c.Errors {
+			tc.Errors = append(tc.Errors, tomlError{
+				Line:       e.Line,
+				Error:      crossplane.ErrorMessage(e.Error),
+				ParseError: crossplane.NewParseErrorDetail(e.Error),
+			})
+		}
+		tp.Config = append(tp.Config, tc)
+	}
+
+	return toml.NewEncoder(w).Encode(tp)
+}
+
+// Decode reads a payload in the TOML encoding written by Encode from r.
+func Decode(r io.Reader) (*crossplane.Payload, error) {
+	var tp tomlPayload
+	if _, err := toml.NewDecoder(r).Decode(&tp); err != nil {
+		return nil, err
+	}
+
+	payload := &crossplane.Payload{
+		Status: tp.Status,
+		Errors: make([]crossplane.PayloadError, 0, len(tp.Errors)),
+		Config: make([]crossplane.Config, 0, len(tp.Config)),
+	}
+	for _, e := range tp.Errors {
+		if err := e.ParseError.CheckVersion(); err != nil {
+			return nil, err
+		}
+		payload.Errors = append(payload.Errors, crossplane.PayloadError{
+			File:     e.File,
+			Line:     e.Line,
+			Error:    crossplane.ErrorFromMessage(e.Error, e.ParseError),
+			Callback: e.Callback,
+		})
+	}
+	for _, tc := range tp.Config {
+		c := crossplane.Config{
+			File:   tc.File,
+			Status: tc.Status,
+			Errors: make([]crossplane.ConfigError, 0, len(tc.Errors)),
+			Parsed: directivesFromTOML(tc.Parsed),
+		}
+		for _, e := range tc.Errors {
+			if err := e.ParseError.CheckVersion(); err != nil {
+				return nil, err
+			}
+			c.Errors = append(c.Errors, crossplane.ConfigError{
+				Line:  e.Line,
+				Error: crossplane.ErrorFromMessage(e.Error, e.ParseError),
+			})
+		}
+		payload.Config = append(payload.Config, c)
+	}
+	return payload, nil
+}
+
+func tomlBlock(block crossplane.Directives) []tomlDirective {
+	tds := make([]tomlDirective, 0, len(block))
+	for _, stmt := range block {
+		td := tomlDirective{
+			Directive:         stmt.Directive,
+			Line:              stmt.Line,
+			Args:              stmt.Args,
+			File:              stmt.File,
+			Includes:          stmt.Includes,
+			Comment:           stmt.Comment,
+			MapBlockParameter: stmt.IsMapBlockParameter,
+		}
+		if td.Args == nil {
+			td.Args = []string{}
+		}
+		if stmt.IsBlock() {
+			inner := tomlBlock(stmt.Block)
+			td.Block = &inner
+		}
+		tds = append(tds, td)
+	}
+	return tds
+}
+
+func directivesFromTOML(tds []tomlDirective) crossplane.Directives {
+	block := make(crossplane.Directives, 0, len(tds))
+	for _, td := range tds {
+		stmt := &crossplane.Directive{
+			Directive:           td.Directive,
+			Line:                td.Line,
+			Args:                td.Args,
+			File:                td.File,
+			Includes:            td.Includes,
+			Comment:             td.Comment,
+			IsMapBlockParameter: td.MapBlockParameter,
+		}
+		if stmt.Args == nil {
+			stmt.Args = []string{}
+		}
+		if td.Block != nil {
+			stmt.Block = directivesFromTOML(*td.Block)
+		}
+		block = append(block, stmt)
+	}
+	return block
+}
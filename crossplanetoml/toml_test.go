@@ -0,0 +1,28 @@
+/**
+ * Copyright (c) F5, Inc.
+ *
+ * This source code is licensed under the Apache License, Version 2.0 license found in the
+ * LICENSE file in the root directory of this source tree.
+ */
+
+package crossplanetoml
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/nginxinc/nginx-go-crossplane/internal/payloadtest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRoundTrip(t *testing.T) {
+	t.Parallel()
+	payloadtest.RoundTrip(t, "..", payloadtest.Codec{Encode: Encode, Decode: Decode})
+}
+
+func TestDecode_unsupportedErrorVersion(t *testing.T) {
+	t.Parallel()
+	in := "[[config]]\nfile = \"nginx.conf\"\n[[config.errors]]\nerror = \"oops\"\n[config.errors.parseError]\nversion = 1\nwhat = \"oops\"\n"
+	_, err := Decode(strings.NewReader(in))
+	require.EqualError(t, err, "unsupported error format version 1, expected 2")
+}
@@ -0,0 +1,58 @@
+/**
+ * Copyright (c) F5, Inc.
+ *
+ * This source code is licensed under the Apache License, Version 2.0 license found in the
+ * LICENSE file in the root directory of this source tree.
+ */
+
+package crossplaneyaml
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/nginxinc/nginx-go-crossplane"
+	"gopkg.in/yaml.v3"
+)
+
+// LoadDirectives reads the definitions of directives in YAML from r, and returns a
+// MatchFunc for them like crossplane.LoadDirectives does for their JSON:
+//
+//	directives:
+//	  my_directive: [ngxHTTPMainConf|ngxHTTPSrvConf|ngxConfTake1]
+func LoadDirectives(r io.Reader) (crossplane.MatchFunc, error) {
+	var defs struct {
+		Directives map[string][]string `yaml:"directives" json:"directives"`
+	}
+	dec := yaml.NewDecoder(r)
+	dec.KnownFields(true)
+	if err := dec.Decode(&defs); err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+
+	b, err := json.Marshal(defs)
+	if err != nil {
+		return nil, err
+	}
+	return crossplane.LoadDirectives(bytes.NewReader(b))
+}
+
+// LoadDirectivesFile reads the definitions of directives from a YAML file, like
+// LoadDirectives.
+func LoadDirectivesFile(path string) (crossplane.MatchFunc, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	match, err := LoadDirectives(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return match, nil
+}
@@ -0,0 +1,79 @@
+/**
+ * Copyright (c) F5, Inc.
+ *
+ * This source code is licensed under the Apache License, Version 2.0 license found in the
+ * LICENSE file in the root directory of this source tree.
+ */
+
+package crossplaneyaml
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/nginxinc/nginx-go-crossplane"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadDirectives(t *testing.T) {
+	t.Parallel()
+	tcs := map[string]struct {
+		defs    string
+		json    string
+		wantErr string
+	}{
+		"yaml": {
+			defs: "directives:\n  my_block:\n    - ngxHTTPLocConf|ngxConfBlock|ngxConfNoArgs\n  my_any:\n    - ngxAnyConf|ngxConfTake7\n",
+			json: `{"directives": {"my_block": ["ngxHTTPLocConf|ngxConfBlock|ngxConfNoArgs"], "my_any": ["ngxAnyConf|ngxConfTake7"]}}`,
+		},
+		"empty": {
+			defs:    "",
+			wantErr: "no directives are defined",
+		},
+		"unknown field": {
+			defs:    "directive:\n  my_directive: [ngxHTTPMainConf|ngxConfTake1]\n",
+			wantErr: "line 1: field directive not found",
+		},
+		"unknown bitmask": {
+			defs:    "directives:\n  my_directive: [ngxHTTPMainConf|NGX_CONF_TAKE1]\n",
+			wantErr: `directive "my_directive": unknown bitmask "NGX_CONF_TAKE1" in "ngxHTTPMainConf|NGX_CONF_TAKE1"`,
+		},
+	}
+
+	for name, tc := range tcs {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			match, err := LoadDirectives(strings.NewReader(tc.defs))
+			if tc.wantErr != "" {
+				require.ErrorContains(t, err, tc.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			want, err := crossplane.LoadDirectives(strings.NewReader(tc.json))
+			require.NoError(t, err)
+			for _, directive := range []string{"my_block", "my_any"} {
+				masks, ok := match(directive)
+				require.True(t, ok)
+				wantMasks, _ := want(directive)
+				require.Equal(t, wantMasks, masks)
+			}
+			_, ok := match("unknown")
+			require.False(t, ok)
+		})
+	}
+}
+
+func TestLoadDirectivesFile(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	_, err := LoadDirectivesFile(filepath.Join(dir, "missing.yaml"))
+	require.Error(t, err)
+
+	bad := filepath.Join(dir, "bad.yaml")
+	require.NoError(t, os.WriteFile(bad, []byte("directives: [\n"), 0o600))
+	_, err = LoadDirectivesFile(bad)
+	require.ErrorContains(t, err, bad+": yaml:")
+}
@@ -0,0 +1,307 @@
+/**
+ * Copyright (c) F5, Inc.
+ *
+ * This source code is licensed under the Apache License, Version 2.0 license found in the
+ * LICENSE file in the root directory of this source tree.
+ */
+
+// Package crossplaneyaml provides the YAML encoding of crossplane payloads.
+//
+// The YAML encoding of a Payload is a compact, human-editable form of the JSON encoding.
+// Every directive is a mapping whose first key is the directive name and whose value is
+// the list of arguments, followed by the optional keys line, file, includes,
+// mapBlockParameter and block. Comments use "#" as the key and the comment as the value:
+//
+//	parsed:
+//	  - "#": " main config"
+//	    line: 1
+//	  - http: []
+//	    line: 2
+//	    block:
+//	      - server_tokens: ["off"]
+//	        line: 3
+package crossplaneyaml
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/nginxinc/nginx-go-crossplane"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	yamlKeyLine     = "line"
+	yamlKeyFile     = "file"
+	yamlKeyIncludes = "includes"
+	yamlKeyMapParam = "mapBlockParameter"
+	yamlKeyBlock    = "block"
+)
+
+type yamlPayload struct {
+	Status string       `yaml:"status"`
+	Errors []yamlError  `yaml:"errors"`
+	Config []yamlConfig `yaml:"config"`
+}
+
+type yamlError struct {
+	File       string                       `yaml:"file,omitempty"`
+	Line       *int                         `yaml:"line"`
+	Error      *string                      `yaml:"error"`
+	Callback   interface{}                  `yaml:"callback,omitempty"`
+	ParseError *crossplane.ParseErrorDetail `yaml:"parseError,omitempty"`
+}
+
+type yamlConfig struct {
+	File   string         `yaml:"file"`
+	Status string         `yaml:"status"`
+	Errors []yamlError    `yaml:"errors"`
+	Parsed yamlDirectives `yaml:"parsed"`
+}
+
+type yamlDirectives crossplane.Directives
+
+// Encode writes the YAML encoding of payload to w.
+func Encode(w io.Writer, payload *crossplane.Payload) error {
+	yp := yamlPayload{
+		Status: payload.Status,
+		Errors: make([]yamlError, 0, len(payload.Errors)),
+		Config: make([]yamlConfig, 0, len(payload.Config)),
+	}
+	for _, e := range payload.Errors {
+		yp.Errors = append(yp.Errors, yamlError{
+			File:       e.File,
+			Line:       e.Line,
+			Error:      crossplane.ErrorMessage(e.Error),
+			Callback:   e.Callback,
+			ParseError: crossplane.NewParseErrorDetail(e.Error),
+		})
+	}
+	for _, c := range payload.Config {
+		yc := yamlConfig{
+			File:   c.File,
+			Status: c.Status,
+			Errors: make([]yamlError, 0, len(c.Errors)),
+			Parsed: yamlDirectives(c.Parsed),
+		}
+		for _, e := range c.Errors {
+			yc.Errors = append(yc.Errors, yamlError{
+				Line:       e.Line,
+				Error:      crossplane.ErrorMessage(e.Error),
+				ParseError: crossplane.NewParseErrorDetail(e.Error),
+			})
+		}
+		yp.Config = append(yp.Config, yc)
+	}
+
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2) //nolint:mnd
+	if err := enc.Encode(yp); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+// Decode reads a payload in the YAML encoding written by Encode from r.
+func Decode(r io.Reader) (*crossplane.Payload, error) {
+	var yp yamlPayload
+	if err := yaml.NewDecoder(r).Decode(&yp); err != nil {
+		return nil, err
+	}
+
+	payload := &crossplane.Payload{
+		Status: yp.Status,
+		Errors: make([]crossplane.PayloadError, 0, len(yp.Errors)),
+		Config: make([]crossplane.Config, 0, len(yp.Config)),
+	}
+	for _, e := range yp.Errors {
+		if err := e.ParseError.CheckVersion(); err != nil {
+			return nil, err
+		}
+		payload.Errors = append(payload.Errors, crossplane.PayloadError{
+			File:     e.File,
+			Line:     e.Line,
+			Error:    crossplane.ErrorFromMessage(e.Error, e.ParseError),
+			Callback: e.Callback,
+		})
+	}
+	for _, yc := range yp.Config {
+		c := crossplane.Config{
+			File:   yc.File,
+			Status: yc.Status,
+			Errors: make([]crossplane.ConfigError, 0, len(yc.Errors)),
+			Parsed: crossplane.Directives(yc.Parsed),
+		}
+		for _, e := range yc.Errors {
+			if err := e.ParseError.CheckVersion(); err != nil {
+				return nil, err
+			}
+			c.Errors = append(c.Errors, crossplane.ConfigError{
+				Line:  e.Line,
+				Error: crossplane.ErrorFromMessage(e.Error, e.ParseError),
+			})
+		}
+		if c.Parsed == nil {
+			c.Parsed = crossplane.Directives{}
+		}
+		payload.Config = append(payload.Config, c)
+	}
+	return payload, nil
+}
+
+// MarshalYAML makes this a yaml.Marshaler.
+func (ds yamlDirectives) MarshalYAML() (interface{}, error) {
+	return yamlBlockNode(crossplane.Directives(ds)), nil
+}
+
+// UnmarshalYAML makes this a yaml.Unmarshaler.
+func (ds *yamlDirectives) UnmarshalYAML(node *yaml.Node) error {
+	block, err := directivesFromYAML(node)
+	if err != nil {
+		return err
+	}
+	*ds = yamlDirectives(block)
+	return nil
+}
+
+func yamlScalar(tag, value string) *yaml.Node {
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: tag, Value: value}
+}
+
+func yamlStrings(ss []string) *yaml.Node {
+	seq := &yaml.Node{Kind: yaml.SequenceNode, Style: yaml.FlowStyle}
+	for _, s := range ss {
+		seq.Content = append(seq.Content, yamlScalar("!!str", s))
+	}
+	return seq
+}
+
+func yamlBlockNode(block crossplane.Directives) *yaml.Node {
+	seq := &yaml.Node{Kind: yaml.SequenceNode}
+	for _, stmt := range block {
+		seq.Content = append(seq.Content, yamlDirectiveNode(stmt))
+	}
+	return seq
+}
+
+func yamlDirectiveNode(stmt *crossplane.Directive) *yaml.Node {
+	m := &yaml.Node{Kind: yaml.MappingNode}
+	add := func(key string, value *yaml.Node) {
+		m.Content = append(m.Content, yamlScalar("!!str", key), value)
+	}
+
+	if stmt.IsComment() {
+		add(stmt.Directive, yamlScalar("!!str", *stmt.Comment))
+	} else {
+		add(stmt.Directive, yamlStrings(stmt.Args))
+	}
+	if stmt.Line != 0 {
+		add(yamlKeyLine, yamlScalar("!!int", strconv.Itoa(stmt.Line)))
+	}
+	if stmt.File != "" {
+		add(yamlKeyFile, yamlScalar("!!str", stmt.File))
+	}
+	if stmt.Includes != nil {
+		includes := &yaml.Node{Kind: yaml.SequenceNode, Style: yaml.FlowStyle}
+		for _, idx := range stmt.Includes {
+			includes.Content = append(includes.Content, yamlScalar("!!int", strconv.Itoa(idx)))
+		}
+		add(yamlKeyIncludes, includes)
+	}
+	if stmt.IsMapBlockParameter {
+		add(yamlKeyMapParam, yamlScalar("!!bool", "true"))
+	}
+	if stmt.IsBlock() {
+		block := yamlBlockNode(stmt.Block)
+		if len(stmt.Block) == 0 {
+			block.Style = yaml.FlowStyle
+		}
+		add(yamlKeyBlock, block)
+	}
+	return m
+}
+
+func yamlNodeError(node *yaml.Node, what string) error {
+	return fmt.Errorf("yaml: line %d: %s", node.Line, what)
+}
+
+func directivesFromYAML(node *yaml.Node) (crossplane.Directives, error) {
+	if node.Kind != yaml.SequenceNode {
+		if node.Tag == "!!null" {
+			return nil, nil
+		}
+		return nil, yamlNodeError(node, "expected a list of directives")
+	}
+	block := make(crossplane.Directives, 0, len(node.Content))
+	for _, n := range node.Content {
+		stmt, err := directiveFromYAML(n)
+		if err != nil {
+			return nil, err
+		}
+		block = append(block, stmt)
+	}
+	return block, nil
+}
+
+//nolint:gocognit,gocyclo,funlen
+func directiveFromYAML(node *yaml.Node) (*crossplane.Directive, error) {
+	if node.Kind != yaml.MappingNode || len(node.Content) == 0 {
+		return nil, yamlNodeError(node, "expected a directive mapping")
+	}
+
+	// the first key is always the directive name, so a directive named like one of the
+	// optional keys (e.g. "block") is still read correctly
+	name, value := node.Content[0], node.Content[1]
+	stmt := &crossplane.Directive{Directive: name.Value, Args: []string{}}
+	switch {
+	case value.Kind == yaml.ScalarNode && name.Value == "#":
+		comment := value.Value
+		stmt.Comment = &comment
+	case value.Kind == yaml.SequenceNode:
+		for _, arg := range value.Content {
+			if arg.Kind != yaml.ScalarNode {
+				return nil, yamlNodeError(arg, fmt.Sprintf(`arguments of "%s" must be scalars`, name.Value))
+			}
+			stmt.Args = append(stmt.Args, arg.Value)
+		}
+	case value.Kind == yaml.ScalarNode && value.Tag == "!!null":
+	default:
+		return nil, yamlNodeError(value, fmt.Sprintf(`arguments of "%s" must be a list`, name.Value))
+	}
+
+	for i := 2; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		switch key.Value {
+		case yamlKeyLine:
+			if err := value.Decode(&stmt.Line); err != nil {
+				return nil, err
+			}
+		case yamlKeyFile:
+			if err := value.Decode(&stmt.File); err != nil {
+				return nil, err
+			}
+		case yamlKeyIncludes:
+			if err := value.Decode(&stmt.Includes); err != nil {
+				return nil, err
+			}
+		case yamlKeyMapParam:
+			if err := value.Decode(&stmt.IsMapBlockParameter); err != nil {
+				return nil, err
+			}
+		case yamlKeyBlock:
+			block, err := directivesFromYAML(value)
+			if err != nil {
+				return nil, err
+			}
+			if block == nil {
+				block = crossplane.Directives{}
+			}
+			stmt.Block = block
+		default:
+			return nil, yamlNodeError(key, fmt.Sprintf(`unknown key "%s" in directive "%s"`, key.Value, name.Value))
+		}
+	}
+
+	return stmt, nil
+}
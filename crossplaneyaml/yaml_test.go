@@ -0,0 +1,100 @@
+/**
+ * Copyright (c) F5, Inc.
+ *
+ * This source code is licensed under the Apache License, Version 2.0 license found in the
+ * LICENSE file in the root directory of this source tree.
+ */
+
+package crossplaneyaml
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/nginxinc/nginx-go-crossplane"
+	"github.com/nginxinc/nginx-go-crossplane/internal/payloadtest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRoundTrip(t *testing.T) {
+	t.Parallel()
+	payloadtest.RoundTrip(t, "..", payloadtest.Codec{Encode: Encode, Decode: Decode})
+}
+
+func TestDecode(t *testing.T) {
+	t.Parallel()
+	in := `
+config:
+  - file: nginx.conf
+    parsed:
+      - "#": " generated"
+        line: 1
+      - user: [nginx]
+      - http:
+        block:
+          - server: []
+            block:
+              - listen: [80]
+              - block: [on]
+              - "#": " the block above is a directive named block"
+                line: 7
+          - map: [$host, $name]
+            block:
+              - default: ["0"]
+                mapBlockParameter: true
+`
+	payload, err := Decode(strings.NewReader(in))
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, crossplane.Build(&buf, payload.Config[0], &crossplane.BuildOptions{}))
+	require.Equal(t, strings.Join([]string{
+		"# generated",
+		"user nginx;",
+		"http {",
+		"    server {",
+		"        listen 80;",
+		"        block on;",
+		"        # the block above is a directive named block",
+		"    }",
+		"    map $host $name {",
+		"        default 0;",
+		"    }",
+		"}",
+	}, "\n"), buf.String())
+	require.True(t, payload.Config[0].Parsed[2].Block[1].Block[0].IsMapBlockParameter)
+}
+
+func TestDecode_errors(t *testing.T) {
+	t.Parallel()
+	tcs := map[string]struct {
+		in  string
+		err string
+	}{
+		"non-list block": {
+			in:  "config:\n  - file: nginx.conf\n    parsed:\n      - http: []\n        block: {server: []}\n",
+			err: "yaml: line 5: expected a list of directives",
+		},
+		"unknown key": {
+			in:  "config:\n  - file: nginx.conf\n    parsed:\n      - listen: [80]\n        lines: 1\n",
+			err: `yaml: line 5: unknown key "lines" in directive "listen"`,
+		},
+		"nested args": {
+			in:  "config:\n  - file: nginx.conf\n    parsed:\n      - listen: [[80]]\n",
+			err: `yaml: line 4: arguments of "listen" must be scalars`,
+		},
+		"unsupported error version": {
+			in:  "errors:\n  - file: nginx.conf\n    error: oops\n    parseError:\n      version: 3\n      what: oops\nconfig: []\n",
+			err: "unsupported error format version 3, expected 2",
+		},
+	}
+	for name, tc := range tcs {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			_, err := Decode(strings.NewReader(tc.in))
+			require.EqualError(t, err, tc.err)
+		})
+	}
+}
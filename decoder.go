@@ -0,0 +1,339 @@
+/**
+ * Copyright (c) F5, Inc.
+ *
+ * This source code is licensed under the Apache License, Version 2.0 license found in the
+ * LICENSE file in the root directory of this source tree.
+ */
+
+package crossplane
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+)
+
+// EventKind is the kind of an Event read by a Decoder.
+type EventKind int
+
+const (
+	// DirectiveEvent is a directive without a block.
+	DirectiveEvent EventKind = iota + 1
+	// BeginBlockEvent is a directive with a block. The events of the directives in
+	// the block follow, up to the matching EndBlockEvent.
+	BeginBlockEvent
+	// EndBlockEvent ends the block of the last BeginBlockEvent that wasn't ended.
+	EndBlockEvent
+	// CommentEvent is a comment, read when ParseOptions.ParseComments is true.
+	CommentEvent
+	// IncludeEvent is an include directive along with the files it includes.
+	IncludeEvent
+	// ErrorEvent is an error that Parse would add to the Payload.
+	ErrorEvent
+)
+
+func (k EventKind) String() string {
+	switch k {
+	case DirectiveEvent:
+		return "directive"
+	case BeginBlockEvent:
+		return "begin block"
+	case EndBlockEvent:
+		return "end block"
+	case CommentEvent:
+		return "comment"
+	case IncludeEvent:
+		return "include"
+	case ErrorEvent:
+		return "error"
+	}
+	return fmt.Sprintf("EventKind(%d)", int(k))
+}
+
+// Event is a part of an NGINX config read by a Decoder.
+type Event struct {
+	Kind EventKind
+
+	// Directive is the directive of the event, without the directives of its block.
+	// For an EndBlockEvent it is the directive of the block that ends. It's nil for
+	// an ErrorEvent.
+	Directive *Directive
+
+	// File is the config file the event was read from.
+	File string
+
+	// Context is the block context of the directive, e.g. ["http", "server"].
+	Context []string
+
+	// Includes are the files matched by the pattern of an include directive, which
+	// are read after the file being read.
+	Includes []string
+
+	// Error is the error of an ErrorEvent.
+	Error error
+}
+
+// Decoder reads an NGINX config as a stream of events, in the same order as the
+// directives of the Payload that Parse returns, without keeping them in memory.
+// Directives are checked like they are by Parse, and the errors that Parse adds to
+// the Payload are read as ErrorEvents. If one of them stops the parse of a file, the
+// blocks left open are ended and the events of the next file follow.
+//
+// The events of every file come after the ones of the file that includes it, as
+// the configs of a Payload do. ParseOptions.CombineConfigs, Concurrency and
+// ErrorCallback have no effect on a Decoder.
+type Decoder struct {
+	p      parser
+	next   int
+	file   *decoderFile
+	events []Event
+	head   int
+	err    error
+}
+
+// decoderFile is the file a Decoder is reading.
+type decoderFile struct {
+	parsedFile
+	closer io.Closer
+	tokens *Tokenizer
+	frames []decoderFrame
+}
+
+// decoderFrame is what's left of a call to parser.parse when a Decoder reads a
+// token: the file, or a block in it.
+type decoderFrame struct {
+	ctx     blockCtx
+	consume bool
+	// stmt is the directive of the block, and comments the ones found in its args
+	stmt     *Directive
+	comments []string
+}
+
+// NewDecoder returns a Decoder that reads the NGINX config file filename and the
+// files it includes. ParseOptions.UseArena is ignored since the directives of the
+// events are owned by the caller.
+func NewDecoder(filename string, options *ParseOptions) *Decoder {
+	if options.Glob == nil {
+		options.Glob = filepath.Glob
+	}
+	return &Decoder{
+		p: parser{
+			configDir:       filepath.Dir(filename),
+			options:         options,
+			includes:        []fileCtx{{path: filename, ctx: blockCtx{}}},
+			included:        map[string]int{filename: 0},
+			includeEdges:    map[string][]string{},
+			includeInDegree: map[string]int{filename: 0},
+		},
+	}
+}
+
+// Token returns the next event of the config. It returns io.EOF once all of the
+// files have been read, or the error that Parse would return, and keeps on
+// returning it after that.
+func (d *Decoder) Token() (Event, error) {
+	for d.head == len(d.events) {
+		if d.err != nil {
+			return Event{}, d.err
+		}
+		d.events, d.head = d.events[:0], 0
+		d.step()
+	}
+	ev := d.events[d.head]
+	d.head++
+	return ev, nil
+}
+
+// Close closes the file being read. Token returns io.EOF after that.
+func (d *Decoder) Close() error {
+	d.events, d.head = d.events[:0], 0
+	if d.err == nil {
+		d.err = io.EOF
+	}
+	if d.file == nil {
+		return nil
+	}
+	err := d.file.closer.Close()
+	d.file = nil
+	return err
+}
+
+func (d *Decoder) step() {
+	if d.file != nil {
+		d.stepFile()
+		return
+	}
+
+	if d.next == len(d.p.includes) {
+		d.err = io.EOF
+		if d.p.isAcyclic() {
+			d.err = errors.New("configs contain include cycle")
+		}
+		return
+	}
+
+	if d.next == 0 {
+		d.p.autoDirectiveSources(d.p.includes[0].path)
+	}
+	incl := d.p.includes[d.next]
+	d.next++
+	file, err := d.p.openFile(incl.path)
+	if err != nil {
+		d.err = err
+		return
+	}
+	d.file = &decoderFile{
+		parsedFile: parsedFile{Config: Config{File: incl.path}},
+		closer:     file,
+		tokens:     NewTokenizer(file, d.p.options.LexOptions),
+		frames:     []decoderFrame{{ctx: incl.ctx}},
+	}
+	if d.p.options.InternStrings {
+		d.file.interned = map[string]string{}
+	}
+}
+
+func (d *Decoder) emit(kind EventKind, stmt *Directive, ctx blockCtx) {
+	d.events = append(d.events, Event{
+		Kind:      kind,
+		Directive: stmt,
+		File:      d.file.File,
+		Context:   append([]string{}, ctx...),
+	})
+}
+
+func (d *Decoder) emitError(err error) {
+	d.events = append(d.events, Event{Kind: ErrorEvent, File: d.file.File, Error: err})
+}
+
+// push starts reading a block, or consuming one if stmt is nil.
+func (d *Decoder) push(ctx blockCtx, stmt *Directive, comments []string) {
+	d.file.frames = append(d.file.frames, decoderFrame{ctx: ctx, consume: stmt == nil, stmt: stmt, comments: comments})
+}
+
+// pop ends the block or the file being read.
+func (d *Decoder) pop() {
+	f := d.file
+	frame := f.frames[len(f.frames)-1]
+	f.frames = f.frames[:len(f.frames)-1]
+	if len(f.frames) == 0 {
+		_ = f.closer.Close()
+		d.file = nil
+		return
+	}
+	if frame.consume {
+		return
+	}
+
+	parent := f.frames[len(f.frames)-1]
+	d.emit(EndBlockEvent, frame.stmt, parent.ctx)
+	d.emitComments(frame.stmt, parent.ctx, frame.comments)
+}
+
+// emitComments emits the comments found inside the args of stmt.
+func (d *Decoder) emitComments(stmt *Directive, ctx blockCtx, comments []string) {
+	for _, comment := range d.p.argComments(&d.file.parsedFile, stmt, comments) {
+		d.emit(CommentEvent, comment, ctx)
+	}
+}
+
+// fail handles an error that stops the parse of the file being read.
+func (d *Decoder) fail(err error) {
+	if d.p.options.StopParsingOnError {
+		_ = d.file.closer.Close()
+		d.file = nil
+		d.err = err
+		return
+	}
+	d.emitError(err)
+	for d.file != nil {
+		d.file.frames[len(d.file.frames)-1].comments = nil
+		d.pop()
+	}
+}
+
+// stepFile reads a directive of the file being read, like an iteration of the loop
+// of parser.parse does.
+func (d *Decoder) stepFile() {
+	f := d.file
+	top := f.frames[len(f.frames)-1]
+	ctx := top.ctx
+
+	t, tokenOk := f.tokens.Next()
+	if !tokenOk {
+		d.pop()
+		return
+	}
+
+	if t.Error != nil {
+		if top.consume {
+			// errors of consumed blocks are dropped, and there are no tokens left
+			d.pop()
+			return
+		}
+		d.fail(f.tokenError(t, ctx))
+		return
+	}
+
+	// we are reading a block, so end it if it's closing
+	if t.Value == "}" && !t.IsQuoted {
+		d.pop()
+		return
+	}
+
+	// if we are consuming, then just continue until end of context
+	if top.consume {
+		// if we find a block inside this context, consume it too
+		if t.Value == "{" && !t.IsQuoted {
+			d.push(nil, nil, nil)
+		}
+		return
+	}
+
+	s, err := d.p.readStmt(&f.parsedFile, f.tokens, t, ctx)
+	for _, err := range f.errs {
+		d.emitError(err)
+	}
+	f.errs = f.errs[:0]
+	if err != nil {
+		d.fail(err)
+		return
+	}
+	switch s.action {
+	case stmtSkip:
+		return
+	case stmtConsume:
+		d.push(nil, nil, nil)
+		return
+	case stmtEndBlock:
+		d.pop()
+		return
+	case stmtAdd:
+	}
+
+	stmt := s.directive
+	kind := DirectiveEvent
+	var includes []string
+	switch {
+	case stmt.Comment != nil:
+		kind = CommentEvent
+	case len(f.includes) > 0:
+		kind = IncludeEvent
+		includes = f.includes[0].fnames
+		d.p.addIncludes(&f.parsedFile)
+		f.includes, f.found = f.includes[:0], f.found[:0]
+	}
+
+	// if this statement terminated with "{" then it is a block
+	if s.block {
+		stmt.Block = make(Directives, 0)
+		d.emit(BeginBlockEvent, stmt, ctx)
+		d.push(enterBlockCtx(stmt, ctx), stmt, s.comments)
+		return
+	}
+
+	d.emit(kind, stmt, ctx)
+	d.events[len(d.events)-1].Includes = includes
+	d.emitComments(stmt, ctx, s.comments)
+}
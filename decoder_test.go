@@ -0,0 +1,244 @@
+/**
+ * Copyright (c) F5, Inc.
+ *
+ * This source code is licensed under the Apache License, Version 2.0 license found in the
+ * LICENSE file in the root directory of this source tree.
+ */
+
+package crossplane
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// decodeConfigs reads all of the events of d into the configs they come from.
+func decodeConfigs(t *testing.T, d *Decoder) map[string]*Config {
+	t.Helper()
+	configs := map[string]*Config{}
+	var blocks []*Directives
+	for {
+		ev, err := d.Token()
+		if errors.Is(err, io.EOF) {
+			require.LessOrEqual(t, len(blocks), 1)
+			return configs
+		}
+		require.NoError(t, err)
+
+		config, ok := configs[ev.File]
+		if !ok {
+			// the events of a file come one after the other
+			require.LessOrEqual(t, len(blocks), 1)
+			config = &Config{File: ev.File, Parsed: Directives{}}
+			configs[ev.File] = config
+			blocks = []*Directives{&config.Parsed}
+		}
+		block := blocks[len(blocks)-1]
+
+		switch ev.Kind {
+		case DirectiveEvent, IncludeEvent, CommentEvent:
+			*block = append(*block, ev.Directive)
+		case BeginBlockEvent:
+			*block = append(*block, ev.Directive)
+			blocks = append(blocks, &ev.Directive.Block)
+		case EndBlockEvent:
+			blocks = blocks[:len(blocks)-1]
+		case ErrorEvent:
+			var line *int
+			var perr *ParseError
+			if errors.As(ev.Error, &perr) {
+				line = perr.Line
+			}
+			config.Errors = append(config.Errors, ConfigError{Line: line, Error: ev.Error})
+		}
+	}
+}
+
+func TestDecoder_fixtures(t *testing.T) {
+	t.Parallel()
+	forEachFixture(t, func(t *testing.T, fixture parseFixture, path string, expected *Payload) {
+		options := fixture.options
+		if options.CombineConfigs {
+			t.Skip("a Decoder doesn't combine configs")
+		}
+		configs := decodeConfigs(t, NewDecoder(path, &options))
+		for _, c := range expected.Config {
+			config, ok := configs[c.File]
+			if !ok {
+				config = &Config{File: c.File, Parsed: Directives{}}
+			}
+			require.Equal(t, len(c.Errors), len(config.Errors), c.File)
+			for i, e := range c.Errors {
+				require.Equal(t, e.Line, config.Errors[i].Line)
+				require.EqualError(t, config.Errors[i].Error, e.Error.Error())
+			}
+			if len(c.Parsed) == 0 && len(c.Errors) > 0 {
+				// the directives read before an error that stopped the parse are dropped by Parse
+				continue
+			}
+			require.Equal(t, c.Parsed, config.Parsed, c.File)
+		}
+	})
+}
+
+func TestDecoder(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	write := func(name, conf string) string {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, []byte(conf), 0o600))
+		return path
+	}
+	main := write("nginx.conf", strings.Join([]string{
+		"events {}",
+		"http { # http",
+		"    include sites/*.conf;",
+		"    map $host $name {",
+		"        default 0;",
+		"    }",
+		"    server_name_in_redirect maybe;",
+		"}",
+		"stream # stream",
+		"{}",
+	}, "\n"))
+	site := write("sites/a.conf", "server {\n    location / {\n        return 200\n")
+
+	type event struct {
+		kind    EventKind
+		file    string
+		line    int
+		ctx     []string
+		value   string
+		include []string
+	}
+	var events []event
+	d := NewDecoder(main, &ParseOptions{ParseComments: true})
+	for {
+		ev, err := d.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		require.NoError(t, err)
+		e := event{kind: ev.Kind, file: ev.File, ctx: ev.Context, include: ev.Includes}
+		switch {
+		case ev.Kind == ErrorEvent:
+			e.value = ev.Error.Error()
+		case ev.Directive.IsComment():
+			e.line = ev.Directive.Line
+			e.value = *ev.Directive.Comment
+		default:
+			e.line = ev.Directive.Line
+			e.value = strings.TrimSpace(ev.Directive.String())
+		}
+		events = append(events, e)
+	}
+
+	require.Equal(t, []event{
+		{kind: BeginBlockEvent, file: main, line: 1, ctx: []string{}, value: "events"},
+		{kind: EndBlockEvent, file: main, line: 1, ctx: []string{}, value: "events"},
+		{kind: BeginBlockEvent, file: main, line: 2, ctx: []string{}, value: "http"},
+		{kind: CommentEvent, file: main, line: 2, ctx: []string{"http"}, value: " http"},
+		{kind: IncludeEvent, file: main, line: 3, ctx: []string{"http"}, value: "include sites/*.conf", include: []string{site}},
+		{kind: BeginBlockEvent, file: main, line: 4, ctx: []string{"http"}, value: "map $host $name"},
+		{kind: DirectiveEvent, file: main, line: 5, ctx: []string{"http", "map"}, value: "default 0"},
+		{kind: EndBlockEvent, file: main, line: 4, ctx: []string{"http"}, value: "map $host $name"},
+		{kind: ErrorEvent, file: main, value: fmt.Sprintf(
+			`invalid value "maybe" in "server_name_in_redirect" directive, it must be "on" or "off" in %s:7`, main)},
+		{kind: EndBlockEvent, file: main, line: 2, ctx: []string{}, value: "http"},
+		{kind: BeginBlockEvent, file: main, line: 9, ctx: []string{}, value: "stream"},
+		{kind: EndBlockEvent, file: main, line: 9, ctx: []string{}, value: "stream"},
+		{kind: CommentEvent, file: main, line: 9, ctx: []string{}, value: " stream"},
+		{kind: BeginBlockEvent, file: site, line: 1, ctx: []string{"http"}, value: "server"},
+		{kind: BeginBlockEvent, file: site, line: 2, ctx: []string{"http", "server"}, value: "location /"},
+		{kind: ErrorEvent, file: site, value: fmt.Sprintf(`premature end of file in %s:3`, site)},
+		{kind: EndBlockEvent, file: site, line: 2, ctx: []string{"http", "server"}, value: "location /"},
+		{kind: EndBlockEvent, file: site, line: 1, ctx: []string{"http"}, value: "server"},
+	}, events)
+}
+
+func TestDecoder_stopParsingOnError(t *testing.T) {
+	t.Parallel()
+	path := getTestConfigPath("includes-regular", "nginx.conf")
+	_, expected := Parse(path, &ParseOptions{StopParsingOnError: true})
+	require.Error(t, expected)
+
+	d := NewDecoder(path, &ParseOptions{StopParsingOnError: true})
+	var err error
+	for err == nil {
+		var ev Event
+		ev, err = d.Token()
+		require.NotEqual(t, ErrorEvent, ev.Kind)
+	}
+	require.Equal(t, expected, err)
+	_, err = d.Token()
+	require.Equal(t, expected, err)
+}
+
+// mapConfig is a config with a map block of n entries, generated as it's read.
+type mapConfig struct {
+	n, i int
+	buf  []byte
+}
+
+func (m *mapConfig) Read(p []byte) (int, error) {
+	for len(m.buf) < len(p) && m.i <= m.n {
+		switch {
+		case m.i == 0:
+			m.buf = append(m.buf, "http {\n    map $host $name {\n"...)
+		case m.i == m.n:
+			m.buf = append(m.buf, "    }\n}\n"...)
+		default:
+			m.buf = append(m.buf, fmt.Sprintf("        host%d.example.com name%d;\n", m.i, m.i)...)
+		}
+		m.i++
+	}
+	if len(m.buf) == 0 {
+		return 0, io.EOF
+	}
+	n := copy(p, m.buf)
+	m.buf = m.buf[:copy(m.buf, m.buf[n:])]
+	return n, nil
+}
+
+func (m *mapConfig) Close() error { return nil }
+
+//nolint:paralleltest // measures the heap
+func TestDecoder_memory(t *testing.T) {
+	const entries = 1_000_000
+	d := NewDecoder("nginx.conf", &ParseOptions{
+		Open: func(string) (io.ReadCloser, error) { return &mapConfig{n: entries}, nil },
+	})
+
+	var stats runtime.MemStats
+	var maxHeap uint64
+	params := 0
+	for {
+		ev, err := d.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		require.NoError(t, err)
+		if ev.Kind == DirectiveEvent && ev.Directive.IsMapBlockParameter {
+			params++
+		}
+		if params%100_000 == 1 {
+			runtime.GC()
+			runtime.ReadMemStats(&stats)
+			if stats.HeapAlloc > maxHeap {
+				maxHeap = stats.HeapAlloc
+			}
+		}
+	}
+	require.Equal(t, entries-1, params)
+	// the config is over 40MB
+	require.Less(t, maxHeap, uint64(16<<20))
+}
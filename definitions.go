@@ -0,0 +1,149 @@
+/**
+ * Copyright (c) F5, Inc.
+ *
+ * This source code is licensed under the Apache License, Version 2.0 license found in the
+ * LICENSE file in the root directory of this source tree.
+ */
+
+package crossplane
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// maskNames are the bitmasks by the names the generator uses for them.
+//
+//nolint:gochecknoglobals
+var maskNames = map[string]uint{
+	"ngxConfNoArgs":     ngxConfNoArgs,
+	"ngxConfTake1":      ngxConfTake1,
+	"ngxConfTake2":      ngxConfTake2,
+	"ngxConfTake3":      ngxConfTake3,
+	"ngxConfTake4":      ngxConfTake4,
+	"ngxConfTake5":      ngxConfTake5,
+	"ngxConfTake6":      ngxConfTake6,
+	"ngxConfTake7":      ngxConfTake7,
+	"ngxConfBlock":      ngxConfBlock,
+	"ngxConfExpr":       ngxConfExpr,
+	"ngxConfFlag":       ngxConfFlag,
+	"ngxConfAny":        ngxConfAny,
+	"ngxConf1More":      ngxConf1More,
+	"ngxConf2More":      ngxConf2More,
+	"ngxConfTake12":     ngxConfTake12,
+	"ngxConfTake13":     ngxConfTake13,
+	"ngxConfTake23":     ngxConfTake23,
+	"ngxConfTake34":     ngxConfTake34,
+	"ngxConfTake123":    ngxConfTake123,
+	"ngxConfTake1234":   ngxConfTake1234,
+	"ngxDirectConf":     ngxDirectConf,
+	"ngxMgmtMainConf":   ngxMgmtMainConf,
+	"ngxMainConf":       ngxMainConf,
+	"ngxEventConf":      ngxEventConf,
+	"ngxMailMainConf":   ngxMailMainConf,
+	"ngxMailSrvConf":    ngxMailSrvConf,
+	"ngxStreamMainConf": ngxStreamMainConf,
+	"ngxStreamSrvConf":  ngxStreamSrvConf,
+	"ngxStreamUpsConf":  ngxStreamUpsConf,
+	"ngxHTTPMainConf":   ngxHTTPMainConf,
+	"ngxHTTPSrvConf":    ngxHTTPSrvConf,
+	"ngxHTTPLocConf":    ngxHTTPLocConf,
+	"ngxHTTPUpsConf":    ngxHTTPUpsConf,
+	"ngxHTTPSifConf":    ngxHTTPSifConf,
+	"ngxHTTPLifConf":    ngxHTTPLifConf,
+	"ngxHTTPLmtConf":    ngxHTTPLmtConf,
+	"ngxHTTPOIDCConf":   ngxHTTPOIDCConf,
+	"ngxAnyConf":        ngxAnyConf,
+}
+
+// directiveDefinitions is the JSON format of the definitions of directives.
+// Every directive has a list of bitmasks, written with the names of the generated
+// directive tables joined by "|":
+//
+//	{
+//	  "directives": {
+//	    "my_directive": ["ngxHTTPMainConf|ngxHTTPSrvConf|ngxConfTake1"],
+//	    "my_block": ["ngxHTTPLocConf|ngxConfBlock|ngxConfNoArgs"]
+//	  }
+//	}
+type directiveDefinitions struct {
+	Directives map[string][]string `json:"directives"`
+}
+
+// LoadDirectives reads the definitions of directives in JSON from r, in the format
+// that "go run ./cmd/generate -format json" writes, and returns a MatchFunc for them.
+// This lets modules be supported without generating Go code for them:
+//
+//	{"directives": {"my_directive": ["ngxHTTPMainConf|ngxHTTPSrvConf|ngxConfTake1"]}}
+//
+// crossplaneyaml.LoadDirectives reads them in YAML.
+func LoadDirectives(r io.Reader) (MatchFunc, error) {
+	var defs directiveDefinitions
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	err := dec.Decode(&defs)
+	if errors.Is(err, io.EOF) || (err == nil && len(defs.Directives) == 0) {
+		return nil, errors.New("no directives are defined")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	directives := make(map[string][]uint, len(defs.Directives))
+	for name, names := range defs.Directives {
+		if len(names) == 0 {
+			return nil, fmt.Errorf("directive %q has no bitmasks", name)
+		}
+		masks := make([]uint, 0, len(names))
+		for _, def := range names {
+			mask, perr := parseMask(def)
+			if perr != nil {
+				return nil, fmt.Errorf("directive %q: %w", name, perr)
+			}
+			masks = append(masks, mask)
+		}
+		directives[name] = masks
+	}
+
+	return func(directive string) ([]uint, bool) {
+		masks, ok := directives[directive]
+		return masks, ok
+	}, nil
+}
+
+// LoadDirectivesFile reads the definitions of directives from a JSON file, like
+// LoadDirectives.
+func LoadDirectivesFile(path string) (MatchFunc, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	match, err := LoadDirectives(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return match, nil
+}
+
+// parseMask returns the bitmask of names joined by "|", e.g. "ngxHTTPMainConf|ngxConfTake1".
+// It must have a context.
+func parseMask(def string) (uint, error) {
+	var mask uint
+	for _, name := range strings.Split(def, "|") {
+		bits, ok := maskNames[strings.TrimSpace(name)]
+		if !ok {
+			return 0, fmt.Errorf("unknown bitmask %q in %q", strings.TrimSpace(name), def)
+		}
+		mask |= bits
+	}
+	if mask&^argStyleMask == 0 {
+		return 0, fmt.Errorf("bitmask %q has no context", def)
+	}
+	return mask, nil
+}
@@ -0,0 +1,102 @@
+/**
+ * Copyright (c) F5, Inc.
+ *
+ * This source code is licensed under the Apache License, Version 2.0 license found in the
+ * LICENSE file in the root directory of this source tree.
+ */
+
+package crossplane
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadDirectives(t *testing.T) {
+	t.Parallel()
+	tcs := map[string]struct {
+		defs    string
+		wantErr string
+		masks   map[string][]uint
+	}{
+		"json": {
+			defs: "{\n\t\"directives\": {\n\t\t\"my_directive\": [\"ngxHTTPMainConf|ngxHTTPSrvConf|ngxConfTake1\", \"ngxHTTPLocConf | ngxConfTake12\"]\n\t}\n}\n",
+			masks: map[string][]uint{
+				"my_directive": {ngxHTTPMainConf | ngxHTTPSrvConf | ngxConfTake1, ngxHTTPLocConf | ngxConfTake12},
+			},
+		},
+		"empty": {
+			defs:    "",
+			wantErr: "no directives are defined",
+		},
+		"no directives": {
+			defs:    `{"directives": {}}`,
+			wantErr: "no directives are defined",
+		},
+		"unknown field": {
+			defs:    `{"directive": {"my_directive": ["ngxHTTPMainConf|ngxConfTake1"]}}`,
+			wantErr: `json: unknown field "directive"`,
+		},
+		"unknown bitmask": {
+			defs:    `{"directives": {"my_directive": ["ngxHTTPMainConf|NGX_CONF_TAKE1"]}}`,
+			wantErr: `directive "my_directive": unknown bitmask "NGX_CONF_TAKE1" in "ngxHTTPMainConf|NGX_CONF_TAKE1"`,
+		},
+		"no context": {
+			defs:    `{"directives": {"my_directive": ["ngxConfTake1"]}}`,
+			wantErr: `directive "my_directive": bitmask "ngxConfTake1" has no context`,
+		},
+		"no bitmasks": {
+			defs:    `{"directives": {"my_directive": []}}`,
+			wantErr: `directive "my_directive" has no bitmasks`,
+		},
+	}
+
+	for name, tc := range tcs {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			match, err := LoadDirectives(strings.NewReader(tc.defs))
+			if tc.wantErr != "" {
+				require.EqualError(t, err, tc.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			for directive, want := range tc.masks {
+				masks, ok := match(directive)
+				require.True(t, ok)
+				require.Equal(t, want, masks)
+			}
+			_, ok := match("unknown")
+			require.False(t, ok)
+		})
+	}
+}
+
+func TestLoadDirectivesFile(t *testing.T) {
+	t.Parallel()
+	// the definitions written by the generator
+	match, err := LoadDirectivesFile(filepath.Join("internal", "generator", "testdata", "expected", "definitions"))
+	require.NoError(t, err)
+	masks, ok := match("my_directive_4")
+	require.True(t, ok)
+	require.Equal(t, []uint{ngxHTTPMainConf | ngxHTTPSrvConf | ngxConfTake4}, masks)
+
+	dir := t.TempDir()
+	conf := filepath.Join(dir, "nginx.conf")
+	require.NoError(t, os.WriteFile(conf, []byte("http {\n    my_directive_4 a b c;\n    my_directive_3;\n}\n"), 0o600))
+	payload, err := Parse(conf, &ParseOptions{DirectiveSources: []MatchFunc{MatchOssLatest, match}, ErrorOnUnknownDirectives: true})
+	require.NoError(t, err)
+	require.Len(t, payload.Errors, 1)
+	require.Equal(t, `invalid number of arguments in "my_directive_4" directive`, payload.Errors[0].Error.(*ParseError).What)
+
+	_, err = LoadDirectivesFile(filepath.Join(dir, "missing.json"))
+	require.Error(t, err)
+	bad := filepath.Join(dir, "bad.json")
+	require.NoError(t, os.WriteFile(bad, []byte(`{"directives": [`), 0o600))
+	_, err = LoadDirectivesFile(bad)
+	require.ErrorContains(t, err, bad+": ")
+}
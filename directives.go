@@ -0,0 +1,184 @@
+/**
+ * Copyright (c) F5, Inc.
+ *
+ * This source code is licensed under the Apache License, Version 2.0 license found in the
+ * LICENSE file in the root directory of this source tree.
+ */
+
+package crossplane
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DirectiveUsage is one of the ways a directive can be used, according to one
+// of its bitmasks.
+type DirectiveUsage struct {
+	// Contexts are the blocks the directive is allowed in, outermost first,
+	// e.g. ["http", "server"]. The main context is an empty slice.
+	Contexts [][]string
+	// Args describes the arguments the directive takes, e.g. "1 or 2", "1 or more"
+	// or "on or off".
+	Args string
+	// Block is true if the directive is followed by a block.
+	Block bool
+}
+
+// DirectiveUsages returns the ways the directive can be used according to the
+// sources, which default to DefaultDirectivesMatchFunc like the DirectiveSources
+// of ParseOptions do.
+func DirectiveUsages(directive string, sources ...MatchFunc) ([]DirectiveUsage, bool) {
+	masks, known := matchDirective(directive, sources)
+	if !known {
+		return nil, false
+	}
+	usages := make([]DirectiveUsage, 0, len(masks))
+	for _, mask := range masks {
+		usage := DirectiveUsage{
+			Contexts: [][]string{},
+			Args:     describeArgs(mask),
+			Block:    mask&ngxConfBlock != 0,
+		}
+		for _, c := range contextMasks() {
+			if mask&c.mask != 0 {
+				usage.Contexts = append(usage.Contexts, append([]string{}, c.ctx...))
+			}
+		}
+		usages = append(usages, usage)
+	}
+	return usages, true
+}
+
+// AllowedDirectives returns the sorted names of the bundled directives that
+// the sources allow in a block. blocks are the names of the directives of the
+// blocks that enclose it, outermost first, e.g. ["http", "server", "location"].
+// Directives only known to custom sources aren't returned.
+func AllowedDirectives(blocks []string, sources ...MatchFunc) []string {
+	ctx := blockCtx{}
+	for _, name := range blocks {
+		ctx = enterBlockCtx(&Directive{Directive: name}, ctx)
+	}
+	currCtx, ok := contexts[ctx.key()]
+	if !ok {
+		return nil
+	}
+
+	var allowed []string
+	for _, name := range bundledDirectives().sorted {
+		masks, _ := matchDirective(name, sources)
+		for _, mask := range masks {
+			if mask&currCtx != 0 {
+				allowed = append(allowed, name)
+				break
+			}
+		}
+	}
+	return allowed
+}
+
+// describeArgs describes the arguments allowed by a bitmask.
+func describeArgs(mask uint) string {
+	switch {
+	case mask&ngxConfFlag != 0:
+		return "on or off"
+	case mask&ngxConfAny != 0:
+		return "any"
+	case mask&ngxConf1More != 0:
+		return "1 or more"
+	case mask&ngxConf2More != 0:
+		return "2 or more"
+	}
+
+	var counts []string
+	for n := 0; n <= 7; n++ {
+		if mask>>n&1 != 0 {
+			counts = append(counts, strconv.Itoa(n))
+		}
+	}
+	switch len(counts) {
+	case 0:
+		return "none"
+	case 1:
+		return counts[0]
+	default:
+		return strings.Join(counts[:len(counts)-1], ", ") + " or " + counts[len(counts)-1]
+	}
+}
+
+type contextMask struct {
+	mask uint
+	ctx  blockCtx
+}
+
+//nolint:gochecknoglobals
+var (
+	contextMasksOnce sync.Once
+	contextMasksList []contextMask
+
+	bundledDirectivesOnce sync.Once
+	bundledDirectiveNames directiveNames
+)
+
+// contextMasks returns the block contexts in the order of their bitmasks.
+func contextMasks() []contextMask {
+	contextMasksOnce.Do(func() {
+		for key, mask := range contexts {
+			ctx := blockCtx{}
+			if key != "" {
+				ctx = strings.Split(key, ">")
+			}
+			contextMasksList = append(contextMasksList, contextMask{mask: mask, ctx: ctx})
+		}
+		sort.Slice(contextMasksList, func(i, j int) bool {
+			return contextMasksList[i].mask < contextMasksList[j].mask
+		})
+	})
+	return contextMasksList
+}
+
+// directiveNames are the names of the directives of all of the bundled tables.
+type directiveNames struct {
+	names  map[string]string
+	sorted []string
+}
+
+func bundledDirectives() directiveNames {
+	bundledDirectivesOnce.Do(func() {
+		tables := []map[string][]uint{
+			appProtectWAFv4Directives,
+			appProtectWAFv5Directives,
+			geoip2Directives,
+			headersMoreDirectives,
+			luaDirectives,
+			njsDirectives,
+			nginxPlusR30Directives,
+			nginxPlusR31Directives,
+			nginxPlusR33Directives,
+			nginxPlusR34Directives,
+			nginxPlusR35Directives,
+			nginxPlusR36Directives,
+			nginxPlusR37Directives,
+			nginxPlusLatestDirectives,
+			oss124Directives,
+			oss126Directives,
+			ossLatestDirectives,
+			otelDirectives,
+		}
+		names := map[string]string{}
+		for _, table := range tables {
+			for name := range table {
+				names[name] = name
+			}
+		}
+		sorted := make([]string, 0, len(names))
+		for name := range names {
+			sorted = append(sorted, name)
+		}
+		sort.Strings(sorted)
+		bundledDirectiveNames = directiveNames{names: names, sorted: sorted}
+	})
+	return bundledDirectiveNames
+}
@@ -0,0 +1,91 @@
+/**
+ * Copyright (c) F5, Inc.
+ *
+ * This source code is licensed under the Apache License, Version 2.0 license found in the
+ * LICENSE file in the root directory of this source tree.
+ */
+
+package crossplane
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDirectiveUsages(t *testing.T) {
+	t.Parallel()
+	testcases := map[string]struct {
+		directive string
+		sources   []MatchFunc
+		usages    []DirectiveUsage
+		known     bool
+	}{
+		"flag": {
+			directive: "server_name_in_redirect",
+			usages: []DirectiveUsage{
+				{Contexts: [][]string{{"http"}, {"http", "server"}, {"http", "location"}}, Args: "on or off"},
+			},
+			known: true,
+		},
+		"block": {
+			directive: "events",
+			usages:    []DirectiveUsage{{Contexts: [][]string{{}}, Args: "0", Block: true}},
+			known:     true,
+		},
+		"several masks": {
+			directive: "proxy_pass",
+			sources:   []MatchFunc{MatchOssLatest},
+			usages: []DirectiveUsage{
+				{Contexts: [][]string{{"http", "location"}, {"http", "location", "if"}, {"http", "location", "limit_except"}}, Args: "1"},
+				{Contexts: [][]string{{"stream", "server"}}, Args: "1"},
+			},
+			known: true,
+		},
+		"counts": {
+			directive: "error_page",
+			usages: []DirectiveUsage{
+				{Contexts: [][]string{{"http"}, {"http", "server"}, {"http", "location"}, {"http", "location", "if"}}, Args: "2 or more"},
+			},
+			known: true,
+		},
+		"unknown": {
+			directive: "proxy_pass",
+			sources:   []MatchFunc{MatchGeoip2Latest},
+		},
+	}
+	for name, tc := range testcases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			usages, known := DirectiveUsages(tc.directive, tc.sources...)
+			require.Equal(t, tc.known, known)
+			require.Equal(t, tc.usages, usages)
+		})
+	}
+}
+
+func TestAllowedDirectives(t *testing.T) {
+	t.Parallel()
+	require.Equal(t, []string{
+		"accept_mutex", "accept_mutex_delay", "debug_connection", "include", "multi_accept",
+		"stall_threshold", "use", "worker_aio_requests", "worker_connections",
+	}, AllowedDirectives([]string{"events"}))
+
+	// locations in locations are still in the location context
+	allowed := AllowedDirectives([]string{"http", "server", "location", "location"})
+	require.Contains(t, allowed, "proxy_pass")
+	require.NotContains(t, allowed, "server_name")
+
+	require.NotContains(t, AllowedDirectives([]string{"http"}, MatchOssLatest), "js_import")
+	require.Contains(t, AllowedDirectives([]string{"http"}, MatchOssLatest, MatchNjsLatest), "js_import")
+
+	require.Nil(t, AllowedDirectives([]string{"unknown"}))
+}
+
+func TestDescribeArgs(t *testing.T) {
+	t.Parallel()
+	require.Equal(t, "none", describeArgs(ngxConfBlock))
+	require.Equal(t, "1, 2 or 3", describeArgs(ngxConfTake123))
+	require.Equal(t, "1 or more", describeArgs(ngxConf1More|ngxConfExpr))
+}
@@ -0,0 +1,38 @@
+/**
+ * Copyright (c) F5, Inc.
+ *
+ * This source code is licensed under the Apache License, Version 2.0 license found in the
+ * LICENSE file in the root directory of this source tree.
+ */
+
+package crossplane
+
+// DirectiveDoc is the documentation of a directive in one of the modules of nginx,
+// as found in the sources of the nginx documentation.
+type DirectiveDoc struct {
+	Name string
+	// Module is the name of the module, e.g. "ngx_http_proxy_module".
+	Module string
+	// Syntax has a line for every way the directive can be written, e.g.
+	// "proxy_read_timeout time;".
+	Syntax []string
+	// Default is the directive with its default value, e.g. "proxy_read_timeout 60s;",
+	// or empty if it has none.
+	Default string
+	// Contexts are the blocks the directive is allowed in as the documentation
+	// names them, e.g. "http", "server" or "if in location".
+	Contexts []string
+	// AppearedIn is the version of nginx the directive appeared in, or empty
+	// if it's as old as its module.
+	AppearedIn string
+	// Description is the first paragraph of the documentation, as plain text.
+	Description string
+}
+
+// LookupDirective returns the documentation of the directive in every module
+// that has it, sorted by module. Directives that are only in the bundled
+// directive tables, like those of third-party modules, aren't documented.
+func LookupDirective(name string) ([]DirectiveDoc, bool) {
+	docs, ok := directiveDocs[name]
+	return docs, ok
+}
@@ -0,0 +1,52 @@
+/**
+ * Copyright (c) F5, Inc.
+ *
+ * This source code is licensed under the Apache License, Version 2.0 license found in the
+ * LICENSE file in the root directory of this source tree.
+ */
+
+package crossplane
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+//nolint:paralleltest // replaces the generated documentation
+func TestLookupDirective(t *testing.T) {
+	docs := []DirectiveDoc{{
+		Name:     "example_timeout",
+		Module:   "ngx_http_example_module",
+		Syntax:   []string{"example_timeout time;"},
+		Default:  "example_timeout 60s;",
+		Contexts: []string{"http", "server", "location"},
+	}}
+	generated := directiveDocs
+	directiveDocs = map[string][]DirectiveDoc{"example_timeout": docs}
+	t.Cleanup(func() { directiveDocs = generated })
+
+	got, ok := LookupDirective("example_timeout")
+	require.True(t, ok)
+	require.Equal(t, docs, got)
+
+	_, ok = LookupDirective("example_unknown")
+	require.False(t, ok)
+}
+
+func TestLookupDirective_generated(t *testing.T) {
+	t.Parallel()
+	if len(directiveDocs) == 0 {
+		t.Skip("directive_docs.gen.go is empty, generate it from a checkout of nginx.org with NGINX_ORG_SRC set")
+	}
+
+	docs, ok := LookupDirective("proxy_read_timeout")
+	require.True(t, ok)
+	require.Len(t, docs, 1)
+	require.Equal(t, "proxy_read_timeout", docs[0].Name)
+	require.Equal(t, "ngx_http_proxy_module", docs[0].Module)
+	require.Equal(t, []string{"proxy_read_timeout time;"}, docs[0].Syntax)
+	require.Equal(t, "proxy_read_timeout 60s;", docs[0].Default)
+	require.Equal(t, []string{"http", "server", "location"}, docs[0].Contexts)
+	require.NotEmpty(t, docs[0].Description)
+}
@@ -9,6 +9,7 @@ package crossplane
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 )
 
@@ -41,3 +42,115 @@ func (e *ParseError) MarshalJSON() ([]byte, error) {
 func (e *ParseError) Unwrap() error {
 	return e.originalErr
 }
+
+// ErrorFormatVersion is the version of the structured error encoding that is
+// written next to the error message of a PayloadError or ConfigError.
+// Version 1 payloads, written by earlier releases, carry only the plain "error"
+// message string and are still accepted when unmarshaling, while structured
+// errors of other versions are rejected.
+const ErrorFormatVersion = 2
+
+// ParseErrorDetail is the structured form of a ParseError that the encodings of
+// payloads write next to the error message, so that it can be restored.
+type ParseErrorDetail struct {
+	Version   int     `json:"version" yaml:"version" toml:"version"`
+	What      string  `json:"what" yaml:"what" toml:"what"`
+	File      *string `json:"file" yaml:"file" toml:"file,omitempty"`
+	Line      *int    `json:"line" yaml:"line" toml:"line,omitempty"`
+	Statement string  `json:"statement,omitempty" yaml:"statement,omitempty" toml:"statement,omitempty"`
+	BlockCtx  string  `json:"blockCtx,omitempty" yaml:"blockCtx,omitempty" toml:"blockCtx,omitempty"`
+}
+
+// NewParseErrorDetail returns the structured form of err, or nil if err isn't a *ParseError.
+func NewParseErrorDetail(err error) *ParseErrorDetail {
+	var perr *ParseError
+	if !errors.As(err, &perr) {
+		return nil
+	}
+	return &ParseErrorDetail{
+		Version:   ErrorFormatVersion,
+		What:      perr.What,
+		File:      perr.File,
+		Line:      perr.Line,
+		Statement: perr.Statement,
+		BlockCtx:  perr.BlockCtx,
+	}
+}
+
+// CheckVersion returns an error if e was written in another version of the
+// encoding, whose fields may not mean the same. A nil e is fine.
+func (e *ParseErrorDetail) CheckVersion() error {
+	if e == nil || e.Version == ErrorFormatVersion {
+		return nil
+	}
+	return fmt.Errorf("unsupported error format version %d, expected %d", e.Version, ErrorFormatVersion)
+}
+
+// ParseError returns the ParseError that e is the structured form of.
+func (e *ParseErrorDetail) ParseError() *ParseError {
+	return &ParseError{
+		What:      e.What,
+		File:      e.File,
+		Line:      e.Line,
+		Statement: e.Statement,
+		BlockCtx:  e.BlockCtx,
+	}
+}
+
+// encodeError returns the JSON message for err and, if err is a *ParseError,
+// its structured form so that it can be restored by decodeError.
+func encodeError(err error) (json.RawMessage, *ParseErrorDetail, error) {
+	if err == nil {
+		return json.RawMessage("null"), nil, nil
+	}
+
+	msg, merr := json.Marshal(err.Error())
+	if merr != nil {
+		return nil, nil, merr
+	}
+
+	return msg, NewParseErrorDetail(err), nil
+}
+
+// decodeError restores an error written by encodeError. Payloads without the
+// structured form only keep the error message.
+func decodeError(msg json.RawMessage, detail *ParseErrorDetail) error {
+	if detail != nil {
+		return detail.ParseError()
+	}
+
+	if len(msg) == 0 || string(msg) == "null" {
+		return nil
+	}
+
+	var s string
+	if err := json.Unmarshal(msg, &s); err != nil {
+		// errors that were not ParseErrors used to be written as JSON objects,
+		// keep whatever was written so the information isn't dropped silently
+		return errors.New(string(msg))
+	}
+
+	return errors.New(s)
+}
+
+// ErrorMessage returns the message of err, or nil if err is nil.
+func ErrorMessage(err error) *string {
+	if err == nil {
+		return nil
+	}
+	msg := err.Error()
+	return &msg
+}
+
+// ErrorFromMessage restores an error from its message and structured form, as
+// written by encodings that don't use JSON for the message.
+func ErrorFromMessage(msg *string, detail *ParseErrorDetail) error {
+	switch {
+	case detail != nil:
+		return detail.ParseError()
+	case msg != nil:
+		return errors.New(*msg)
+	default:
+		return nil
+	}
+}
@@ -3,9 +3,13 @@ module github.com/nginxinc/nginx-go-crossplane
 go 1.19
 
 require (
+	github.com/BurntSushi/toml v1.4.0
 	github.com/jstemmer/go-junit-report v1.0.0
 	github.com/stretchr/testify v1.10.0
+	github.com/yuin/gopher-lua v1.1.1
 	golang.org/x/tools v0.32.0
+	google.golang.org/protobuf v1.34.2
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
@@ -15,5 +19,4 @@ require (
 	golang.org/x/mod v0.24.0 // indirect
 	golang.org/x/sync v0.13.0 // indirect
 	gopkg.in/check.v1 v1.0.0-20201130134442-10cb98267c6c // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
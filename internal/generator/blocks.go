@@ -0,0 +1,360 @@
+/**
+ * Copyright (c) F5, Inc.
+ *
+ * This source code is licensed under the Apache License, Version 2.0 license found in the
+ * LICENSE file in the root directory of this source tree.
+ */
+
+package generator
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// contextRegistration is a block context that crossplane doesn't know, with the
+// directives allowed in it, to be registered in a crossplane.BlockContexts.
+type contextRegistration struct {
+	// Path are the names of the blocks that enclose the context followed by the
+	// name of the directive that opens it, like []string{"http", "foo_zone"}.
+	Path []string
+	// Args is the mask of the arguments of the directive that opens it.
+	Args Mask
+	// Directives are the masks of the arguments of the directives allowed in it.
+	Directives map[string]Mask
+}
+
+// builtinContextPaths are the paths of the block contexts that crossplane knows.
+//
+//nolint:gochecknoglobals
+var builtinContextPaths = map[string][]string{
+	"ngxMainConf":       {},
+	"ngxEventConf":      {"events"},
+	"ngxMailMainConf":   {"mail"},
+	"ngxMailSrvConf":    {"mail", "server"},
+	"ngxStreamMainConf": {"stream"},
+	"ngxStreamSrvConf":  {"stream", "server"},
+	"ngxStreamUpsConf":  {"stream", "upstream"},
+	"ngxHTTPMainConf":   {"http"},
+	"ngxHTTPSrvConf":    {"http", "server"},
+	"ngxHTTPLocConf":    {"http", "location"},
+	"ngxHTTPUpsConf":    {"http", "upstream"},
+	"ngxHTTPSifConf":    {"http", "server", "if"},
+	"ngxHTTPLifConf":    {"http", "location", "if"},
+	"ngxHTTPLmtConf":    {"http", "location", "limit_except"},
+	"ngxMgmtMainConf":   {"mgmt"},
+	"ngxHTTPOIDCConf":   {"http", "oidc_provider"},
+}
+
+// contextBuilder collects the block contexts that crossplane doesn't know, which are
+// the ones opened by blocks that set cf->cmd_type to a bitmask that isn't built in.
+type contextBuilder struct {
+	// openers are the directives that open each context, by the name of its bitmask in C.
+	openers map[string][]cCommand
+	// directives are the masks of the arguments of the directives of each context.
+	directives map[string]map[string]Mask
+}
+
+func newContextBuilder() *contextBuilder {
+	return &contextBuilder{openers: map[string][]cCommand{}, directives: map[string]map[string]Mask{}}
+}
+
+// addOpener records cmd if it opens a context that crossplane doesn't know.
+func (b *contextBuilder) addOpener(cmd cCommand) {
+	for _, name := range cmd.context {
+		if !goVarNames[name] {
+			b.openers[name] = append(b.openers[name], cmd)
+		}
+	}
+}
+
+// isCustom returns whether name is the bitmask of a context that crossplane doesn't know.
+func (b *contextBuilder) isCustom(name string) bool {
+	_, ok := b.openers[name]
+	return ok
+}
+
+// addDirective records a directive that is allowed in the custom contexts.
+func (b *contextBuilder) addDirective(directive string, mask Mask, custom []string) {
+	for _, c := range custom {
+		if b.directives[c] == nil {
+			b.directives[c] = map[string]Mask{}
+		}
+		args := b.directives[c][directive]
+		for _, name := range argNames(mask) {
+			if !containsName(args, name) {
+				args = append(args, name)
+			}
+		}
+		b.directives[c][directive] = args
+	}
+}
+
+// registrations returns the contexts in the order they can be registered in,
+// the ones that enclose others first.
+func (b *contextBuilder) registrations() []contextRegistration {
+	var regs []contextRegistration
+	paths := map[string][][]string{}
+	visiting := map[string]bool{}
+
+	var visit func(custom string) [][]string
+	visit = func(custom string) [][]string {
+		if p, ok := paths[custom]; ok {
+			return p
+		}
+		if visiting[custom] {
+			return nil
+		}
+		visiting[custom] = true
+
+		var result [][]string
+		for _, opener := range b.openers[custom] {
+			var parents [][]string
+			for _, name := range opener.mask {
+				if p, ok := builtinContextPaths[name]; ok {
+					parents = append(parents, p)
+				} else if b.isCustom(name) {
+					parents = append(parents, visit(name)...)
+				}
+			}
+			for _, parent := range parents {
+				path := append(append([]string{}, parent...), opener.name)
+				regs = append(regs, contextRegistration{Path: path, Args: argNames(opener.mask), Directives: b.directives[custom]})
+				result = append(result, path)
+			}
+		}
+		paths[custom] = result
+		return result
+	}
+
+	customs := make([]string, 0, len(b.openers))
+	for custom := range b.openers {
+		customs = append(customs, custom)
+	}
+	sort.Strings(customs)
+	for _, custom := range customs {
+		visit(custom)
+	}
+	return regs
+}
+
+// filterContexts removes the contexts opened by the directives of filter, with the
+// ones in them, and the directives of filter from the others.
+func filterContexts(regs []contextRegistration, filter Filters) []contextRegistration {
+	var kept []contextRegistration
+	var removed []string
+	for _, reg := range regs {
+		key := strings.Join(reg.Path, ">")
+		_, found := filter[reg.Path[len(reg.Path)-1]]
+		for _, prefix := range removed {
+			found = found || strings.HasPrefix(key, prefix+">")
+		}
+		if found {
+			removed = append(removed, key)
+			continue
+		}
+		directives := make(map[string]Mask, len(reg.Directives))
+		for d, mask := range reg.Directives {
+			if _, found := filter[d]; !found {
+				directives[d] = mask
+			}
+		}
+		reg.Directives = directives
+		kept = append(kept, reg)
+	}
+	return kept
+}
+
+// argNames returns the names of the bitmasks of the arguments in mask, which start with ngxConf.
+func argNames(mask Mask) Mask {
+	var args Mask
+	for _, name := range mask {
+		if strings.HasPrefix(name, "ngxConf") {
+			args = append(args, name)
+		}
+	}
+	return args
+}
+
+// hasContext returns whether mask has a bitmask that isn't one of arguments.
+func hasContext(mask Mask) bool {
+	return len(argNames(mask)) < len(mask)
+}
+
+// classifyBlock finds out how the handler of a block directive parses its block: with
+// a handler of its own, like map, whose block is a body of parameters, or with
+// another cmd_type, like upstream, whose block is a context of directives. Handlers
+// that aren't in the same file as the directive aren't analyzed.
+func (x *cCommandsExtractor) classifyBlock(cmd *cCommand, functions map[string][]cToken) {
+	if !containsName(cmd.mask, "ngxConfBlock") {
+		return
+	}
+	body, ok := functions[cmd.handler]
+	if !ok || !callsFunc(body, "ngx_conf_parse") {
+		return
+	}
+
+	for i := 0; i+4 < len(body); i++ {
+		if body[i].text != "-" || body[i+1].text != ">" || body[i+3].text != "=" || body[i+4].kind != cIdent {
+			continue
+		}
+		switch body[i+2].text {
+		case "handler":
+			if handler := body[i+4].text; handler != "NULL" && cmd.mapBody == nil {
+				mapBody := mapBodyOf(functions[handler])
+				cmd.mapBody = &mapBody
+			}
+		case "cmd_type":
+			end := i + 4
+			for end < len(body) && body[end].text != ";" {
+				end++
+			}
+			if mask, err := x.mask(cmd.name, body[i+4:end], 0); err == nil && cmd.context == nil {
+				cmd.context = mask
+			}
+		}
+	}
+}
+
+// mapBodyOf returns the masks of the parameters that the handler of a map-like block
+// takes, from the numbers of arguments it checks for. The default mask of the
+// parameters is the one of the first if (cf->args->nelts != n), and the special parameters
+// are the ones compared with ngx_strcmp(value[0].data, "name") in a condition, or
+// in a block of one, with cf->args->nelts == n. Parameters take one or more arguments
+// if the handler doesn't check them.
+func mapBodyOf(body []cToken) MapBodyMasks {
+	mapBody := MapBodyMasks{}
+
+	// conds are the conditions of the if blocks that the tokens are in, innermost last
+	type ifBlock struct {
+		depth int
+		cond  []cToken
+	}
+	var conds []ifBlock
+	depth := 0
+	for i := 0; i < len(body); i++ {
+		tok := body[i]
+		switch {
+		case tok.text == "{":
+			depth++
+		case tok.text == "}":
+			depth--
+			for len(conds) > 0 && conds[len(conds)-1].depth > depth {
+				conds = conds[:len(conds)-1]
+			}
+		case tok.kind == cIdent && tok.text == "if" && i+1 < len(body) && body[i+1].text == "(":
+			end := closingParen(body, i+1)
+			cond := body[i+2 : end]
+			if end+1 < len(body) && body[end+1].text == "{" {
+				conds = append(conds, ifBlock{depth: depth + 1, cond: cond})
+			}
+			if args, ok := neltsCompared(cond, "!="); ok && mapBody.Params == nil {
+				mapBody.Params = args
+			}
+			for _, param := range strcmpParams(cond) {
+				args, ok := neltsCompared(cond, "==")
+				for j := len(conds) - 1; !ok && j >= 0; j-- {
+					args, ok = neltsCompared(conds[j].cond, "==")
+				}
+				if !ok {
+					continue
+				}
+				if mapBody.SpecialParams == nil {
+					mapBody.SpecialParams = map[string]Mask{}
+				}
+				if _, found := mapBody.SpecialParams[param]; !found {
+					mapBody.SpecialParams[param] = args
+				}
+			}
+			i = end
+		}
+	}
+
+	if mapBody.Params == nil {
+		mapBody.Params = Mask{"ngxConf1More"}
+	}
+	return mapBody
+}
+
+// closingParen returns the index of the parenthesis that closes the one at open.
+func closingParen(tokens []cToken, open int) int {
+	depth := 0
+	for i := open; i < len(tokens); i++ {
+		switch tokens[i].text {
+		case "(":
+			depth++
+		case ")":
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return len(tokens) - 1
+}
+
+// strcmpParams returns the names that value[0] is compared with in cond, like
+// "hostnames" in ngx_strcmp(value[0].data, "hostnames") == 0.
+func strcmpParams(cond []cToken) []string {
+	var params []string
+	for i := 0; i+8 < len(cond); i++ {
+		if cond[i].text != "ngx_strcmp" || cond[i+1].text != "(" || cond[i+2].text != "value" ||
+			cond[i+3].text != "[" || cond[i+4].text != "0" || cond[i+5].text != "]" ||
+			cond[i+6].text != "." || cond[i+7].text != "data" || cond[i+8].text != "," {
+			continue
+		}
+		for j := i + 9; j < len(cond) && cond[j].text != ")"; j++ {
+			if cond[j].kind == cString {
+				if param, err := strconv.Unquote(cond[j].text); err == nil {
+					params = append(params, param)
+				}
+				break
+			}
+		}
+	}
+	return params
+}
+
+// neltsCompared returns the mask of the arguments after the name of a parameter if
+// cond compares nelts with a number with op, like nelts == 2.
+func neltsCompared(cond []cToken, op string) (Mask, bool) {
+	for i := 0; i+2 < len(cond); i++ {
+		if cond[i].text == "nelts" && cond[i+1].text == op {
+			return argsMask(cond[i+2])
+		}
+	}
+	return nil, false
+}
+
+// argsMask returns the mask of the arguments of a parameter from the number of
+// its words, the name included.
+func argsMask(nelts cToken) (Mask, bool) {
+	n, err := strconv.Atoi(nelts.text)
+	if nelts.kind != cNumber || err != nil || n < 1 || n > 8 {
+		return nil, false
+	}
+	if n == 1 {
+		return Mask{"ngxConfNoArgs"}, true
+	}
+	return Mask{"ngxConfTake" + strconv.Itoa(n-1)}, true
+}
+
+// callsFunc returns whether the tokens call the function name.
+func callsFunc(tokens []cToken, name string) bool {
+	for i := 0; i+1 < len(tokens); i++ {
+		if tokens[i].kind == cIdent && tokens[i].text == name && tokens[i+1].text == "(" {
+			return true
+		}
+	}
+	return false
+}
+
+func containsName(mask Mask, name string) bool {
+	for _, n := range mask {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,48 @@
+/**
+ * Copyright (c) F5, Inc.
+ *
+ * This source code is licensed under the Apache License, Version 2.0 license found in the
+ * LICENSE file in the root directory of this source tree.
+ */
+
+package generator
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFilterContexts(t *testing.T) {
+	t.Parallel()
+	regs := []contextRegistration{
+		{Path: []string{"http", "my_zone"}, Directives: map[string]Mask{"my_pool": {"ngxConfBlock"}, "my_size": {"ngxConfTake1"}}},
+		{Path: []string{"http", "my_zone", "my_pool"}, Directives: map[string]Mask{"my_max": {"ngxConfTake1"}}},
+		{Path: []string{"stream", "my_other"}, Directives: map[string]Mask{"my_size": {"ngxConfTake1"}}},
+	}
+
+	require.Equal(t, []contextRegistration{
+		{Path: []string{"http", "my_zone"}, Directives: map[string]Mask{"my_pool": {"ngxConfBlock"}}},
+		{Path: []string{"http", "my_zone", "my_pool"}, Directives: map[string]Mask{"my_max": {"ngxConfTake1"}}},
+		{Path: []string{"stream", "my_other"}, Directives: map[string]Mask{}},
+	}, filterContexts(regs, Filters{"my_size": {}}))
+
+	require.Equal(t, []contextRegistration{
+		{Path: []string{"stream", "my_other"}, Directives: map[string]Mask{"my_size": {"ngxConfTake1"}}},
+	}, filterContexts(regs, Filters{"my_zone": {}}))
+}
+
+func TestBuiltinContextPaths(t *testing.T) {
+	t.Parallel()
+	for _, name := range ngxVarNameToGo {
+		// the other names are the masks of arguments
+		if strings.HasPrefix(name, "ngxConf") || name == "ngxAnyConf" || name == "ngxDirectConf" {
+			continue
+		}
+		require.Contains(t, builtinContextPaths, name)
+	}
+	for name := range builtinContextPaths {
+		require.True(t, goVarNames[name], "%s isn't in ngxVarNameToGo", name)
+	}
+}
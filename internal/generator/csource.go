@@ -0,0 +1,932 @@
+/**
+ * Copyright (c) F5, Inc.
+ *
+ * This source code is licensed under the Apache License, Version 2.0 license found in the
+ * LICENSE file in the root directory of this source tree.
+ */
+
+package generator
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type cTokenKind int
+
+const (
+	cIdent cTokenKind = iota
+	cNumber
+	cString
+	cChar
+	cPunct
+	// cDirective is a preprocessor line, like "if (NGX_HTTP_SSL)", without its "#".
+	cDirective
+)
+
+// cToken is a token of C source code. Comments aren't tokens.
+type cToken struct {
+	kind cTokenKind
+	text string
+	line int
+}
+
+// cTokenizer splits C source code into tokens. It knows just enough of C to find
+// the ngx_command_t arrays of nginx modules: comments, string and character literals
+// with escapes, and preprocessor lines with their continuations.
+type cTokenizer struct {
+	src       string
+	pos       int
+	line      int
+	lineStart bool
+}
+
+func tokenizeC(src string) ([]cToken, error) {
+	t := &cTokenizer{src: src, line: 1, lineStart: true}
+	var tokens []cToken
+	for {
+		tok, ok, err := t.next()
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return tokens, nil
+		}
+		tokens = append(tokens, tok)
+	}
+}
+
+func (t *cTokenizer) peek(offset int) byte {
+	if t.pos+offset < len(t.src) {
+		return t.src[t.pos+offset]
+	}
+	return 0
+}
+
+func (t *cTokenizer) next() (cToken, bool, error) {
+	for t.pos < len(t.src) {
+		c := t.src[t.pos]
+		line := t.line
+		switch {
+		case c == '\n':
+			t.pos++
+			t.line++
+			t.lineStart = true
+		case c == ' ' || c == '\t' || c == '\r' || c == '\f' || c == '\v':
+			t.pos++
+		case c == '\\' && t.peek(1) == '\n':
+			t.pos += 2
+			t.line++
+		case c == '/' && t.peek(1) == '/':
+			t.skipLineComment()
+		case c == '/' && t.peek(1) == '*':
+			if err := t.skipBlockComment(); err != nil {
+				return cToken{}, false, err
+			}
+		case c == '#' && t.lineStart:
+			t.pos++
+			text, err := t.directive()
+			if err != nil {
+				return cToken{}, false, err
+			}
+			return cToken{kind: cDirective, text: text, line: line}, true, nil
+		default:
+			t.lineStart = false
+			return t.token()
+		}
+	}
+	return cToken{}, false, nil
+}
+
+func (t *cTokenizer) token() (cToken, bool, error) {
+	start, line := t.pos, t.line
+	c := t.src[t.pos]
+	kind := cPunct
+	switch {
+	case c == '"' || c == '\'':
+		if err := t.skipLiteral(c); err != nil {
+			return cToken{}, false, err
+		}
+		kind = cString
+		if c == '\'' {
+			kind = cChar
+		}
+	case isCIdentStart(c):
+		for t.pos < len(t.src) && isCIdentChar(t.src[t.pos]) {
+			t.pos++
+		}
+		kind = cIdent
+	case c >= '0' && c <= '9':
+		for t.pos < len(t.src) && (isCIdentChar(t.src[t.pos]) || t.src[t.pos] == '.') {
+			t.pos++
+		}
+		kind = cNumber
+	default:
+		t.pos++
+		// the operators of preprocessor conditions
+		if t.pos < len(t.src) {
+			switch t.src[start : t.pos+1] {
+			case "&&", "||", "==", "!=", "<=", ">=":
+				t.pos++
+			}
+		}
+	}
+	return cToken{kind: kind, text: t.src[start:t.pos], line: line}, true, nil
+}
+
+func (t *cTokenizer) skipLineComment() {
+	for t.pos < len(t.src) && t.src[t.pos] != '\n' {
+		if t.src[t.pos] == '\\' && t.peek(1) == '\n' {
+			t.pos++
+			t.line++
+		}
+		t.pos++
+	}
+}
+
+func (t *cTokenizer) skipBlockComment() error {
+	line := t.line
+	end := strings.Index(t.src[t.pos+2:], "*/")
+	if end < 0 {
+		return fmt.Errorf("line %d: unterminated comment", line)
+	}
+	comment := t.src[t.pos : t.pos+2+end+2]
+	t.line += strings.Count(comment, "\n")
+	t.pos += len(comment)
+	return nil
+}
+
+// skipLiteral skips a string or character literal that's quoted with quote.
+func (t *cTokenizer) skipLiteral(quote byte) error {
+	line := t.line
+	for t.pos++; t.pos < len(t.src); t.pos++ {
+		switch t.src[t.pos] {
+		case '\\':
+			if t.peek(1) == '\n' {
+				t.line++
+			}
+			t.pos++
+		case '\n':
+			return fmt.Errorf("line %d: unterminated literal", line)
+		case quote:
+			t.pos++
+			return nil
+		}
+	}
+	return fmt.Errorf("line %d: unterminated literal", line)
+}
+
+// directive returns the text of a preprocessor line, without comments and with
+// its whitespace collapsed. The line is consumed with its continuations.
+func (t *cTokenizer) directive() (string, error) {
+	var b strings.Builder
+	for t.pos < len(t.src) && t.src[t.pos] != '\n' {
+		c := t.src[t.pos]
+		switch {
+		case c == '\\' && t.peek(1) == '\n':
+			b.WriteByte(' ')
+			t.pos += 2
+			t.line++
+		case c == '/' && t.peek(1) == '/':
+			t.skipLineComment()
+		case c == '/' && t.peek(1) == '*':
+			if err := t.skipBlockComment(); err != nil {
+				return "", err
+			}
+			b.WriteByte(' ')
+		case c == '"' || c == '\'':
+			start := t.pos
+			if err := t.skipLiteral(c); err != nil {
+				return "", err
+			}
+			b.WriteString(t.src[start:t.pos])
+		default:
+			b.WriteByte(c)
+			t.pos++
+		}
+	}
+	return strings.Join(strings.Fields(b.String()), " "), nil
+}
+
+func isCIdentStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isCIdentChar(c byte) bool {
+	return isCIdentStart(c) || (c >= '0' && c <= '9')
+}
+
+// splitDirective returns the name of a preprocessor directive and the rest of its line.
+func splitDirective(text string) (string, string) {
+	end := 0
+	for end < len(text) && isCIdentChar(text[end]) {
+		end++
+	}
+	return text[:end], strings.TrimSpace(text[end:])
+}
+
+// condValue is the value of a preprocessor condition, which is unknown if it
+// depends on macros that the generator isn't told about.
+type condValue int
+
+const (
+	condUnknown condValue = iota
+	condFalse
+	condTrue
+)
+
+func condNot(v condValue) condValue {
+	switch v {
+	case condTrue:
+		return condFalse
+	case condFalse:
+		return condTrue
+	}
+	return condUnknown
+}
+
+func condAnd(a, b condValue) condValue {
+	switch {
+	case a == condFalse || b == condFalse:
+		return condFalse
+	case a == condTrue && b == condTrue:
+		return condTrue
+	}
+	return condUnknown
+}
+
+func condOr(a, b condValue) condValue {
+	switch {
+	case a == condTrue || b == condTrue:
+		return condTrue
+	case a == condFalse && b == condFalse:
+		return condFalse
+	}
+	return condUnknown
+}
+
+// condEvaluator evaluates preprocessor conditions, with the macros that are
+// known to be defined (true) or not (false).
+type condEvaluator struct {
+	macros map[string]bool
+	tokens []cToken
+	pos    int
+}
+
+var errUnsupportedCond = errors.New("unsupported condition")
+
+// evalCond returns the value of the condition of an #if. Conditions that aren't
+// made of macros, numbers, defined, !, && and || are unknown.
+func evalCond(cond string, macros map[string]bool) condValue {
+	tokens, err := tokenizeC(cond)
+	if err != nil {
+		return condUnknown
+	}
+	e := &condEvaluator{macros: macros, tokens: tokens}
+	v, err := e.or()
+	if err != nil || e.pos != len(e.tokens) {
+		return condUnknown
+	}
+	return v
+}
+
+func (e *condEvaluator) accept(text string) bool {
+	if e.pos < len(e.tokens) && e.tokens[e.pos].kind == cPunct && e.tokens[e.pos].text == text {
+		e.pos++
+		return true
+	}
+	return false
+}
+
+func (e *condEvaluator) or() (condValue, error) {
+	v, err := e.and()
+	for err == nil && e.accept("||") {
+		var w condValue
+		w, err = e.and()
+		v = condOr(v, w)
+	}
+	return v, err
+}
+
+func (e *condEvaluator) and() (condValue, error) {
+	v, err := e.unary()
+	for err == nil && e.accept("&&") {
+		var w condValue
+		w, err = e.unary()
+		v = condAnd(v, w)
+	}
+	return v, err
+}
+
+func (e *condEvaluator) unary() (condValue, error) {
+	switch {
+	case e.accept("!"):
+		v, err := e.unary()
+		return condNot(v), err
+	case e.accept("("):
+		v, err := e.or()
+		if err == nil && !e.accept(")") {
+			err = errUnsupportedCond
+		}
+		return v, err
+	}
+	if e.pos >= len(e.tokens) {
+		return condUnknown, errUnsupportedCond
+	}
+	tok := e.tokens[e.pos]
+	e.pos++
+	switch {
+	case tok.kind == cIdent && tok.text == "defined":
+		paren := e.accept("(")
+		if e.pos >= len(e.tokens) || e.tokens[e.pos].kind != cIdent {
+			return condUnknown, errUnsupportedCond
+		}
+		v := e.macro(e.tokens[e.pos].text)
+		e.pos++
+		if paren && !e.accept(")") {
+			return condUnknown, errUnsupportedCond
+		}
+		return v, nil
+	case tok.kind == cIdent:
+		return e.macro(tok.text), nil
+	case tok.kind == cNumber:
+		n, err := strconv.ParseInt(strings.TrimRight(tok.text, "uUlL"), 0, 64)
+		if err != nil {
+			return condUnknown, errUnsupportedCond
+		}
+		if n == 0 {
+			return condFalse, nil
+		}
+		return condTrue, nil
+	}
+	return condUnknown, errUnsupportedCond
+}
+
+func (e *condEvaluator) macro(name string) condValue {
+	defined, ok := e.macros[name]
+	switch {
+	case !ok:
+		return condUnknown
+	case defined:
+		return condTrue
+	}
+	return condFalse
+}
+
+// condFrame is an #if, #ifdef or #ifndef with its #elif and #else branches.
+type condFrame struct {
+	// prev are the conditions of the branches before the current one, and taken
+	// is whether one of them is.
+	prev  []string
+	taken condValue
+	// cond is the condition of the current branch, and guard and value are the
+	// ones of its code, which also needs the previous conditions to be false.
+	cond  string
+	guard string
+	value condValue
+}
+
+// condStack tracks the preprocessor conditions that the code is in.
+type condStack struct {
+	macros map[string]bool
+	frames []condFrame
+}
+
+// directive updates the stack with the preprocessor line of tok.
+func (s *condStack) directive(tok cToken) error {
+	name, rest := splitDirective(tok.text)
+	switch name {
+	case "if", "ifdef", "ifndef":
+		cond := trimParens(rest)
+		switch name {
+		case "ifdef":
+			cond = "defined(" + rest + ")"
+		case "ifndef":
+			cond = "!defined(" + rest + ")"
+		}
+		v := evalCond(cond, s.macros)
+		text := condText(name, rest)
+		s.frames = append(s.frames, condFrame{taken: v, cond: text, guard: text, value: v})
+	case "elif", "else":
+		if len(s.frames) == 0 {
+			return fmt.Errorf("line %d: #%s without #if", tok.line, name)
+		}
+		f := &s.frames[len(s.frames)-1]
+		f.prev = append(f.prev, f.cond)
+		notPrev := condNot(f.taken)
+		guards := make([]string, 0, len(f.prev)+1)
+		for _, g := range f.prev {
+			guards = append(guards, negateCond(g))
+		}
+		if name == "elif" {
+			cond := trimParens(rest)
+			v := evalCond(cond, s.macros)
+			f.value = condAnd(notPrev, v)
+			f.taken = condOr(f.taken, v)
+			guards = append(guards, cond)
+			f.cond = cond
+		} else {
+			f.value = notPrev
+			f.cond = ""
+		}
+		f.guard = strings.Join(guards, " && ")
+	case "endif":
+		if len(s.frames) == 0 {
+			return fmt.Errorf("line %d: #endif without #if", tok.line)
+		}
+		s.frames = s.frames[:len(s.frames)-1]
+	}
+	return nil
+}
+
+// active returns whether the code can be compiled with the known macros, and the
+// conditions guarding it whose values are unknown.
+func (s *condStack) active() (bool, string) {
+	var guards []string
+	for _, f := range s.frames {
+		switch f.value {
+		case condFalse:
+			return false, ""
+		case condUnknown:
+			guards = append(guards, f.guard)
+		}
+	}
+	return true, strings.Join(guards, " && ")
+}
+
+func condText(name, rest string) string {
+	switch name {
+	case "ifdef":
+		return rest
+	case "ifndef":
+		return "!" + rest
+	}
+	return trimParens(rest)
+}
+
+// negateCond returns the negation of a condition, like !NGX_HTTP_SSL.
+func negateCond(cond string) string {
+	if strings.HasPrefix(cond, "!") && isCIdent(cond[1:]) {
+		return cond[1:]
+	}
+	macro := strings.TrimSuffix(strings.TrimPrefix(cond, "defined("), ")")
+	if isCIdent(cond) || (strings.HasPrefix(cond, "defined(") && isCIdent(macro)) {
+		return "!" + cond
+	}
+	return "!(" + cond + ")"
+}
+
+// trimParens removes the parentheses around a whole condition, like (NGX_HTTP_SSL).
+func trimParens(cond string) string {
+	cond = strings.TrimSpace(cond)
+	for strings.HasPrefix(cond, "(") && strings.HasSuffix(cond, ")") {
+		depth := 0
+		for i, c := range cond {
+			switch c {
+			case '(':
+				depth++
+			case ')':
+				depth--
+			}
+			if depth == 0 && i < len(cond)-1 {
+				return cond
+			}
+		}
+		cond = strings.TrimSpace(cond[1 : len(cond)-1])
+	}
+	return cond
+}
+
+func isCIdent(s string) bool {
+	if s == "" || !isCIdentStart(s[0]) {
+		return false
+	}
+	for i := 1; i < len(s); i++ {
+		if !isCIdentChar(s[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// cCommand is a directive defined in an ngx_command_t array.
+type cCommand struct {
+	name string
+	// mask is the type of the directive, with the names of its bitmasks in Go. The
+	// bitmasks that crossplane doesn't know, like the ones of custom block contexts,
+	// keep their names in C.
+	mask Mask
+	// handler is the name of the function that sets the directive.
+	handler string
+	// post is the name of the variable that the post field of the directive points to.
+	post string
+	// values are the members of the ngx_conf_enum_t or ngx_conf_bitmask_t table
+	// that the post field of the directive points to.
+	values []string
+	// mapBody is set for a block directive whose handler parses the block with a
+	// handler of its own, like map, so that its body is made of parameters.
+	mapBody *MapBodyMasks
+	// context is the cmd_type that the handler of a block directive parses the
+	// block with, like NGX_HTTP_UPS_CONF for upstream, in the names of mask.
+	context Mask
+	// guard are the preprocessor conditions the directive is defined in, like
+	// NGX_HTTP_SSL, or empty if it's always defined.
+	guard string
+}
+
+// maxMacroDepth limits the expansion of macros that are used in the type of directives.
+const maxMacroDepth = 16
+
+// cCommandsExtractor finds the directives in the ngx_command_t arrays of C source code.
+type cCommandsExtractor struct {
+	tokens  []cToken
+	pos     int
+	conds   condStack
+	defines map[string][]cToken
+}
+
+// extractCommands returns the directives defined in the ngx_command_t arrays of src,
+// skipping the ones in preprocessor branches that macros tell can't be compiled.
+func extractCommands(src string, macros map[string]bool) ([]cCommand, error) {
+	tokens, err := tokenizeC(src)
+	if err != nil {
+		return nil, err
+	}
+	x := &cCommandsExtractor{tokens: tokens, conds: condStack{macros: macros}, defines: map[string][]cToken{}}
+	x.collectDefines()
+
+	var commands []cCommand
+	functions := map[string][]cToken{}
+	tables := map[string][]string{}
+	for x.pos < len(x.tokens) {
+		tok := x.tokens[x.pos]
+		x.pos++
+		switch {
+		case tok.kind == cDirective:
+			if err = x.conds.directive(tok); err != nil {
+				return nil, err
+			}
+		case tok.kind == cIdent && tok.text == "ngx_command_t":
+			if _, ok := x.arrayStart(); !ok {
+				continue
+			}
+			var cmds []cCommand
+			if cmds, err = x.array(); err != nil {
+				return nil, err
+			}
+			commands = append(commands, cmds...)
+		case tok.kind == cIdent && (tok.text == "ngx_conf_enum_t" || tok.text == "ngx_conf_bitmask_t"):
+			name, ok := x.arrayStart()
+			if !ok {
+				continue
+			}
+			var values []string
+			if values, err = x.valueTable(); err != nil {
+				return nil, err
+			}
+			tables[name] = values
+		case tok.kind == cPunct && tok.text == "{":
+			name := x.functionName(x.pos - 1)
+			if name == "" {
+				// the braces of structs, initializers and extern "C" blocks
+				continue
+			}
+			var body []cToken
+			if body, err = x.block(); err != nil {
+				return nil, err
+			}
+			functions[name] = body
+		}
+	}
+
+	for i := range commands {
+		x.classifyBlock(&commands[i], functions)
+		if commands[i].post != "" {
+			commands[i].values = tables[commands[i].post]
+		}
+	}
+	return commands, nil
+}
+
+// functionName returns the name of the function whose body starts with the brace at
+// index open, or an empty string if the brace doesn't start the body of a function.
+func (x *cCommandsExtractor) functionName(open int) string {
+	i := x.prevToken(open)
+	if i < 0 || x.tokens[i].text != ")" {
+		return ""
+	}
+	for depth := 0; i >= 0; i = x.prevToken(i) {
+		switch x.tokens[i].text {
+		case ")":
+			depth++
+		case "(":
+			depth--
+		}
+		if depth == 0 {
+			break
+		}
+	}
+	if i = x.prevToken(i); i < 0 || x.tokens[i].kind != cIdent {
+		return ""
+	}
+	return x.tokens[i].text
+}
+
+// prevToken returns the index of the token before index i that isn't a preprocessor line.
+func (x *cCommandsExtractor) prevToken(i int) int {
+	i--
+	for i >= 0 && x.tokens[i].kind == cDirective {
+		i--
+	}
+	return i
+}
+
+// block returns the tokens up to the brace that closes the one before them.
+func (x *cCommandsExtractor) block() ([]cToken, error) {
+	var tokens []cToken
+	for depth := 0; ; {
+		tok, ok, err := x.nextToken()
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, errors.New("unterminated function body")
+		}
+		if tok.kind == cPunct {
+			switch tok.text {
+			case "{":
+				depth++
+			case "}":
+				if depth == 0 {
+					return tokens, nil
+				}
+				depth--
+			}
+		}
+		tokens = append(tokens, tok)
+	}
+}
+
+// collectDefines records the object-like macros of the source, so that the ones
+// used as the type of directives can be expanded.
+func (x *cCommandsExtractor) collectDefines() {
+	for _, tok := range x.tokens {
+		if tok.kind != cDirective {
+			continue
+		}
+		name, rest := splitDirective(tok.text)
+		if name != "define" {
+			continue
+		}
+		end := 0
+		for end < len(rest) && isCIdentChar(rest[end]) {
+			end++
+		}
+		// function-like macros can't be the type of a directive
+		if end == 0 || (end < len(rest) && rest[end] == '(') {
+			continue
+		}
+		body, err := tokenizeC(rest[end:])
+		if err == nil {
+			x.defines[rest[:end]] = body
+		}
+	}
+}
+
+// nextToken returns the next token that isn't a preprocessor line, updating the
+// conditions with the lines before it.
+func (x *cCommandsExtractor) nextToken() (cToken, bool, error) {
+	for x.pos < len(x.tokens) {
+		tok := x.tokens[x.pos]
+		x.pos++
+		if tok.kind != cDirective {
+			return tok, true, nil
+		}
+		if err := x.conds.directive(tok); err != nil {
+			return cToken{}, false, err
+		}
+	}
+	return cToken{}, false, nil
+}
+
+// arrayStart consumes "name[] = {" after the type of an array, and returns the name,
+// or returns false and consumes nothing if the type isn't the one of an array definition.
+func (x *cCommandsExtractor) arrayStart() (string, bool) {
+	i := x.pos
+	if i+1 >= len(x.tokens) || x.tokens[i].kind != cIdent || x.tokens[i+1].text != "[" {
+		return "", false
+	}
+	name := x.tokens[i].text
+	i += 2
+	for i < len(x.tokens) && x.tokens[i].text != "]" && x.tokens[i].kind != cPunct {
+		i++
+	}
+	for _, text := range []string{"]", "=", "{"} {
+		if i >= len(x.tokens) || x.tokens[i].kind != cPunct || x.tokens[i].text != text {
+			return "", false
+		}
+		i++
+	}
+	x.pos = i
+	return name, true
+}
+
+// array returns the directives of an ngx_command_t array, after its opening brace.
+func (x *cCommandsExtractor) array() ([]cCommand, error) {
+	var commands []cCommand
+	for {
+		tok, ok, err := x.nextToken()
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, errors.New("unterminated ngx_command_t array")
+		}
+		switch {
+		case tok.text == "}":
+			return commands, nil
+		case tok.text == "{":
+			active, guard := x.conds.active()
+			var fields [][]cToken
+			if fields, err = x.entry(); err != nil {
+				return nil, err
+			}
+			if !active {
+				continue
+			}
+			var cmd cCommand
+			if cmd, ok, err = x.command(fields); err != nil {
+				return nil, err
+			}
+			if ok {
+				cmd.guard = guard
+				commands = append(commands, cmd)
+			}
+		}
+		// commas and ngx_null_command end up here
+	}
+}
+
+// valueTable returns the names of an ngx_conf_enum_t or ngx_conf_bitmask_t array,
+// after its opening brace.
+func (x *cCommandsExtractor) valueTable() ([]string, error) {
+	var values []string
+	for {
+		tok, ok, err := x.nextToken()
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, errors.New("unterminated value table")
+		}
+		switch tok.text {
+		case "}":
+			return values, nil
+		case "{":
+			active, _ := x.conds.active()
+			var fields [][]cToken
+			if fields, err = x.entry(); err != nil {
+				return nil, err
+			}
+			if !active {
+				continue
+			}
+			var value string
+			if value, ok, err = ngxString(fields[0]); err != nil {
+				return nil, err
+			}
+			if ok {
+				values = append(values, value)
+			}
+		}
+	}
+}
+
+// entry returns the fields of an entry of an ngx_command_t array, after its opening brace.
+func (x *cCommandsExtractor) entry() ([][]cToken, error) {
+	var fields [][]cToken
+	var field []cToken
+	depth := 0
+	for {
+		tok, ok, err := x.nextToken()
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, errors.New("unterminated ngx_command_t entry")
+		}
+		if tok.kind == cPunct {
+			switch tok.text {
+			case "(", "{", "[":
+				depth++
+			case ")", "]":
+				depth--
+			case "}":
+				if depth == 0 {
+					return append(fields, field), nil
+				}
+				depth--
+			case ",":
+				if depth == 0 {
+					fields = append(fields, field)
+					field = nil
+					continue
+				}
+			}
+		}
+		field = append(field, tok)
+	}
+}
+
+// command returns the directive of the fields of an entry, which starts with its
+// name in ngx_string("name") and its type, the bitmask of the directive.
+// Entries that don't start with ngx_string are skipped.
+func (x *cCommandsExtractor) command(fields [][]cToken) (cCommand, bool, error) {
+	if len(fields) < 2 {
+		return cCommand{}, false, nil
+	}
+	directive, ok, err := ngxString(fields[0])
+	if err != nil || !ok {
+		return cCommand{}, false, err
+	}
+
+	mask, err := x.mask(directive, fields[1], 0)
+	if err != nil {
+		return cCommand{}, false, err
+	}
+	cmd := cCommand{name: directive, mask: mask}
+	if len(fields) > 2 && len(fields[2]) == 1 && fields[2][0].kind == cIdent {
+		cmd.handler = fields[2][0].text
+	}
+	if len(fields) > 5 && len(fields[5]) == 2 && fields[5][0].text == "&" && fields[5][1].kind == cIdent {
+		cmd.post = fields[5][1].text
+	}
+	return cmd, true, nil
+}
+
+// ngxString returns the string of ngx_string("string"), or false if field isn't one.
+func ngxString(field []cToken) (string, bool, error) {
+	if len(field) < 4 || field[0].text != "ngx_string" || field[1].text != "(" || field[len(field)-1].text != ")" {
+		return "", false, nil
+	}
+	var b strings.Builder
+	for _, tok := range field[2 : len(field)-1] {
+		if tok.kind != cString {
+			return "", false, fmt.Errorf("line %d: unsupported string", tok.line)
+		}
+		s, err := strconv.Unquote(tok.text)
+		if err != nil {
+			return "", false, fmt.Errorf("line %d: unsupported string %s", tok.line, tok.text)
+		}
+		b.WriteString(s)
+	}
+	return b.String(), true, nil
+}
+
+// mask returns the bitmask of the type of a directive, made of bitmasks joined by |,
+// expanding the macros of the source that are made of bitmasks too.
+func (x *cCommandsExtractor) mask(directive string, tokens []cToken, depth int) (Mask, error) {
+	if depth > maxMacroDepth {
+		return nil, fmt.Errorf("parsing directive %s, macros in its bitmask are nested too deep", directive)
+	}
+	var mask Mask
+	for _, tok := range tokens {
+		switch {
+		case tok.kind == cPunct && (tok.text == "|" || tok.text == "(" || tok.text == ")"):
+		case tok.kind == cIdent:
+			if goVarName, found := ngxVarNameToGo[tok.text]; found {
+				mask = append(mask, goVarName)
+				continue
+			}
+			body, found := x.defines[tok.text]
+			if !found || !isMaskExpr(body) {
+				mask = append(mask, tok.text)
+				continue
+			}
+			expanded, err := x.mask(directive, body, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			mask = append(mask, expanded...)
+		default:
+			return nil, fmt.Errorf("parsing directive %s, unsupported bitmask %s in source code at line %d", directive, tok.text, tok.line)
+		}
+	}
+	if len(mask) == 0 {
+		return nil, fmt.Errorf("parsing directive %s, its bitmask is empty", directive)
+	}
+	return mask, nil
+}
+
+// isMaskExpr returns whether tokens are names joined by |, like the body of
+// #define NGX_HTTP_MY_CONF (NGX_HTTP_MAIN_CONF|NGX_HTTP_SRV_CONF).
+func isMaskExpr(tokens []cToken) bool {
+	for _, tok := range tokens {
+		if tok.kind != cIdent && !(tok.kind == cPunct && (tok.text == "|" || tok.text == "(" || tok.text == ")")) {
+			return false
+		}
+	}
+	return len(tokens) > 0
+}
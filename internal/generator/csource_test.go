@@ -0,0 +1,167 @@
+/**
+ * Copyright (c) F5, Inc.
+ *
+ * This source code is licensed under the Apache License, Version 2.0 license found in the
+ * LICENSE file in the root directory of this source tree.
+ */
+
+package generator
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const conditionalCommands = `
+static ngx_command_t  commands[] = {
+#if (NGX_HTTP_SSL)
+    { ngx_string("ssl_only"), NGX_HTTP_SRV_CONF|NGX_CONF_FLAG, 0, 0, 0, NULL },
+#elif defined(NGX_HTTP_V2)
+    { ngx_string("v2_only"), NGX_HTTP_SRV_CONF|NGX_CONF_FLAG, 0, 0, 0, NULL },
+#else
+    { ngx_string("neither"), NGX_HTTP_SRV_CONF|NGX_CONF_FLAG, 0, 0, 0, NULL },
+#endif
+#ifndef NGX_HTTP_SSL
+    { ngx_string("no_ssl"), NGX_HTTP_SRV_CONF|NGX_CONF_FLAG, 0, 0, 0, NULL },
+#endif
+#if (NGX_HTTP_SSL || NGX_COMPAT) && 1
+    { ngx_string("ssl_or_compat"), NGX_HTTP_SRV_CONF|NGX_CONF_FLAG, 0, 0, 0, NULL },
+#endif
+      ngx_null_command
+};
+`
+
+func TestExtractCommands(t *testing.T) {
+	t.Parallel()
+	tests := map[string]struct {
+		src     string
+		macros  map[string]bool
+		want    []cCommand
+		wantErr bool
+	}{
+		"unknown macros are annotated": {
+			src: conditionalCommands,
+			want: []cCommand{
+				{name: "ssl_only", mask: Mask{"ngxHTTPSrvConf", "ngxConfFlag"}, guard: "NGX_HTTP_SSL"},
+				{name: "v2_only", mask: Mask{"ngxHTTPSrvConf", "ngxConfFlag"}, guard: "!NGX_HTTP_SSL && defined(NGX_HTTP_V2)"},
+				{name: "neither", mask: Mask{"ngxHTTPSrvConf", "ngxConfFlag"}, guard: "!NGX_HTTP_SSL && !defined(NGX_HTTP_V2)"},
+				{name: "no_ssl", mask: Mask{"ngxHTTPSrvConf", "ngxConfFlag"}, guard: "!NGX_HTTP_SSL"},
+				{name: "ssl_or_compat", mask: Mask{"ngxHTTPSrvConf", "ngxConfFlag"}, guard: "(NGX_HTTP_SSL || NGX_COMPAT) && 1"},
+			},
+		},
+		"defined macros skip the other branches": {
+			src:    conditionalCommands,
+			macros: map[string]bool{"NGX_HTTP_SSL": true},
+			want: []cCommand{
+				{name: "ssl_only", mask: Mask{"ngxHTTPSrvConf", "ngxConfFlag"}},
+				{name: "ssl_or_compat", mask: Mask{"ngxHTTPSrvConf", "ngxConfFlag"}},
+			},
+		},
+		"undefined macros skip their branches": {
+			src:    conditionalCommands,
+			macros: map[string]bool{"NGX_HTTP_SSL": false, "NGX_HTTP_V2": true},
+			want: []cCommand{
+				{name: "v2_only", mask: Mask{"ngxHTTPSrvConf", "ngxConfFlag"}},
+				{name: "no_ssl", mask: Mask{"ngxHTTPSrvConf", "ngxConfFlag"}},
+				{name: "ssl_or_compat", mask: Mask{"ngxHTTPSrvConf", "ngxConfFlag"}, guard: "(NGX_HTTP_SSL || NGX_COMPAT) && 1"},
+			},
+		},
+		"literals and comments": {
+			src: `
+// static ngx_command_t commented[] = { { ngx_string("commented"), NGX_MAIN_CONF|NGX_CONF_FLAG } };
+static ngx_command_t  commands[] = {
+    { ngx_string("escaped"), NGX_MAIN_CONF|NGX_CONF_FLAG, 0, 0, 0, "\"}, {" },
+    { ngx_string("char"), NGX_MAIN_CONF /* | NGX_CONF_BLOCK } */ | NGX_CONF_FLAG, 0, 0, 0, '{' },
+    ngx_null_command
+};
+ngx_command_t *cmd;
+`,
+			want: []cCommand{
+				{name: "escaped", mask: Mask{"ngxMainConf", "ngxConfFlag"}},
+				{name: "char", mask: Mask{"ngxMainConf", "ngxConfFlag"}},
+			},
+		},
+		"macros as type": {
+			src: `
+#define MY_CONF NGX_HTTP_MAIN_CONF \
+    |NGX_HTTP_SRV_CONF
+#define MY_TAKE(n) NGX_CONF_TAKE1
+static ngx_command_t  commands[] = {
+    { ngx_string("macro"), (MY_CONF|NGX_CONF_TAKE1), 0, 0, 0, NULL },
+    ngx_null_command
+};
+`,
+			want: []cCommand{
+				{name: "macro", mask: Mask{"ngxHTTPMainConf", "ngxHTTPSrvConf", "ngxConfTake1"}},
+			},
+		},
+		"unknown bitmasks keep their names": {
+			src: `
+static ngx_command_t  commands[] = {
+    { ngx_string("unknown"), NGX_HTTP_MAIN_CONF|NGX_CONF_UNKNOWN, 0, 0, 0, NULL },
+    ngx_null_command
+};
+`,
+			want: []cCommand{
+				{name: "unknown", mask: Mask{"ngxHTTPMainConf", "NGX_CONF_UNKNOWN"}},
+			},
+		},
+		"setters and value tables": {
+			src: `
+static ngx_conf_enum_t  modes[] = {
+    { ngx_string("on"), 1 },
+#if (NGX_MY_CLEAN)
+    { ngx_string("clean"), 2 },
+#endif
+    { ngx_null_string, 0 }
+};
+static ngx_command_t  commands[] = {
+    { ngx_string("mode"), NGX_MAIN_CONF|NGX_CONF_TAKE1, ngx_conf_set_enum_slot, 0, 0, &modes },
+    ngx_null_command
+};
+`,
+			macros: map[string]bool{"NGX_MY_CLEAN": false},
+			want: []cCommand{
+				{
+					name:    "mode",
+					mask:    Mask{"ngxMainConf", "ngxConfTake1"},
+					handler: "ngx_conf_set_enum_slot",
+					post:    "modes",
+					values:  []string{"on"},
+				},
+			},
+		},
+		"recursive macro": {
+			src: `
+#define MY_CONF MY_CONF|NGX_CONF_TAKE1
+static ngx_command_t  commands[] = {
+    { ngx_string("recursive"), MY_CONF, 0, 0, 0, NULL },
+    ngx_null_command
+};
+`,
+			wantErr: true,
+		},
+		"unterminated comment": {
+			src:     `static ngx_command_t  commands[] = { /* `,
+			wantErr: true,
+		},
+		"endif without if": {
+			src:     "#endif\n",
+			wantErr: true,
+		},
+	}
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			got, err := extractCommands(tc.src, tc.macros)
+			if tc.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.want, got)
+		})
+	}
+}
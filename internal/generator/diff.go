@@ -0,0 +1,354 @@
+/**
+ * Copyright (c) F5, Inc.
+ *
+ * This source code is licensed under the Apache License, Version 2.0 license found in the
+ * LICENSE file in the root directory of this source tree.
+ */
+
+package generator
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DiffReport is the difference between the directives of two versions of a module,
+// like nginx plus R36 and R37.
+type DiffReport struct {
+	// Added are the directives that only the new version has.
+	Added []DirectiveChange `json:"added"`
+	// Removed are the directives that only the old version has.
+	Removed []DirectiveChange `json:"removed"`
+	// Changed are the directives whose masks changed.
+	Changed []DirectiveChange `json:"changed"`
+	// Config is the filter and override that make the generator keep the directives
+	// of the old version when it generates the new one. Removed directives can't
+	// be kept with them.
+	Config DiffConfig `json:"config"`
+}
+
+// DirectiveChange is a directive that is added, removed or changed.
+type DirectiveChange struct {
+	Directive string `json:"directive"`
+	Old       []Mask `json:"old,omitempty"`
+	New       []Mask `json:"new,omitempty"`
+	// AddedContexts and RemovedContexts are the contexts that a changed directive
+	// is allowed in now, and isn't anymore, like "ngxHTTPLocConf".
+	AddedContexts   []string `json:"addedContexts,omitempty"`
+	RemovedContexts []string `json:"removedContexts,omitempty"`
+	// AddedArgs and RemovedArgs are the argument masks of a changed directive that
+	// are new and gone, like "ngxConfTake2".
+	AddedArgs   []string `json:"addedArgs,omitempty"`
+	RemovedArgs []string `json:"removedArgs,omitempty"`
+}
+
+// DiffConfig is the part of a GenerateConfig that a DiffReport suggests, in the
+// format of the json config of the generator.
+type DiffConfig struct {
+	Filter   []string          `json:"filter,omitempty"`
+	Override map[string][]Mask `json:"override,omitempty"`
+}
+
+// Diff compares the directives of oldPath and newPath, which are either the paths
+// of source code like the ones Generate receives, or generated .go files. The source
+// code is extracted with the Filter, Override and Macros of config.
+func Diff(oldPath string, newPath string, config GenerateConfig) (*DiffReport, error) {
+	oldMasks, err := directiveMasksOf(oldPath, config)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", oldPath, err)
+	}
+	newMasks, err := directiveMasksOf(newPath, config)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", newPath, err)
+	}
+	return diffMasks(oldMasks, newMasks), nil
+}
+
+func directiveMasksOf(path string, config GenerateConfig) (map[string][]Mask, error) {
+	if strings.HasSuffix(path, ".go") {
+		return masksFromGoFile(path)
+	}
+	dm, err := directivesFromSrcCode(path, config)
+	if err != nil {
+		return nil, err
+	}
+	return dm.masks, nil
+}
+
+// masksFromGoFile returns the masks of the first map[string][]uint variable of a
+// file generated by Generate.
+func masksFromGoFile(path string) (map[string][]Mask, error) {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return masksFromGoSrc(path, src)
+}
+
+// masksFromGoSrc is masksFromGoFile for the content of the file.
+func masksFromGoSrc(filename string, src []byte) (map[string][]Mask, error) {
+	file, err := parser.ParseFile(token.NewFileSet(), filename, src, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, decl := range file.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.VAR {
+			continue
+		}
+		for _, spec := range gen.Specs {
+			for _, value := range spec.(*ast.ValueSpec).Values {
+				lit, ok := value.(*ast.CompositeLit)
+				if ok && isDirectivesMapType(lit.Type) {
+					return masksFromMapLit(lit)
+				}
+			}
+		}
+	}
+	return nil, errors.New("can't find a map[string][]uint variable of directives")
+}
+
+func isDirectivesMapType(expr ast.Expr) bool {
+	m, ok := expr.(*ast.MapType)
+	if !ok {
+		return false
+	}
+	key, ok := m.Key.(*ast.Ident)
+	if !ok || key.Name != "string" {
+		return false
+	}
+	value, ok := m.Value.(*ast.ArrayType)
+	if !ok || value.Len != nil {
+		return false
+	}
+	elt, ok := value.Elt.(*ast.Ident)
+	return ok && elt.Name == "uint"
+}
+
+func masksFromMapLit(lit *ast.CompositeLit) (map[string][]Mask, error) {
+	directive2Masks := make(map[string][]Mask, len(lit.Elts))
+	for _, elt := range lit.Elts {
+		kv, ok := elt.(*ast.KeyValueExpr)
+		if !ok {
+			return nil, errors.New("directives must be keys of the map")
+		}
+		key, ok := kv.Key.(*ast.BasicLit)
+		if !ok || key.Kind != token.STRING {
+			return nil, errors.New("directives must be string literals")
+		}
+		directive, err := strconv.Unquote(key.Value)
+		if err != nil {
+			return nil, err
+		}
+		masks, ok := kv.Value.(*ast.CompositeLit)
+		if !ok {
+			return nil, fmt.Errorf("the masks of directive %s must be a slice literal", directive)
+		}
+		for _, expr := range masks.Elts {
+			var mask Mask
+			if mask, err = maskFromExpr(expr); err != nil {
+				return nil, fmt.Errorf("directive %s: %w", directive, err)
+			}
+			directive2Masks[directive] = append(directive2Masks[directive], mask)
+		}
+	}
+	return directive2Masks, nil
+}
+
+// maskFromExpr returns the names of an expression like ngxHTTPMainConf | ngxConfTake1.
+func maskFromExpr(expr ast.Expr) (Mask, error) {
+	switch e := expr.(type) {
+	case *ast.Ident:
+		return Mask{e.Name}, nil
+	case *ast.ParenExpr:
+		return maskFromExpr(e.X)
+	case *ast.BinaryExpr:
+		if e.Op != token.OR {
+			return nil, fmt.Errorf("unsupported operator %s in a mask", e.Op)
+		}
+		x, err := maskFromExpr(e.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := maskFromExpr(e.Y)
+		if err != nil {
+			return nil, err
+		}
+		return append(x, y...), nil
+	}
+	return nil, errors.New("masks must be names joined by |")
+}
+
+func diffMasks(oldMasks, newMasks map[string][]Mask) *DiffReport {
+	report := &DiffReport{Added: []DirectiveChange{}, Removed: []DirectiveChange{}, Changed: []DirectiveChange{}}
+	for _, d := range sortedDirectives(newMasks) {
+		old, found := oldMasks[d]
+		switch {
+		case !found:
+			report.Added = append(report.Added, DirectiveChange{Directive: d, New: newMasks[d]})
+			report.Config.Filter = append(report.Config.Filter, d)
+		case !sameMasks(old, newMasks[d]):
+			change := DirectiveChange{Directive: d, Old: old, New: newMasks[d]}
+			oldContexts, oldArgs := maskNameSets(old)
+			newContexts, newArgs := maskNameSets(newMasks[d])
+			change.AddedContexts = setDiff(newContexts, oldContexts)
+			change.RemovedContexts = setDiff(oldContexts, newContexts)
+			change.AddedArgs = setDiff(newArgs, oldArgs)
+			change.RemovedArgs = setDiff(oldArgs, newArgs)
+			report.Changed = append(report.Changed, change)
+			if report.Config.Override == nil {
+				report.Config.Override = map[string][]Mask{}
+			}
+			report.Config.Override[d] = old
+		}
+	}
+	for _, d := range sortedDirectives(oldMasks) {
+		if _, found := newMasks[d]; !found {
+			report.Removed = append(report.Removed, DirectiveChange{Directive: d, Old: oldMasks[d]})
+		}
+	}
+	return report
+}
+
+func sortedDirectives(directive2Masks map[string][]Mask) []string {
+	directives := make([]string, 0, len(directive2Masks))
+	for d := range directive2Masks {
+		directives = append(directives, d)
+	}
+	sort.Strings(directives)
+	return directives
+}
+
+// sameMasks returns whether two lists of masks are the same, regardless of the
+// order of the masks and of the names in them.
+func sameMasks(a, b []Mask) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	x, y := normalizeMasks(a), normalizeMasks(b)
+	for i := range x {
+		if x[i] != y[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func normalizeMasks(masks []Mask) []string {
+	normalized := make([]string, 0, len(masks))
+	for _, mask := range masks {
+		names := append([]string{}, mask...)
+		sort.Strings(names)
+		normalized = append(normalized, strings.Join(names, "|"))
+	}
+	sort.Strings(normalized)
+	return normalized
+}
+
+// maskNameSets returns the names of contexts and the names of argument masks in masks.
+// The names of argument masks are the ones that start with ngxConf.
+func maskNameSets(masks []Mask) (map[string]bool, map[string]bool) {
+	contexts, args := map[string]bool{}, map[string]bool{}
+	for _, mask := range masks {
+		for _, name := range mask {
+			if strings.HasPrefix(name, "ngxConf") {
+				args[name] = true
+			} else {
+				contexts[name] = true
+			}
+		}
+	}
+	return contexts, args
+}
+
+// setDiff returns the sorted names that are in a but not in b.
+func setDiff(a, b map[string]bool) []string {
+	var names []string
+	for name := range a {
+		if !b[name] {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// WriteMarkdown writes the report as Markdown.
+func (r *DiffReport) WriteMarkdown(w io.Writer) error {
+	var b strings.Builder
+	b.WriteString("# Directive changes\n")
+
+	writeChanges := func(title string, changes []DirectiveChange, describe func(DirectiveChange)) {
+		fmt.Fprintf(&b, "\n## %s (%d)\n\n", title, len(changes))
+		if len(changes) == 0 {
+			b.WriteString("None.\n")
+		}
+		for _, change := range changes {
+			describe(change)
+		}
+	}
+	writeChanges("Added", r.Added, func(c DirectiveChange) {
+		fmt.Fprintf(&b, "- `%s`: %s\n", c.Directive, markdownMasks(c.New))
+	})
+	writeChanges("Removed", r.Removed, func(c DirectiveChange) {
+		fmt.Fprintf(&b, "- `%s`: %s\n", c.Directive, markdownMasks(c.Old))
+	})
+	writeChanges("Changed", r.Changed, func(c DirectiveChange) {
+		var parts []string
+		for _, names := range []struct {
+			label string
+			names []string
+		}{
+			{"added contexts", c.AddedContexts},
+			{"removed contexts", c.RemovedContexts},
+			{"added arguments", c.AddedArgs},
+			{"removed arguments", c.RemovedArgs},
+		} {
+			if len(names.names) > 0 {
+				parts = append(parts, fmt.Sprintf("%s `%s`", names.label, strings.Join(names.names, "`, `")))
+			}
+		}
+		if len(parts) == 0 {
+			parts = append(parts, "masks combined differently")
+		}
+		fmt.Fprintf(&b, "- `%s`: %s\n", c.Directive, strings.Join(parts, "; "))
+		fmt.Fprintf(&b, "  - old: %s\n", markdownMasks(c.Old))
+		fmt.Fprintf(&b, "  - new: %s\n", markdownMasks(c.New))
+	})
+
+	b.WriteString("\n## Config\n\n")
+	if len(r.Config.Filter) == 0 && len(r.Config.Override) == 0 {
+		b.WriteString("The new version keeps the directives of the old one without a config.\n")
+	} else {
+		config, err := json.MarshalIndent(r.Config, "", "    ")
+		if err != nil {
+			return err
+		}
+		b.WriteString("Add this to the json config of the new version to keep the directives of the old one:\n\n")
+		fmt.Fprintf(&b, "```json\n%s\n```\n", config)
+	}
+	if len(r.Removed) > 0 {
+		b.WriteString("\nThe removed directives can't be kept with a config.\n")
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func markdownMasks(masks []Mask) string {
+	quoted := make([]string, 0, len(masks))
+	for _, mask := range masks {
+		quoted = append(quoted, "`"+strings.Join(mask, "|")+"`")
+	}
+	return strings.Join(quoted, ", ")
+}
@@ -0,0 +1,101 @@
+/**
+ * Copyright (c) F5, Inc.
+ *
+ * This source code is licensed under the Apache License, Version 2.0 license found in the
+ * LICENSE file in the root directory of this source tree.
+ */
+
+package generator
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiff(t *testing.T) {
+	t.Parallel()
+	oldPath, err := getTestSrcCodePath("diffOld")
+	require.NoError(t, err)
+	newPath, err := getTestSrcCodePath("diffNew")
+	require.NoError(t, err)
+
+	report, err := Diff(oldPath, newPath, GenerateConfig{})
+	require.NoError(t, err)
+	require.Equal(t, &DiffReport{
+		Added: []DirectiveChange{
+			{Directive: "my_added", New: []Mask{{"ngxStreamMainConf", "ngxConfTake1"}}},
+		},
+		Removed: []DirectiveChange{
+			{Directive: "my_removed", Old: []Mask{{"ngxHTTPMainConf", "ngxConfTake1"}}},
+		},
+		Changed: []DirectiveChange{
+			{
+				Directive:       "my_changed",
+				Old:             []Mask{{"ngxHTTPMainConf", "ngxHTTPSrvConf", "ngxConfTake1"}},
+				New:             []Mask{{"ngxHTTPMainConf", "ngxHTTPLocConf", "ngxConfTake12"}},
+				AddedContexts:   []string{"ngxHTTPLocConf"},
+				RemovedContexts: []string{"ngxHTTPSrvConf"},
+				AddedArgs:       []string{"ngxConfTake12"},
+				RemovedArgs:     []string{"ngxConfTake1"},
+			},
+		},
+		Config: DiffConfig{
+			Filter: []string{"my_added"},
+			Override: map[string][]Mask{
+				"my_changed": {{"ngxHTTPMainConf", "ngxHTTPSrvConf", "ngxConfTake1"}},
+			},
+		},
+	}, report)
+
+	var buf bytes.Buffer
+	require.NoError(t, report.WriteMarkdown(&buf))
+	expectedFilePth, err := getExpectedFilePath("diff.md")
+	require.NoError(t, err)
+	if *update {
+		require.NoError(t, os.WriteFile(expectedFilePth, buf.Bytes(), 0o600))
+		return
+	}
+	expected, err := os.ReadFile(expectedFilePth)
+	require.NoError(t, err)
+	require.Equal(t, string(expected), buf.String())
+
+	// the suggested config makes the new version generate the old directives
+	dm, err := directivesFromSrcCode(newPath, GenerateConfig{
+		Filter:   Filters{"my_added": {}},
+		Override: report.Config.Override,
+	})
+	require.NoError(t, err)
+	oldMasks, err := directiveMasksOf(oldPath, GenerateConfig{})
+	require.NoError(t, err)
+	delete(oldMasks, "my_removed")
+	require.Empty(t, diffMasks(oldMasks, dm.masks).Changed)
+	require.Empty(t, diffMasks(oldMasks, dm.masks).Added)
+}
+
+func TestMasksFromGoFile(t *testing.T) {
+	t.Parallel()
+	codePath, err := getTestSrcCodePath("fullNgxBitmaskCover")
+	require.NoError(t, err)
+	dm, err := directivesFromSrcCode(codePath, GenerateConfig{})
+	require.NoError(t, err)
+
+	// the expected files of the generator are generated .go files without their extension
+	generated, err := getExpectedFilePath("fullNgxBitmaskCover")
+	require.NoError(t, err)
+	goFile := filepath.Join(t.TempDir(), "generated.go")
+	content, err := os.ReadFile(generated)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(goFile, content, 0o600))
+
+	masks, err := directiveMasksOf(goFile, GenerateConfig{})
+	require.NoError(t, err)
+	require.Equal(t, dm.masks, masks)
+
+	require.NoError(t, os.WriteFile(goFile, []byte("package crossplane\n\nvar x = 1\n"), 0o600))
+	_, err = directiveMasksOf(goFile, GenerateConfig{})
+	require.Error(t, err)
+}
@@ -0,0 +1,243 @@
+/**
+ * Copyright (c) F5, Inc.
+ *
+ * This source code is licensed under the Apache License, Version 2.0 license found in the
+ * LICENSE file in the root directory of this source tree.
+ */
+
+package generator
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"go/format"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// A DirectiveDoc is the documentation of a directive in one of the modules of nginx.
+type DirectiveDoc struct {
+	Name   string
+	Module string
+	// Syntax has a line for every way the directive can be written, e.g.
+	// "proxy_read_timeout time;".
+	Syntax []string
+	// Default is the directive with its default value, e.g. "proxy_read_timeout 60s;",
+	// or empty if it has none.
+	Default    string
+	Contexts   []string
+	AppearedIn string
+	// Description is the first paragraph of the documentation, as plain text.
+	Description string
+}
+
+type docsFileTmplStruct struct {
+	Directive2Docs  map[string][]DirectiveDoc
+	MapVariableName string
+}
+
+// Template of docs file. A docs file contains a map from directive to
+// its documentation in every module that has it.
+//
+//go:embed tmpl/docs_file.tmpl
+var docsFileTmplStr string
+
+//nolint:gochecknoglobals
+var docsFileTmpl = template.Must(template.New("docsFile").
+	Funcs(template.FuncMap{"Quote": strconv.Quote}).Parse(docsFileTmplStr))
+
+// the parts of the XML of a module of the nginx documentation, e.g.
+// xml/en/docs/http/ngx_http_proxy_module.xml, that describe its directives.
+type xmlDirective struct {
+	Name       string      `xml:"name,attr"`
+	Syntax     []xmlSyntax `xml:"syntax"`
+	Default    []xmlText   `xml:"default"`
+	Contexts   []string    `xml:"context"`
+	AppearedIn string      `xml:"appeared-in"`
+	Paras      []xmlText   `xml:"para"`
+}
+
+type xmlSyntax struct {
+	Block string `xml:"block,attr"`
+	xmlText
+}
+
+type xmlText struct {
+	Inner string `xml:",innerxml"`
+}
+
+// text returns the text of t without its markup, with whitespace collapsed.
+func (t xmlText) text() string {
+	d := newXMLDecoder(strings.NewReader("<text>" + t.Inner + "</text>"))
+	var sb strings.Builder
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			break
+		}
+		if data, ok := tok.(xml.CharData); ok {
+			sb.Write(data)
+		}
+	}
+	return strings.Join(strings.Fields(sb.String()), " ")
+}
+
+func newXMLDecoder(r io.Reader) *xml.Decoder {
+	d := xml.NewDecoder(r)
+	// the sources use HTML entities like &nbsp; that are declared in their DTDs
+	d.Strict = false
+	d.Entity = xml.HTMLEntity
+	return d
+}
+
+//nolint:nonamedreturns
+func docsFromFile(path string) (directive2Docs map[string][]DirectiveDoc, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	directive2Docs = make(map[string][]DirectiveDoc)
+	var module string
+	d := newXMLDecoder(f)
+	for {
+		tok, terr := d.Token()
+		if errors.Is(terr, io.EOF) {
+			break
+		}
+		if terr != nil {
+			return nil, fmt.Errorf("%s: %w", path, terr)
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+
+		switch start.Name.Local {
+		case "module":
+			for _, attr := range start.Attr {
+				if attr.Name.Local == "name" {
+					module = strings.TrimPrefix(attr.Value, "Module ")
+				}
+			}
+		case "directive":
+			var x xmlDirective
+			if derr := d.DecodeElement(&x, &start); derr != nil {
+				return nil, fmt.Errorf("%s: %w", path, derr)
+			}
+			if x.Name == "" {
+				continue
+			}
+			directive2Docs[x.Name] = append(directive2Docs[x.Name], newDirectiveDoc(module, &x))
+		}
+	}
+	return directive2Docs, nil
+}
+
+func newDirectiveDoc(module string, x *xmlDirective) DirectiveDoc {
+	doc := DirectiveDoc{
+		Name:       x.Name,
+		Module:     module,
+		Syntax:     []string{},
+		Contexts:   []string{},
+		AppearedIn: strings.TrimSpace(x.AppearedIn),
+	}
+	for _, syntax := range x.Syntax {
+		line := x.Name
+		if args := syntax.text(); args != "" {
+			line += " " + args
+		}
+		if syntax.Block == "yes" {
+			line += " { ... }"
+		} else {
+			line += ";"
+		}
+		doc.Syntax = append(doc.Syntax, line)
+	}
+	for _, def := range x.Default {
+		if value := def.text(); value != "" {
+			doc.Default = x.Name + " " + value + ";"
+			break
+		}
+	}
+	for _, ctx := range x.Contexts {
+		doc.Contexts = append(doc.Contexts, strings.Join(strings.Fields(ctx), " "))
+	}
+	if len(x.Paras) > 0 {
+		doc.Description = x.Paras[0].text()
+	}
+	return doc
+}
+
+//nolint:nonamedreturns
+func getDocsFromPath(path string) (directive2Docs map[string][]DirectiveDoc, err error) {
+	directive2Docs = make(map[string][]DirectiveDoc)
+
+	err = filepath.WalkDir(path, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !strings.HasSuffix(path, ".xml") {
+			return nil
+		}
+
+		directive2DocsInFile, err := docsFromFile(path)
+		if err != nil {
+			return err
+		}
+		for directive, docs := range directive2DocsInFile {
+			directive2Docs[directive] = append(directive2Docs[directive], docs...)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(directive2Docs) == 0 {
+		return nil, errors.New("can't find any directives in the XML files of the directory and subdirectories, please check the path")
+	}
+
+	// files are walked in lexical order, but modules are sorted by name
+	for _, docs := range directive2Docs {
+		sort.SliceStable(docs, func(i, j int) bool { return docs[i].Module < docs[j].Module })
+	}
+	return directive2Docs, nil
+}
+
+func genFromDocs(docsPath string, writer io.Writer, config GenerateConfig) error {
+	directive2Docs, err := getDocsFromPath(docsPath)
+	if err != nil {
+		return err
+	}
+
+	for d := range config.Filter {
+		delete(directive2Docs, d)
+	}
+
+	var buf bytes.Buffer
+	err = docsFileTmpl.Execute(&buf, docsFileTmplStruct{
+		Directive2Docs:  directive2Docs,
+		MapVariableName: config.DirectiveMapName,
+	})
+	if err != nil {
+		return err
+	}
+
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return err
+	}
+	_, err = writer.Write(src)
+	return err
+}
@@ -0,0 +1,75 @@
+/**
+ * Copyright (c) F5, Inc.
+ *
+ * This source code is licensed under the Apache License, Version 2.0 license found in the
+ * LICENSE file in the root directory of this source tree.
+ */
+
+package generator
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenFromDocs(t *testing.T) {
+	t.Parallel()
+	tests := map[string]struct {
+		relativePath string
+		expectedPath string
+		wantErr      bool
+		config       GenerateConfig
+	}{
+		"docs_pass": {
+			relativePath: "docs",
+			expectedPath: "docs",
+			config: GenerateConfig{
+				DirectiveMapName: "directiveDocs",
+			},
+		},
+		"docsFilter_pass": {
+			relativePath: "docs",
+			expectedPath: "docsFilter",
+			config: GenerateConfig{
+				DirectiveMapName: "directiveDocs",
+				Filter:           map[string]struct{}{"example_pass": {}, "example_zone": {}},
+			},
+		},
+		"noDocs_fail": {
+			relativePath: "noDocs",
+			config: GenerateConfig{
+				DirectiveMapName: "directiveDocs",
+			},
+			wantErr: true,
+		},
+	}
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			docsPath, err := getTestSrcCodePath(tc.relativePath)
+			require.NoError(t, err)
+
+			var buf bytes.Buffer
+			err = genFromDocs(docsPath, &buf, tc.config)
+			if tc.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+
+			expectedPath, err := getExpectedFilePath(tc.expectedPath)
+			require.NoError(t, err)
+			if *update {
+				require.NoError(t, os.WriteFile(expectedPath, buf.Bytes(), 0o600))
+				return
+			}
+			expected, err := os.ReadFile(expectedPath)
+			require.NoError(t, err)
+			require.Equal(t, string(expected), buf.String())
+		})
+	}
+}
@@ -60,6 +60,57 @@ type GenerateConfig struct {
 	// in the generated MatchFunc. Generally it should start with MatchFuncName.
 	// If it is empty, no comments will appear above the generated MatchFunc.
 	MatchFuncComment string `json:"matchFuncComment"`
+
+	// Macros are the feature macros of nginx, like NGX_HTTP_SSL, that are known to be
+	// defined (true) or not (false). Directives that are defined in preprocessor
+	// conditions that are false with them are skipped. Directives in conditions on
+	// other macros are kept, with a comment of the conditions in the generated code.
+	Macros map[string]bool `json:"macros"`
+
+	// MapBodies are the map-like block directives of the module, whose bodies are
+	// made of parameters instead of directives. The key of it is the directive name.
+	// If it isn't empty, a function named MapBodiesFuncName that returns them is
+	// generated too.
+	MapBodies map[string]MapBodyMasks `json:"mapBodies"`
+
+	// MapBodiesFuncName is the name assigned to the function that returns MapBodies.
+	// It should generally start with a uppercase to export. It should not be
+	// empty if MapBodies isn't.
+	MapBodiesFuncName string `json:"mapBodiesFuncName"`
+
+	// DetectBlocks makes the generator analyze the handlers of block directives that
+	// call ngx_conf_parse. A block whose handler sets cf->handler is map-like, and is
+	// added to MapBodies unless it's there already, with the masks of the parameters
+	// guessed from the numbers of arguments the handler checks. A block whose handler
+	// sets cf->cmd_type to a bitmask that crossplane doesn't know opens a new block
+	// context, which is registered with the directives of that bitmask by a function
+	// named ContextsFuncName. Only handlers in the same file as the directive are analyzed.
+	DetectBlocks bool `json:"detectBlocks"`
+
+	// ContextsFuncName is the name assigned to the function that registers the block
+	// contexts found with DetectBlocks. It should generally start with a uppercase
+	// to export. It should not be empty if block contexts are found.
+	ContextsFuncName string `json:"contextsFuncName"`
+
+	// ArgTypesFuncName is the name assigned to the function that returns the types
+	// of the arguments of the directives, as a map[string]crossplane.ArgType. The
+	// type of a directive is the one of the setter of nginx that sets it, like
+	// ngx_conf_set_size_slot, with the members of its ngx_conf_enum_t or
+	// ngx_conf_bitmask_t table. Directives with other handlers have none. It should
+	// generally start with a uppercase to export. If it is empty, the types aren't
+	// generated.
+	ArgTypesFuncName string `json:"argTypesFuncName"`
+}
+
+// MapBodyMasks are the masks of the parameters in the body of a map-like block directive.
+type MapBodyMasks struct {
+	// Params is the mask of the arguments that the parameters take after their name,
+	// e.g. Mask{"ngxConfTake1"}.
+	Params Mask `json:"params"`
+
+	// SpecialParams are the masks of the parameters that take other arguments,
+	// e.g. {"hostnames": Mask{"ngxConfNoArgs"}}.
+	SpecialParams map[string]Mask `json:"specialParams"`
 }
 
 // Generate receives a string sourcePath, an io.Writer writer, and a
@@ -69,3 +120,22 @@ type GenerateConfig struct {
 func Generate(sourcePath string, writer io.Writer, config GenerateConfig) error {
 	return genFromSrcCode(sourcePath, writer, config)
 }
+
+// GenerateDefinitions receives a string sourcePath, an io.Writer writer, and a
+// GenerateConfig config. It will extract all the directives definitions from the
+// .c and .cpp files in sourcePath and its subdirectories like Generate, then output
+// them as JSON via writer, in the format that crossplane.LoadDirectives reads.
+// Only the Filter and Override of config are used.
+func GenerateDefinitions(sourcePath string, writer io.Writer, config GenerateConfig) error {
+	return genDefinitionsFromSrcCode(sourcePath, writer, config)
+}
+
+// GenerateDocs receives a string docsPath, an io.Writer writer, and a
+// GenerateConfig config. It will extract the documentation of all the directives
+// from the .xml files in docsPath and its subdirectories, which is a checkout of
+// the sources of the nginx documentation like xml/en/docs of nginx.org, then
+// output the map from directives to their documentation via writer. Only the
+// DirectiveMapName and Filter of config are used.
+func GenerateDocs(docsPath string, writer io.Writer, config GenerateConfig) error {
+	return genFromDocs(docsPath, writer, config)
+}
@@ -9,15 +9,15 @@ package generator
 
 import (
 	_ "embed"
+	"encoding/json"
 	"errors"
 	"fmt"
-	"html/template"
 	"io"
 	"io/fs"
 	"os"
 	"path/filepath"
-	"regexp"
 	"strings"
+	"text/template"
 )
 
 // A Mask is a list of string, includes several variable names,
@@ -28,28 +28,26 @@ type Mask []string
 
 type supportFileTmplStruct struct {
 	Directive2Masks map[string][]Mask
+	Guards          map[string][]string
 	MapVariableName string
 	MatchFnName     string
 	MatchFnComment  string
+	MapBodies       map[string]MapBodyMasks
+	MapBodiesFnName string
+	Contexts        []contextRegistration
+	ContextsFnName  string
+	ArgTypes        map[string]argType
+	ArgTypesFnName  string
 }
 
-var (
-	// Extract single directive definition block
-	// static ngx_command_t  {name}[] = {definition}
-	// this regex extracts {name} and {definition}.
-	directivesDefineBlockExtracter = regexp.MustCompile(`ngx_command_t\s+(\w+)\[\]\s*=\s*{(.*?)}\s*;`)
-
-	// Extract one directive definition and attributes from extracted block
-	// { ngx_string({directive_name}),
-	//   {bitmask1|bitmask2|...},
-	//   ... },
-	// this regex extracts {directive_name} and {bitmask1|bitmask2|...}.
-	singleDirectiveExtracter = regexp.MustCompile(`ngx_string\("(.*?)"\).*?,(.*?),`)
-
-	singleLineCommentExtracter = regexp.MustCompile(`//.*`)
-
-	multiLineCommentExtracter = regexp.MustCompile(`/\*[\s\S]*?\*/`)
-)
+// Guard returns the preprocessor conditions that the i-th mask of a directive is
+// defined in, or an empty string if it's always defined.
+func (s supportFileTmplStruct) Guard(directive string, i int) string {
+	if guards := s.Guards[directive]; i < len(guards) {
+		return guards[i]
+	}
+	return ""
+}
 
 // Template of support file. A support file contains a map from
 // diective to its bitmask definitions, and a MatchFunc for it.
@@ -61,6 +59,17 @@ var supportFileTmplStr string
 var supportFileTmpl = template.Must(template.New("supportFile").
 	Funcs(template.FuncMap{"Join": strings.Join}).Parse(supportFileTmplStr))
 
+// goVarNames are the values of ngxVarNameToGo.
+//
+//nolint:gochecknoglobals
+var goVarNames = func() map[string]bool {
+	names := make(map[string]bool, len(ngxVarNameToGo))
+	for _, name := range ngxVarNameToGo {
+		names[name] = true
+	}
+	return names
+}()
+
 //nolint:gochecknoglobals
 var ngxVarNameToGo = map[string]string{
 	"NGX_MAIL_MAIN_CONF":   "ngxMailMainConf",
@@ -97,57 +106,101 @@ var ngxVarNameToGo = map[string]string{
 	"NGX_CONF_TAKE5":       "ngxConfTake5",
 	"NGX_CONF_TAKE6":       "ngxConfTake6",
 	"NGX_CONF_TAKE7":       "ngxConfTake7",
+	"NGX_MGMT_MAIN_CONF":   "ngxMgmtMainConf",
+	"NGX_HTTP_OIDC_CONF":   "ngxHTTPOIDCConf",
+}
+
+// directiveMasks are the masks of directives, with the preprocessor conditions
+// that each mask is defined in, and the blocks that the directives open.
+type directiveMasks struct {
+	masks  map[string][]Mask
+	guards map[string][]string
+	// mapBodies and contexts are the map-like blocks and the block contexts
+	// detected in the source code, if GenerateConfig.DetectBlocks is set.
+	mapBodies map[string]MapBodyMasks
+	contexts  []contextRegistration
+	// argTypes are the types of the arguments of the directives that are set with
+	// the setters of nginx, like ngx_conf_set_size_slot.
+	argTypes map[string]argType
+}
+
+// argType is the type of the arguments of a directive, with the name of its
+// crossplane.ArgKind in Go.
+type argType struct {
+	Kind   string
+	Values []string
 }
 
-//nolint:nonamedreturns
-func masksFromFile(path string) (directive2Masks map[string][]Mask, err error) {
-	directive2Masks = make(map[string][]Mask, 0)
-	byteContent, err := os.ReadFile(path)
+// setterKinds are the crossplane.ArgKind of the setters of nginx.
+//
+//nolint:gochecknoglobals
+var setterKinds = map[string]string{
+	"ngx_conf_set_flag_slot":      "ArgKindFlag",
+	"ngx_conf_set_str_slot":       "ArgKindStr",
+	"ngx_conf_set_str_array_slot": "ArgKindStr",
+	"ngx_conf_set_keyval_slot":    "ArgKindKeyval",
+	"ngx_conf_set_num_slot":       "ArgKindNum",
+	"ngx_conf_set_size_slot":      "ArgKindSize",
+	"ngx_conf_set_off_slot":       "ArgKindOffset",
+	"ngx_conf_set_msec_slot":      "ArgKindMsec",
+	"ngx_conf_set_sec_slot":       "ArgKindSec",
+	"ngx_conf_set_bufs_slot":      "ArgKindBufs",
+	"ngx_conf_set_enum_slot":      "ArgKindEnum",
+	"ngx_conf_set_bitmask_slot":   "ArgKindBitmask",
+	"ngx_conf_set_path_slot":      "ArgKindPath",
+	"ngx_conf_set_access_slot":    "ArgKindAccess",
+}
+
+func newDirectiveMasks() directiveMasks {
+	return directiveMasks{
+		masks:     map[string][]Mask{},
+		guards:    map[string][]string{},
+		mapBodies: map[string]MapBodyMasks{},
+		argTypes:  map[string]argType{},
+	}
+}
+
+// addArgType records the type of the arguments of cmd. Directives that are defined
+// with different types, e.g. in http and stream, have none.
+func (dm directiveMasks) addArgType(cmd cCommand, conflicts map[string]bool) {
+	kind, ok := setterKinds[cmd.handler]
+	if !ok || conflicts[cmd.name] {
+		return
+	}
+	t := argType{Kind: kind}
+	if kind == "ArgKindEnum" || kind == "ArgKindBitmask" {
+		t.Values = cmd.values
+	}
+	if prev, found := dm.argTypes[cmd.name]; found && (prev.Kind != t.Kind || strings.Join(prev.Values, " ") != strings.Join(t.Values, " ")) {
+		delete(dm.argTypes, cmd.name)
+		conflicts[cmd.name] = true
+		return
+	}
+	dm.argTypes[cmd.name] = t
+}
+
+func (dm directiveMasks) add(directive string, mask Mask, guard string) {
+	dm.masks[directive] = append(dm.masks[directive], mask)
+	dm.guards[directive] = append(dm.guards[directive], guard)
+}
+
+func commandsFromFile(path string, macros map[string]bool) ([]cCommand, error) {
+	content, err := os.ReadFile(path)
 	if err != nil {
 		return nil, err
 	}
-	strContent := string(byteContent)
-
-	// Remove comments
-	strContent = singleLineCommentExtracter.ReplaceAllString(strContent, "")
-	strContent = multiLineCommentExtracter.ReplaceAllString(strContent, "")
-	strContent = strings.ReplaceAll(strContent, "\r\n", "")
-	strContent = strings.ReplaceAll(strContent, "\n", "")
-
-	// Extract directives definition code blocks, each code block contains a list of directives definition
-	blocks := directivesDefineBlockExtracter.FindAllStringSubmatch(strContent, -1)
-
-	for _, block := range blocks {
-		// Extract directives and their attributes in the code block, the first dimension of subBlocks
-		// is index of directive, the second dimension is index of attributes
-		subBlocks := singleDirectiveExtracter.FindAllStringSubmatch(block[2], -1)
-
-		// Iterate through every directive
-		for _, attributes := range subBlocks {
-			// Extract attributes from the directive
-			directiveName := strings.TrimSpace(attributes[1])
-			directiveMask := strings.Split(attributes[2], "|")
-
-			// Transfer C-style mask to go style
-			for idx, ngxVarName := range directiveMask {
-				goVarName, found := ngxVarNameToGo[strings.TrimSpace(ngxVarName)]
-				if !found {
-					return nil, fmt.Errorf("parsing directive %s, bitmask %s in source code not found in crossplane", directiveName, ngxVarName)
-				}
-				directiveMask[idx] = goVarName
-			}
 
-			directive2Masks[directiveName] = append(directive2Masks[directiveName], directiveMask)
-		}
+	commands, err := extractCommands(string(content), macros)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
 	}
-	return directive2Masks, nil
+	return commands, nil
 }
 
-//nolint:nonamedreturns
-func getMasksFromPath(path string) (directive2Masks map[string][]Mask, err error) {
-	directive2Masks = make(map[string][]Mask, 0)
+func getMasksFromPath(path string, config GenerateConfig) (directiveMasks, error) {
+	var commands []cCommand
 
-	err = filepath.WalkDir(path, func(path string, d fs.DirEntry, err error) error {
+	err := filepath.WalkDir(path, func(path string, d fs.DirEntry, err error) error {
 		if err != nil {
 			return err
 		}
@@ -162,58 +215,157 @@ func getMasksFromPath(path string) (directive2Masks map[string][]Mask, err error
 			return nil
 		}
 
-		directive2MasksInFile, err := masksFromFile(path)
+		commandsInFile, err := commandsFromFile(path, config.Macros)
 		if err != nil {
 			return err
 		}
-
-		for directive, masksInFile := range directive2MasksInFile {
-			directive2Masks[directive] = append(directive2Masks[directive], masksInFile...)
-		}
-
+		commands = append(commands, commandsInFile...)
 		return nil
 	})
 
 	if err != nil {
-		return nil, err
+		return directiveMasks{}, err
+	}
+
+	dm, err := masksFromCommands(commands, config.DetectBlocks)
+	if err != nil {
+		return directiveMasks{}, err
 	}
 
-	if len(directive2Masks) == 0 {
-		return nil, errors.New("can't find any directives in the directory and subdirectories, please check the path")
+	if len(dm.masks) == 0 && len(dm.contexts) == 0 {
+		return directiveMasks{}, errors.New("can't find any directives in the directory and subdirectories, please check the path")
 	}
 
-	return directive2Masks, nil
+	return dm, nil
 }
 
-func genFromSrcCode(codePath string, writer io.Writer, config GenerateConfig) error {
-	directive2Masks, err := getMasksFromPath(codePath)
+// masksFromCommands returns the masks of the commands of the source code. If detectBlocks
+// is set, the map-like blocks and the block contexts that the commands open are detected,
+// and the directives of the contexts that crossplane doesn't know are registered in them.
+func masksFromCommands(commands []cCommand, detectBlocks bool) (directiveMasks, error) {
+	dm := newDirectiveMasks()
+	contexts := newContextBuilder()
+	if detectBlocks {
+		for _, cmd := range commands {
+			contexts.addOpener(cmd)
+			if cmd.mapBody != nil {
+				if _, found := dm.mapBodies[cmd.name]; !found {
+					dm.mapBodies[cmd.name] = *cmd.mapBody
+				}
+			}
+		}
+	}
+
+	conflicts := map[string]bool{}
+	for _, cmd := range commands {
+		dm.addArgType(cmd, conflicts)
+
+		var mask Mask
+		var custom []string
+		for _, name := range cmd.mask {
+			switch {
+			case goVarNames[name]:
+				mask = append(mask, name)
+			case contexts.isCustom(name):
+				custom = append(custom, name)
+			default:
+				return directiveMasks{}, fmt.Errorf("parsing directive %s, bitmask %s in source code not found in crossplane", cmd.name, name)
+			}
+		}
+		contexts.addDirective(cmd.name, mask, custom)
+		// the directives of custom contexts only are left to the registrations of the contexts
+		if len(custom) == 0 || hasContext(mask) {
+			dm.add(cmd.name, mask, cmd.guard)
+		}
+	}
+
+	dm.contexts = contexts.registrations()
+	return dm, nil
+}
+
+// directivesFromSrcCode returns the masks of the directives of the source code in
+// codePath, filtered and overridden by config.
+func directivesFromSrcCode(codePath string, config GenerateConfig) (directiveMasks, error) {
+	dm, err := getMasksFromPath(codePath, config)
 	if err != nil {
-		return err
+		return directiveMasks{}, err
 	}
 
 	filter := config.Filter
 	if len(filter) > 0 {
-		for d := range directive2Masks {
+		for d := range dm.masks {
 			if _, found := filter[d]; found {
-				delete(directive2Masks, d)
+				delete(dm.masks, d)
+				delete(dm.guards, d)
 			}
 		}
+		for d := range dm.mapBodies {
+			if _, found := filter[d]; found {
+				delete(dm.mapBodies, d)
+			}
+		}
+		for d := range dm.argTypes {
+			if _, found := filter[d]; found {
+				delete(dm.argTypes, d)
+			}
+		}
+		dm.contexts = filterContexts(dm.contexts, filter)
 	}
 
 	override := config.Override
 	if override != nil {
-		for d := range directive2Masks {
+		for d := range dm.masks {
 			if newMasks, found := override[d]; found {
-				directive2Masks[d] = newMasks
+				dm.masks[d] = newMasks
+				delete(dm.guards, d)
 			}
 		}
 	}
+	return dm, nil
+}
+
+func genFromSrcCode(codePath string, writer io.Writer, config GenerateConfig) error {
+	dm, err := directivesFromSrcCode(codePath, config)
+	if err != nil {
+		return err
+	}
+
+	// the map bodies of config take precedence over the detected ones
+	mapBodies := dm.mapBodies
+	for d, body := range config.MapBodies {
+		mapBodies[d] = body
+	}
+
+	if len(mapBodies) > 0 && config.MapBodiesFuncName == "" {
+		return errors.New("mapBodiesFuncName can't be empty when there are map bodies")
+	}
+	if len(dm.contexts) > 0 && config.ContextsFuncName == "" {
+		return errors.New("contextsFuncName can't be empty when there are block contexts")
+	}
+	// the types of arguments are only generated when they're asked for
+	var argTypes map[string]argType
+	if config.ArgTypesFuncName != "" {
+		argTypes = dm.argTypes
+	}
+
+	for d, body := range mapBodies {
+		if len(body.Params) == 0 {
+			return fmt.Errorf("the params of the map body of %s can't be empty", d)
+		}
+	}
 
 	err = supportFileTmpl.Execute(writer, supportFileTmplStruct{
-		Directive2Masks: directive2Masks,
+		Directive2Masks: dm.masks,
+		Guards:          dm.guards,
 		MapVariableName: config.DirectiveMapName,
 		MatchFnName:     config.MatchFuncName,
 		MatchFnComment:  config.MatchFuncComment,
+		MapBodies:       mapBodies,
+		MapBodiesFnName: config.MapBodiesFuncName,
+		Contexts:        dm.contexts,
+		ContextsFnName:  config.ContextsFuncName,
+		ArgTypes:        argTypes,
+		ArgTypesFnName:  config.ArgTypesFuncName,
 	})
 	if err != nil {
 		return err
@@ -221,3 +373,26 @@ func genFromSrcCode(codePath string, writer io.Writer, config GenerateConfig) er
 
 	return nil
 }
+
+// definitionsFile is the format of the directive definitions that crossplane.LoadDirectives reads.
+type definitionsFile struct {
+	Directives map[string][]string `json:"directives"`
+}
+
+func genDefinitionsFromSrcCode(codePath string, writer io.Writer, config GenerateConfig) error {
+	dm, err := directivesFromSrcCode(codePath, config)
+	if err != nil {
+		return err
+	}
+
+	defs := definitionsFile{Directives: make(map[string][]string, len(dm.masks))}
+	for d, masks := range dm.masks {
+		for _, mask := range masks {
+			defs.Directives[d] = append(defs.Directives[d], strings.Join(mask, "|"))
+		}
+	}
+
+	enc := json.NewEncoder(writer)
+	enc.SetIndent("", "    ")
+	return enc.Encode(defs)
+}
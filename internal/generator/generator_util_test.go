@@ -189,6 +189,88 @@ func TestGenFromSrcCode(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		"mapBodies_pass": {
+			relativePath: "mapBodies",
+			config: GenerateConfig{
+				DirectiveMapName: "directives",
+				MatchFuncName:    "Match",
+				MapBodies: map[string]MapBodyMasks{
+					"my_table": {
+						Params:        Mask{"ngxConfTake1", "ngxConfTake2"},
+						SpecialParams: map[string]Mask{"hostnames": {"ngxConfNoArgs"}, "default": {"ngxConfTake1"}},
+					},
+					"my_list": {Params: Mask{"ngxConf1More"}},
+				},
+				MapBodiesFuncName: "MapBodies",
+			},
+			wantErr: false,
+		},
+		"mapBodiesWithoutFuncName_fail": {
+			relativePath: "mapBodies",
+			config: GenerateConfig{
+				DirectiveMapName: "directives",
+				MatchFuncName:    "Match",
+				MapBodies:        map[string]MapBodyMasks{"my_list": {Params: Mask{"ngxConf1More"}}},
+			},
+			wantErr: true,
+		},
+		"mapBodiesWithoutParams_fail": {
+			relativePath: "mapBodies",
+			config: GenerateConfig{
+				DirectiveMapName:  "directives",
+				MatchFuncName:     "Match",
+				MapBodies:         map[string]MapBodyMasks{"my_list": {}},
+				MapBodiesFuncName: "MapBodies",
+			},
+			wantErr: true,
+		},
+		"conditionals_pass": {
+			relativePath: "conditionals",
+			config: GenerateConfig{
+				DirectiveMapName: "directives",
+				MatchFuncName:    "Match",
+			},
+			wantErr: false,
+		},
+		"detectBlocks_pass": {
+			relativePath: "detectBlocks",
+			config: GenerateConfig{
+				DirectiveMapName:  "directives",
+				MatchFuncName:     "Match",
+				MapBodiesFuncName: "MapBodies",
+				ContextsFuncName:  "RegisterContexts",
+				DetectBlocks:      true,
+			},
+			wantErr: false,
+		},
+		"detectBlocksWithoutContextsFuncName_fail": {
+			relativePath: "detectBlocks",
+			config: GenerateConfig{
+				DirectiveMapName:  "directives",
+				MatchFuncName:     "Match",
+				MapBodiesFuncName: "MapBodies",
+				DetectBlocks:      true,
+			},
+			wantErr: true,
+		},
+		"undetectedBlocks_fail": {
+			relativePath: "detectBlocks",
+			config: GenerateConfig{
+				DirectiveMapName: "directives",
+				MatchFuncName:    "Match",
+			},
+			wantErr: true,
+		},
+		"argTypes_pass": {
+			relativePath: "argTypes",
+			config: GenerateConfig{
+				DirectiveMapName: "directives",
+				MatchFuncName:    "Match",
+				ArgTypesFuncName: "ArgTypes",
+				Filter:           map[string]struct{}{"my_flag": {}},
+			},
+			wantErr: false,
+		},
 		"withMatchFuncComment_pass": {
 			relativePath: "withMatchFuncComment",
 			config: GenerateConfig{
@@ -257,3 +339,35 @@ func TestGenFromSrcCode(t *testing.T) {
 		})
 	}
 }
+
+func TestGenDefinitionsFromSrcCode(t *testing.T) {
+	t.Parallel()
+	codePath, err := getTestSrcCodePath("override")
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	err = genDefinitionsFromSrcCode(codePath, &buf, GenerateConfig{
+		Filter: map[string]struct{}{"my_directive_2": {}},
+		Override: map[string][]Mask{
+			"my_directive_1": {
+				Mask{"ngxHTTPMainConf", "ngxConfTake1"},
+				Mask{"ngxHTTPMainConf", "ngxConfTake2"},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	expectedFilePth, err := getExpectedFilePath("definitions")
+	require.NoError(t, err)
+	if *update {
+		require.NoError(t, os.WriteFile(expectedFilePth, buf.Bytes(), 0o600))
+		return
+	}
+	expected, err := os.ReadFile(expectedFilePth)
+	require.NoError(t, err)
+	require.Equal(t, string(expected), buf.String())
+
+	codePath, err = getTestSrcCodePath("noDirectives")
+	require.NoError(t, err)
+	require.Error(t, genDefinitionsFromSrcCode(codePath, &buf, GenerateConfig{}))
+}
@@ -0,0 +1,300 @@
+/**
+ * Copyright (c) F5, Inc.
+ *
+ * This source code is licensed under the Apache License, Version 2.0 license found in the
+ * LICENSE file in the root directory of this source tree.
+ */
+
+package generator
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Manifest lists the .gen.go files that are generated from local checkouts of
+// the source code of modules, so that all of them are generated in one run
+// without fetching anything.
+type Manifest struct {
+	Tables []ManifestTable `json:"tables"`
+}
+
+// ManifestTable is a .gen.go file of a Manifest. Relative paths in it are
+// relative to the directory of the manifest.
+type ManifestTable struct {
+	// Output is the path of the generated file.
+	Output string `json:"output"`
+
+	// Source is the path of a local checkout of the module. Environment variables
+	// like $NGINX_SRC in it are expanded. The table is skipped if it's empty.
+	Source string `json:"source"`
+
+	// Ref is the branch, tag or commit of the checkout to generate from, like
+	// origin/stable-1.26. It's resolved in the checkout, so it must be fetched
+	// beforehand. Environment variables in it are expanded. If it's empty, the
+	// files of the checkout are used as they are. (optional)
+	Ref string `json:"ref"`
+
+	// Path is the path of the source code in the checkout, like --path of
+	// generate.sh. (optional)
+	Path string `json:"path"`
+
+	// Config is the path of the json config of the table.
+	Config string `json:"config"`
+
+	// Docs is set if the table is the documentation of directives, which is
+	// generated from the XML sources of nginx.org.
+	Docs bool `json:"docs"`
+}
+
+// TableStatus is the status of a table after GenerateManifest.
+type TableStatus string
+
+const (
+	// TableUnchanged is a table whose generated file is up to date.
+	TableUnchanged TableStatus = "unchanged"
+	// TableCreated is a table whose file didn't exist and is written.
+	TableCreated TableStatus = "created"
+	// TableUpdated is a table whose file is rewritten.
+	TableUpdated TableStatus = "updated"
+	// TableOutdated is a table whose file isn't up to date, in check mode.
+	TableOutdated TableStatus = "out of date"
+	// TableSkipped is a table whose source isn't set.
+	TableSkipped TableStatus = "skipped"
+)
+
+// TableResult is what GenerateManifest did to a table.
+type TableResult struct {
+	// Output is the path of the generated file.
+	Output string
+	Status TableStatus
+	// Report is the difference between the directives of the old and the new
+	// file, if the file is a directive map that changed.
+	Report *DiffReport
+}
+
+// LoadConfig reads a json config of the generator.
+func LoadConfig(path string) (GenerateConfig, error) {
+	var config GenerateConfig
+	f, err := os.Open(path)
+	if err != nil {
+		return config, err
+	}
+
+	defer f.Close()
+
+	if err := json.NewDecoder(f).Decode(&config); err != nil {
+		return config, err
+	}
+
+	return config, nil
+}
+
+// LoadManifest reads a Manifest from a json file.
+func LoadManifest(path string) (*Manifest, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+
+	var m Manifest
+	dec := json.NewDecoder(f)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&m); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	for i, t := range m.Tables {
+		if t.Output == "" || t.Config == "" {
+			return nil, fmt.Errorf("%s: table %d needs an output and a config", path, i)
+		}
+	}
+	return &m, nil
+}
+
+// GenerateManifest generates the tables of the manifest at manifestPath. The files
+// of the tables are written, unless check is set, in which case the tables whose
+// files aren't up to date are reported as TableOutdated. The tables whose source
+// isn't set are TableSkipped, which a check should treat as a failure since they
+// aren't checked.
+func GenerateManifest(manifestPath string, check bool) ([]TableResult, error) {
+	m, err := LoadManifest(manifestPath)
+	if err != nil {
+		return nil, err
+	}
+
+	dir := filepath.Dir(manifestPath)
+	results := make([]TableResult, 0, len(m.Tables))
+	for _, t := range m.Tables {
+		var result TableResult
+		if result, err = t.generate(dir, check); err != nil {
+			return nil, fmt.Errorf("%s: %w", t.Output, err)
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+func (t ManifestTable) generate(dir string, check bool) (TableResult, error) {
+	output := resolvePath(dir, t.Output)
+	result := TableResult{Output: output}
+	source := os.ExpandEnv(t.Source)
+	if source == "" {
+		result.Status = TableSkipped
+		return result, nil
+	}
+
+	config, err := LoadConfig(resolvePath(dir, t.Config))
+	if err != nil {
+		return result, err
+	}
+	if config.DirectiveMapName == "" {
+		return result, errors.New("directiveMapName can't be empty")
+	}
+	if !t.Docs && config.MatchFuncName == "" {
+		return result, errors.New("matchFuncName can't be empty")
+	}
+
+	srcPath, cleanup, err := checkout(resolvePath(dir, source), os.ExpandEnv(t.Ref))
+	if err != nil {
+		return result, err
+	}
+	defer cleanup()
+	srcPath = filepath.Join(srcPath, t.Path)
+
+	var buf bytes.Buffer
+	if t.Docs {
+		err = GenerateDocs(srcPath, &buf, config)
+	} else {
+		err = Generate(srcPath, &buf, config)
+	}
+	if err != nil {
+		return result, err
+	}
+
+	old, err := os.ReadFile(output)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		result.Status = TableCreated
+	case err != nil:
+		return result, err
+	case bytes.Equal(old, buf.Bytes()):
+		result.Status = TableUnchanged
+		return result, nil
+	default:
+		result.Status = TableUpdated
+		result.Report = diffGoSrc(output, old, buf.Bytes())
+	}
+
+	if check {
+		result.Status = TableOutdated
+		return result, nil
+	}
+	return result, os.WriteFile(output, buf.Bytes(), 0o644) //nolint:gosec // generated source is committed
+}
+
+// diffGoSrc returns the difference between the directive maps of two versions of
+// a generated file, or nil if they aren't directive maps.
+func diffGoSrc(filename string, oldSrc, newSrc []byte) *DiffReport {
+	oldMasks, err := masksFromGoSrc(filename, oldSrc)
+	if err != nil {
+		return nil
+	}
+	newMasks, err := masksFromGoSrc(filename, newSrc)
+	if err != nil {
+		return nil
+	}
+	return diffMasks(oldMasks, newMasks)
+}
+
+func resolvePath(dir, path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(dir, path)
+}
+
+// checkout returns the path of the files of ref in the git checkout at source,
+// and a func that removes them. The files are checked out from a clone that shares
+// the objects of source, so neither source nor the network are touched. If ref is
+// empty, source itself is returned.
+func checkout(source, ref string) (string, func(), error) {
+	if ref == "" {
+		return source, func() {}, nil
+	}
+
+	commit, err := git(source, "rev-parse", "--verify", "--quiet", ref+"^{commit}")
+	if err != nil {
+		return "", nil, fmt.Errorf("can't find %s in %s: %w", ref, source, err)
+	}
+
+	tmp, err := os.MkdirTemp("", "crossplane-generate-")
+	if err != nil {
+		return "", nil, err
+	}
+	cleanup := func() { os.RemoveAll(tmp) }
+
+	if _, err = git("", "clone", "--quiet", "--shared", "--no-checkout", source, tmp); err != nil {
+		cleanup()
+		return "", nil, err
+	}
+	if _, err = git(tmp, "checkout", "--quiet", "--detach", commit); err != nil {
+		cleanup()
+		return "", nil, err
+	}
+	return tmp, cleanup, nil
+}
+
+func git(dir string, args ...string) (string, error) {
+	command := args[0]
+	if dir != "" {
+		args = append([]string{"-C", dir}, args...)
+	}
+	var stderr bytes.Buffer
+	cmd := exec.Command("git", args...)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("git %s: %s", command, msg)
+		}
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// WriteSummary writes a line for every table in results, with the number of the
+// added, removed and changed directives of the tables that changed.
+func WriteSummary(w io.Writer, results []TableResult) error {
+	var b strings.Builder
+	for _, r := range results {
+		fmt.Fprintf(&b, "%s: %s", r.Output, r.Status)
+		if r.Report != nil {
+			fmt.Fprintf(&b, " (%d added, %d removed, %d changed)", len(r.Report.Added), len(r.Report.Removed), len(r.Report.Changed))
+		}
+		b.WriteString("\n")
+		if r.Report == nil {
+			continue
+		}
+		for _, c := range r.Report.Added {
+			fmt.Fprintf(&b, "    + %s\n", c.Directive)
+		}
+		for _, c := range r.Report.Removed {
+			fmt.Fprintf(&b, "    - %s\n", c.Directive)
+		}
+		for _, c := range r.Report.Changed {
+			fmt.Fprintf(&b, "    ~ %s\n", c.Directive)
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
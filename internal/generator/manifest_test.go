@@ -0,0 +1,123 @@
+/**
+ * Copyright (c) F5, Inc.
+ *
+ * This source code is licensed under the Apache License, Version 2.0 license found in the
+ * LICENSE file in the root directory of this source tree.
+ */
+
+package generator
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const testManifest = `{
+    "tables": [
+        {"output": "old.gen.go", "source": "src", "ref": "v1", "config": "config.json"},
+        {"output": "new.gen.go", "source": "src", "config": "config.json"},
+        {"output": "private.gen.go", "source": "${CROSSPLANE_TEST_UNSET_SRC}", "config": "config.json"}
+    ]
+}`
+
+// copyTestSrcCode copies the .c file of a test source code to dst.
+func copyTestSrcCode(t *testing.T, name string, dst string) {
+	t.Helper()
+	srcPath, err := getTestSrcCodePath(name)
+	require.NoError(t, err)
+	files, err := filepath.Glob(filepath.Join(srcPath, "*.c"))
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	src, err := os.ReadFile(files[0])
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(dst, src, 0o600))
+}
+
+func TestGenerateManifest(t *testing.T) {
+	t.Parallel()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	dir := t.TempDir()
+	srcDir := filepath.Join(dir, "src")
+	require.NoError(t, os.Mkdir(srcDir, 0o700))
+	copyTestSrcCode(t, "diffOld", filepath.Join(srcDir, "module.c"))
+	for _, args := range [][]string{
+		{"init", "--quiet"},
+		{"add", "module.c"},
+		{"-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "--quiet", "-m", "v1"},
+		{"tag", "v1"},
+	} {
+		_, err := git(srcDir, args...)
+		require.NoError(t, err)
+	}
+	// the checkout is ahead of v1
+	copyTestSrcCode(t, "diffNew", filepath.Join(srcDir, "module.c"))
+
+	config := `{"directiveMapName": "myDirectives", "matchFuncName": "MatchMy"}`
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "config.json"), []byte(config), 0o600))
+	manifestPath := filepath.Join(dir, "manifest.json")
+	require.NoError(t, os.WriteFile(manifestPath, []byte(testManifest), 0o600))
+	oldOutput, newOutput := filepath.Join(dir, "old.gen.go"), filepath.Join(dir, "new.gen.go")
+	statuses := func(results []TableResult) []TableStatus {
+		var s []TableStatus
+		for _, r := range results {
+			s = append(s, r.Status)
+		}
+		return s
+	}
+
+	results, err := GenerateManifest(manifestPath, true)
+	require.NoError(t, err)
+	require.Equal(t, []TableStatus{TableOutdated, TableOutdated, TableSkipped}, statuses(results))
+	require.NoFileExists(t, oldOutput)
+
+	results, err = GenerateManifest(manifestPath, false)
+	require.NoError(t, err)
+	require.Equal(t, []TableStatus{TableCreated, TableCreated, TableSkipped}, statuses(results))
+	require.Equal(t, []string{oldOutput, newOutput, filepath.Join(dir, "private.gen.go")},
+		[]string{results[0].Output, results[1].Output, results[2].Output})
+
+	oldPath, err := getTestSrcCodePath("diffOld")
+	require.NoError(t, err)
+	var expected bytes.Buffer
+	require.NoError(t, Generate(oldPath, &expected, GenerateConfig{DirectiveMapName: "myDirectives", MatchFuncName: "MatchMy"}))
+	got, err := os.ReadFile(oldOutput)
+	require.NoError(t, err)
+	require.Equal(t, expected.String(), string(got))
+
+	results, err = GenerateManifest(manifestPath, true)
+	require.NoError(t, err)
+	require.Equal(t, []TableStatus{TableUnchanged, TableUnchanged, TableSkipped}, statuses(results))
+
+	// the old table is generated from the checkout in the new one
+	newManifest := bytes.Replace([]byte(testManifest), []byte(`"new.gen.go"`), []byte(`"old.gen.go"`), 1)
+	require.NoError(t, os.WriteFile(manifestPath, newManifest, 0o600))
+	results, err = GenerateManifest(manifestPath, true)
+	require.NoError(t, err)
+	require.Equal(t, []TableStatus{TableUnchanged, TableOutdated, TableSkipped}, statuses(results))
+	require.NotNil(t, results[1].Report)
+	require.Equal(t, []string{"my_added"}, results[1].Report.Config.Filter)
+
+	var summary bytes.Buffer
+	require.NoError(t, WriteSummary(&summary, results[1:2]))
+	require.Equal(t, oldOutput+": out of date (1 added, 1 removed, 1 changed)\n"+
+		"    + my_added\n    - my_removed\n    ~ my_changed\n", summary.String())
+	got, err = os.ReadFile(oldOutput)
+	require.NoError(t, err)
+	require.Equal(t, expected.String(), string(got))
+
+	require.NoError(t, os.WriteFile(manifestPath, []byte(`{"tables": [{"output": "old.gen.go", "source": "src", "ref": "v2", "config": "config.json"}]}`), 0o600))
+	_, err = GenerateManifest(manifestPath, true)
+	require.Error(t, err)
+
+	require.NoError(t, os.WriteFile(manifestPath, []byte(`{"tables": [{"output": "old.gen.go", "source": "src"}]}`), 0o600))
+	_, err = GenerateManifest(manifestPath, true)
+	require.Error(t, err)
+}
@@ -0,0 +1,91 @@
+static ngx_conf_enum_t  ngx_http_my_body_in_file[] = {
+    { ngx_string("off"), NGX_HTTP_MY_FILE_OFF },
+    { ngx_string("on"), NGX_HTTP_MY_FILE_ON },
+#if (NGX_HTTP_MY_CLEAN)
+    { ngx_string("clean"), NGX_HTTP_MY_FILE_CLEAN },
+#endif
+    { ngx_null_string, 0 }
+};
+
+
+static ngx_conf_bitmask_t  ngx_http_my_proxied_mask[] = {
+    { ngx_string("off"), NGX_HTTP_MY_PROXIED_OFF },
+    { ngx_string("expired"), NGX_HTTP_MY_PROXIED_EXPIRED },
+    { ngx_string("any"), NGX_HTTP_MY_PROXIED_ANY },
+    { ngx_null_string, 0 }
+};
+
+
+static ngx_command_t  ngx_http_my_commands[] = {
+
+    { ngx_string("my_flag"),
+      NGX_HTTP_MAIN_CONF|NGX_CONF_FLAG,
+      ngx_conf_set_flag_slot,
+      NGX_HTTP_LOC_CONF_OFFSET,
+      offsetof(ngx_http_my_loc_conf_t, flag),
+      NULL },
+
+    { ngx_string("my_buffer_size"),
+      NGX_HTTP_MAIN_CONF|NGX_CONF_TAKE1,
+      ngx_conf_set_size_slot,
+      NGX_HTTP_LOC_CONF_OFFSET,
+      offsetof(ngx_http_my_loc_conf_t, buffer_size),
+      NULL },
+
+    { ngx_string("my_timeout"),
+      NGX_HTTP_MAIN_CONF|NGX_CONF_TAKE1,
+      ngx_conf_set_msec_slot,
+      NGX_HTTP_LOC_CONF_OFFSET,
+      offsetof(ngx_http_my_loc_conf_t, timeout),
+      NULL },
+
+    { ngx_string("my_body_in_file"),
+      NGX_HTTP_MAIN_CONF|NGX_CONF_TAKE1,
+      ngx_conf_set_enum_slot,
+      NGX_HTTP_LOC_CONF_OFFSET,
+      offsetof(ngx_http_my_loc_conf_t, body_in_file),
+      &ngx_http_my_body_in_file },
+
+    { ngx_string("my_proxied"),
+      NGX_HTTP_MAIN_CONF|NGX_CONF_1MORE,
+      ngx_conf_set_bitmask_slot,
+      NGX_HTTP_LOC_CONF_OFFSET,
+      offsetof(ngx_http_my_loc_conf_t, proxied),
+      &ngx_http_my_proxied_mask },
+
+    { ngx_string("my_custom"),
+      NGX_HTTP_MAIN_CONF|NGX_CONF_TAKE1,
+      ngx_http_my_custom,
+      NGX_HTTP_LOC_CONF_OFFSET,
+      0,
+      NULL },
+
+    { ngx_string("my_both"),
+      NGX_HTTP_MAIN_CONF|NGX_CONF_TAKE1,
+      ngx_conf_set_size_slot,
+      NGX_HTTP_LOC_CONF_OFFSET,
+      offsetof(ngx_http_my_loc_conf_t, both),
+      NULL },
+
+      ngx_null_command
+};
+
+
+static ngx_command_t  ngx_stream_my_commands[] = {
+
+    { ngx_string("my_both"),
+      NGX_STREAM_MAIN_CONF|NGX_CONF_TAKE1,
+      ngx_conf_set_msec_slot,
+      NGX_STREAM_SRV_CONF_OFFSET,
+      offsetof(ngx_stream_my_srv_conf_t, both),
+      NULL },
+
+    { ngx_string("my_timeout"),
+      NGX_STREAM_MAIN_CONF|NGX_CONF_TAKE1,
+      ngx_conf_set_msec_slot,
+      NGX_STREAM_SRV_CONF_OFFSET,
+      offsetof(ngx_stream_my_srv_conf_t, timeout),
+      NULL },
+
+      ngx_null_command
+};
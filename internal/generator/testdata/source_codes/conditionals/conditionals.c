@@ -0,0 +1,55 @@
+#define NGX_HTTP_MY_CONF  (NGX_HTTP_MAIN_CONF|NGX_HTTP_SRV_CONF)
+#define NGX_HTTP_MY_TAKE1 NGX_HTTP_MY_CONF|NGX_CONF_TAKE1
+
+static char *ngx_http_my_commands_doc = "ngx_command_t fake[] = { {";
+
+static ngx_command_t  ngx_http_my_commands[] = {
+
+    { ngx_string("my_always"),
+      NGX_HTTP_MY_TAKE1,
+      0,
+      0,
+      0,
+      "{ not a } brace, // nor a comment" },
+
+#if (NGX_HTTP_SSL)
+
+    { ngx_string("my_ssl"),
+      NGX_HTTP_MAIN_CONF|NGX_CONF_FLAG,
+      0,
+      0,
+      0,
+      NULL },
+
+#else
+
+    { ngx_string("my_ssl"),
+      NGX_HTTP_MAIN_CONF|NGX_CONF_NOARGS,
+      0,
+      0,
+      0,
+      '}' },
+
+#endif
+
+#ifdef NGX_HTTP_MY_FEATURE
+# if (NGX_PCRE && NGX_HTTP_SSL)
+    { ngx_string("my_regex"), /* { */
+      NGX_HTTP_MY_CONF|NGX_HTTP_LOC_CONF \
+          |NGX_CONF_TAKE12,
+      0,
+      0,
+      0,
+      NULL },
+# elif (NGX_PCRE2)
+    { ngx_string("my_regex"),
+      NGX_HTTP_LOC_CONF|NGX_CONF_TAKE1,
+      0,
+      0,
+      0,
+      NULL },
+# endif
+#endif
+
+      ngx_null_command
+};
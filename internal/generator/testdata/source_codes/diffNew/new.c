@@ -0,0 +1,32 @@
+static ngx_command_t  my_directives[] = {
+
+    { ngx_string("my_kept"),
+      NGX_HTTP_MAIN_CONF|NGX_CONF_FLAG,
+      0,
+      0,
+      0,
+      NULL },
+
+    { ngx_string("my_added"),
+      NGX_STREAM_MAIN_CONF|NGX_CONF_TAKE1,
+      0,
+      0,
+      0,
+      NULL },
+
+    { ngx_string("my_changed"),
+      NGX_HTTP_MAIN_CONF|NGX_HTTP_LOC_CONF|NGX_CONF_TAKE12,
+      0,
+      0,
+      0,
+      NULL },
+
+    { ngx_string("my_reordered"),
+      NGX_CONF_TAKE1|NGX_HTTP_MAIN_CONF,
+      0,
+      0,
+      0,
+      NULL },
+
+    ngx_null_command
+};
@@ -0,0 +1,17 @@
+static ngx_command_t my_directives[] = {
+
+    { ngx_string("my_table"),
+      NGX_HTTP_MAIN_CONF|NGX_CONF_BLOCK|NGX_CONF_TAKE1,
+      0,
+      0,
+      0,
+      NULL },
+    { ngx_string("my_list"),
+      NGX_HTTP_MAIN_CONF|NGX_HTTP_SRV_CONF|NGX_CONF_BLOCK|NGX_CONF_NOARGS,
+      0,
+      0,
+      0,
+      NULL },
+
+    ngx_null_command
+};
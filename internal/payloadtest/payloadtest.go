@@ -0,0 +1,79 @@
+/**
+ * Copyright (c) F5, Inc.
+ *
+ * This source code is licensed under the Apache License, Version 2.0 license found in the
+ * LICENSE file in the root directory of this source tree.
+ */
+
+// Package payloadtest has the tests that the encodings of payloads in the
+// subpackages of crossplane share.
+package payloadtest
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"path/filepath"
+	"testing"
+
+	"github.com/nginxinc/nginx-go-crossplane"
+	"github.com/stretchr/testify/require"
+)
+
+// Codec is an encoding of payloads, like the Encode and Decode of crossplaneyaml.
+type Codec struct {
+	Encode func(w io.Writer, payload *crossplane.Payload) error
+	Decode func(r io.Reader) (*crossplane.Payload, error)
+}
+
+// RoundTrip checks that the payloads of the configs in the testdata/configs
+// directory at root, parsed with comments, are the same once they're encoded and
+// decoded with codec.
+func RoundTrip(t *testing.T, root string, codec Codec) {
+	t.Helper()
+	paths, err := filepath.Glob(filepath.Join(root, "testdata", "configs", "*", "nginx.conf"))
+	require.NoError(t, err)
+	require.NotEmpty(t, paths)
+
+	for _, path := range paths {
+		path := path
+		t.Run(filepath.Base(filepath.Dir(path)), func(t *testing.T) {
+			t.Parallel()
+			payload, err := crossplane.Parse(path, &crossplane.ParseOptions{ParseComments: true})
+			if err != nil {
+				t.Skipf("config can't be parsed: %v", err)
+			}
+
+			var buf bytes.Buffer
+			require.NoError(t, codec.Encode(&buf, payload))
+			decoded, err := codec.Decode(&buf)
+			require.NoError(t, err)
+
+			require.Len(t, decoded.Config, len(payload.Config))
+			for i, config := range payload.Config {
+				equalDirectives(t, config.Parsed, decoded.Config[i].Parsed)
+				// the decoders don't tell nil and empty configs apart
+				if config.Parsed == nil {
+					payload.Config[i].Parsed = crossplane.Directives{}
+				}
+			}
+			want, err := json.Marshal(payload)
+			require.NoError(t, err)
+			got, err := json.Marshal(decoded)
+			require.NoError(t, err)
+			require.JSONEq(t, string(want), string(got))
+		})
+	}
+}
+
+// equalDirectives checks the fields that the JSON encoding of directives doesn't
+// tell apart, like empty and missing blocks.
+func equalDirectives(t *testing.T, want, got crossplane.Directives) {
+	t.Helper()
+	require.Len(t, got, len(want))
+	for i, stmt := range want {
+		require.True(t, stmt.Equal(got[i]), "directive %s differs", stmt)
+		require.Equal(t, stmt.IsBlock(), got[i].IsBlock(), "directive %s differs", stmt)
+		equalDirectives(t, stmt.Block, got[i].Block)
+	}
+}
@@ -8,10 +8,10 @@
 package crossplane
 
 import (
-	"bufio"
 	"fmt"
 	"io"
 	"strings"
+	"unicode/utf8"
 )
 
 type NgxToken struct {
@@ -23,6 +23,22 @@ type NgxToken struct {
 
 type state int
 
+//nolint:gochecknoglobals
+var (
+	// ASCII characters that can be added to a token without looking at them one by one
+	wordChars    = asciiCharsExcept(" \t\n\v\f\r\\\"'{};")
+	commentChars = asciiCharsExcept("\n\r\\")
+	quotedChars  = asciiCharsExcept("\n\r\\\"'")
+)
+
+func asciiCharsExcept(except string) [utf8.RuneSelf]bool {
+	var chars [utf8.RuneSelf]bool
+	for c := range chars {
+		chars[c] = !strings.ContainsRune(except, rune(c))
+	}
+	return chars
+}
+
 const (
 	skipSpace state = iota
 	inWord
@@ -33,6 +49,9 @@ const (
 
 const TokenChanCap = 2048
 
+// tokenizerReadSize is the least number of bytes a Tokenizer reads at once.
+const tokenizerReadSize = 64 * 1024
+
 //nolint:gochecknoglobals
 var lexerFile = "lexer" // pseudo file name for use by parse errors
 
@@ -89,13 +108,16 @@ func LexWithLexer(l Lexer, stringTokens ...string) RegisterLexer { //nolint:iret
 }
 
 // LexWithOptions allows for custom lexing behavior through external lexers specified in the LexOptions.
+// The tokens are read by a Tokenizer in a separate goroutine and sent over the returned channel.
 func LexWithOptions(r io.Reader, options LexOptions) chan NgxToken {
-	for _, o := range options.Lexers {
-		o.applyLexOptions(&options)
-	}
-
 	tc := make(chan NgxToken, tokChanCap)
-	go tokenize(r, tc, options)
+	go func() {
+		defer close(tc)
+		t := NewTokenizer(r, options)
+		for tok, ok := t.Next(); ok; tok, ok = t.Next() {
+			tc <- tok
+		}
+	}()
 	return tc
 }
 
@@ -105,234 +127,490 @@ func Lex(reader io.Reader) chan NgxToken {
 
 // SubScanner provides an interface for scanning alternative grammars within NGINX configuration data.
 type SubScanner struct {
-	scanner   *bufio.Scanner
+	t         *Tokenizer
+	text      string
 	tokenLine int
 }
 
 // Scan advances the scanner to the next token which will be available though the Text method. It returns false
 // when the scan stops by reaching the end of input.
 func (e *SubScanner) Scan() bool {
-	if !e.scanner.Scan() {
+	la, ok := e.t.next()
+	if !ok {
 		return false
 	}
-	if t := e.scanner.Text(); isEOL(t) {
+	e.text = la.s
+	if isEOL(e.text) {
 		e.tokenLine++
 	}
 	return true
 }
 
 // Err returns the fist non-EOF error encountered by the Scanner.
-func (e *SubScanner) Err() error { return e.scanner.Err() }
+func (e *SubScanner) Err() error { return e.t.err }
 
 // Text returns the most recent token generated by a call to Scan.
-func (e *SubScanner) Text() string { return e.scanner.Text() }
+func (e *SubScanner) Text() string { return e.text }
 
 // Line returns the line number of the most recent token generated by a call to Scan.
 func (e *SubScanner) Line() int { return e.tokenLine }
 
-//nolint:gocyclo,funlen,gocognit,maintidx
-func tokenize(reader io.Reader, tokenCh chan NgxToken, options LexOptions) {
-	token := strings.Builder{}
-	tokenLine := 1
-	tokenStartLine := 1
-
-	lexState := skipSpace
-	newToken := false
-	dupSpecialChar := false
-	readNext := true
-	esc := false
-	depth := 0
-	var la, quote string
-	nextTokenIsDirective := true
-
-	scanner := bufio.NewScanner(reader)
-	scanner.Split(bufio.ScanRunes)
-
-	emit := func(line int, quoted bool, err error) {
-		tokenCh <- NgxToken{Value: token.String(), Line: line, IsQuoted: quoted, Error: err}
-		token.Reset()
-		lexState = skipSpace
-	}
-
-	for {
-		if readNext {
-			if !scanner.Scan() {
-				break // done
-			}
+// lookahead is the character the lexer is looking at, possibly escaped by a backslash.
+type lookahead struct {
+	s string
+	// pos is the offset of s in the config, or -1 if s can't be found in it
+	pos int
+}
 
-			la = scanner.Text()
-			if isEOL(la) {
-				tokenLine++
-				nextTokenIsDirective = true
-			}
-		} else {
-			readNext = true
-		}
+// Tokenizer splits an NGINX configuration into NgxTokens, which are read one at a time
+// with Next. The configuration is read in chunks, of which only the part holding the
+// token being lexed is kept, and the Value of a token is a substring of a chunk whenever
+// possible, so most tokens are read without any allocation.
+type Tokenizer struct {
+	r       io.Reader
+	src     string
+	pos     int
+	err     error
+	options LexOptions
+
+	// tokens that have been lexed but not read yet
+	pending []NgxToken
+	head    int
+	done    bool
+
+	// the token being lexed is src[tokStart:tokEnd], unless it has characters that
+	// are not next to each other in src, in which case it is copied to scratch
+	tokStart, tokEnd int
+	scratch          []byte
+	inScratch        bool
+
+	la                   lookahead
+	tokenLine            int
+	tokenStartLine       int
+	lexState             state
+	newToken             bool
+	dupSpecialChar       bool
+	readNext             bool
+	esc                  bool
+	escPos               int
+	depth                int
+	quote                string
+	nextTokenIsDirective bool
+}
 
-		// skip CRs
-		if la == "\r" || la == "\\\r" {
-			continue
+// NewTokenizer returns a Tokenizer that reads the NGINX configuration from r. An error
+// reading it stops lexing like the end of the configuration would.
+func NewTokenizer(r io.Reader, options LexOptions) *Tokenizer {
+	t := newTokenizer("", nil, options)
+	t.r = r
+	return t
+}
+
+// newTokenizer returns a Tokenizer of src, which was read from a reader that returned err.
+func newTokenizer(src string, err error, options LexOptions) *Tokenizer {
+	for _, o := range options.Lexers {
+		o.applyLexOptions(&options)
+	}
+
+	return &Tokenizer{
+		src:                  src,
+		err:                  err,
+		options:              options,
+		tokenLine:            1,
+		tokenStartLine:       1,
+		lexState:             skipSpace,
+		readNext:             true,
+		nextTokenIsDirective: true,
+	}
+}
+
+// Next returns the next token of the configuration. It returns false once all of the tokens
+// have been read. A token with an Error is always the last one.
+func (t *Tokenizer) Next() (NgxToken, bool) {
+	if t.head == len(t.pending) {
+		t.pending = t.pending[:0]
+		t.head = 0
+		for len(t.pending) == 0 && !t.done {
+			t.step()
+		}
+		if len(t.pending) == 0 {
+			return NgxToken{}, false
 		}
+	}
+	tok := t.pending[t.head]
+	t.head++
+	return tok, true
+}
+
+// next reads the next character of the config. Invalid UTF-8 is read one byte at a time
+// as utf8.RuneError, the way bufio.ScanRunes does.
+func (t *Tokenizer) next() (lookahead, bool) {
+	if t.pos >= len(t.src) && !t.fill() {
+		return lookahead{}, false
+	}
+	la := lookahead{pos: t.pos}
+	if c := t.src[t.pos]; c < utf8.RuneSelf {
+		la.s = t.src[t.pos : t.pos+1]
+		t.pos++
+		return la, true
+	}
+	for !utf8.FullRuneInString(t.src[t.pos:]) && t.fill() {
+	}
+	la.pos = t.pos
+	r, size := utf8.DecodeRuneInString(t.src[t.pos:])
+	if r == utf8.RuneError && size == 1 {
+		la.s, la.pos = string(utf8.RuneError), -1
+	} else {
+		la.s = t.src[t.pos : t.pos+size]
+	}
+	t.pos += size
+	return la, true
+}
 
-		if la == "\\" && !esc {
-			esc = true
-			continue
+// fill reads the next chunk of the config. It keeps what's left of the current one that
+// the Tokenizer still refers to, and moves the offsets into it accordingly. It returns
+// false if there's nothing left to read.
+func (t *Tokenizer) fill() bool {
+	if t.r == nil {
+		return false
+	}
+
+	keep := t.pos
+	if !t.inScratch && t.tokEnd > t.tokStart && t.tokStart < keep {
+		keep = t.tokStart
+	}
+	if t.esc && t.escPos >= 0 && t.escPos < keep {
+		keep = t.escPos
+	}
+	if t.la.pos >= 0 && t.la.pos < keep {
+		keep = t.la.pos
+	}
+	rest := t.src[keep:]
+
+	// reading at least as much as what's kept makes lexing long tokens linear
+	size := tokenizerReadSize
+	if len(rest) > size {
+		size = len(rest)
+	}
+	buf := make([]byte, len(rest)+size)
+	copy(buf, rest)
+	n, err := io.ReadAtLeast(t.r, buf[len(rest):], 1)
+	if err != nil {
+		if err != io.EOF {
+			t.err = err
 		}
-		if esc {
-			esc = false
-			la = "\\" + la
+		t.r = nil
+	}
+	if n == 0 {
+		return false
+	}
+
+	t.src = string(buf[:len(rest)+n])
+	t.pos -= keep
+	if t.inScratch || t.tokEnd == t.tokStart {
+		t.tokStart, t.tokEnd = 0, 0
+	} else {
+		t.tokStart -= keep
+		t.tokEnd -= keep
+	}
+	if t.esc && t.escPos >= 0 {
+		t.escPos -= keep
+	}
+	if t.la.pos >= 0 {
+		t.la.pos -= keep
+	}
+	return true
+}
+
+func (t *Tokenizer) tokenLen() int {
+	if t.inScratch {
+		return len(t.scratch)
+	}
+	return t.tokEnd - t.tokStart
+}
+
+func (t *Tokenizer) tokenHasSuffix(c byte) bool {
+	if t.inScratch {
+		return len(t.scratch) > 0 && t.scratch[len(t.scratch)-1] == c
+	}
+	return t.tokEnd > t.tokStart && t.src[t.tokEnd-1] == c
+}
+
+func (t *Tokenizer) tokenString() string {
+	if t.inScratch {
+		return string(t.scratch)
+	}
+	return t.src[t.tokStart:t.tokEnd]
+}
+
+func (t *Tokenizer) write(la lookahead) {
+	if !t.inScratch {
+		switch {
+		case la.pos >= 0 && t.tokEnd == t.tokStart:
+			t.tokStart, t.tokEnd = la.pos, la.pos+len(la.s)
+			return
+		case la.pos >= 0 && t.tokEnd == la.pos:
+			t.tokEnd += len(la.s)
+			return
 		}
+		t.scratch = append(t.scratch[:0], t.src[t.tokStart:t.tokEnd]...)
+		t.inScratch = true
+	}
+	t.scratch = append(t.scratch, la.s...)
+}
 
-		if token.Len() > 0 {
-			tokenStr := token.String()
-			if nextTokenIsDirective {
-				if ext, ok := options.extLexers[tokenStr]; ok {
-					// saving lex state before emitting tokenStr to know if we encountered start quote
-					lastLexState := lexState
-					emit(tokenStartLine, lexState == inQuote, nil)
-
-					externalScanner := &SubScanner{scanner: scanner, tokenLine: tokenLine}
-					extTokenCh := ext.Lex(externalScanner, tokenStr)
-					for tok := range extTokenCh {
-						tokenCh <- tok
-					}
-					tokenLine = externalScanner.tokenLine
-
-					// if we detected a start quote and current char after external lexer processing is end quote we skip it
-					if lastLexState == inQuote && la == quote {
-						continue
-					}
-				}
-			}
+func (t *Tokenizer) emit(line int, quoted bool, err error) {
+	t.pending = append(t.pending, NgxToken{Value: t.tokenString(), Line: line, IsQuoted: quoted, Error: err})
+	t.tokStart, t.tokEnd = 0, 0
+	t.inScratch = false
+	t.lexState = skipSpace
+}
+
+func (t *Tokenizer) extLexer() (Lexer, bool) {
+	if t.inScratch {
+		ext, ok := t.options.extLexers[string(t.scratch)]
+		return ext, ok
+	}
+	ext, ok := t.options.extLexers[t.src[t.tokStart:t.tokEnd]]
+	return ext, ok
+}
+
+// step lexes one character, emitting the tokens it completes.
+//
+//nolint:gocyclo,funlen,gocognit,maintidx
+func (t *Tokenizer) step() {
+	if t.readNext {
+		la, ok := t.next()
+		if !ok {
+			t.finish()
+			return
+		}
+		t.la = la
+		if isEOL(la.s) {
+			t.tokenLine++
+			t.nextTokenIsDirective = true
 		}
+	} else {
+		t.readNext = true
+	}
+
+	// skip CRs
+	if t.la.s == "\r" || t.la.s == "\\\r" {
+		return
+	}
 
-		switch lexState {
-		case skipSpace:
-			if !isSpace(la) {
-				lexState = inWord
-				newToken = true
-				readNext = false // re-eval
-				tokenStartLine = tokenLine
+	if t.la.s == "\\" && !t.esc {
+		t.esc = true
+		t.escPos = t.la.pos
+		return
+	}
+	if t.esc {
+		t.esc = false
+		if t.la.pos >= 0 && t.la.pos == t.escPos+1 {
+			t.la = lookahead{s: t.src[t.escPos : t.la.pos+len(t.la.s)], pos: t.escPos}
+		} else {
+			t.la = lookahead{s: "\\" + t.la.s, pos: -1}
+		}
+	}
+	la := t.la.s
+
+	if len(t.options.extLexers) > 0 && t.tokenLen() > 0 && t.nextTokenIsDirective {
+		if ext, ok := t.extLexer(); ok {
+			tokenStr := t.tokenString()
+			// saving lex state before emitting tokenStr to know if we encountered start quote
+			lastLexState := t.lexState
+			t.emit(t.tokenStartLine, t.lexState == inQuote, nil)
+
+			externalScanner := &SubScanner{t: t, tokenLine: t.tokenLine}
+			failed := false
+			for tok := range ext.Lex(externalScanner, tokenStr) {
+				t.pending = append(t.pending, tok)
+				failed = failed || tok.Error != nil
 			}
-			continue
-		case inWord:
-			if newToken {
-				newToken = false
-				if la == "#" {
-					token.WriteString(la)
-					nextTokenIsDirective = false
-					lexState = inComment
-					tokenStartLine = tokenLine
-					continue
-				}
+			t.tokenLine = externalScanner.tokenLine
+			// a token with an error is the last one
+			if failed {
+				t.done = true
+				return
 			}
 
-			if isSpace(la) {
-				emit(tokenStartLine, false, nil)
-				nextTokenIsDirective = false
-				continue
+			// if we detected a start quote and current char after external lexer processing is end quote we skip it
+			if lastLexState == inQuote && la == t.quote {
+				return
 			}
+		}
+	}
 
-			// handle parameter expansion syntax (ex: "${var[@]}")
-			if token.Len() > 0 && strings.HasSuffix(token.String(), "$") && la == "{" {
-				nextTokenIsDirective = false
-				token.WriteString(la)
-				lexState = inVar
-				dupSpecialChar = false
-				continue
+	switch t.lexState {
+	case skipSpace:
+		if !isSpaceChar(la) {
+			t.lexState = inWord
+			t.newToken = true
+			t.readNext = false // re-eval
+			t.tokenStartLine = t.tokenLine
+		}
+		return
+	case inWord:
+		if t.newToken {
+			t.newToken = false
+			if la == "#" {
+				t.write(t.la)
+				t.nextTokenIsDirective = false
+				t.lexState = inComment
+				t.tokenStartLine = t.tokenLine
+				return
 			}
+		}
 
-			// if a quote is found, add the whole string to the token buffer
-			if la == `"` || la == "'" {
-				if token.Len() > 0 {
-					// if a quote is inside a token, treat it like any other char
-					token.WriteString(la)
-				} else {
-					// swallow quote and change state
-					quote = la
-					lexState = inQuote
-					tokenStartLine = tokenLine
-				}
-				dupSpecialChar = false
-				continue
+		if isSpaceChar(la) {
+			t.emit(t.tokenStartLine, false, nil)
+			t.nextTokenIsDirective = false
+			return
+		}
+
+		// handle parameter expansion syntax (ex: "${var[@]}")
+		if t.tokenHasSuffix('$') && la == "{" {
+			t.nextTokenIsDirective = false
+			t.write(t.la)
+			t.lexState = inVar
+			t.dupSpecialChar = false
+			return
+		}
+
+		// if a quote is found, add the whole string to the token buffer
+		if la == `"` || la == "'" {
+			if t.tokenLen() > 0 {
+				// if a quote is inside a token, treat it like any other char
+				t.write(t.la)
+			} else {
+				// swallow quote and change state
+				t.quote = la
+				t.lexState = inQuote
+				t.tokenStartLine = t.tokenLine
 			}
+			t.dupSpecialChar = false
+			return
+		}
 
-			// handle special characters that are treated like full tokens
-			if la == "{" || la == "}" || la == ";" {
-				// if token complete yield it and reset token buffer
-				if token.Len() > 0 {
-					emit(tokenStartLine, false, nil)
-				}
+		// handle special characters that are treated like full tokens
+		if la == "{" || la == "}" || la == ";" {
+			// if token complete yield it and reset token buffer
+			if t.tokenLen() > 0 {
+				t.emit(t.tokenStartLine, false, nil)
+			}
 
-				// only '}' can be repeated
-				if dupSpecialChar && la != "}" {
-					emit(tokenStartLine, false, &ParseError{
-						File: &lexerFile,
-						What: fmt.Sprintf(`unexpected "%s"`, la),
-						Line: &tokenLine,
-					})
-					close(tokenCh)
-					return
-				}
+			// only '}' can be repeated
+			if t.dupSpecialChar && la != "}" {
+				line := t.tokenLine
+				t.emit(t.tokenStartLine, false, &ParseError{
+					File: &lexerFile,
+					What: fmt.Sprintf(`unexpected "%s"`, la),
+					Line: &line,
+				})
+				t.done = true
+				return
+			}
 
-				dupSpecialChar = true
+			t.dupSpecialChar = true
 
-				if la == "{" {
-					depth++
-				}
-				if la == "}" {
-					depth--
-					// early exit if unbalanced braces
-					if depth < 0 {
-						emit(tokenStartLine, false, &ParseError{File: &lexerFile, What: `unexpected "}"`, Line: &tokenLine})
-						close(tokenCh)
-						return
-					}
+			if la == "{" {
+				t.depth++
+			}
+			if la == "}" {
+				t.depth--
+				// early exit if unbalanced braces
+				if t.depth < 0 {
+					line := t.tokenLine
+					t.emit(t.tokenStartLine, false, &ParseError{File: &lexerFile, What: `unexpected "}"`, Line: &line})
+					t.done = true
+					return
 				}
-
-				token.WriteString(la)
-				// this character is a full token so emit it
-				emit(tokenStartLine, false, nil)
-				nextTokenIsDirective = true
-				continue
 			}
 
-			dupSpecialChar = false
-			token.WriteString(la)
+			t.write(t.la)
+			// this character is a full token so emit it
+			t.emit(t.tokenStartLine, false, nil)
+			t.nextTokenIsDirective = true
+			return
+		}
 
-		case inComment:
-			if isEOL(la) {
-				emit(tokenStartLine, false, nil)
-				continue
-			}
-			token.WriteString(la)
+		t.dupSpecialChar = false
+		t.write(t.la)
+		t.writeRun(&wordChars)
 
-		case inVar:
-			token.WriteString(la)
-			// this is using the same logic as the exiting lexer, but this is wrong since it does not terminate on token boundary
-			if !strings.HasSuffix(token.String(), "}") && !isSpace(la) {
-				continue
-			}
-			lexState = inWord
+	case inComment:
+		if isEOL(la) {
+			t.emit(t.tokenStartLine, false, nil)
+			return
+		}
+		t.write(t.la)
+		t.writeRun(&commentChars)
+
+	case inVar:
+		t.write(t.la)
+		// this is using the same logic as the exiting lexer, but this is wrong since it does not terminate on token boundary
+		if !t.tokenHasSuffix('}') && !isSpaceChar(la) {
+			return
+		}
+		t.lexState = inWord
 
-		case inQuote:
-			if la == quote {
-				emit(tokenStartLine, true, nil)
-				continue
-			}
-			if la == "\\"+quote {
-				la = quote
+	case inQuote:
+		if la == t.quote {
+			t.emit(t.tokenStartLine, true, nil)
+			return
+		}
+		if la == "\\"+t.quote {
+			// drop the backslash
+			unescaped := lookahead{s: t.quote, pos: -1}
+			if t.la.pos >= 0 {
+				unescaped.pos = t.la.pos + 1
 			}
-			token.WriteString(la)
+			t.write(unescaped)
+			return
 		}
+		t.write(t.la)
+		t.writeRun(&quotedChars)
+	}
+}
+
+// writeRun writes the characters that follow to the token for as long as they are in chars,
+// which must only hold characters that need no special handling in the current state.
+// External lexers need to see the token after every character, so it does nothing if
+// there are any.
+func (t *Tokenizer) writeRun(chars *[utf8.RuneSelf]bool) {
+	if len(t.options.extLexers) > 0 {
+		return
 	}
+	end := t.pos
+	for end < len(t.src) && t.src[end] < utf8.RuneSelf && chars[t.src[end]] {
+		end++
+	}
+	if end > t.pos {
+		t.write(lookahead{s: t.src[t.pos:end], pos: t.pos})
+		t.pos = end
+	}
+}
 
-	if token.Len() > 0 {
-		emit(tokenStartLine, lexState == inQuote, nil)
+// finish emits what's left at the end of the config.
+func (t *Tokenizer) finish() {
+	if t.tokenLen() > 0 {
+		t.emit(t.tokenStartLine, t.lexState == inQuote, nil)
 	}
-	if depth > 0 {
-		emit(tokenStartLine, false, &ParseError{File: &lexerFile, What: `unexpected end of file, expecting "}"`, Line: &tokenLine})
+	if t.depth > 0 {
+		line := t.tokenLine
+		t.emit(t.tokenStartLine, false, &ParseError{File: &lexerFile, What: `unexpected end of file, expecting "}"`, Line: &line})
 	}
+	t.done = true
+}
 
-	close(tokenCh)
+// isSpaceChar is isSpace for the single, possibly escaped, characters read by the Tokenizer.
+func isSpaceChar(s string) bool {
+	if len(s) == 1 {
+		switch s[0] {
+		case ' ', '\t', '\n', '\v', '\f', '\r':
+			return true
+		}
+		return false
+	}
+	return isSpace(s)
 }
@@ -8,9 +8,17 @@
 package crossplane
 
 import (
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/require"
 )
 
 type tokenLine struct {
@@ -415,6 +423,40 @@ var lexFixtures = []lexFixture{
 		{"}", 20},
 		{"}", 21},
 	}},
+	{"lua-block-brackets", []tokenLine{
+		{"http", 1},
+		{"{", 1},
+		{"log_by_lua_block", 2},
+		{
+			"\n        local x = \"line \\" +
+				"\ncontinued }\"" +
+				"\n        ngx.log(ngx.ERR, #x) -- }" +
+				"\n    ",
+			6,
+		},
+		{";", 6},
+		{"server", 7},
+		{"{", 7},
+		{"location", 8},
+		{"/", 8},
+		{"{", 8},
+		{"content_by_lua_block", 9},
+		{
+			"\n                local s = [[ } ]]" +
+				"\n                local t = [==[ ]] } ]=] ]==]" +
+				"\n                -- a comment with a } and a quote '" +
+				"\n                --[[ a long" +
+				"\n                comment } ]]" +
+				"\n                --[==[ } ]==]" +
+				"\n                ngx.say(\"escaped \\\" } quote\", 'it\\'s } fine', s .. t[1])" +
+				"\n            ",
+			17,
+		},
+		{";", 17},
+		{"}", 18},
+		{"}", 19},
+		{"}", 20},
+	}},
 }
 
 func TestLex(t *testing.T) {
@@ -479,3 +521,213 @@ func TestLex_unhappy(t *testing.T) {
 		})
 	}
 }
+
+func TestLex_luaUnhappy(t *testing.T) {
+	t.Parallel()
+
+	testcases := map[string]struct {
+		input string
+		line  int
+		what  string
+	}{
+		"unfinished block": {
+			"http {\n    content_by_lua_block {\n        ngx.say('}')\n",
+			4, `unexpected end of file, expecting "}"`,
+		},
+		"unfinished string": {
+			"content_by_lua_block {\n    ngx.say(\"}\n    )\n}",
+			2, "unfinished string in lua block",
+		},
+		"unfinished long string": {
+			"content_by_lua_block {\n    local s = [==[ ]] }\n}\n",
+			2, "unfinished long string in lua block",
+		},
+		"unfinished long comment": {
+			"content_by_lua_block {\n\n    --[[ }\n}\n",
+			3, "unfinished long comment in lua block",
+		},
+		"invalid long string delimiter": {
+			"content_by_lua_block {\n    local s = [= ]\n}\n",
+			2, "invalid long string delimiter in lua block",
+		},
+		"no block": {
+			"content_by_lua_block foo;",
+			1, `expected "{" to start lua block`,
+		},
+	}
+
+	for name, tc := range testcases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			var tokens []NgxToken
+			for tok := range LexWithOptions(strings.NewReader(tc.input), LexOptions{Lexers: []RegisterLexer{lua.RegisterLexer()}}) {
+				tokens = append(tokens, tok)
+			}
+			require.NotEmpty(t, tokens)
+			last := tokens[len(tokens)-1]
+			var perr *ParseError
+			require.ErrorAs(t, last.Error, &perr)
+			require.Equal(t, tc.what, perr.What)
+			require.Equal(t, tc.line, *perr.Line)
+			for _, tok := range tokens[:len(tokens)-1] {
+				require.NoError(t, tok.Error)
+			}
+		})
+	}
+}
+
+func TestTokenizer(t *testing.T) {
+	t.Parallel()
+
+	testcases := map[string]struct {
+		input    string
+		expected []NgxToken
+	}{
+		"crlf": {
+			"a\r\nb;\r\n",
+			[]NgxToken{{Value: "a", Line: 1}, {Value: "b", Line: 2}, {Value: ";", Line: 2}},
+		},
+		"escaped crlf": {
+			"x \\\r\n y;",
+			[]NgxToken{{Value: "x", Line: 1}, {Value: "\\\n", Line: 2}, {Value: "y", Line: 2}, {Value: ";", Line: 2}},
+		},
+		"escaped quotes": {
+			`s "a\"b" 'c\'d' "e\\f";`,
+			[]NgxToken{
+				{Value: "s", Line: 1},
+				{Value: `a"b`, Line: 1, IsQuoted: true},
+				{Value: `c'd`, Line: 1, IsQuoted: true},
+				{Value: `e\\f`, Line: 1, IsQuoted: true},
+				{Value: ";", Line: 1},
+			},
+		},
+		"escaped whitespace and semicolon": {
+			`a\ b c\;d;`,
+			[]NgxToken{{Value: `a\ b`, Line: 1}, {Value: `c\;d`, Line: 1}, {Value: ";", Line: 1}},
+		},
+		"crlf in quotes": {
+			"q 'a\r\nb';",
+			[]NgxToken{{Value: "q", Line: 1}, {Value: "a\nb", Line: 1, IsQuoted: true}, {Value: ";", Line: 2}},
+		},
+		"invalid utf-8": {
+			"k a\xffb \"q\xfe\";",
+			[]NgxToken{
+				{Value: "k", Line: 1},
+				{Value: "a�b", Line: 1},
+				{Value: "q�", Line: 1, IsQuoted: true},
+				{Value: ";", Line: 1},
+			},
+		},
+		"comments": {
+			"# c\r\n#d\\\nx;",
+			[]NgxToken{{Value: "# c", Line: 1}, {Value: "#d", Line: 2}, {Value: "x", Line: 3}, {Value: ";", Line: 3}},
+		},
+		"unicode": {
+			"é ü;\tx y;",
+			[]NgxToken{
+				{Value: "é", Line: 1},
+				{Value: "ü", Line: 1},
+				{Value: ";", Line: 1},
+				{Value: "x", Line: 1},
+				{Value: "y", Line: 1},
+				{Value: ";", Line: 1},
+			},
+		},
+		"unterminated quote": {
+			`a "unterminated`,
+			[]NgxToken{{Value: "a", Line: 1}, {Value: "unterminated", Line: 1, IsQuoted: true}},
+		},
+	}
+
+	for name, tc := range testcases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var pulled []NgxToken
+			tokenizer := NewTokenizer(strings.NewReader(tc.input), LexOptions{})
+			for tok, ok := tokenizer.Next(); ok; tok, ok = tokenizer.Next() {
+				pulled = append(pulled, tok)
+			}
+			require.Equal(t, tc.expected, pulled)
+
+			pulled = nil
+			tokenizer = NewTokenizer(iotest.OneByteReader(strings.NewReader(tc.input)), LexOptions{})
+			for tok, ok := tokenizer.Next(); ok; tok, ok = tokenizer.Next() {
+				pulled = append(pulled, tok)
+			}
+			require.Equal(t, tc.expected, pulled)
+
+			var sent []NgxToken
+			for tok := range Lex(strings.NewReader(tc.input)) {
+				sent = append(sent, tok)
+			}
+			require.Equal(t, tc.expected, sent)
+		})
+	}
+}
+
+func TestTokenizer_chunks(t *testing.T) {
+	t.Parallel()
+	var configs []string
+	err := filepath.WalkDir(getTestConfigPath(), func(path string, d fs.DirEntry, err error) error {
+		if err == nil && strings.HasSuffix(path, ".conf") {
+			configs = append(configs, path)
+		}
+		return err
+	})
+	require.NoError(t, err)
+
+	tokenize := func(tokenizer *Tokenizer) []NgxToken {
+		var tokens []NgxToken
+		for tok, ok := tokenizer.Next(); ok; tok, ok = tokenizer.Next() {
+			tokens = append(tokens, tok)
+		}
+		return tokens
+	}
+	readers := map[string]func(io.Reader) io.Reader{
+		"one byte": iotest.OneByteReader,
+		"half":     iotest.HalfReader,
+	}
+
+	for _, path := range configs {
+		path := path
+		t.Run(strings.TrimPrefix(path, getTestConfigPath()), func(t *testing.T) {
+			t.Parallel()
+			src, err := os.ReadFile(path)
+			require.NoError(t, err)
+			for _, options := range []LexOptions{{}, {Lexers: []RegisterLexer{lua.RegisterLexer()}}} {
+				expected := tokenize(newTokenizer(string(src), nil, options))
+				for name, reader := range readers {
+					tokens := tokenize(NewTokenizer(reader(bytes.NewReader(src)), options))
+					require.Equal(t, expected, tokens, name)
+				}
+			}
+		})
+	}
+}
+
+func TestTokenizer_readError(t *testing.T) {
+	t.Parallel()
+	boom := errors.New("boom")
+	tokenizer := NewTokenizer(io.MultiReader(strings.NewReader("a b;\nc"), iotest.ErrReader(boom)), LexOptions{})
+	var tokens []NgxToken
+	for tok, ok := tokenizer.Next(); ok; tok, ok = tokenizer.Next() {
+		tokens = append(tokens, tok)
+	}
+	require.Equal(t, []NgxToken{{Value: "a", Line: 1}, {Value: "b", Line: 1}, {Value: ";", Line: 1}, {Value: "c", Line: 2}}, tokens)
+	require.ErrorIs(t, tokenizer.err, boom)
+}
+
+//nolint:paralleltest // AllocsPerRun can't be used in parallel tests
+func TestTokenizer_allocs(t *testing.T) {
+	config := strings.Repeat("location /foo {\n    # comment\n    return 200 \"ok\";\n}\n", 100)
+	tokenizer := NewTokenizer(strings.NewReader(config), LexOptions{})
+	allocs := testing.AllocsPerRun(500, func() {
+		if _, ok := tokenizer.Next(); !ok {
+			t.Fatal("ran out of tokens")
+		}
+	})
+	require.Zero(t, allocs)
+}
@@ -51,17 +51,12 @@ func (l *Lua) RegisterLexer() RegisterLexer { //nolint:ireturn
 // Lex lexically analyzes the Lua blocks based on directives detected.
 // It is used by the lexer to tokenize Lua content within configuration files.
 //
-//nolint:funlen,gocognit,gocyclo,nosec
+//nolint:funlen,gocognit,nosec
 func (l *Lua) Lex(s *SubScanner, matchedToken string) <-chan NgxToken {
 	tokenCh := make(chan NgxToken)
 
-	tokenDepth := 0
-
 	go func() {
 		defer close(tokenCh)
-		var tok strings.Builder
-		var inQuotes bool
-		var quoteType string
 
 		// special handling for'set_by_lua_block' directive
 		// ignore potential hardcoded credentials linter warning for "set_by_lua_block"
@@ -102,77 +97,202 @@ func (l *Lua) Lex(s *SubScanner, matchedToken string) <-chan NgxToken {
 					tokenCh <- NgxToken{Error: &ParseError{File: &lexerFile, What: `expected "{" to start lua block`, Line: &lineno}}
 					return
 				}
-				tokenDepth++
 				break
 			}
 		}
 
-		// Grab everything in Lua block as a single token and watch for curly brace '{' in strings
-		for {
-			if !s.Scan() {
-				return
-			}
+		// Grab everything in Lua block as a single token, skipping over the braces in
+		// strings and comments
+		lx := &luaLexer{s: s, line: s.Line()}
+		value, err := lx.block()
+		if err != nil {
+			tokenCh <- NgxToken{Error: err}
+			return
+		}
+		tokenCh <- NgxToken{Value: value, Line: lx.line, IsQuoted: true}
+		tokenCh <- NgxToken{Value: ";", Line: lx.line, IsQuoted: false} // For an end to the Lua string based on the nginx bahavior
+		// See: https://github.com/nginxinc/crossplane/blob/master/crossplane/ext/lua.py#L122C25-L122C41
+	}()
 
-			next := s.Text()
-			if err := s.Err(); err != nil {
-				lineno := s.Line()
-				tokenCh <- NgxToken{Error: &ParseError{File: &lexerFile, What: err.Error(), Line: &lineno}}
-			}
+	return tokenCh
+}
 
-			switch {
-			case next == "{" && !inQuotes:
-				tokenDepth++
-				if tokenDepth > 1 { // not the first open brace
-					tok.WriteString(next)
-				}
+// luaLexer reads the body of a Lua block following the lexical rules of Lua 5.1 and
+// LuaJIT, so that braces in strings and comments don't end the block.
+type luaLexer struct {
+	s    *SubScanner
+	tok  strings.Builder
+	line int
 
-			case next == "}" && !inQuotes:
-				tokenDepth--
-				if tokenDepth < 0 {
-					lineno := s.Line()
-					tokenCh <- NgxToken{Error: &ParseError{File: &lexerFile, What: `unexpected "}"`, Line: &lineno}}
-					return
-				}
+	// a character that has been peeked at but not read yet
+	peeked    string
+	hasPeeked bool
+}
 
-				if tokenDepth > 0 { // not the last close brace for it to be 0
-					tok.WriteString(next)
-				}
+// next reads the next character of the block and adds it to the token.
+func (lx *luaLexer) next() (string, bool) {
+	c, ok := lx.peek()
+	if !ok {
+		return "", false
+	}
+	lx.hasPeeked = false
+	lx.tok.WriteString(c)
+	if isEOL(c) {
+		lx.line++
+	}
+	return c, true
+}
 
-				if tokenDepth == 0 {
-					tokenCh <- NgxToken{Value: tok.String(), Line: s.Line(), IsQuoted: true}
-					tokenCh <- NgxToken{Value: ";", Line: s.Line(), IsQuoted: false} // For an end to the Lua string based on the nginx bahavior
-					// See: https://github.com/nginxinc/crossplane/blob/master/crossplane/ext/lua.py#L122C25-L122C41
-					return
-				}
+// peek returns the next character of the block without reading it.
+func (lx *luaLexer) peek() (string, bool) {
+	if !lx.hasPeeked {
+		if !lx.s.Scan() {
+			return "", false
+		}
+		lx.peeked, lx.hasPeeked = lx.s.Text(), true
+	}
+	return lx.peeked, true
+}
 
-			case next == `"` || next == "'":
-				if !inQuotes {
-					inQuotes = true
-					quoteType = next
-				} else if inQuotes && next == quoteType {
-					inQuotes = false
-				}
-				tok.WriteString(next)
-
-			default:
-				// Expected first token is “{” to open a Lua block. If the first non-whitespace character is not “{”,
-				// we are not starting Lua tokenization. This is crucial for cases like ‘server_name content_by_lua_block;’.
-				// Without an opening “{”, ignore input until encountering a brace “{” with tokenDepth > 0.
-				if isSpace(next) && tokenDepth == 0 {
-					continue
-				}
+// accept reads the next character if it's c.
+func (lx *luaLexer) accept(c string) bool {
+	if next, ok := lx.peek(); ok && next == c {
+		lx.next()
+		return true
+	}
+	return false
+}
 
-				// stricly check that first non space character is {
-				if tokenDepth == 0 {
-					tokenCh <- NgxToken{Value: next, Line: s.Line(), IsQuoted: false}
-					return
+func (lx *luaLexer) error(what string, line int) error {
+	return &ParseError{File: &lexerFile, What: what, Line: &line}
+}
+
+// eof returns the error of reaching the end of the input, which is either an error
+// reading it or an unfinished block.
+func (lx *luaLexer) eof(what string, line int) error {
+	if err := lx.s.Err(); err != nil {
+		return lx.error(err.Error(), lx.line)
+	}
+	return lx.error(what, line)
+}
+
+// block reads the block up to the "}" that closes it, and returns what's in it.
+func (lx *luaLexer) block() (string, error) {
+	depth := 1
+	for {
+		c, ok := lx.next()
+		if !ok {
+			return "", lx.eof(`unexpected end of file, expecting "}"`, lx.line)
+		}
+
+		var err error
+		switch c {
+		case "{":
+			depth++
+		case "}":
+			depth--
+			if depth == 0 {
+				value := lx.tok.String()
+				return value[:len(value)-len(c)], nil
+			}
+		case `"`, "'":
+			err = lx.shortString(c)
+		case "-":
+			if lx.accept("-") {
+				err = lx.comment()
+			}
+		case "[":
+			line := lx.line
+			level, long := lx.longBracket()
+			switch {
+			case long:
+				err = lx.longString(level, "string", line)
+			case level > 0:
+				err = lx.error("invalid long string delimiter in lua block", line)
+			}
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+}
+
+// shortString reads a string quoted with quote, up to the closing quote.
+func (lx *luaLexer) shortString(quote string) error {
+	line := lx.line
+	for {
+		c, ok := lx.next()
+		switch {
+		case !ok:
+			return lx.eof("unfinished string in lua block", line)
+		case c == quote:
+			return nil
+		case c == "\n" || c == "\r":
+			return lx.error("unfinished string in lua block", line)
+		case c == "\\":
+			escaped, ok := lx.next()
+			switch {
+			case !ok:
+				return lx.eof("unfinished string in lua block", line)
+			case escaped == "\r":
+				lx.accept("\n")
+			case escaped == "z":
+				// \z skips the whitespace that follows, including line breaks
+				for next, ok := lx.peek(); ok && isSpace(next); next, ok = lx.peek() {
+					lx.next()
 				}
-				tok.WriteString(next)
 			}
 		}
-	}()
+	}
+}
 
-	return tokenCh
+// comment reads a comment after its "--", which is either a long comment like
+// --[[ ... ]] or a comment up to the end of the line.
+func (lx *luaLexer) comment() error {
+	line := lx.line
+	if lx.accept("[") {
+		if level, long := lx.longBracket(); long {
+			return lx.longString(level, "comment", line)
+		}
+	}
+	for {
+		c, ok := lx.peek()
+		if !ok || c == "\n" {
+			return nil
+		}
+		lx.next()
+	}
+}
+
+// longBracket reads the rest of an opening long bracket after its "[", like "==[".
+// It returns the level of the bracket, which is the number of "=", and whether it
+// is one. If it isn't, the "=" have been read.
+func (lx *luaLexer) longBracket() (int, bool) {
+	level := 0
+	for lx.accept("=") {
+		level++
+	}
+	return level, lx.accept("[")
+}
+
+// longString reads a long string or comment of level up to its closing long bracket.
+func (lx *luaLexer) longString(level int, what string, line int) error {
+	for {
+		c, ok := lx.next()
+		if !ok {
+			return lx.eof(fmt.Sprintf("unfinished long %s in lua block", what), line)
+		}
+		if c != "]" {
+			continue
+		}
+		closing := 0
+		for closing < level && lx.accept("=") {
+			closing++
+		}
+		if closing == level && lx.accept("]") {
+			return nil
+		}
+	}
 }
 
 // RegisterBuilder registers a builder for generating Lua NGINX configuration.
@@ -0,0 +1,332 @@
+/**
+ * Copyright (c) F5, Inc.
+ *
+ * This source code is licensed under the Apache License, Version 2.0 license found in the
+ * LICENSE file in the root directory of this source tree.
+ */
+
+// Package luacheck checks the Lua code of the *_by_lua_block directives of
+// crossplane payloads. It's a package of its own so that programs that don't
+// use it don't depend on a Lua parser.
+package luacheck
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/nginxinc/nginx-go-crossplane"
+	"github.com/yuin/gopher-lua/ast"
+	"github.com/yuin/gopher-lua/parse"
+)
+
+// Problem is a problem with the Lua code of a *_by_lua_block directive.
+type Problem struct {
+	Directive string `json:"directive"`
+	File      string `json:"file"`
+	// Line is the line of the config that the problem is on.
+	Line int `json:"line"`
+	// Reason is e.g. `lua syntax error near "end"`.
+	Reason string `json:"reason"`
+}
+
+// the phases of ngx_lua that the APIs that yield are allowed in
+//
+//nolint:gochecknoglobals
+var (
+	luaRequestPhases = []string{
+		"server_rewrite_by_lua_block",
+		"rewrite_by_lua_block",
+		"access_by_lua_block",
+		"content_by_lua_block",
+	}
+	luaYieldingPhases = append([]string{
+		"ssl_client_hello_by_lua_block",
+		"ssl_certificate_by_lua_block",
+		"ssl_session_fetch_by_lua_block",
+	}, luaRequestPhases...)
+)
+
+// luaBlockingAPIs are the APIs of ngx_lua that block the Lua code, with the
+// *_by_lua_block directives that they're allowed in. Timers may use all of them.
+//
+//nolint:gochecknoglobals
+var luaBlockingAPIs = map[string][]string{
+	"ngx.sleep":                  luaYieldingPhases,
+	"ngx.socket.tcp":             luaYieldingPhases,
+	"ngx.socket.udp":             luaYieldingPhases,
+	"ngx.socket.stream":          luaYieldingPhases,
+	"ngx.socket.connect":         luaYieldingPhases,
+	"ngx.thread.spawn":           luaYieldingPhases,
+	"ngx.thread.wait":            luaYieldingPhases,
+	"ngx.location.capture":       luaRequestPhases,
+	"ngx.location.capture_multi": luaRequestPhases,
+	"ngx.req.read_body":          luaRequestPhases,
+	"ngx.req.socket":             luaRequestPhases,
+}
+
+// Check parses the Lua code of the *_by_lua_block directives of payload, which
+// the crossplane.Lua lexer captures as their last argument, and reports its syntax errors and
+// its uses of blocking APIs like ngx.sleep in the directives where ngx_lua doesn't
+// allow them, like header_filter_by_lua_block. Code passed to ngx.timer.at and
+// ngx.timer.every runs in a timer, so it may use all of them.
+//
+// The lines of the problems are the lines of the config. They're found from where
+// the blocks end in payloads returned by crossplane.Parse, and assuming that the blocks start
+// on the line of their directive otherwise. The code is parsed as Lua 5.1 with the
+// extensions of LuaJIT that don't change its structure: numbers like 0x1p4, 1LL
+// and 2i, and the \z escape of strings.
+func Check(payload *crossplane.Payload) []Problem {
+	var problems []Problem
+	var walk func(file string, ds crossplane.Directives)
+	walk = func(file string, ds crossplane.Directives) {
+		for _, d := range ds {
+			if strings.HasSuffix(d.Directive, "_by_lua_block") && len(d.Args) > 0 {
+				problems = append(problems, checkLuaBlock(file, d)...)
+			}
+			walk(file, d.Block)
+		}
+	}
+	for _, config := range payload.Config {
+		walk(config.File, config.Parsed)
+	}
+	return problems
+}
+
+func checkLuaBlock(file string, d *crossplane.Directive) []Problem {
+	code := d.Args[len(d.Args)-1]
+	start := d.Line
+	if d.LastArgLine() != 0 {
+		// the "{" of the block can be on a line after the directive
+		start = d.LastArgLine() - strings.Count(code, "\n")
+	}
+	problem := func(line int, reason string) Problem {
+		return Problem{Directive: d.Directive, File: file, Line: start + line - 1, Reason: reason}
+	}
+
+	chunk, err := parse.Parse(strings.NewReader(luajitToLua51(code)), d.Directive)
+	if err != nil {
+		var perr *parse.Error
+		if !errors.As(err, &perr) {
+			return []Problem{problem(1, "lua "+err.Error())}
+		}
+		// errors at the end of the code are reported near <eof> like Lua does
+		line, near := perr.Pos.Line, perr.Token
+		if line == parse.EOF {
+			line, near = strings.Count(code, "\n")+1, "<eof>"
+		}
+		return []Problem{problem(line, fmt.Sprintf("lua %s near %q", perr.Message, near))}
+	}
+
+	var problems []Problem
+	walkLuaAPIs(reflect.ValueOf(chunk), func(api string, line int) {
+		allowed, ok := luaBlockingAPIs[api]
+		if ok && !contains(allowed, d.Directive) {
+			problems = append(problems, problem(line, fmt.Sprintf("%s: API disabled in the context of %s", api, d.Directive)))
+		}
+	})
+	return problems
+}
+
+// walkLuaAPIs calls visit with the names like ngx.socket.tcp, and their lines, of
+// the fields of tables that are read in the nodes of v, except in timers.
+func walkLuaAPIs(v reflect.Value, visit func(api string, line int)) {
+	switch v.Kind() { //nolint:exhaustive
+	case reflect.Interface:
+		if !v.IsNil() {
+			walkLuaAPIs(v.Elem(), visit)
+		}
+	case reflect.Ptr:
+		if v.IsNil() {
+			return
+		}
+		switch node := v.Interface().(type) {
+		case *ast.AttrGetExpr:
+			if api := luaName(node); api != "" {
+				visit(api, node.Line())
+			}
+		case *ast.FuncCallExpr:
+			if api := luaName(node.Func); api == "ngx.timer.at" || api == "ngx.timer.every" {
+				return
+			}
+		}
+		walkLuaAPIs(v.Elem(), visit)
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).IsExported() {
+				walkLuaAPIs(v.Field(i), visit)
+			}
+		}
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			walkLuaAPIs(v.Index(i), visit)
+		}
+	}
+}
+
+// luaName returns the name of a variable like ngx, or of a field of it like ngx.sleep,
+// or "" if expr is neither.
+func luaName(expr ast.Expr) string {
+	switch e := expr.(type) {
+	case *ast.IdentExpr:
+		return e.Value
+	case *ast.AttrGetExpr:
+		key, ok := e.Key.(*ast.StringExpr)
+		if !ok {
+			return ""
+		}
+		if object := luaName(e.Object); object != "" {
+			return object + "." + key.Value
+		}
+	}
+	return ""
+}
+
+//nolint:gochecknoglobals
+var (
+	lua51Number  = regexp.MustCompile(`^(0[xX][0-9a-fA-F]+|(\d+\.?\d*|\.\d+)([eE][+-]?\d+)?)$`)
+	luajitNumber = regexp.MustCompile(`^(0[xX]([0-9a-fA-F]+\.?[0-9a-fA-F]*|\.[0-9a-fA-F]+)([pP][+-]?\d+)?|` +
+		`(\d+\.?\d*|\.\d+)([eE][+-]?\d+)?)([uU]?[lL][lL]|[iI])?$`)
+)
+
+// luajitToLua51 rewrites the parts of code that LuaJIT accepts but Lua 5.1 doesn't,
+// keeping everything on its line. Numbers like 0x1p4, 1LL and 2i become 0, and the
+// \z escapes of strings are removed with the spaces they skip, the newlines of which
+// are moved after the string. Comments and long strings are kept as they are.
+func luajitToLua51(code string) string {
+	var b strings.Builder
+	for i := 0; i < len(code); {
+		n := 1
+		switch c := code[i]; {
+		case strings.HasPrefix(code[i:], "--"):
+			n = 2 + luaLongBracketLen(code[i+2:])
+			if n == 2 {
+				n = strings.IndexByte(code[i:], '\n')
+				if n < 0 {
+					n = len(code) - i
+				}
+			}
+		case c == '[' && luaLongBracketLen(code[i:]) > 0:
+			n = luaLongBracketLen(code[i:])
+		case c == '"' || c == '\'':
+			var s string
+			s, n = luaShortString(code[i:])
+			b.WriteString(s)
+			i += n
+			continue
+		case c == '_' || isLetter(c):
+			for i+n < len(code) && (code[i+n] == '_' || isLetter(code[i+n]) || isDigit(code[i+n])) {
+				n++
+			}
+		case isDigit(c) || c == '.' && i+1 < len(code) && isDigit(code[i+1]):
+			n = luaNumberLen(code[i:])
+			if num := code[i : i+n]; !lua51Number.MatchString(num) && luajitNumber.MatchString(num) {
+				b.WriteString("0")
+				i += n
+				continue
+			}
+		}
+		b.WriteString(code[i : i+n])
+		i += n
+	}
+	return b.String()
+}
+
+// luaLongBracketLen returns the length of the long string or comment that s starts
+// with, like [==[ a ]==], up to the end of s if it isn't closed, or 0 if s doesn't
+// start with one.
+func luaLongBracketLen(s string) int {
+	level := 1
+	for level < len(s) && s[level] == '=' {
+		level++
+	}
+	if s == "" || s[0] != '[' || level == len(s) || s[level] != '[' {
+		return 0
+	}
+	closing := "]" + strings.Repeat("=", level-1) + "]"
+	if end := strings.Index(s[level+1:], closing); end >= 0 {
+		return level + 1 + end + len(closing)
+	}
+	return len(s)
+}
+
+// luaShortString returns the quoted string that s starts with, without its \z
+// escapes, followed by the newlines that they skip, and its length in s. A string
+// that isn't closed on its line is returned as it is, for the parser to report.
+func luaShortString(s string) (string, int) {
+	var b strings.Builder
+	newlines := 0
+	b.WriteByte(s[0])
+	for i := 1; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == s[0]:
+			b.WriteByte(c)
+			b.WriteString(strings.Repeat("\n", newlines))
+			return b.String(), i + 1
+		case c == '\n' || c == '\r':
+			return s[:i], i
+		case c == '\\' && i+1 < len(s) && s[i+1] == 'z':
+			i += 2
+			for i < len(s) && strings.IndexByte(" \t\n\r\v\f", s[i]) >= 0 {
+				// like the parser, "\r\n" and "\n\r" end a single line
+				if c := s[i]; c == '\n' || c == '\r' {
+					newlines++
+					if i+1 < len(s) && s[i+1] == '\n'+'\r'-c {
+						i++
+					}
+				}
+				i++
+			}
+			i--
+		case c == '\\' && strings.HasPrefix(s[i+1:], "\r\n"):
+			b.WriteString(s[i : i+3])
+			i += 2
+		case c == '\\' && i+1 < len(s):
+			b.WriteString(s[i : i+2])
+			i++
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return s, len(s)
+}
+
+// luaNumberLen returns the length of the number that s starts with, read like
+// LuaJIT does: up to the first character that can't be in a number.
+func luaNumberLen(s string) int {
+	exp := byte('e')
+	if strings.HasPrefix(s, "0x") || strings.HasPrefix(s, "0X") {
+		exp = 'p'
+	}
+	n := 0
+	for n < len(s) {
+		c := s[n]
+		switch {
+		case c == '_' || c == '.' || isLetter(c) || isDigit(c):
+		case (c == '+' || c == '-') && n > 0 && s[n-1]|0x20 == exp:
+		default:
+			return n
+		}
+		n++
+	}
+	return n
+}
+
+func isLetter(c byte) bool {
+	return c|0x20 >= 'a' && c|0x20 <= 'z'
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func contains(xs []string, x string) bool {
+	for _, s := range xs {
+		if s == x {
+			return true
+		}
+	}
+	return false
+}
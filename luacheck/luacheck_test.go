@@ -0,0 +1,186 @@
+/**
+ * Copyright (c) F5, Inc.
+ *
+ * This source code is licensed under the Apache License, Version 2.0 license found in the
+ * LICENSE file in the root directory of this source tree.
+ */
+
+package luacheck
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/nginxinc/nginx-go-crossplane"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheck(t *testing.T) {
+	t.Parallel()
+	tests := map[string]struct {
+		config string
+		want   []Problem
+	}{
+		"valid blocks": {
+			config: `http {
+    init_worker_by_lua_block {
+        ngx.timer.at(0, function()
+            ngx.sleep(1)
+        end)
+    }
+    server {
+        location / {
+            content_by_lua_block {
+                local sock = ngx.socket.tcp()
+                ngx.sleep(0.1) -- header_filter_by_lua_block { ngx.sleep(1) }
+                ngx.say("ngx.sleep in a string")
+            }
+        }
+    }
+}
+`,
+		},
+		"syntax errors": {
+			config: `http {
+    init_by_lua_block {
+        local a =
+            = 3
+    }
+    server {
+        location / {
+            set_by_lua_block $res {
+                if a then
+                    return 1
+            }
+        }
+    }
+}
+`,
+			want: []Problem{
+				{Directive: "init_by_lua_block", Line: 4, Reason: `lua syntax error near "="`},
+				{Directive: "set_by_lua_block", Line: 11, Reason: `lua syntax error near "<eof>"`},
+			},
+		},
+		"luajit": {
+			config: `http {
+    server {
+        location / {
+            header_filter_by_lua_block {
+                local s = 'skipped \z
+                           spaces' .. "\
+"
+                local n = 0x1p4 + 0x.8P-1 + 1LL + 2ULL + 3i + 1e3
+
+                ngx.sleep(n)
+            }
+        }
+    }
+}
+`,
+			want: []Problem{
+				{
+					Directive: "header_filter_by_lua_block", Line: 10,
+					Reason: "ngx.sleep: API disabled in the context of header_filter_by_lua_block",
+				},
+			},
+		},
+		"crlf line endings": {
+			config: "http {\r\n" +
+				"    init_by_lua_block {\r\n" +
+				"        local s = 'skipped \\z\r\n" +
+				"                   \r\n" +
+				"                   spaces'\r\n" +
+				"        local a =\r\n" +
+				"            = 3\r\n" +
+				"    }\r\n" +
+				"    init_worker_by_lua_block {\r\n" +
+				"        local t = 'skipped \\z\r" +
+				"                   cr'\r\n" +
+				"        local b =\r\n" +
+				"            = 3\r\n" +
+				"    }\r\n" +
+				"}\r\n",
+			want: []Problem{
+				{Directive: "init_by_lua_block", Line: 7, Reason: `lua syntax error near "="`},
+				// Lua ends a line at a lone "\r" too, unlike the config
+				{Directive: "init_worker_by_lua_block", Line: 13, Reason: `lua syntax error near "="`},
+			},
+		},
+		"block on the next line": {
+			config: `http {
+    init_by_lua_block
+    {
+        local a =
+            = 3
+    }
+    server {
+        location /
+        {
+            set_by_lua_block $res
+                {
+                    return 1 +
+                }
+        }
+    }
+}
+`,
+			want: []Problem{
+				{Directive: "init_by_lua_block", Line: 5, Reason: `lua syntax error near "="`},
+				{Directive: "set_by_lua_block", Line: 13, Reason: `lua syntax error near "<eof>"`},
+			},
+		},
+		"blocking apis": {
+			config: `http {
+    server {
+        location / {
+            header_filter_by_lua_block {
+                local res = ngx.location.capture("/sub")
+
+                ngx.sleep(1)
+            }
+            log_by_lua_block {
+                local sock = ngx.socket.tcp()
+            }
+        }
+    }
+}
+`,
+			want: []Problem{
+				{
+					Directive: "header_filter_by_lua_block", Line: 5,
+					Reason: "ngx.location.capture: API disabled in the context of header_filter_by_lua_block",
+				},
+				{
+					Directive: "header_filter_by_lua_block", Line: 7,
+					Reason: "ngx.sleep: API disabled in the context of header_filter_by_lua_block",
+				},
+				{
+					Directive: "log_by_lua_block", Line: 10,
+					Reason: "ngx.socket.tcp: API disabled in the context of log_by_lua_block",
+				},
+			},
+		},
+	}
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			path := filepath.Join(t.TempDir(), "nginx.conf")
+			require.NoError(t, os.WriteFile(path, []byte(tc.config), 0o600))
+			payload, err := crossplane.Parse(path, &crossplane.ParseOptions{
+				SingleFile:               true,
+				ErrorOnUnknownDirectives: true,
+				DirectiveSources:         []crossplane.MatchFunc{crossplane.MatchNginxPlusLatest, crossplane.MatchLuaLatest},
+				LexOptions:               crossplane.LexOptions{Lexers: []crossplane.RegisterLexer{(&crossplane.Lua{}).RegisterLexer()}},
+			})
+			require.NoError(t, err)
+			require.Empty(t, payload.Errors)
+
+			for i := range tc.want {
+				tc.want[i].File = path
+			}
+			require.Equal(t, tc.want, Check(payload))
+		})
+	}
+}
@@ -16,6 +16,7 @@ import (
 	"regexp"
 	"sort"
 	"strings"
+	"sync"
 )
 
 //nolint:gochecknoglobals
@@ -46,11 +47,56 @@ type fileCtx struct {
 type parser struct {
 	configDir       string
 	options         *ParseOptions
-	handleError     func(*Config, error)
 	includes        []fileCtx
 	included        map[string]int
 	includeEdges    map[string][]string
 	includeInDegree map[string]int
+
+	// cache is the Parser whose files are reused, entries the files to keep
+	// in it and changed the files that weren't reused.
+	cache   *Parser
+	entries map[string]*cacheEntry
+	changed []string
+
+	// modules are the modules loaded by the config when the directive sources
+	// are selected from it
+	modules string
+}
+
+// parsedFile is a config file as parsed on its own. Parse adds it to the payload
+// in the order files are included, which is when its errors are handled and its
+// included files get their indices.
+type parsedFile struct {
+	Config
+	openErr  error
+	parseErr error
+	errs     []error
+	includes []includeStmt
+	found    []foundIncludes
+	entry    *cacheEntry
+	reused   bool
+	arena    *arena
+	interned map[string]string
+	// args is reused to collect the arguments of each directive
+	args []string
+}
+
+// includeStmt is an include directive along with the names of the files it matched.
+type includeStmt struct {
+	stmt   *Directive
+	fnames []string
+	ctx    blockCtx
+}
+
+// foundIncludes is what findIncludes returned for a pattern.
+type foundIncludes struct {
+	pattern string
+	fnames  []string
+	err     error
+}
+
+func (f *parsedFile) handleError(err error) {
+	f.errs = append(f.errs, err)
 }
 
 // MatchFunc is the signature of the match function used to identify NGINX directives that
@@ -86,6 +132,9 @@ type ParseOptions struct {
 	// Config structs into one.
 	CombineConfigs bool
 
+	// CombineOptions determine how configs are combined when CombineConfigs is true.
+	CombineOptions CombineOptions
+
 	// If true, only the config file with the given filename will be parsed
 	// and Parse will not parse files included files.
 	SingleFile bool
@@ -110,13 +159,65 @@ type ParseOptions struct {
 	// to DefaultDirectivesMatchFunc.
 	DirectiveSources []MatchFunc
 
+	// If true, DirectiveSources is ignored and the directive sources are the
+	// ones returned by the DirectiveSources function for NginxBuild and the
+	// load_module directives in the main context of the config, including the
+	// files it includes there. The config is read once more to find them.
+	AutoDirectiveSources bool
+
+	// NginxBuild is the output of "nginx -V" of the nginx that the config is
+	// for. It's used when AutoDirectiveSources is true.
+	NginxBuild string
+
+	// BlockContexts are the block contexts of third-party modules, and the
+	// directives allowed in them, in addition to the ones of DirectiveSources.
+	BlockContexts *BlockContexts
+
+	// MapBodies are map-like block directives in addition to the bundled ones,
+	// like map or geo, by name. They take the place of bundled ones of the
+	// same name.
+	MapBodies map[string]MapBody
+
+	// Concurrency is the maximum number of config files parsed at the same
+	// time. Values below 2 parse files one after the other. Concurrent parses
+	// give the same Payload, but Open, Glob, DirectiveSources and the lexers in
+	// LexOptions may then be called from several goroutines. ErrorCallback is
+	// always called from the goroutine that called Parse.
+	Concurrency int
+
+	// If true, directive names are the strings of the directive tables and the
+	// arguments that are repeated within a file share one copy. The arguments
+	// are copied from the config that was read, so it can be freed.
+	InternStrings bool
+
+	// If true, directives and their arguments are allocated in slabs that are
+	// reused by later parses once Payload.Release is called. The first slabs of
+	// small files are sized from their length. The Payload, and the ones combined
+	// from it, must not be used after that.
+	UseArena bool
+
 	LexOptions LexOptions
+
+	// If true, the parser follows Python crossplane where the two differ, so that
+	// MarshalPythonJSON encodes the resulting Payload exactly like Python crossplane:
+	//   - errors of directives with several bitmasks are reported for the last one,
+	//   - arguments of "if" directives aren't checked for parentheses,
+	//   - bodies of "map-like" blocks are parsed as regular directives,
+	//   - include directives always have an "includes" field.
+	PythonCompat bool
 }
 
 // Parse parses an NGINX configuration file.
-//
-//nolint:funlen
 func Parse(filename string, options *ParseOptions) (*Payload, error) {
+	payload, _, err := parseFiles(filename, options, nil)
+	return payload, err
+}
+
+// parseFiles parses an NGINX configuration file, reusing the files of cache if
+// it isn't nil. It also returns the files that weren't reused.
+//
+//nolint:funlen,gocognit
+func parseFiles(filename string, options *ParseOptions, cache *Parser) (*Payload, []string, error) {
 	payload := &Payload{
 		Status: "ok",
 		Errors: []PayloadError{},
@@ -131,6 +232,10 @@ func Parse(filename string, options *ParseOptions) (*Payload, error) {
 		if e, ok := err.(*ParseError); ok {
 			line = e.Line
 		}
+		if options.PythonCompat && errors.Is(err, ErrPrematureLexEnd) {
+			// Python crossplane runs out of tokens with a StopIteration, which has no line
+			line = nil
+		}
 		cerr := ConfigError{Line: line, Error: err}
 		perr := PayloadError{Line: line, Error: err, File: config.File}
 		if options.ErrorCallback != nil {
@@ -147,57 +252,174 @@ func Parse(filename string, options *ParseOptions) (*Payload, error) {
 
 	// Start with the main nginx config file/context.
 	p := parser{
-		configDir:   filepath.Dir(filename),
-		options:     options,
-		handleError: handleError,
-		includes:    []fileCtx{{path: filename, ctx: blockCtx{}}},
-		included:    map[string]int{filename: 0},
+		configDir: filepath.Dir(filename),
+		options:   options,
+		includes:  []fileCtx{{path: filename, ctx: blockCtx{}}},
+		included:  map[string]int{filename: 0},
 		// adjacency list where an edge exists between a file and the file it includes
 		includeEdges: map[string][]string{},
 		// number of times a file is included by another file
 		includeInDegree: map[string]int{filename: 0},
+		cache:           cache,
+		entries:         map[string]*cacheEntry{},
 	}
+	p.modules = strings.Join(p.autoDirectiveSources(filename), ",")
 
-	for len(p.includes) > 0 {
-		incl := p.includes[0]
-		p.includes = p.includes[1:]
+	workers := options.Concurrency
+	if workers < 1 {
+		workers = 1
+	}
 
-		file, err := p.openFile(incl.path)
-		if err != nil {
-			return nil, err
+	// Files are parsed ahead of the one being added to the payload, up to the
+	// number of workers. Adding them in order keeps the payload the same as
+	// when they are parsed one after the other.
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	results := []chan *parsedFile{}
+	for i := 0; i < len(p.includes); i++ {
+		for n := len(results); n < len(p.includes) && n < i+workers; n++ {
+			c := make(chan *parsedFile, 1)
+			results = append(results, c)
+			wg.Add(1)
+			go func(incl fileCtx) {
+				defer wg.Done()
+				c <- p.parseFile(incl)
+			}(p.includes[n])
 		}
 
-		defer file.Close()
-
-		tokens := LexWithOptions(file, options.LexOptions)
-		config := Config{
-			File:   incl.path,
-			Status: "ok",
-			Errors: []ConfigError{},
-			Parsed: Directives{},
+		f := <-results[i]
+		if f.openErr != nil {
+			return nil, nil, f.openErr
 		}
-		parsed, err := p.parse(&config, tokens, incl.ctx, false)
-		if err != nil {
+		for _, err := range f.errs {
+			handleError(&f.Config, err)
+		}
+		if f.parseErr != nil {
 			if options.StopParsingOnError {
-				return nil, err
+				return nil, nil, f.parseErr
 			}
-			handleError(&config, err)
-		} else {
-			config.Parsed = parsed
+			handleError(&f.Config, f.parseErr)
+		}
+		p.addIncludes(f)
+		if f.entry != nil {
+			p.entries[f.File] = f.entry
+		}
+		if !f.reused {
+			p.changed = append(p.changed, f.File)
 		}
 
-		payload.Config = append(payload.Config, config)
+		payload.Config = append(payload.Config, f.Config)
+		if f.arena != nil {
+			payload.arenas = append(payload.arenas, f.arena)
+		}
 	}
 
 	if p.isAcyclic() {
-		return nil, errors.New("configs contain include cycle")
+		return nil, nil, errors.New("configs contain include cycle")
+	}
+
+	if cache != nil {
+		p.changed = cache.update(p.entries, p.changed)
 	}
 
 	if options.CombineConfigs {
-		return payload.Combined()
+		combined, err := payload.CombinedWithOptions(options.CombineOptions)
+		return combined, p.changed, err
+	}
+
+	return payload, p.changed, nil
+}
+
+// parseFile parses one config file. It's safe to call concurrently since it
+// doesn't change p.
+func (p *parser) parseFile(incl fileCtx) *parsedFile {
+	f := &parsedFile{
+		Config: Config{
+			File:   incl.path,
+			Status: "ok",
+			Errors: []ConfigError{},
+			Parsed: Directives{},
+		},
+	}
+
+	file, err := p.openFile(incl.path)
+	if err != nil {
+		f.openErr = err
+		return f
+	}
+	defer file.Close()
+
+	var sb strings.Builder
+	_, readErr := io.Copy(&sb, file)
+	src := sb.String()
+
+	var key fileKey
+	if p.cache != nil && readErr == nil {
+		key = p.cache.key(p, incl, src)
+		if cached := p.cache.lookup(p, key); cached != nil {
+			return cached
+		}
+	}
+
+	if p.options.UseArena {
+		f.arena = newArena(len(src))
+	}
+	if p.options.InternStrings {
+		f.interned = map[string]string{}
+	}
+	parsed, err := p.parse(f, newTokenizer(src, readErr, p.options.LexOptions), incl.ctx, false)
+	if err != nil {
+		f.parseErr = err
+	} else {
+		f.Parsed = parsed
 	}
 
-	return payload, nil
+	if p.cache != nil && readErr == nil {
+		// keep a copy from before the includes get their indices
+		f.entry = &cacheEntry{key: key, file: f.clone()}
+	}
+	return f
+}
+
+// findIncludes returns the names of the files matched by the pattern of an include directive.
+func (p *parser) findIncludes(pattern string) ([]string, error) {
+	if hasMagic.MatchString(pattern) {
+		fnames, err := p.options.Glob(pattern)
+		if err != nil {
+			return nil, err
+		}
+		sort.Strings(fnames)
+		return fnames, nil
+	}
+
+	// if the file pattern was explicit, nginx will check
+	// that the included file can be opened and read
+	f, err := p.openFile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	f.Close()
+	return []string{pattern}, nil
+}
+
+// addIncludes gives indices to the files included by f and queues the ones
+// that haven't been seen yet.
+func (p *parser) addIncludes(f *parsedFile) {
+	for _, incl := range f.includes {
+		for _, fname := range incl.fnames {
+			// the included set keeps files from being parsed twice
+			// TODO: handle files included from multiple contexts
+			if _, ok := p.included[fname]; !ok {
+				p.included[fname] = len(p.included)
+				p.includes = append(p.includes, fileCtx{fname, incl.ctx})
+			}
+			incl.stmt.Includes = append(incl.stmt.Includes, p.included[fname])
+			// add edge between the current file and it's included file and
+			// increase the included file's in degree
+			p.includeEdges[f.File] = append(p.includeEdges[f.File], fname)
+			p.includeInDegree[fname]++
+		}
+	}
 }
 
 func (p *parser) openFile(path string) (io.ReadCloser, error) {
@@ -210,28 +432,18 @@ func (p *parser) openFile(path string) (io.ReadCloser, error) {
 
 // parse Recursively parses directives from an nginx config context.
 //
-//nolint:gocyclo,funlen,gocognit,maintidx,nonamedreturns
-func (p *parser) parse(parsing *Config, tokens <-chan NgxToken, ctx blockCtx, consume bool) (parsed Directives, err error) {
-	var tokenOk bool
+//nolint:nonamedreturns
+func (p *parser) parse(parsing *parsedFile, tokens *Tokenizer, ctx blockCtx, consume bool) (parsed Directives, err error) {
 	// parse recursively by pulling from a flat stream of tokens
-	for t := range tokens {
-		if t.Error != nil {
-			var perr *ParseError
-			if errors.As(t.Error, &perr) {
-				perr.File = &parsing.File
-				perr.BlockCtx = ctx.getLastBlock()
-				return nil, perr
-			}
-			return nil, &ParseError{
-				What:        t.Error.Error(),
-				File:        &parsing.File,
-				Line:        &t.Line,
-				originalErr: t.Error,
-				BlockCtx:    ctx.getLastBlock(),
-			}
+	for {
+		t, tokenOk := tokens.Next()
+		if !tokenOk {
+			break
 		}
 
-		var commentsInArgs []string
+		if t.Error != nil {
+			return nil, parsing.tokenError(t, ctx)
+		}
 
 		// we are parsing a block, so break if it's closing
 		if t.Value == "}" && !t.IsQuoted {
@@ -247,205 +459,267 @@ func (p *parser) parse(parsing *Config, tokens <-chan NgxToken, ctx blockCtx, co
 			continue
 		}
 
-		var fileName string
-		if p.options.CombineConfigs {
-			fileName = parsing.File
+		s, err := p.readStmt(parsing, tokens, t, ctx)
+		if err != nil {
+			return nil, err
 		}
-
-		// the first token should always be an nginx directive
-		stmt := &Directive{
-			Directive: t.Value,
-			Line:      t.Line,
-			Args:      []string{},
-			File:      fileName,
+		switch s.action {
+		case stmtSkip:
+			continue
+		case stmtConsume:
+			_, _ = p.parse(parsing, tokens, nil, true)
+			continue
+		case stmtEndBlock:
+			return parsed, nil
+		case stmtAdd:
 		}
 
-		// if token is comment
-		if strings.HasPrefix(t.Value, "#") && !t.IsQuoted {
-			if p.options.ParseComments {
-				comment := t.Value[1:]
-				stmt.Directive = "#"
-				stmt.Comment = &comment
-				parsed = append(parsed, stmt)
+		// if this statement terminated with "{" then it is a block
+		stmt := s.directive
+		if s.block {
+			stmt.Block = make(Directives, 0)
+			inner := enterBlockCtx(stmt, ctx) // get context for block
+			blocks, err := p.parse(parsing, tokens, inner, false)
+			if err != nil {
+				return nil, err
 			}
-			continue
+			stmt.Block = append(stmt.Block, blocks...)
 		}
 
-		// parse arguments by reading tokens
-		t, tokenOk = <-tokens
-		if !tokenOk {
-			return nil, &ParseError{
-				What:        ErrPrematureLexEnd.Error(),
-				File:        &parsing.File,
-				Line:        &stmt.Line,
-				originalErr: ErrPrematureLexEnd,
-				BlockCtx:    ctx.getLastBlock(),
-			}
+		parsed = append(parsed, stmt)
+
+		// add all comments found inside args after stmt is added
+		parsed = append(parsed, p.argComments(parsing, stmt, s.comments)...)
+	}
+
+	return parsed, nil
+}
+
+// stmtAction is what parse does with a statement read by readStmt.
+type stmtAction int
+
+const (
+	// stmtAdd adds the statement, and parses its block if it has one.
+	stmtAdd stmtAction = iota
+	// stmtSkip drops the statement.
+	stmtSkip
+	// stmtConsume drops the statement and consumes its block.
+	stmtConsume
+	// stmtEndBlock drops the statement and ends the block it's in.
+	stmtEndBlock
+)
+
+// parsedStmt is a statement read by parser.readStmt.
+type parsedStmt struct {
+	directive *Directive
+	action    stmtAction
+	// block is set if the statement is terminated by "{"
+	block bool
+	// comments are the ones found in the args of the statement
+	comments []string
+}
+
+// readStmt reads the statement that starts with the token t, and checks it in the
+// context ctx. It's what parse and Decoder do with every statement of a file. The
+// errors that the parse carries on after are added to parsing, and the ones that
+// stop it are returned.
+//
+//nolint:gocyclo,funlen,gocognit,maintidx
+func (p *parser) readStmt(parsing *parsedFile, tokens *Tokenizer, t NgxToken, ctx blockCtx) (parsedStmt, error) {
+	var fileName string
+	if p.options.CombineConfigs {
+		fileName = parsing.File
+	}
+
+	// the first token should always be an nginx directive
+	stmt := parsing.newDirective(t.Value, t.Line, fileName)
+	s := parsedStmt{directive: stmt}
+
+	// if token is comment
+	if strings.HasPrefix(t.Value, "#") && !t.IsQuoted {
+		if !p.options.ParseComments {
+			s.action = stmtSkip
+			return s, nil
 		}
-		for t.IsQuoted || (t.Value != "{" && t.Value != ";" && t.Value != "}") {
-			if !strings.HasPrefix(t.Value, "#") || t.IsQuoted {
-				stmt.Args = append(stmt.Args, t.Value)
-			} else if p.options.ParseComments {
-				commentsInArgs = append(commentsInArgs, t.Value[1:])
-			}
-			t, tokenOk = <-tokens
-			if !tokenOk {
-				return nil, &ParseError{
-					What:        ErrPrematureLexEnd.Error(),
-					File:        &parsing.File,
-					Line:        &stmt.Line,
-					originalErr: ErrPrematureLexEnd,
-					BlockCtx:    ctx.getLastBlock(),
-				}
-			}
+		comment := t.Value[1:]
+		stmt.Directive = "#"
+		stmt.Comment = &comment
+		return s, nil
+	}
+
+	// parse arguments by reading tokens
+	t, tokenOk := tokens.Next()
+	if !tokenOk {
+		return s, parsing.prematureEnd(stmt, ctx)
+	}
+	args := parsing.args[:0]
+	for t.IsQuoted || (t.Value != "{" && t.Value != ";" && t.Value != "}") {
+		if !strings.HasPrefix(t.Value, "#") || t.IsQuoted {
+			args = append(args, parsing.intern(t.Value))
+			stmt.lastArgLine = t.Line
+		} else if p.options.ParseComments {
+			s.comments = append(s.comments, t.Value[1:])
+		}
+		t, tokenOk = tokens.Next()
+		if !tokenOk {
+			return s, parsing.prematureEnd(stmt, ctx)
 		}
+	}
+	stmt.Args = parsing.copyArgs(args)
+	parsing.args = args
+
+	isBlock := t.Value == "{" && !t.IsQuoted
+	skip := stmtSkip
+	if isBlock {
+		skip = stmtConsume
+	}
 
-		// if inside "map-like" block - add contents to payload, but do not parse further
-		if len(ctx) > 0 {
-			if _, ok := mapBodies[ctx[len(ctx)-1]]; ok {
-				mapErr := analyzeMapBody(parsing.File, stmt, t.Value, ctx[len(ctx)-1])
-				if mapErr != nil && p.options.StopParsingOnError {
-					return nil, mapErr
-				} else if mapErr != nil {
-					p.handleError(parsing, mapErr)
-					// consume invalid block
-					if t.Value == "{" && !t.IsQuoted {
-						_, _ = p.parse(parsing, tokens, nil, true)
-					}
-					continue
-				}
-				stmt.IsMapBlockParameter = true
-				parsed = append(parsed, stmt)
-				continue
+	// if inside "map-like" block - add contents to payload, but do not parse further
+	if len(ctx) > 0 && !p.options.PythonCompat {
+		if body, ok := lookupMapBody(ctx[len(ctx)-1], p.options); ok {
+			mapErr := analyzeMapBody(parsing.File, stmt, t.Value, ctx[len(ctx)-1], body)
+			if mapErr != nil && p.options.StopParsingOnError {
+				return s, mapErr
+			} else if mapErr != nil {
+				parsing.handleError(mapErr)
+				// consume invalid block
+				s.action = skip
+				return s, nil
 			}
+			stmt.IsMapBlockParameter = true
+			return s, nil
 		}
+	}
 
-		// consume the directive if it is ignored and move on
-		if contains(p.options.IgnoreDirectives, stmt.Directive) {
-			// if this directive was a block consume it too
-			if t.Value == "{" && !t.IsQuoted {
-				_, _ = p.parse(parsing, tokens, nil, true)
+	// consume the directive if it is ignored and move on
+	if contains(p.options.IgnoreDirectives, stmt.Directive) {
+		// if this directive was a block consume it too
+		s.action = skip
+		return s, nil
+	}
+
+	// raise errors if this statement is invalid
+	err := analyze(parsing.File, stmt, t.Value, ctx, p.options)
+
+	var perr *ParseError
+	if errors.As(err, &perr) && !p.options.StopParsingOnError {
+		parsing.handleError(perr)
+		s.action = stmtSkip
+		// if it was a block but shouldn"t have been then consume
+		if strings.HasSuffix(perr.What, ` is not terminated by ";"`) {
+			if t.Value != "}" && !t.IsQuoted {
+				s.action = stmtConsume
+			} else {
+				s.action = stmtEndBlock
 			}
-			continue
 		}
+		// keep on parsin'
+		return s, nil
+	} else if err != nil {
+		return s, err
+	}
+
+	// prepare arguments - strip parentheses
+	if stmt.Directive == "if" {
+		stmt = prepareIfArgs(stmt)
+		s.directive = stmt
+	}
 
-		// raise errors if this statement is invalid
-		err = analyze(parsing.File, stmt, t.Value, ctx, p.options)
-
-		if perr, ok := err.(*ParseError); ok && !p.options.StopParsingOnError {
-			p.handleError(parsing, perr)
-			// if it was a block but shouldn"t have been then consume
-			if strings.HasSuffix(perr.What, ` is not terminated by ";"`) {
-				if t.Value != "}" && !t.IsQuoted {
-					_, _ = p.parse(parsing, tokens, nil, true)
-				} else {
-					break
-				}
+	// add "includes" to the payload if this is an include statement
+	if !p.options.SingleFile && stmt.Directive == "include" {
+		if len(stmt.Args) == 0 {
+			return s, &ParseError{
+				What: fmt.Sprintf(`invalid number of arguments in "%s" directive in %s:%d`,
+					stmt.Directive,
+					parsing.File,
+					stmt.Line,
+				),
+				File:      &parsing.File,
+				Line:      &stmt.Line,
+				Statement: stmt.String(),
+				BlockCtx:  ctx.getLastBlock(),
 			}
-			// keep on parsin'
-			continue
-		} else if err != nil {
-			return nil, err
 		}
 
-		// prepare arguments - strip parentheses
-		if stmt.Directive == "if" {
-			stmt = prepareIfArgs(stmt)
+		if p.options.PythonCompat {
+			stmt.Includes = []int{}
 		}
 
-		// add "includes" to the payload if this is an include statement
-		if !p.options.SingleFile && stmt.Directive == "include" {
-			if len(stmt.Args) == 0 {
-				return nil, &ParseError{
-					What: fmt.Sprintf(`invalid number of arguments in "%s" directive in %s:%d`,
-						stmt.Directive,
-						parsing.File,
-						stmt.Line,
-					),
-					File:      &parsing.File,
-					Line:      &stmt.Line,
-					Statement: stmt.String(),
-					BlockCtx:  ctx.getLastBlock(),
-				}
-			}
-
-			pattern := stmt.Args[0]
-			if !filepath.IsAbs(pattern) {
-				pattern = filepath.Join(p.configDir, pattern)
-			}
+		pattern := stmt.Args[0]
+		if !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(p.configDir, pattern)
+		}
 
-			// get names of all included files
-			var fnames []string
-			if hasMagic.MatchString(pattern) {
-				fnames, err = p.options.Glob(pattern)
-				if err != nil {
-					return nil, err
-				}
-				sort.Strings(fnames)
-			} else {
-				// if the file pattern was explicit, nginx will check
-				// that the included file can be opened and read
-				if f, err := p.openFile(pattern); err != nil {
-					perr := &ParseError{
-						What:      err.Error(),
-						File:      &parsing.File,
-						Line:      &stmt.Line,
-						Statement: stmt.String(),
-						BlockCtx:  ctx.getLastBlock(),
-					}
-					if !p.options.StopParsingOnError {
-						p.handleError(parsing, perr)
-					} else {
-						return nil, perr
-					}
-				} else {
-					defer f.Close()
-					fnames = []string{pattern}
-				}
+		// get names of all included files
+		fnames, err := p.findIncludes(pattern)
+		parsing.found = append(parsing.found, foundIncludes{pattern: pattern, fnames: fnames, err: err})
+		if err != nil && hasMagic.MatchString(pattern) {
+			return s, err
+		} else if err != nil {
+			perr := &ParseError{
+				What:        err.Error(),
+				File:        &parsing.File,
+				Line:        &stmt.Line,
+				Statement:   stmt.String(),
+				BlockCtx:    ctx.getLastBlock(),
+				originalErr: err,
 			}
-
-			for _, fname := range fnames {
-				// the included set keeps files from being parsed twice
-				// TODO: handle files included from multiple contexts
-				if _, ok := p.included[fname]; !ok {
-					p.included[fname] = len(p.included)
-					p.includes = append(p.includes, fileCtx{fname, ctx})
-				}
-				stmt.Includes = append(stmt.Includes, p.included[fname])
-				// add edge between the current file and it's included file and
-				// increase the included file's in degree
-				p.includeEdges[parsing.File] = append(p.includeEdges[parsing.File], fname)
-				p.includeInDegree[fname]++
+			if p.options.StopParsingOnError {
+				return s, perr
 			}
+			parsing.handleError(perr)
 		}
 
-		// if this statement terminated with "{" then it is a block
-		if t.Value == "{" && !t.IsQuoted {
-			stmt.Block = make(Directives, 0)
-			inner := enterBlockCtx(stmt, ctx) // get context for block
-			blocks, err := p.parse(parsing, tokens, inner, false)
-			if err != nil {
-				return nil, err
-			}
-			stmt.Block = append(stmt.Block, blocks...)
-		}
+		parsing.includes = append(parsing.includes, includeStmt{stmt: stmt, fnames: fnames, ctx: ctx})
+	}
 
-		parsed = append(parsed, stmt)
+	s.block = isBlock
+	return s, nil
+}
 
-		// add all comments found inside args after stmt is added
-		for _, comment := range commentsInArgs {
-			comment := comment
-			parsed = append(parsed, &Directive{
-				Directive: "#",
-				Line:      stmt.Line,
-				Args:      []string{},
-				File:      fileName,
-				Comment:   &comment,
-			})
-		}
+// argComments returns the comments found inside the args of stmt as directives.
+func (p *parser) argComments(parsing *parsedFile, stmt *Directive, comments []string) Directives {
+	var fileName string
+	if p.options.CombineConfigs && !p.options.PythonCompat {
+		// Python crossplane doesn't add the file to these
+		fileName = parsing.File
+	}
+	var ds Directives
+	for _, comment := range comments {
+		comment := comment
+		d := parsing.newDirective("#", stmt.Line, fileName)
+		d.Comment = &comment
+		ds = append(ds, d)
 	}
+	return ds
+}
 
-	return parsed, nil
+// tokenError returns the error of a token of parsing read in the context ctx.
+func (f *parsedFile) tokenError(t NgxToken, ctx blockCtx) error {
+	var perr *ParseError
+	if errors.As(t.Error, &perr) {
+		perr.File = &f.File
+		perr.BlockCtx = ctx.getLastBlock()
+		return perr
+	}
+	return &ParseError{
+		What:        t.Error.Error(),
+		File:        &f.File,
+		Line:        &t.Line,
+		originalErr: t.Error,
+		BlockCtx:    ctx.getLastBlock(),
+	}
+}
+
+// prematureEnd returns the error of a file that ends in the middle of stmt.
+func (f *parsedFile) prematureEnd(stmt *Directive, ctx blockCtx) error {
+	return &ParseError{
+		What:        ErrPrematureLexEnd.Error(),
+		File:        &f.File,
+		Line:        &stmt.Line,
+		originalErr: ErrPrematureLexEnd,
+		BlockCtx:    ctx.getLastBlock(),
+	}
 }
 
 // isAcyclic performs a topological sort to check if there are cycles created by configs' includes.
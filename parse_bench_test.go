@@ -11,9 +11,12 @@ import (
 	"bytes"
 	"compress/bzip2"
 	"flag"
+	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"runtime"
+	"strings"
 	"sync"
 	"testing"
 
@@ -92,6 +95,140 @@ func benchmarkParseLargeConfig(b *testing.B, sz int) {
 	}
 }
 
+// getLargeConfigData returns the uncompressed content of the large config.
+func getLargeConfigData(b *testing.B) string {
+	if !*runBenchLocally {
+		b.Skip("getLargeConfigData is only run locally when -local-parse-bench is specified")
+	}
+
+	f, err := os.Open(getTestConfigPath("large-config", "nginx.conf.bz2"))
+	require.NoError(b, err)
+	defer f.Close()
+
+	data, err := io.ReadAll(bzip2.NewReader(f))
+	require.NoError(b, err)
+	return string(data)
+}
+
+func BenchmarkLexLargeConfig(b *testing.B) {
+	data := getLargeConfigData(b)
+
+	b.ReportAllocs()
+	b.SetBytes(int64(len(data)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for tok := range Lex(strings.NewReader(data)) {
+			_ = tok
+		}
+	}
+}
+
+func BenchmarkTokenizeLargeConfig(b *testing.B) {
+	data := getLargeConfigData(b)
+
+	b.ReportAllocs()
+	b.SetBytes(int64(len(data)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		t := NewTokenizer(strings.NewReader(data), LexOptions{})
+		for _, ok := t.Next(); ok; _, ok = t.Next() {
+		}
+	}
+}
+
+// getLargeConfigPayload returns the payload of the large config. If split is true, the
+// blocks inside of its blocks are moved to configs of their own, which are included.
+func getLargeConfigPayload(b *testing.B, split bool) *Payload {
+	data := getLargeConfigData(b)
+	payload, err := Parse("nginx.conf", &ParseOptions{
+		SingleFile:         true,
+		StopParsingOnError: true,
+		Open: func(string) (io.ReadCloser, error) {
+			return io.NopCloser(strings.NewReader(data)), nil
+		},
+	})
+	require.NoError(b, err)
+	if !split {
+		return payload
+	}
+
+	for _, stmt := range payload.Config[0].Parsed {
+		for i, inner := range stmt.Block {
+			if !inner.IsBlock() {
+				continue
+			}
+			file := fmt.Sprintf("conf.d/%d.conf", len(payload.Config))
+			stmt.Block[i] = &Directive{Directive: "include", Args: []string{file}, Line: inner.Line, Includes: []int{len(payload.Config)}}
+			payload.Config = append(payload.Config, Config{File: file, Status: "ok", Parsed: Directives{inner}})
+		}
+	}
+	return payload
+}
+
+func benchmarkCombineLargeConfig(b *testing.B, split bool) {
+	payload := getLargeConfigPayload(b, split)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, err := payload.Combined()
+		require.NoError(b, err)
+	}
+}
+
+func BenchmarkCombineLargeConfig(b *testing.B)         { benchmarkCombineLargeConfig(b, false) }
+func BenchmarkCombineLargeConfigIncludes(b *testing.B) { benchmarkCombineLargeConfig(b, true) }
+
+// benchmarkParseLargeConfigMemory parses the large config from memory with the
+// given options and reports the heap that's still used by the payload.
+func benchmarkParseLargeConfigMemory(b *testing.B, internStrings, useArena bool) {
+	data := getLargeConfigData(b)
+	options := &ParseOptions{
+		SingleFile:         true,
+		StopParsingOnError: true,
+		InternStrings:      internStrings,
+		UseArena:           useArena,
+		Open: func(string) (io.ReadCloser, error) {
+			return io.NopCloser(strings.NewReader(data)), nil
+		},
+	}
+
+	var before, after runtime.MemStats
+	var retained uint64
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		runtime.GC()
+		runtime.ReadMemStats(&before)
+		b.StartTimer()
+
+		payload, err := Parse("nginx.conf", options)
+		require.NoError(b, err)
+
+		b.StopTimer()
+		runtime.GC()
+		runtime.ReadMemStats(&after)
+		if after.HeapAlloc > before.HeapAlloc {
+			retained += after.HeapAlloc - before.HeapAlloc
+		}
+		payload.Release()
+		b.StartTimer()
+	}
+	b.ReportMetric(float64(retained)/float64(b.N), "retained-B/op")
+}
+
+func BenchmarkParseLargeConfigMemory(b *testing.B) { benchmarkParseLargeConfigMemory(b, false, false) }
+func BenchmarkParseLargeConfigMemory_Intern(b *testing.B) {
+	benchmarkParseLargeConfigMemory(b, true, false)
+}
+func BenchmarkParseLargeConfigMemory_Arena(b *testing.B) {
+	benchmarkParseLargeConfigMemory(b, false, true)
+}
+func BenchmarkParseLargeConfigMemory_InternArena(b *testing.B) {
+	benchmarkParseLargeConfigMemory(b, true, true)
+}
+
 func TestMain(m *testing.M) {
 	code := m.Run()
 	if rm != nil {
@@ -8,11 +8,15 @@
 package crossplane
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
+	"io"
+	"os"
 	"path/filepath"
 	"runtime"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 )
@@ -2399,6 +2403,48 @@ func TestParse(t *testing.T) {
 	}
 }
 
+// forEachFixture runs test in parallel for each of parseFixtures, with the path of
+// its config and the payload Parse returns with its options.
+func forEachFixture(t *testing.T, test func(t *testing.T, fixture parseFixture, path string, payload *Payload)) {
+	t.Helper()
+	for _, fixture := range parseFixtures {
+		fixture := fixture
+		t.Run(fixture.name+fixture.suffix, func(t *testing.T) {
+			t.Parallel()
+			path := getTestConfigPath(fixture.name, "nginx.conf")
+			options := fixture.options
+			payload, err := Parse(path, &options)
+			require.NoError(t, err)
+			test(t, fixture, path, payload)
+		})
+	}
+}
+
+// payloadCodec is an encoding of payloads, like their JSON encoding.
+type payloadCodec struct {
+	encode func(w io.Writer, payload *Payload) error
+	decode func(r io.Reader) (*Payload, error)
+}
+
+// testRoundTrip checks that the payloads of parseFixtures are the same once they're
+// encoded and decoded with codec.
+func testRoundTrip(t *testing.T, codec payloadCodec) {
+	t.Helper()
+	forEachFixture(t, func(t *testing.T, _ parseFixture, _ string, payload *Payload) {
+		var buf bytes.Buffer
+		require.NoError(t, codec.encode(&buf, payload))
+		decoded, err := codec.decode(&buf)
+		require.NoError(t, err)
+
+		require.True(t, equalPayloads(t, *payload, *decoded))
+		for i := range payload.Config {
+			for j, stmt := range payload.Config[i].Parsed {
+				require.True(t, stmt.Equal(decoded.Config[i].Parsed[j]), "directive %s differs", stmt)
+			}
+		}
+	})
+}
+
 //nolint:errchkjson
 func TestParseVarArgs(t *testing.T) {
 	t.Parallel()
@@ -2510,3 +2556,82 @@ func TestDefaultUbuntu(t *testing.T) {
 	_, err := Parse(path, &ParseOptions{SingleFile: false, StopParsingOnError: true})
 	require.NoError(t, err, "unexpected parsing error when reading test file: %s", path)
 }
+
+func TestParse_concurrency(t *testing.T) {
+	t.Parallel()
+	forEachFixture(t, func(t *testing.T, fixture parseFixture, path string, _ *Payload) {
+		options := fixture.options
+		options.Concurrency = 4
+		payload, err := Parse(path, &options)
+		require.NoError(t, err)
+		if !equalPayloads(t, *payload, fixture.expected) {
+			b1, _ := json.Marshal(fixture.expected)
+			b2, _ := json.Marshal(payload)
+			t.Fatalf("expected: %s\nbut got: %s", b1, b2)
+		}
+	})
+}
+
+func TestParse_concurrencyOrder(t *testing.T) {
+	t.Parallel()
+	// the first sites take the longest to open, so they are parsed last
+	const sites = 50
+	files := map[string]string{
+		"nginx.conf": "events {}\nhttp {\n    include sites/*.conf;\n    include missing.conf;\n}\n",
+	}
+	for i := 0; i < sites; i++ {
+		conf := fmt.Sprintf("server {\n    listen %d;\n    include shared/*.conf;\n    unknown_%d on;\n}\n", 8000+i, i)
+		if i%7 == 0 {
+			conf += "server {\n"
+		}
+		files[fmt.Sprintf("sites/site%02d.conf", i)] = conf
+	}
+	files["shared/a.conf"] = "location /a {}\n"
+	files["shared/b.conf"] = "location /b { return 200; }\n"
+
+	dir := t.TempDir()
+	for name, conf := range files {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, []byte(conf), 0o600))
+	}
+
+	parse := func(concurrency int) (*Payload, []string) {
+		var callbacks []string
+		payload, err := Parse(filepath.Join(dir, "nginx.conf"), &ParseOptions{
+			Concurrency:              concurrency,
+			ErrorOnUnknownDirectives: true,
+			ErrorCallback: func(err error) interface{} {
+				callbacks = append(callbacks, err.Error())
+				return len(callbacks)
+			},
+			Open: func(path string) (io.ReadCloser, error) {
+				var i int
+				if _, err := fmt.Sscanf(filepath.Base(path), "site%d.conf", &i); err == nil {
+					time.Sleep(time.Duration(sites-i) * 100 * time.Microsecond)
+				}
+				return os.Open(path)
+			},
+		})
+		require.NoError(t, err)
+		return payload, callbacks
+	}
+
+	expected, expectedCallbacks := parse(0)
+	require.Len(t, expected.Config, sites+3)
+	require.Len(t, expectedCallbacks, sites+sites/7+2)
+	for _, concurrency := range []int{2, 8, 64} {
+		payload, callbacks := parse(concurrency)
+		require.Equal(t, expectedCallbacks, callbacks, "concurrency %d", concurrency)
+		require.Equal(t, expected, payload, "concurrency %d", concurrency)
+	}
+}
+
+func TestParse_concurrencyStopParsingOnError(t *testing.T) {
+	t.Parallel()
+	options := ParseOptions{Concurrency: 4, StopParsingOnError: true}
+	_, expected := Parse(getTestConfigPath("includes-regular", "nginx.conf"), &ParseOptions{StopParsingOnError: true})
+	require.Error(t, expected)
+	_, err := Parse(getTestConfigPath("includes-regular", "nginx.conf"), &options)
+	require.Equal(t, expected, err)
+}
@@ -0,0 +1,316 @@
+/**
+ * Copyright (c) F5, Inc.
+ *
+ * This source code is licensed under the Apache License, Version 2.0 license found in the
+ * LICENSE file in the root directory of this source tree.
+ */
+
+package crossplane
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"strconv"
+	"strings"
+	"syscall"
+	"unicode"
+	"unicode/utf8"
+)
+
+// This file holds MarshalPythonJSON and the parts of BuildOptions.PythonCompat that
+// change how payloads and configs are written, so that the output is the same as the
+// one of Python crossplane (https://github.com/nginxinc/crossplane).
+
+// pythonRepr returns s quoted like Python 3's repr of a str.
+func pythonRepr(s string) string {
+	quote := '\''
+	if strings.ContainsRune(s, '\'') && !strings.ContainsRune(s, '"') {
+		quote = '"'
+	}
+
+	var sb strings.Builder
+	sb.WriteRune(quote)
+	for _, r := range s {
+		switch {
+		case r == quote || r == '\\':
+			sb.WriteByte('\\')
+			sb.WriteRune(r)
+		case r == '\t':
+			sb.WriteString(`\t`)
+		case r == '\n':
+			sb.WriteString(`\n`)
+		case r == '\r':
+			sb.WriteString(`\r`)
+		case r < ' ' || r == 0x7f:
+			fmt.Fprintf(&sb, `\x%02x`, r)
+		case r < utf8.RuneSelf || unicode.IsPrint(r):
+			// unicode.IsPrint and Python's str.isprintable agree on the categories they accept
+			sb.WriteRune(r)
+		case r <= 0xff:
+			fmt.Fprintf(&sb, `\x%02x`, r)
+		case r <= 0xffff:
+			fmt.Fprintf(&sb, `\u%04x`, r)
+		default:
+			fmt.Fprintf(&sb, `\U%08x`, r)
+		}
+	}
+	sb.WriteRune(quote)
+	return sb.String()
+}
+
+// pythonEnquote is Enquote as it's done by Python crossplane.
+func pythonEnquote(arg string) string {
+	if !needsQuote(arg) {
+		return arg
+	}
+	return strings.ReplaceAll(pythonRepr(arg), `\\`, `\`)
+}
+
+// pythonError returns the message Python crossplane writes for err. Most errors are
+// worded the same, except for the ones raised when an included file can't be opened
+// since Python writes the OSError as is, and premature ends of file, which Python
+// crossplane doesn't catch and end up as an empty StopIteration.
+func pythonError(err error) string {
+	if errors.Is(err, ErrPrematureLexEnd) {
+		return ""
+	}
+
+	var perr *ParseError
+	var pathErr *fs.PathError
+	if !errors.As(err, &perr) || !errors.As(perr.originalErr, &pathErr) {
+		return err.Error()
+	}
+	var errno syscall.Errno
+	if !errors.As(pathErr.Err, &errno) {
+		return err.Error()
+	}
+	// Go's messages are the C library's strerror in lower case
+	strerror := errno.Error()
+	if strerror != "" {
+		strerror = strings.ToUpper(strerror[:1]) + strerror[1:]
+	}
+	return fmt.Sprintf("[Errno %d] %s: %s", int(errno), strerror, pythonRepr(pathErr.Path))
+}
+
+// MarshalPythonJSON encodes payload like the crossplane command of Python crossplane
+// does, with Python's json.dumps and no whitespace:
+//   - keys are written in Python's order, and non-ASCII characters are escaped,
+//   - empty blocks are written as "block": [],
+//   - errors opening included files are written like Python's IOError.
+//
+// Parse the payload with ParseOptions.PythonCompat to get the exact bytes.
+func MarshalPythonJSON(payload *Payload) ([]byte, error) {
+	var buf bytes.Buffer
+	w := &pythonJSONWriter{buf: &buf}
+
+	buf.WriteString(`{"status":`)
+	w.string(payload.Status)
+	buf.WriteString(`,"errors":[`)
+	for i, e := range payload.Errors {
+		w.comma(i)
+		buf.WriteString(`{"file":`)
+		w.string(e.File)
+		buf.WriteString(`,"error":`)
+		w.error(e.Error)
+		buf.WriteString(`,"line":`)
+		w.line(e.Line)
+		if e.Callback != nil {
+			buf.WriteString(`,"callback":`)
+			if err := w.value(e.Callback); err != nil {
+				return nil, err
+			}
+		}
+		buf.WriteByte('}')
+	}
+	buf.WriteString(`],"config":[`)
+	for i, c := range payload.Config {
+		w.comma(i)
+		buf.WriteString(`{"file":`)
+		w.string(c.File)
+		buf.WriteString(`,"status":`)
+		w.string(c.Status)
+		buf.WriteString(`,"errors":[`)
+		for j, e := range c.Errors {
+			w.comma(j)
+			buf.WriteString(`{"error":`)
+			w.error(e.Error)
+			buf.WriteString(`,"line":`)
+			w.line(e.Line)
+			buf.WriteByte('}')
+		}
+		buf.WriteString(`],"parsed":`)
+		w.block(c.Parsed)
+		buf.WriteByte('}')
+	}
+	buf.WriteString(`]}`)
+
+	return buf.Bytes(), nil
+}
+
+type pythonJSONWriter struct {
+	buf *bytes.Buffer
+}
+
+func (w *pythonJSONWriter) comma(i int) {
+	if i > 0 {
+		w.buf.WriteByte(',')
+	}
+}
+
+// string writes s like Python's json module does with ensure_ascii, which is the default.
+func (w *pythonJSONWriter) string(s string) {
+	w.buf.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			w.buf.WriteString(`\"`)
+		case '\\':
+			w.buf.WriteString(`\\`)
+		case '\b':
+			w.buf.WriteString(`\b`)
+		case '\f':
+			w.buf.WriteString(`\f`)
+		case '\n':
+			w.buf.WriteString(`\n`)
+		case '\r':
+			w.buf.WriteString(`\r`)
+		case '\t':
+			w.buf.WriteString(`\t`)
+		default:
+			switch {
+			case r >= ' ' && r <= '~':
+				w.buf.WriteRune(r)
+			case r > 0xffff:
+				r1, r2 := utf16Surrogates(r)
+				fmt.Fprintf(w.buf, `\u%04x\u%04x`, r1, r2)
+			default:
+				fmt.Fprintf(w.buf, `\u%04x`, r)
+			}
+		}
+	}
+	w.buf.WriteByte('"')
+}
+
+func utf16Surrogates(r rune) (rune, rune) {
+	r -= 0x10000
+	return 0xd800 + (r>>10)&0x3ff, 0xdc00 + r&0x3ff
+}
+
+func (w *pythonJSONWriter) line(line *int) {
+	if line == nil {
+		w.buf.WriteString("null")
+		return
+	}
+	w.buf.WriteString(strconv.Itoa(*line))
+}
+
+func (w *pythonJSONWriter) error(err error) {
+	if err == nil {
+		w.buf.WriteString("null")
+		return
+	}
+	w.string(pythonError(err))
+}
+
+func (w *pythonJSONWriter) block(block Directives) {
+	w.buf.WriteByte('[')
+	for i, stmt := range block {
+		w.comma(i)
+		w.buf.WriteByte('{')
+		if stmt.File != "" {
+			w.buf.WriteString(`"file":`)
+			w.string(stmt.File)
+			w.buf.WriteByte(',')
+		}
+		w.buf.WriteString(`"directive":`)
+		w.string(stmt.Directive)
+		w.buf.WriteString(`,"line":`)
+		w.buf.WriteString(strconv.Itoa(stmt.Line))
+		w.buf.WriteString(`,"args":[`)
+		for j, arg := range stmt.Args {
+			w.comma(j)
+			w.string(arg)
+		}
+		w.buf.WriteByte(']')
+		if stmt.Includes != nil {
+			w.buf.WriteString(`,"includes":[`)
+			for j, idx := range stmt.Includes {
+				w.comma(j)
+				w.buf.WriteString(strconv.Itoa(idx))
+			}
+			w.buf.WriteByte(']')
+		}
+		if stmt.Block != nil {
+			w.buf.WriteString(`,"block":`)
+			w.block(stmt.Block)
+		}
+		if stmt.Comment != nil {
+			w.buf.WriteString(`,"comment":`)
+			w.string(*stmt.Comment)
+		}
+		w.buf.WriteByte('}')
+	}
+	w.buf.WriteByte(']')
+}
+
+// value writes the result of an error callback. It goes through its Go JSON encoding,
+// so the keys of maps are sorted where Python would keep their insertion order.
+func (w *pythonJSONWriter) value(v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+
+	// a comma is needed before every value in an array and every key in an object
+	// except the first, and a colon after every key
+	type level struct {
+		object bool
+		n      int
+	}
+	var stack []level
+	for {
+		tok, err := dec.Token()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if d, ok := tok.(json.Delim); ok && (d == '}' || d == ']') {
+			stack = stack[:len(stack)-1]
+			w.buf.WriteRune(rune(d))
+			continue
+		}
+		if len(stack) > 0 {
+			top := &stack[len(stack)-1]
+			switch {
+			case top.object && top.n%2 == 1:
+				w.buf.WriteByte(':')
+			case top.n > 0:
+				w.buf.WriteByte(',')
+			}
+			top.n++
+		}
+
+		switch tok := tok.(type) {
+		case json.Delim:
+			w.buf.WriteRune(rune(tok))
+			stack = append(stack, level{object: tok == '{'})
+		case string:
+			w.string(tok)
+		case json.Number:
+			w.buf.WriteString(tok.String())
+		case bool:
+			w.buf.WriteString(strconv.FormatBool(tok))
+		case nil:
+			w.buf.WriteString("null")
+		}
+	}
+}
@@ -0,0 +1,256 @@
+/**
+ * Copyright (c) F5, Inc.
+ *
+ * This source code is licensed under the Apache License, Version 2.0 license found in the
+ * LICENSE file in the root directory of this source tree.
+ */
+
+package crossplane
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// The conformance corpus is made of the payloads Python crossplane wrote for the configs
+// in testdata/configs. The ones written by testdata/configs/master.sh are the exact
+// output of `crossplane parse`, for config files found under a "python" directory.
+//
+//nolint:gochecknoglobals
+var pythonExactCorpus = []string{
+	"includes-globbed/http.json",
+	"includes-globbed/locations/location1.json",
+	"includes-globbed/locations/location2.json",
+	"includes-globbed/servers/server1.json",
+	"includes-globbed/servers/server2.json",
+	"missing-semicolon/broken-above.json",
+	"missing-semicolon/broken-below.json",
+}
+
+// The nginx.json files were written by `crossplane parse --include-comments` and then
+// pretty printed, so only their keys and values are compared. The ones for
+// comments-between-args, directive-with-space, includes-regular, lua-basic,
+// lua-block-larger, messy and with-comments are left out: they are older than the
+// nginx.conf next to them.
+//
+//nolint:gochecknoglobals
+var pythonCorpus = []string{
+	"bad-args",
+	"empty-value-map",
+	"geo",
+	"includes-globbed",
+	"lua-block-simple",
+	"lua-block-tricky",
+	"quote-behavior",
+	"quoted-right-brace",
+	"returns",
+	"russian-text",
+	"simple",
+	"spelling-mistake",
+}
+
+func pythonParseOptions() ParseOptions {
+	return ParseOptions{
+		PythonCompat: true,
+		LexOptions: LexOptions{
+			Lexers: []RegisterLexer{lua.RegisterLexer()},
+		},
+	}
+}
+
+func TestParse_pythonCompatExact(t *testing.T) {
+	t.Parallel()
+	// master.sh parsed copies of the configs under a "python" directory
+	fromPython := func(path string) string {
+		return getTestConfigPath(strings.TrimPrefix(path, "python/"))
+	}
+	toPython := func(path string) string {
+		return "python/" + strings.TrimPrefix(path, getTestConfigPath()+string(filepath.Separator))
+	}
+
+	for _, name := range pythonExactCorpus {
+		name := name
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			expected, err := os.ReadFile(getTestConfigPath(name))
+			require.NoError(t, err)
+
+			options := pythonParseOptions()
+			options.Open = func(path string) (io.ReadCloser, error) {
+				return os.Open(fromPython(path))
+			}
+			options.Glob = func(pattern string) ([]string, error) {
+				paths, err := filepath.Glob(fromPython(pattern))
+				for i := range paths {
+					paths[i] = toPython(paths[i])
+				}
+				return paths, err
+			}
+			payload, err := Parse("python/"+strings.TrimSuffix(name, ".json")+".conf", &options)
+			require.NoError(t, err)
+
+			b, err := MarshalPythonJSON(payload)
+			require.NoError(t, err)
+			// the command ends the output with a newline
+			require.Equal(t, string(expected), string(b)+"\n")
+		})
+	}
+}
+
+func TestParse_pythonCompat(t *testing.T) {
+	t.Parallel()
+	for _, name := range pythonCorpus {
+		name := name
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			expected, err := os.ReadFile(getTestConfigPath(name, "nginx.json"))
+			require.NoError(t, err)
+
+			options := pythonParseOptions()
+			options.ParseComments = true
+			payload, err := Parse(getTestConfigPath(name, "nginx.conf"), &options)
+			require.NoError(t, err)
+			b, err := MarshalPythonJSON(payload)
+			require.NoError(t, err)
+
+			require.Equal(t, jsonTokens(t, expected), jsonTokens(t, b))
+		})
+	}
+}
+
+// jsonTokens returns the tokens of a JSON document, which keep the order of object keys.
+func jsonTokens(t *testing.T, b []byte) []string {
+	t.Helper()
+	var tokens []string
+	dec := json.NewDecoder(bytes.NewReader(b))
+	for {
+		tok, err := dec.Token()
+		if errors.Is(err, io.EOF) {
+			return tokens
+		}
+		require.NoError(t, err)
+		v, err := json.Marshal(tok)
+		require.NoError(t, err)
+		tokens = append(tokens, string(v))
+	}
+}
+
+func TestParse_pythonCompatIOError(t *testing.T) {
+	t.Parallel()
+	options := pythonParseOptions()
+	payload, err := Parse(getTestConfigPath("includes-regular", "nginx.conf"), &options)
+	require.NoError(t, err)
+	b, err := MarshalPythonJSON(payload)
+	require.NoError(t, err)
+
+	conf := getTestConfigPath("includes-regular", "conf.d", "server.conf")
+	missing := getTestConfigPath("includes-regular", "bar.conf")
+	expected := `{"file":"` + conf + `","error":"[Errno 2] No such file or directory: '` + missing + `'","line":5}`
+	require.Contains(t, string(b), `"errors":[`+expected+`]`)
+}
+
+func TestPythonEnquote(t *testing.T) {
+	t.Parallel()
+	tcs := []struct {
+		arg      string
+		expected string
+	}{
+		{"simple", "simple"},
+		{"", `''`},
+		{"two words", `'two words'`},
+		{`it's`, `"it's"`},
+		{`say "hi"`, `'say "hi"'`},
+		{`'both" quotes`, `'\'both" quotes'`},
+		{"tab\there", `'tab\there'`},
+		{"bell\a ring", `'bell\x07 ring'`},
+		{"русский текст", `'русский текст'`},
+		{"no\u00a0break", `'no\xa0break'`},
+		{"zero\u200bwidth space", `'zero\u200bwidth space'`},
+		{`${var`, `'${var'`},
+		{`trailing\`, `'trailing\'`},
+	}
+	for _, tc := range tcs {
+		require.Equal(t, tc.expected, pythonEnquote(tc.arg), "arg %q", tc.arg)
+	}
+}
+
+func TestBuild_pythonCompat(t *testing.T) {
+	t.Parallel()
+	tcs := map[string]struct {
+		parsed   Directives
+		options  BuildOptions
+		expected string
+	}{
+		"quote-behavior": {
+			parsed: Directives{
+				{Directive: "outer-quote", Line: 1, Args: []string{"left", "-quote", `right-"quote"`, `inner"-"quote`}},
+				{Directive: "", Line: 2, Args: []string{"", "left-empty", `right-empty""`, `inner""empty`, `right-empty-single"`}},
+			},
+			expected: `outer-quote left -quote 'right-"quote"' 'inner"-"quote';` + "\n" +
+				`'' '' left-empty 'right-empty""' 'inner""empty' 'right-empty-single"';`,
+		},
+		"if": {
+			parsed: Directives{
+				{Directive: "if", Line: 1, Args: []string{"$request_method", "=", "a b"}, Block: Directives{
+					{Directive: "return", Line: 2, Args: []string{"403"}},
+				}},
+			},
+			expected: "if ($request_method = 'a b') {\n    return 403;\n}",
+		},
+		"comment after external builder": {
+			parsed: Directives{
+				{Directive: "content_by_lua_block", Line: 1, Args: []string{" ngx.say('hi') "}},
+				{Directive: "#", Line: 1, Args: []string{}, Comment: pStr(" say hi")},
+			},
+			options:  BuildOptions{Builders: []RegisterBuilder{lua.RegisterBuilder()}},
+			expected: "content_by_lua_block { ngx.say('hi') } # say hi",
+		},
+	}
+
+	for name, tc := range tcs {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			var buf bytes.Buffer
+			tc.options.PythonCompat = true
+			require.NoError(t, Build(&buf, Config{Parsed: tc.parsed}, &tc.options))
+			require.Equal(t, tc.expected, buf.String())
+		})
+	}
+}
+
+func TestMarshalPythonJSON(t *testing.T) {
+	t.Parallel()
+	payload := &Payload{
+		Status: "failed",
+		Errors: []PayloadError{{File: "nginx.conf", Error: ErrPrematureLexEnd, Callback: map[string]interface{}{"n": 1}}},
+		Config: []Config{{
+			File:   "nginx.conf",
+			Status: "failed",
+			Errors: []ConfigError{{Error: ErrPrematureLexEnd}},
+			Parsed: Directives{
+				{Directive: "env", Line: 1, Args: []string{"text=\u00f1 \U0001f600 <\x01>"}},
+				{Directive: "events", Line: 2, Args: []string{}, Block: Directives{}},
+			},
+		}},
+	}
+	b, err := MarshalPythonJSON(payload)
+	require.NoError(t, err)
+	require.Equal(t, `{"status":"failed","errors":[{"file":"nginx.conf","error":"","line":null,"callback":{"n":1}}],`+
+		`"config":[{"file":"nginx.conf","status":"failed","errors":[{"error":"","line":null}],"parsed":[`+
+		`{"directive":"env","line":1,"args":["text=\u00f1 \ud83d\ude00 <\u0001>"]},`+
+		`{"directive":"events","line":2,"args":[],"block":[]}]}]}`, string(b))
+
+	// the payload is written like Go does without MarshalPythonJSON
+	b, err = json.Marshal(payload)
+	require.NoError(t, err)
+	require.NotContains(t, string(b), `"block":[]`)
+}
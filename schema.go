@@ -0,0 +1,316 @@
+/**
+ * Copyright (c) F5, Inc.
+ *
+ * This source code is licensed under the Apache License, Version 2.0 license found in the
+ * LICENSE file in the root directory of this source tree.
+ */
+
+package crossplane
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// The JSON Schema of the Payload format is generated from the Go types by reflection.
+// Struct fields are described by their `json` tags and can be annotated with a
+// `jsonschema` tag holding a comma separated list of:
+//   - required: the field must be present.
+//   - type=a|b: the JSON types of the field, overriding the reflected ones.
+//
+// The generated schema is shipped as payload.schema.json in the repository root.
+
+const payloadSchemaID = "https://github.com/nginxinc/nginx-go-crossplane/payload.schema.json"
+
+//nolint:gochecknoglobals
+var (
+	// types with a custom JSON encoding are described by the types they are encoded with.
+	schemaShadowTypes = map[reflect.Type]reflect.Type{
+		reflect.TypeOf(PayloadError{}): reflect.TypeOf(payloadErrorJSON{}),
+		reflect.TypeOf(ConfigError{}):  reflect.TypeOf(configErrorJSON{}),
+	}
+	schemaTypeNames = map[reflect.Type]string{
+		reflect.TypeOf(ParseErrorDetail{}): "ParseError",
+	}
+
+	payloadSchemaOnce   sync.Once
+	payloadSchema       map[string]interface{}
+	payloadSchemaJSON   []byte
+	errPayloadSchemaGen error
+)
+
+// PayloadSchema returns the JSON Schema (draft 2020-12) describing the JSON encoding of a Payload.
+func PayloadSchema() ([]byte, error) {
+	payloadSchemaOnce.Do(func() {
+		payloadSchema = generateSchema(reflect.TypeOf(Payload{}))
+		var buf bytes.Buffer
+		enc := json.NewEncoder(&buf)
+		enc.SetEscapeHTML(false)
+		enc.SetIndent("", "  ")
+		errPayloadSchemaGen = enc.Encode(payloadSchema)
+		payloadSchemaJSON = buf.Bytes()
+	})
+	if errPayloadSchemaGen != nil {
+		return nil, errPayloadSchemaGen
+	}
+	return append([]byte(nil), payloadSchemaJSON...), nil
+}
+
+type schemaGenerator struct {
+	defs map[string]interface{}
+}
+
+func generateSchema(t reflect.Type) map[string]interface{} {
+	g := &schemaGenerator{defs: map[string]interface{}{}}
+	root := g.typeSchema(t)
+	root["$schema"] = "https://json-schema.org/draft/2020-12/schema"
+	root["$id"] = payloadSchemaID
+	root["$defs"] = g.defs
+	return root
+}
+
+//nolint:exhaustive
+func (g *schemaGenerator) typeSchema(t reflect.Type) map[string]interface{} {
+	switch t.Kind() {
+	case reflect.Ptr:
+		if t.Elem().Kind() == reflect.Struct {
+			// a null struct can't be used in place of a Directive or error, so don't allow it
+			return g.structRef(t.Elem())
+		}
+		return nullable(g.typeSchema(t.Elem()))
+	case reflect.Slice:
+		return map[string]interface{}{
+			"type":  []string{"array", "null"},
+			"items": g.typeSchema(t.Elem()),
+		}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Struct:
+		return g.structRef(t)
+	default:
+		// interfaces and anything else can hold any JSON value
+		return map[string]interface{}{}
+	}
+}
+
+// structRef adds the definition of a struct to the $defs of the schema and returns a reference to it.
+func (g *schemaGenerator) structRef(t reflect.Type) map[string]interface{} {
+	name := t.Name()
+	if n, ok := schemaTypeNames[t]; ok {
+		name = n
+	}
+	if shadow, ok := schemaShadowTypes[t]; ok {
+		t = shadow
+	}
+	ref := map[string]interface{}{"$ref": "#/$defs/" + name}
+	if _, ok := g.defs[name]; ok {
+		return ref
+	}
+
+	// reserve the name before descending so recursive types terminate
+	g.defs[name] = nil
+
+	properties := map[string]interface{}{}
+	required := []string{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		key, omitempty := jsonFieldName(f)
+		if key == "" {
+			continue
+		}
+
+		s := g.typeSchema(f.Type)
+		if omitempty && f.Type.Kind() == reflect.Slice {
+			// empty slices are omitted, so a present value is never null
+			s["type"] = "array"
+		}
+		for _, opt := range strings.Split(f.Tag.Get("jsonschema"), ",") {
+			switch {
+			case opt == "required":
+				required = append(required, key)
+			case strings.HasPrefix(opt, "type="):
+				s = map[string]interface{}{"type": strings.Split(strings.TrimPrefix(opt, "type="), "|")}
+			}
+		}
+		properties[key] = s
+	}
+
+	def := map[string]interface{}{
+		"title":      name,
+		"type":       "object",
+		"properties": properties,
+	}
+	if len(required) > 0 {
+		sort.Strings(required)
+		def["required"] = required
+	}
+	g.defs[name] = def
+	return ref
+}
+
+// jsonFieldName returns the key of a struct field in its JSON encoding, or "" if the field isn't encoded.
+func jsonFieldName(f reflect.StructField) (name string, omitempty bool) { //nolint:nonamedreturns
+	if f.PkgPath != "" {
+		return "", false
+	}
+	tag := f.Tag.Get("json")
+	if tag == "-" {
+		return "", false
+	}
+	name, opts, _ := strings.Cut(tag, ",")
+	if name == "" {
+		name = f.Name
+	}
+	return name, strings.Contains(opts, "omitempty")
+}
+
+func nullable(s map[string]interface{}) map[string]interface{} {
+	switch t := s["type"].(type) {
+	case string:
+		s["type"] = []string{t, "null"}
+	case []string:
+		if !contains(t, "null") {
+			s["type"] = append(t, "null")
+		}
+	}
+	return s
+}
+
+// SchemaError describes a value in a JSON payload that doesn't match the Payload schema.
+type SchemaError struct {
+	// Path is the location of the value in the payload, e.g. "config[0].parsed[2].block".
+	Path string
+	What string
+}
+
+func (e *SchemaError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Path, e.What)
+}
+
+// ValidationError holds all of the SchemaErrors found by Validate.
+type ValidationError struct {
+	Errors []*SchemaError
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, 0, len(e.Errors))
+	for _, err := range e.Errors {
+		msgs = append(msgs, err.Error())
+	}
+	return "invalid payload: " + strings.Join(msgs, "; ")
+}
+
+// Validate checks that data is a JSON encoded Payload matching the schema returned by
+// PayloadSchema. It is meant to be used on payloads that come from outside of this
+// package before passing them to Build. If data doesn't match the schema, the returned
+// error is a *ValidationError.
+func Validate(data []byte) error {
+	if _, err := PayloadSchema(); err != nil {
+		return err
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return err
+	}
+	if dec.More() {
+		return &ValidationError{Errors: []*SchemaError{{Path: "payload", What: "unexpected data after payload"}}}
+	}
+
+	var errs []*SchemaError
+	validateValue(payloadSchema, v, "payload", &errs)
+	if len(errs) > 0 {
+		return &ValidationError{Errors: errs}
+	}
+	return nil
+}
+
+func validateValue(s map[string]interface{}, v interface{}, path string, errs *[]*SchemaError) {
+	if ref, ok := s["$ref"].(string); ok {
+		s, _ = payloadSchema["$defs"].(map[string]interface{})[strings.TrimPrefix(ref, "#/$defs/")].(map[string]interface{})
+	}
+
+	if types := schemaTypes(s["type"]); len(types) > 0 {
+		got := jsonType(v)
+		if !contains(types, got) && !(got == "integer" && contains(types, "number")) {
+			*errs = append(*errs, &SchemaError{
+				Path: path,
+				What: fmt.Sprintf("expected %s, got %s", strings.Join(types, " or "), got),
+			})
+			return
+		}
+	}
+
+	switch val := v.(type) {
+	case map[string]interface{}:
+		required, _ := s["required"].([]string)
+		for _, key := range required {
+			if _, ok := val[key]; !ok {
+				*errs = append(*errs, &SchemaError{Path: path, What: fmt.Sprintf("missing required field %q", key)})
+			}
+		}
+		properties, _ := s["properties"].(map[string]interface{})
+		keys := make([]string, 0, len(val))
+		for key := range val {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if ps, ok := properties[key].(map[string]interface{}); ok {
+				validateValue(ps, val[key], path+"."+key, errs)
+			}
+		}
+	case []interface{}:
+		if items, ok := s["items"].(map[string]interface{}); ok {
+			for i, item := range val {
+				validateValue(items, item, fmt.Sprintf("%s[%d]", path, i), errs)
+			}
+		}
+	}
+}
+
+func schemaTypes(t interface{}) []string {
+	switch t := t.(type) {
+	case string:
+		return []string{t}
+	case []string:
+		return t
+	}
+	return nil
+}
+
+// jsonType returns the JSON Schema type name of a value decoded with json.Decoder.UseNumber.
+func jsonType(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case json.Number:
+		if _, err := v.Int64(); err == nil {
+			return "integer"
+		}
+		return "number"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return fmt.Sprintf("%T", v)
+}
@@ -0,0 +1,120 @@
+/**
+ * Copyright (c) F5, Inc.
+ *
+ * This source code is licensed under the Apache License, Version 2.0 license found in the
+ * LICENSE file in the root directory of this source tree.
+ */
+
+package crossplane
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+//nolint:gochecknoglobals
+var updateSchema = flag.Bool("update-schema", false, "update payload.schema.json from the Go types")
+
+func TestPayloadSchema_upToDate(t *testing.T) {
+	t.Parallel()
+	schema, err := PayloadSchema()
+	require.NoError(t, err)
+
+	if *updateSchema {
+		require.NoError(t, os.WriteFile("payload.schema.json", schema, 0o644)) //nolint:gosec
+		return
+	}
+
+	shipped, err := os.ReadFile("payload.schema.json")
+	require.NoError(t, err)
+	require.Equal(t, string(shipped), string(schema), "payload.schema.json is outdated, run go test -run TestPayloadSchema -update-schema")
+}
+
+// The JSON of parsed payloads is valid, and is read back the same.
+func TestValidate_parsedPayloads(t *testing.T) {
+	t.Parallel()
+	testRoundTrip(t, payloadCodec{
+		encode: func(w io.Writer, payload *Payload) error {
+			return json.NewEncoder(w).Encode(payload)
+		},
+		decode: func(r io.Reader) (*Payload, error) {
+			b, err := io.ReadAll(r)
+			if err != nil {
+				return nil, err
+			}
+			if err = Validate(b); err != nil {
+				return nil, err
+			}
+			var payload Payload
+			return &payload, json.Unmarshal(b, &payload)
+		},
+	})
+}
+
+func TestValidate(t *testing.T) {
+	t.Parallel()
+	tcs := map[string]struct {
+		payload  string
+		expected []string
+	}{
+		"minimal": {
+			payload: `{"config": [{"file": "nginx.conf", "parsed": [{"directive": "user", "args": ["nginx"]}]}]}`,
+		},
+		"legacy error object": {
+			payload: `{"config": [{"file": "nginx.conf", "errors": [{"line": null, "error": {}}], "parsed": []}], "errors": [{"file": "nginx.conf", "line": null, "error": {}}]}`,
+		},
+		"non-array block": {
+			payload:  `{"config": [{"file": "nginx.conf", "parsed": [{"directive": "http", "args": [], "block": {"directive": "server"}}]}]}`,
+			expected: []string{"payload.config[0].parsed[0].block: expected array, got object"},
+		},
+		"missing fields": {
+			payload: `{"config": [{"parsed": [{"args": []}]}]}`,
+			expected: []string{
+				`payload.config[0]: missing required field "file"`,
+				`payload.config[0].parsed[0]: missing required field "directive"`,
+			},
+		},
+		"wrong scalar types": {
+			payload: `{"config": [{"file": "nginx.conf", "parsed": [{"directive": "listen", "line": "1", "args": [80]}]}]}`,
+			expected: []string{
+				"payload.config[0].parsed[0].args[0]: expected string, got integer",
+				"payload.config[0].parsed[0].line: expected integer, got string",
+			},
+		},
+		"null directive": {
+			payload:  `{"config": [{"file": "nginx.conf", "parsed": [null]}]}`,
+			expected: []string{"payload.config[0].parsed[0]: expected object, got null"},
+		},
+		"not an object": {
+			payload:  `[]`,
+			expected: []string{"payload: expected object, got array"},
+		},
+	}
+
+	for name, tc := range tcs {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			err := Validate([]byte(tc.payload))
+			if len(tc.expected) == 0 {
+				require.NoError(t, err)
+				return
+			}
+
+			var verr *ValidationError
+			require.True(t, errors.As(err, &verr), "expected *ValidationError, got %v", err)
+			got := make([]string, 0, len(verr.Errors))
+			for _, e := range verr.Errors {
+				got = append(got, e.Error())
+			}
+			assert.Equal(t, tc.expected, got)
+		})
+	}
+}
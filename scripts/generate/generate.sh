@@ -13,6 +13,7 @@ set -e
 branch="master"
 url=""
 sub_path=""
+docs=false
 genArgs=()
 
 help() {
@@ -23,7 +24,7 @@ If the command line is long, we recommend using json config(--config-path).
 If you don't provide --path, we will use the whole repository at provided url by default.
 
 usage: $(basename "$0") [-b|--branch] [-c|--config-path] [-d|--directive-map-name]
- [-mn|--match-func-name] [-f|--filter] [-o | --override] [-mc|--match-func-comment] [-p|--path] [--url] [-h|--help]
+ [-mn|--match-func-name] [-f|--filter] [-o | --override] [-mc|--match-func-comment] [-p|--path] [--docs] [--url] [-h|--help]
     -h  | --help                Display this message
 
     -b  | --branch              Branch to checkout, defaults to "$branch". (optional)
@@ -31,6 +32,7 @@ usage: $(basename "$0") [-b|--branch] [-c|--config-path] [-d|--directive-map-nam
     -c  | --config-path         The path of json config file. Normally the json config file should be under nginx-go-crossplane/scripts/generate/configs.
      The json config will be unmarshed into generator.GenerateConfig(at nginx-go-crossplane/internal/generator/generator.go).
      The file can contain directiveMapName, matchFuncName, matchFuncComment, filter, and override.
+     It can also contain mapBodies and mapBodiesFuncName to generate the map-like block directives of the module.
      They provide same functions as other arguments directive-map-name, match-func-name, match-func-comment, filter, and override. (optional)
 
     -d  | --directive-map-name  Name of the generated directive map. You should provide it here or through json config(--config-path).
@@ -56,6 +58,9 @@ usage: $(basename "$0") [-b|--branch] [-c|--config-path] [-d|--directive-map-nam
     
     -p  | --path                Path to a directory in the repository containing the source code of the nginx module. (optional)
     
+    --docs                      Generate the documentation of directives from the XML sources of the nginx documentation
+     in the repository instead of support. Only the directive map name and filter are used. (optional)
+
     --url                       Url used for git clone. (required)
 EOF
 }
@@ -98,6 +103,9 @@ while [ ! $# -eq 0 ]; do
             sub_path="$2"
             shift
             ;;
+        --docs)
+            docs=true
+            ;;
         --url)
             url="$2"
             shift
@@ -122,7 +130,11 @@ cleanup() {
 }
 trap cleanup EXIT
 
-genArgs+=("--src-path=$tmp")
+if [ "$docs" = true ]; then
+    genArgs+=("--docs-path=$tmp/$sub_path")
+else
+    genArgs+=("--src-path=$tmp")
+fi
 
 if [ "$sub_path" = "" ]; then
     git clone "$url" "$tmp" --depth 1 --branch "$branch" -q 1>&2
@@ -0,0 +1,419 @@
+/**
+ * Copyright (c) F5, Inc.
+ *
+ * This source code is licensed under the Apache License, Version 2.0 license found in the
+ * LICENSE file in the root directory of this source tree.
+ */
+
+package crossplane
+
+import (
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// loadModules are the modules loaded by the files of load_module directives,
+// without their extension.
+//
+//nolint:gochecknoglobals
+var loadModules = map[string]string{
+	"ngx_http_js_module":                  ModuleNjs,
+	"ngx_stream_js_module":                ModuleNjs,
+	"ngx_http_lua_module":                 ModuleLua,
+	"ngx_otel_module":                     ModuleOtel,
+	"ngx_http_geoip2_module":              ModuleGeoip2,
+	"ngx_stream_geoip2_module":            ModuleGeoip2,
+	"ngx_http_headers_more_filter_module": ModuleHeadersMore,
+	"ngx_http_app_protect_module":         ModuleAppProtect,
+}
+
+// addModules are the modules added by the --add-module arguments of configure,
+// by a part of the path of their source.
+//
+//nolint:gochecknoglobals
+var addModules = []struct {
+	part   string
+	module string
+}{
+	{"njs", ModuleNjs},
+	{"lua-nginx-module", ModuleLua},
+	{"nginx-otel", ModuleOtel},
+	{"geoip2", ModuleGeoip2},
+	{"headers-more", ModuleHeadersMore},
+	{"app-protect", ModuleAppProtect},
+	{"app_protect", ModuleAppProtect},
+}
+
+const (
+	httpContexts = ngxHTTPMainConf | ngxHTTPSrvConf | ngxHTTPLocConf | ngxHTTPUpsConf |
+		ngxHTTPSifConf | ngxHTTPLifConf | ngxHTTPLmtConf | ngxHTTPOIDCConf
+	streamContexts = ngxStreamMainConf | ngxStreamSrvConf | ngxStreamUpsConf
+	mailContexts   = ngxMailMainConf | ngxMailSrvConf
+)
+
+// buildModule is a module of nginx that the --with-* and --without-* arguments of
+// configure add or remove.
+type buildModule struct {
+	// name is the name in the arguments, e.g. "http_ssl_module" for --with-http_ssl_module.
+	name string
+	// builtin is set if the module is built unless --without-<name> is given.
+	builtin bool
+	// file is the file of the module when it's built with --with-<name>=dynamic.
+	file string
+	// contexts are the contexts of the directives of the module. Directives that
+	// other modules have in other contexts are kept.
+	contexts uint
+	// directives are the directives of the module, or the prefixes of their names
+	// if they end with "*". All the directives in contexts are if it's empty.
+	directives []string
+}
+
+// buildModules are the modules of nginx that the arguments of configure select.
+//
+//nolint:gochecknoglobals
+var buildModules = []buildModule{
+	{name: "http", builtin: true, contexts: httpContexts},
+	{name: "http", builtin: true, contexts: ngxMainConf, directives: []string{"http"}},
+	{name: "http_access_module", builtin: true, contexts: httpContexts, directives: []string{"allow", "deny"}},
+	{name: "http_auth_basic_module", builtin: true, contexts: httpContexts, directives: []string{"auth_basic*"}},
+	{name: "http_autoindex_module", builtin: true, contexts: httpContexts, directives: []string{"autoindex*"}},
+	{name: "http_browser_module", builtin: true, contexts: httpContexts, directives: []string{"ancient_browser*", "modern_browser*"}},
+	{name: "http_charset_module", builtin: true, contexts: httpContexts, directives: []string{
+		"charset", "charset_map", "charset_types", "override_charset", "source_charset",
+	}},
+	{name: "http_empty_gif_module", builtin: true, contexts: httpContexts, directives: []string{"empty_gif"}},
+	{name: "http_fastcgi_module", builtin: true, contexts: httpContexts, directives: []string{"fastcgi_*"}},
+	{name: "http_geo_module", builtin: true, contexts: httpContexts, directives: []string{"geo"}},
+	{name: "http_grpc_module", builtin: true, contexts: httpContexts, directives: []string{"grpc_*"}},
+	{name: "http_gzip_module", builtin: true, contexts: httpContexts, directives: []string{
+		"gzip", "gzip_buffers", "gzip_comp_level", "gzip_disable", "gzip_http_version", "gzip_min_length",
+		"gzip_proxied", "gzip_types", "gzip_vary",
+	}},
+	{name: "http_limit_conn_module", builtin: true, contexts: httpContexts, directives: []string{"limit_conn*"}},
+	{name: "http_limit_req_module", builtin: true, contexts: httpContexts, directives: []string{"limit_req*"}},
+	{name: "http_map_module", builtin: true, contexts: httpContexts, directives: []string{"map", "map_hash_*"}},
+	{name: "http_memcached_module", builtin: true, contexts: httpContexts, directives: []string{"memcached_*"}},
+	{name: "http_mirror_module", builtin: true, contexts: httpContexts, directives: []string{"mirror*"}},
+	{name: "http_proxy_module", builtin: true, contexts: httpContexts, directives: []string{"proxy_*"}},
+	{name: "http_referer_module", builtin: true, contexts: httpContexts, directives: []string{"valid_referers", "referer_hash_*"}},
+	{name: "http_rewrite_module", builtin: true, contexts: httpContexts, directives: []string{
+		"break", "if", "return", "rewrite", "rewrite_log", "set", "uninitialized_variable_warn",
+	}},
+	{name: "http_scgi_module", builtin: true, contexts: httpContexts, directives: []string{"scgi_*"}},
+	{name: "http_split_clients_module", builtin: true, contexts: httpContexts, directives: []string{"split_clients"}},
+	{name: "http_ssi_module", builtin: true, contexts: httpContexts, directives: []string{"ssi", "ssi_*"}},
+	{name: "http_upstream_hash_module", builtin: true, contexts: ngxHTTPUpsConf, directives: []string{"hash"}},
+	{name: "http_upstream_ip_hash_module", builtin: true, contexts: ngxHTTPUpsConf, directives: []string{"ip_hash"}},
+	{name: "http_upstream_keepalive_module", builtin: true, contexts: ngxHTTPUpsConf, directives: []string{"keepalive*"}},
+	{name: "http_upstream_least_conn_module", builtin: true, contexts: ngxHTTPUpsConf, directives: []string{"least_conn"}},
+	{name: "http_upstream_random_module", builtin: true, contexts: ngxHTTPUpsConf, directives: []string{"random"}},
+	{name: "http_upstream_zone_module", builtin: true, contexts: ngxHTTPUpsConf, directives: []string{"zone"}},
+	{name: "http_userid_module", builtin: true, contexts: httpContexts, directives: []string{"userid*"}},
+	{name: "http_uwsgi_module", builtin: true, contexts: httpContexts, directives: []string{"uwsgi_*"}},
+
+	{name: "http_addition_module", contexts: httpContexts, directives: []string{"add_after_body", "add_before_body", "addition_types"}},
+	{name: "http_auth_request_module", contexts: httpContexts, directives: []string{"auth_request", "auth_request_set"}},
+	{name: "http_dav_module", contexts: httpContexts, directives: []string{"dav_*", "create_full_put_path", "min_delete_depth"}},
+	{name: "http_flv_module", contexts: httpContexts, directives: []string{"flv"}},
+	{name: "http_geoip_module", file: "ngx_http_geoip_module", contexts: httpContexts, directives: []string{"geoip_*"}},
+	{name: "http_gunzip_module", contexts: httpContexts, directives: []string{"gunzip*"}},
+	{name: "http_gzip_static_module", contexts: httpContexts, directives: []string{"gzip_static"}},
+	{name: "http_image_filter_module", file: "ngx_http_image_filter_module", contexts: httpContexts, directives: []string{"image_filter*"}},
+	{name: "http_mp4_module", contexts: httpContexts, directives: []string{"mp4*"}},
+	{name: "http_perl_module", file: "ngx_http_perl_module", contexts: httpContexts, directives: []string{"perl*"}},
+	{name: "http_random_index_module", contexts: httpContexts, directives: []string{"random_index"}},
+	{name: "http_realip_module", contexts: httpContexts, directives: []string{"set_real_ip_from", "real_ip_*"}},
+	{name: "http_secure_link_module", contexts: httpContexts, directives: []string{"secure_link*"}},
+	{name: "http_slice_module", contexts: httpContexts, directives: []string{"slice"}},
+	{name: "http_ssl_module", contexts: httpContexts, directives: []string{"ssl", "ssl_*", "proxy_ssl*", "grpc_ssl*", "uwsgi_ssl*"}},
+	{name: "http_stub_status_module", contexts: httpContexts, directives: []string{"stub_status"}},
+	{name: "http_sub_module", contexts: httpContexts, directives: []string{"sub_filter*"}},
+	{name: "http_v2_module", contexts: httpContexts, directives: []string{"http2*"}},
+	{name: "http_v3_module", contexts: httpContexts, directives: []string{"http3*", "quic_*"}},
+	{name: "http_xslt_module", file: "ngx_http_xslt_filter_module", contexts: httpContexts, directives: []string{"xml_entities", "xslt_*"}},
+
+	{name: "mail", file: "ngx_mail_module", contexts: mailContexts},
+	{name: "mail", file: "ngx_mail_module", contexts: ngxMainConf, directives: []string{"mail"}},
+	{name: "mail_imap_module", builtin: true, contexts: mailContexts, directives: []string{"imap_*"}},
+	{name: "mail_pop3_module", builtin: true, contexts: mailContexts, directives: []string{"pop3_*"}},
+	{name: "mail_smtp_module", builtin: true, contexts: mailContexts, directives: []string{"smtp_*"}},
+	{name: "mail_ssl_module", contexts: mailContexts, directives: []string{"ssl_*", "starttls"}},
+
+	{name: "stream", file: "ngx_stream_module", contexts: streamContexts},
+	{name: "stream", file: "ngx_stream_module", contexts: ngxMainConf, directives: []string{"stream"}},
+	{name: "stream_access_module", builtin: true, contexts: streamContexts, directives: []string{"allow", "deny"}},
+	{name: "stream_geo_module", builtin: true, contexts: streamContexts, directives: []string{"geo"}},
+	{name: "stream_limit_conn_module", builtin: true, contexts: streamContexts, directives: []string{"limit_conn*"}},
+	{name: "stream_map_module", builtin: true, contexts: streamContexts, directives: []string{"map", "map_hash_*"}},
+	{name: "stream_pass_module", builtin: true, contexts: streamContexts, directives: []string{"pass"}},
+	{name: "stream_return_module", builtin: true, contexts: streamContexts, directives: []string{"return"}},
+	{name: "stream_set_module", builtin: true, contexts: streamContexts, directives: []string{"set"}},
+	{name: "stream_split_clients_module", builtin: true, contexts: streamContexts, directives: []string{"split_clients"}},
+	{name: "stream_upstream_hash_module", builtin: true, contexts: ngxStreamUpsConf, directives: []string{"hash"}},
+	{name: "stream_upstream_least_conn_module", builtin: true, contexts: ngxStreamUpsConf, directives: []string{"least_conn"}},
+	{name: "stream_upstream_random_module", builtin: true, contexts: ngxStreamUpsConf, directives: []string{"random"}},
+	{name: "stream_upstream_zone_module", builtin: true, contexts: ngxStreamUpsConf, directives: []string{"zone"}},
+	{name: "stream_geoip_module", file: "ngx_stream_geoip_module", contexts: streamContexts, directives: []string{"geoip_*"}},
+	{name: "stream_realip_module", contexts: streamContexts, directives: []string{"set_real_ip_from"}},
+	{name: "stream_ssl_module", contexts: streamContexts, directives: []string{
+		"ssl_alpn", "ssl_certificate", "ssl_certificate_cache", "ssl_certificate_compression", "ssl_certificate_key",
+		"ssl_ciphers", "ssl_client_certificate", "ssl_conf_command", "ssl_crl", "ssl_dhparam", "ssl_ecdh_curve",
+		"ssl_handshake_timeout", "ssl_key_log", "ssl_ocsp*", "ssl_password_file", "ssl_prefer_server_ciphers",
+		"ssl_protocols", "ssl_reject_handshake", "ssl_session_*", "ssl_stapling*", "ssl_trusted_certificate",
+		"ssl_verify_client", "ssl_verify_depth", "proxy_ssl*",
+	}},
+	{name: "stream_ssl_preread_module", contexts: streamContexts, directives: []string{"ssl_preread"}},
+
+	{name: "threads", contexts: ngxMainConf, directives: []string{"thread_pool"}},
+}
+
+// has returns whether the directive with the bitmask mask is one of those of m.
+func (m buildModule) has(directive string, mask uint) bool {
+	if mask&m.contexts == 0 {
+		return false
+	}
+	if len(m.directives) == 0 {
+		return true
+	}
+	for _, d := range m.directives {
+		if d == directive || strings.HasSuffix(d, "*") && strings.HasPrefix(directive, strings.TrimSuffix(d, "*")) {
+			return true
+		}
+	}
+	return false
+}
+
+//nolint:gochecknoglobals
+var (
+	plusReleaseRe  = regexp.MustCompile(`nginx-plus-r(\d+)`)
+	ossVersionRe   = regexp.MustCompile(`nginx version: nginx/1\.(\d+)\.`)
+	addModuleArgRe = regexp.MustCompile(`--add-module=("[^"]*"|'[^']*'|\S+)`)
+	configureRe    = regexp.MustCompile(`(?m)^configure arguments:(.*)$`)
+)
+
+// DirectiveSources returns the directive sources of the nginx described by
+// nginxV, the output of "nginx -V", with the dynamic modules loaded by the files
+// of load_module directives, e.g. "modules/ngx_http_js_module.so".
+//
+// The version of nginx or nginx plus is the newest one with bundled directives
+// that isn't newer than the one of nginxV, or the latest nginx plus if nginxV
+// doesn't name one. Its directives are limited to the modules of nginx that the
+// configure arguments of nginxV build: the ones built by default, unless they're
+// removed by a --without-* argument, and the ones added by --with-* arguments,
+// e.g. --with-http_ssl_module or --with-stream. A module built with =dynamic is
+// only added if a load_module directive loads it. If nginxV has no configure
+// arguments, like the output of "nginx -v", all of the modules are kept.
+//
+// Modules added with --add-module, like njs or lua, are added too. Modules that
+// have no bundled directives are ignored.
+func DirectiveSources(nginxV string, loadModuleFiles ...string) []MatchFunc {
+	modules := map[string]bool{}
+	for _, m := range addModuleArgRe.FindAllStringSubmatch(nginxV, -1) {
+		src := strings.ToLower(m[1])
+		for _, add := range addModules {
+			if strings.Contains(src, add.part) {
+				modules[add.module] = true
+				break
+			}
+		}
+	}
+	for _, file := range loadModuleFiles {
+		if module, ok := loadModules[strings.TrimSuffix(path.Base(file), ".so")]; ok {
+			modules[module] = true
+		}
+	}
+
+	sources := []MatchFunc{withoutModules(versionSource(nginxV), missingModules(nginxV, loadModuleFiles))}
+	for _, module := range compatModules {
+		if modules[module.name] {
+			sources = append(sources, module.matches...)
+		}
+	}
+	return sources
+}
+
+// missingModules returns the buildModules that the configure arguments of nginxV
+// don't build, or that it builds as dynamic modules that loadModuleFiles don't load.
+func missingModules(nginxV string, loadModuleFiles []string) []buildModule {
+	m := configureRe.FindStringSubmatch(nginxV)
+	if m == nil {
+		return nil
+	}
+	with := map[string]bool{}
+	without := map[string]bool{}
+	for _, arg := range strings.Fields(m[1]) {
+		switch {
+		case strings.HasPrefix(arg, "--without-"):
+			without[strings.TrimPrefix(arg, "--without-")] = true
+		case strings.HasPrefix(arg, "--with-") && !strings.Contains(arg, "="):
+			with[strings.TrimPrefix(arg, "--with-")] = true
+		}
+	}
+	// --with-<name>=dynamic builds a module that has to be loaded
+	loaded := map[string]bool{}
+	for _, file := range loadModuleFiles {
+		loaded[strings.TrimSuffix(path.Base(file), ".so")] = true
+	}
+
+	var missing []buildModule
+	for _, module := range buildModules {
+		built := module.builtin && !without[module.name] || with[module.name] || module.file != "" && loaded[module.file]
+		if !built {
+			missing = append(missing, module)
+		}
+	}
+	return missing
+}
+
+// withoutModules returns match without the directives of the missing modules.
+func withoutModules(match MatchFunc, missing []buildModule) MatchFunc {
+	if len(missing) == 0 {
+		return match
+	}
+	return func(directive string) ([]uint, bool) {
+		masks, ok := match(directive)
+		if !ok {
+			return nil, false
+		}
+		kept := make([]uint, 0, len(masks))
+		for _, mask := range masks {
+			if !hasDirective(missing, directive, mask) {
+				kept = append(kept, mask)
+			}
+		}
+		return kept, len(kept) > 0
+	}
+}
+
+func hasDirective(modules []buildModule, directive string, mask uint) bool {
+	for _, module := range modules {
+		if module.has(directive, mask) {
+			return true
+		}
+	}
+	return false
+}
+
+// versionSource returns the directive source of the version of nginx or nginx plus of nginxV.
+func versionSource(nginxV string) MatchFunc {
+	product, number := ProductPlus, -1
+	if m := plusReleaseRe.FindStringSubmatch(nginxV); m != nil {
+		number, _ = strconv.Atoi(m[1])
+	} else if m := ossVersionRe.FindStringSubmatch(nginxV); m != nil {
+		product = ProductOSS
+		number, _ = strconv.Atoi(m[1])
+	}
+	if number < 0 {
+		return MatchNginxPlusLatest
+	}
+
+	// the oldest version of the product is used for versions older than it
+	var match MatchFunc
+	for _, v := range compatVersions {
+		if v.product != product {
+			continue
+		}
+		if match == nil || v.version == "latest" || versionNumber(v) <= number {
+			match = v.match
+		}
+		if v.version != "latest" && versionNumber(v) >= number {
+			break
+		}
+	}
+	return match
+}
+
+// versionNumber returns the minor version of nginx, or the release of nginx plus, of v.
+func versionNumber(v compatVersion) int {
+	n, _ := strconv.Atoi(strings.TrimPrefix(strings.TrimPrefix(v.version, "R"), "1."))
+	return n
+}
+
+// autoDirectiveSources replaces the options of p with a copy whose DirectiveSources
+// are selected from the config if AutoDirectiveSources is set, and returns the
+// modules its load_module directives load.
+func (p *parser) autoDirectiveSources(filename string) []string {
+	if !p.options.AutoDirectiveSources {
+		return nil
+	}
+	files := p.loadModuleFiles(filename, map[string]bool{})
+	options := *p.options
+	options.DirectiveSources = DirectiveSources(options.NginxBuild, files...)
+	p.options = &options
+
+	var modules []string
+	for _, file := range files {
+		modules = append(modules, strings.TrimSuffix(path.Base(file), ".so"))
+	}
+	sort.Strings(modules)
+	return modules
+}
+
+// loadModuleFiles returns the arguments of the load_module directives of the main
+// context of the config file, and of the files it includes there. Errors are left
+// for the parse to report.
+func (p *parser) loadModuleFiles(filename string, seen map[string]bool) []string {
+	if seen[filename] {
+		return nil
+	}
+	seen[filename] = true
+	file, err := p.openFile(filename)
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+
+	var files []string
+	var stmt []string
+	depth := 0
+	tokens := NewTokenizer(file, p.options.LexOptions)
+	for t, ok := tokens.Next(); ok && t.Error == nil; t, ok = tokens.Next() {
+		switch {
+		case t.IsQuoted:
+			stmt = append(stmt, t.Value)
+		case strings.HasPrefix(t.Value, "#"):
+		case t.Value == "{":
+			depth++
+			stmt = nil
+		case t.Value == "}":
+			depth--
+			stmt = nil
+		case t.Value == ";":
+			if depth == 0 && len(stmt) == 2 {
+				files = append(files, p.mainContextFiles(stmt, seen)...)
+			}
+			stmt = nil
+		default:
+			stmt = append(stmt, t.Value)
+		}
+	}
+	return files
+}
+
+// mainContextFiles returns the module file of a load_module directive, or the
+// ones loaded by the files of an include directive.
+func (p *parser) mainContextFiles(stmt []string, seen map[string]bool) []string {
+	switch stmt[0] {
+	case "load_module":
+		return stmt[1:]
+	case "include":
+		if p.options.SingleFile {
+			return nil
+		}
+		pattern := stmt[1]
+		if !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(p.configDir, pattern)
+		}
+		fnames, _ := p.findIncludes(pattern)
+		var files []string
+		for _, fname := range fnames {
+			files = append(files, p.loadModuleFiles(fname, seen)...)
+		}
+		return files
+	}
+	return nil
+}
@@ -0,0 +1,258 @@
+/**
+ * Copyright (c) F5, Inc.
+ *
+ * This source code is licensed under the Apache License, Version 2.0 license found in the
+ * LICENSE file in the root directory of this source tree.
+ */
+
+package crossplane
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// the nginx -V of the nginx.org package of nginx 1.26.3 for Debian bookworm
+const nginxOrgV = `nginx version: nginx/1.26.3
+built by gcc 12.2.0 (Debian 12.2.0-14)
+built with OpenSSL 3.0.15 3 Sep 2024
+TLS SNI support enabled
+configure arguments: --prefix=/etc/nginx --sbin-path=/usr/sbin/nginx --modules-path=/usr/lib/nginx/modules ` +
+	`--conf-path=/etc/nginx/nginx.conf --error-log-path=/var/log/nginx/error.log --http-log-path=/var/log/nginx/access.log ` +
+	`--pid-path=/var/run/nginx.pid --lock-path=/var/run/nginx.lock --http-client-body-temp-path=/var/cache/nginx/client_temp ` +
+	`--http-proxy-temp-path=/var/cache/nginx/proxy_temp --http-fastcgi-temp-path=/var/cache/nginx/fastcgi_temp ` +
+	`--http-uwsgi-temp-path=/var/cache/nginx/uwsgi_temp --http-scgi-temp-path=/var/cache/nginx/scgi_temp --user=nginx ` +
+	`--group=nginx --with-compat --with-file-aio --with-threads --with-http_addition_module --with-http_auth_request_module ` +
+	`--with-http_dav_module --with-http_flv_module --with-http_gunzip_module --with-http_gzip_static_module ` +
+	`--with-http_mp4_module --with-http_random_index_module --with-http_realip_module --with-http_secure_link_module ` +
+	`--with-http_slice_module --with-http_ssl_module --with-http_stub_status_module --with-http_sub_module ` +
+	`--with-http_v2_module --with-http_v3_module --with-mail --with-mail_ssl_module --with-stream ` +
+	`--with-stream_realip_module --with-stream_ssl_module --with-stream_ssl_preread_module ` +
+	`--with-cc-opt='-g -O2 -ffile-prefix-map=/data/builder/debuild/nginx-1.26.3/debian/debuild-base/nginx-1.26.3=. ` +
+	`-fstack-protector-strong -Wformat -Werror=format-security -Wp,-D_FORTIFY_SOURCE=2 -fPIC' ` +
+	`--with-ld-opt='-Wl,-z,relro -Wl,-z,now -Wl,--as-needed -pie'
+`
+
+// the nginx -V of the nginx package of Debian bookworm, which has dynamic modules
+const debianV = `nginx version: nginx/1.22.1
+built with OpenSSL 3.0.8 7 Feb 2023 (running with OpenSSL 3.0.11 19 Sep 2023)
+TLS SNI support enabled
+configure arguments: --with-cc-opt='-g -O2 -ffile-prefix-map=/build/nginx-AoTv4W/nginx-1.22.1=. -fstack-protector-strong ` +
+	`-Wformat -Werror=format-security -fPIC -Wdate-time -D_FORTIFY_SOURCE=2' --with-ld-opt='-Wl,-z,relro -Wl,-z,now -fPIC' ` +
+	`--prefix=/usr/share/nginx --conf-path=/etc/nginx/nginx.conf --http-log-path=/var/log/nginx/access.log ` +
+	`--error-log-path=stderr --lock-path=/var/lock/nginx.lock --pid-path=/run/nginx.pid --modules-path=/usr/lib/nginx/modules ` +
+	`--http-client-body-temp-path=/var/lib/nginx/body --http-fastcgi-temp-path=/var/lib/nginx/fastcgi ` +
+	`--http-proxy-temp-path=/var/lib/nginx/proxy --http-scgi-temp-path=/var/lib/nginx/scgi ` +
+	`--http-uwsgi-temp-path=/var/lib/nginx/uwsgi --with-compat --with-debug --with-pcre-jit --with-http_ssl_module ` +
+	`--with-http_stub_status_module --with-http_realip_module --with-http_auth_request_module --with-http_v2_module ` +
+	`--with-http_dav_module --with-http_slice_module --with-threads --with-http_addition_module --with-http_flv_module ` +
+	`--with-http_gunzip_module --with-http_gzip_static_module --with-http_mp4_module --with-http_random_index_module ` +
+	`--with-http_secure_link_module --with-http_sub_module --with-mail_ssl_module --with-stream_ssl_module ` +
+	`--with-stream_ssl_preread_module --with-stream_realip_module --with-http_geoip_module=dynamic ` +
+	`--with-http_image_filter_module=dynamic --with-http_perl_module=dynamic --with-http_xslt_module=dynamic ` +
+	`--with-mail=dynamic --with-stream=dynamic --with-stream_geoip_module=dynamic
+`
+
+func TestDirectiveSources(t *testing.T) {
+	t.Parallel()
+	tcs := map[string]struct {
+		nginxV      string
+		loadModules []string
+		known       []string
+		unknown     []string
+		masks       map[string][]uint
+	}{
+		"no build": {
+			known:   []string{"api", "http2", "tunnel_pass"},
+			unknown: []string{"js_import", "otel_exporter"},
+		},
+		"oss 1.24": {
+			nginxV:  "nginx version: nginx/1.24.0\nbuilt by gcc 12.2.0\nconfigure arguments: --with-http_v2_module",
+			known:   []string{"http2_push", "proxy_pass", "gzip"},
+			unknown: []string{"http2", "api", "js_import", "ssl_certificate", "stream", "mail", "auth_request"},
+			masks: map[string][]uint{
+				"listen": {ngxHTTPSrvConf | ngxConf1More},
+			},
+		},
+		"nginx.org package": {
+			nginxV: nginxOrgV,
+			known: []string{
+				"ssl_certificate", "http2", "http3", "quic_retry", "auth_request", "stream", "ssl_preread", "mail",
+				"starttls", "set_real_ip_from", "gzip_static", "secure_link", "thread_pool", "proxy_ssl_verify",
+			},
+			unknown: []string{"image_filter", "xslt_stylesheet", "geoip_country", "perl", "api", "js_import"},
+			masks: map[string][]uint{
+				"ssl_certificate": {
+					ngxHTTPMainConf | ngxHTTPSrvConf | ngxConfTake1,
+					ngxMailMainConf | ngxMailSrvConf | ngxConfTake1,
+					ngxStreamMainConf | ngxStreamSrvConf | ngxConfTake1,
+				},
+			},
+		},
+		"without modules": {
+			nginxV: "nginx version: nginx/1.26.3\nbuilt by gcc 12.2.0 (Debian 12.2.0-14)\n" +
+				"configure arguments: --prefix=/usr/local/nginx --with-stream --without-http_gzip_module " +
+				"--without-http_rewrite_module --without-stream_limit_conn_module --without-http_upstream_keepalive_module",
+			known:   []string{"stream", "proxy_pass", "keepalive_timeout"},
+			unknown: []string{"gzip", "gzip_types", "rewrite", "if", "ssl_certificate", "ssl_preread", "mail"},
+			masks: map[string][]uint{
+				// the return of stream is kept, and so is the limit_conn of http
+				"return":           {ngxStreamSrvConf | ngxConfTake1},
+				"limit_conn":       {ngxHTTPMainConf | ngxHTTPSrvConf | ngxHTTPLocConf | ngxConfTake2},
+				"keepalive_time":   {ngxHTTPMainConf | ngxHTTPSrvConf | ngxHTTPLocConf | ngxConfTake1},
+				"ssl_certificate":  nil,
+				"proxy_ssl_verify": nil,
+			},
+		},
+		"dynamic modules": {
+			nginxV:      debianV,
+			loadModules: []string{"modules/ngx_stream_module.so", "/usr/lib/nginx/modules/ngx_http_geoip_module.so"},
+			known:       []string{"stream", "ssl_preread", "geoip_country", "ssl_certificate", "http2_push", "thread_pool"},
+			unknown:     []string{"mail", "starttls", "image_filter", "xslt_stylesheet", "perl", "http3"},
+			masks: map[string][]uint{
+				// geoip_country of stream isn't loaded
+				"geoip_country": {ngxHTTPMainConf | ngxConfTake12},
+			},
+		},
+		"oss 1.25 mainline": {
+			nginxV:  "nginx version: nginx/1.25.5\n",
+			unknown: []string{"http2"},
+		},
+		"oss 1.27": {
+			nginxV:  "nginx version: nginx/1.27.4\n",
+			known:   []string{"http2", "ssl_certificate_cache"},
+			unknown: []string{"api"},
+		},
+		"plus R32": {
+			nginxV:  "nginx version: nginx/1.25.5 (nginx-plus-r32-p1)\n",
+			known:   []string{"api", "http2"},
+			unknown: []string{"tunnel_pass"},
+		},
+		"added modules": {
+			nginxV: "nginx version: nginx/1.26.3\nconfigure arguments: --with-compat " +
+				"--add-module=/build/njs/nginx --add-module='/build/headers-more-nginx-module' " +
+				"--add-dynamic-module=/build/lua-nginx-module",
+			known:   []string{"js_import", "more_set_headers"},
+			unknown: []string{"content_by_lua_block"},
+		},
+		"loaded modules": {
+			nginxV:      "nginx version: nginx/1.26.3\n",
+			loadModules: []string{"modules/ngx_otel_module.so", "/usr/lib/nginx/modules/ngx_stream_geoip2_module.so", "ngx_unknown_module.so"},
+			known:       []string{"otel_exporter", "geoip2"},
+			unknown:     []string{"js_import"},
+		},
+	}
+
+	for name, tc := range tcs {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			sources := DirectiveSources(tc.nginxV, tc.loadModules...)
+			for _, directive := range tc.known {
+				_, known := matchDirective(directive, sources)
+				require.True(t, known, directive)
+			}
+			for _, directive := range tc.unknown {
+				_, known := matchDirective(directive, sources)
+				require.False(t, known, directive)
+			}
+			for directive, want := range tc.masks {
+				masks, _ := matchDirective(directive, sources)
+				require.Equal(t, want, masks, directive)
+			}
+		})
+	}
+}
+
+func TestParse_autoDirectiveSources(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	write := func(name, conf string) string {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, []byte(conf), 0o600))
+		return path
+	}
+	main := write("nginx.conf", `
+include modules-enabled/*.conf;
+events {}
+http {
+    js_import main.js;
+    more_set_headers "Server: test";
+    server {
+        http2 on;
+        # load_module modules/ngx_http_headers_more_filter_module.so;
+        location / {
+            include nested.conf;
+        }
+    }
+}
+`)
+	write("modules-enabled/50-njs.conf", "load_module modules/ngx_http_js_module.so;\n")
+	write("nested.conf", "load_module modules/ngx_http_headers_more_filter_module.so;\n")
+
+	unknown := func(errs []PayloadError) []string {
+		directives := []string{}
+		for _, e := range errs {
+			var perr *ParseError
+			require.True(t, errors.As(e.Error, &perr), e.Error)
+			directives = append(directives, perr.What)
+		}
+		return directives
+	}
+
+	payload, err := Parse(main, &ParseOptions{AutoDirectiveSources: true, ErrorOnUnknownDirectives: true})
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		`unknown directive "more_set_headers"`,
+		`"load_module" directive is not allowed here`,
+	}, unknown(payload.Errors))
+
+	options := &ParseOptions{
+		AutoDirectiveSources:     true,
+		ErrorOnUnknownDirectives: true,
+		NginxBuild:               "nginx version: nginx/1.24.0\n",
+	}
+	payload, err = Parse(main, options)
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		`unknown directive "more_set_headers"`,
+		`unknown directive "http2"`,
+		`"load_module" directive is not allowed here`,
+	}, unknown(payload.Errors))
+	// the options aren't changed
+	require.Nil(t, options.DirectiveSources)
+
+	// the Decoder selects the same sources
+	dec := NewDecoder(main, options)
+	var errs []PayloadError
+	for {
+		ev, derr := dec.Token()
+		if errors.Is(derr, io.EOF) {
+			break
+		}
+		require.NoError(t, derr)
+		if ev.Kind == ErrorEvent {
+			errs = append(errs, PayloadError{File: ev.File, Error: ev.Error})
+		}
+	}
+	require.Equal(t, unknown(payload.Errors), unknown(errs))
+
+	// a Parser parses the files again when the loaded modules change
+	parser := NewParser(&ParseOptions{AutoDirectiveSources: true, ErrorOnUnknownDirectives: true})
+	_, _, err = parser.Parse(main)
+	require.NoError(t, err)
+	write("modules-enabled/50-njs.conf", "load_module modules/ngx_http_headers_more_filter_module.so;\n")
+	payload, changed, err := parser.Parse(main)
+	require.NoError(t, err)
+	require.Contains(t, changed, main)
+	require.Equal(t, []string{
+		`unknown directive "js_import"`,
+		`"load_module" directive is not allowed here`,
+	}, unknown(payload.Errors))
+}
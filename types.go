@@ -8,6 +8,7 @@
 package crossplane
 
 import (
+	"encoding/json"
 	"fmt"
 	"strings"
 )
@@ -15,7 +16,10 @@ import (
 type Payload struct {
 	Status string         `json:"status"`
 	Errors []PayloadError `json:"errors"`
-	Config []Config       `json:"config"`
+	Config []Config       `json:"config" jsonschema:"required"`
+
+	// arenas hold the directives of the configs when ParseOptions.UseArena is true.
+	arenas []*arena
 }
 
 type PayloadError struct {
@@ -25,11 +29,52 @@ type PayloadError struct {
 	Callback interface{} `json:"callback,omitempty"`
 }
 
+type payloadErrorJSON struct {
+	File       string            `json:"file"`
+	Line       *int              `json:"line"`
+	Error      json.RawMessage   `json:"error" jsonschema:"type=string|object|null"`
+	Callback   interface{}       `json:"callback,omitempty"`
+	ParseError *ParseErrorDetail `json:"parseError,omitempty"`
+}
+
+// MarshalJSON writes the error message in the "error" field and, if the error
+// is a *ParseError, its structured form in the "parseError" field.
+func (e PayloadError) MarshalJSON() ([]byte, error) {
+	msg, detail, err := encodeError(e.Error)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(payloadErrorJSON{
+		File:       e.File,
+		Line:       e.Line,
+		Error:      msg,
+		Callback:   e.Callback,
+		ParseError: detail,
+	})
+}
+
+// UnmarshalJSON restores a PayloadError written by MarshalJSON. Errors without
+// a "parseError" field are restored from their message only.
+func (e *PayloadError) UnmarshalJSON(b []byte) error {
+	var v payloadErrorJSON
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	if err := v.ParseError.CheckVersion(); err != nil {
+		return err
+	}
+	e.File = v.File
+	e.Line = v.Line
+	e.Error = decodeError(v.Error, v.ParseError)
+	e.Callback = v.Callback
+	return nil
+}
+
 type Config struct {
-	File   string        `json:"file"`
+	File   string        `json:"file" jsonschema:"required"`
 	Status string        `json:"status"`
 	Errors []ConfigError `json:"errors"`
-	Parsed Directives    `json:"parsed"`
+	Parsed Directives    `json:"parsed" jsonschema:"required"`
 }
 
 type ConfigError struct {
@@ -37,8 +82,43 @@ type ConfigError struct {
 	Error error `json:"error"`
 }
 
+type configErrorJSON struct {
+	Line       *int              `json:"line"`
+	Error      json.RawMessage   `json:"error" jsonschema:"type=string|object|null"`
+	ParseError *ParseErrorDetail `json:"parseError,omitempty"`
+}
+
+// MarshalJSON writes the error message in the "error" field and, if the error
+// is a *ParseError, its structured form in the "parseError" field.
+func (e ConfigError) MarshalJSON() ([]byte, error) {
+	msg, detail, err := encodeError(e.Error)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(configErrorJSON{
+		Line:       e.Line,
+		Error:      msg,
+		ParseError: detail,
+	})
+}
+
+// UnmarshalJSON restores a ConfigError written by MarshalJSON. Errors without
+// a "parseError" field are restored from their message only.
+func (e *ConfigError) UnmarshalJSON(b []byte) error {
+	var v configErrorJSON
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	if err := v.ParseError.CheckVersion(); err != nil {
+		return err
+	}
+	e.Line = v.Line
+	e.Error = decodeError(v.Error, v.ParseError)
+	return nil
+}
+
 type Directive struct {
-	Directive string     `json:"directive"`
+	Directive string     `json:"directive" jsonschema:"required"`
 	Line      int        `json:"line"`
 	Args      []string   `json:"args"`
 	File      string     `json:"file,omitempty"`
@@ -47,6 +127,19 @@ type Directive struct {
 	Comment   *string    `json:"comment,omitempty"`
 	// IsMapBlockParameter is true if the directive represents a parameter in the body of a "map-like" directive.
 	IsMapBlockParameter bool `json:"mapBlockParameter,omitempty"`
+
+	// lastArgLine is the line of the token of the last argument of a parsed
+	// directive, which for the code of a *_by_lua_block directive is the line of
+	// the "}" that ends it. It's 0 if the directive has no arguments or wasn't parsed.
+	lastArgLine int
+}
+
+// LastArgLine returns the line of the token of the last argument of a directive
+// returned by Parse or a Decoder. For the code of a *_by_lua_block directive that's
+// the line of the "}" that ends it. It's 0 if the directive has no arguments or
+// wasn't parsed.
+func (d *Directive) LastArgLine() int {
+	return d.lastArgLine
 }
 
 type Directives []*Directive
@@ -138,9 +231,37 @@ func (d *Directive) String() string {
 	return fmt.Sprintf("%s %s {...}", d.Directive, strings.Join(d.Args, " "))
 }
 
+// CombineOptions determine how the configs of a Payload are combined.
+type CombineOptions struct {
+	// If true, the directives of every included file are put between two comments
+	// marking where the file begins and ends, " begin of <file>" and " end of <file>".
+	// The File and Line of these comments are the ones of the include directive.
+	MarkIncludes bool
+}
+
 // Combined returns a new Payload that is the same except that the inluding
 // logic is performed on its configs. This means that the resulting Payload
 // will always have 0 or 1 configs in its Config field.
 func (p *Payload) Combined() (*Payload, error) {
-	return combineConfigs(p)
+	return combineConfigs(p, CombineOptions{})
+}
+
+// CombinedWithOptions is Combined with the given options.
+func (p *Payload) CombinedWithOptions(options CombineOptions) (*Payload, error) {
+	return combineConfigs(p, options)
+}
+
+// Release returns the memory of a Payload parsed with ParseOptions.UseArena to
+// be reused by later parses, and removes its configs. Neither the Payload nor
+// the ones combined from it, or their directives, can be used after that. It
+// does nothing for other payloads.
+func (p *Payload) Release() {
+	if p.arenas == nil {
+		return
+	}
+	for _, a := range p.arenas {
+		a.release()
+	}
+	p.arenas = nil
+	p.Config = nil
 }
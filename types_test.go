@@ -8,9 +8,12 @@
 package crossplane
 
 import (
+	"encoding/json"
+	"errors"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestDirective_String(t *testing.T) {
@@ -325,3 +328,88 @@ func TestDirective_Equal(t *testing.T) {
 		assert.Equal(t, eq, ef.equal)
 	}
 }
+
+func TestPayload_JSONRoundTrip(t *testing.T) {
+	t.Parallel()
+	payload, err := Parse(getTestConfigPath("includes-regular", "nginx.conf"), &ParseOptions{})
+	require.NoError(t, err)
+	require.NotEmpty(t, payload.Errors)
+
+	b, err := json.Marshal(payload)
+	require.NoError(t, err)
+
+	var decoded Payload
+	require.NoError(t, json.Unmarshal(b, &decoded))
+	require.True(t, equalPayloads(t, *payload, decoded))
+
+	for i, perr := range decoded.Errors {
+		var got, want *ParseError
+		require.True(t, errors.As(perr.Error, &got))
+		require.True(t, errors.As(payload.Errors[i].Error, &want))
+		assert.Equal(t, want.What, got.What)
+		assert.Equal(t, *want.File, *got.File)
+		assert.Equal(t, *want.Line, *got.Line)
+		assert.Equal(t, want.Statement, got.Statement)
+		assert.Equal(t, want.BlockCtx, got.BlockCtx)
+	}
+	for i, config := range decoded.Config {
+		for j, cerr := range config.Errors {
+			assert.Equal(t, payload.Config[i].Errors[j].Error.Error(), cerr.Error.Error())
+		}
+	}
+
+	// marshaling the decoded payload must produce the same JSON
+	b2, err := json.Marshal(decoded)
+	require.NoError(t, err)
+	assert.JSONEq(t, string(b), string(b2))
+}
+
+func TestPayload_UnmarshalVersion1Errors(t *testing.T) {
+	t.Parallel()
+	v1 := `{
+		"status": "failed",
+		"errors": [{"file": "nginx.conf", "line": 2, "error": "unknown directive \"foo\" in nginx.conf:2"}],
+		"config": [{
+			"file": "nginx.conf",
+			"status": "failed",
+			"errors": [{"line": 2, "error": "unknown directive \"foo\" in nginx.conf:2"}, {"line": null, "error": {}}],
+			"parsed": []
+		}]
+	}`
+
+	var payload Payload
+	require.NoError(t, json.Unmarshal([]byte(v1), &payload))
+	require.Len(t, payload.Errors, 1)
+	assert.Equal(t, `unknown directive "foo" in nginx.conf:2`, payload.Errors[0].Error.Error())
+	assert.Equal(t, 2, *payload.Errors[0].Line)
+	require.Len(t, payload.Config[0].Errors, 2)
+	assert.Equal(t, `unknown directive "foo" in nginx.conf:2`, payload.Config[0].Errors[0].Error.Error())
+	assert.Equal(t, "{}", payload.Config[0].Errors[1].Error.Error())
+}
+
+func TestPayload_UnmarshalUnsupportedErrorVersion(t *testing.T) {
+	t.Parallel()
+	tcs := map[string]struct {
+		decode func(s string) error
+		data   string
+	}{
+		"json payload error": {
+			decode: func(s string) error { return json.Unmarshal([]byte(s), &Payload{}) },
+			data:   `{"errors": [{"file": "nginx.conf", "line": 1, "error": "oops", "parseError": {"version": 3, "what": "oops"}}], "config": []}`,
+		},
+		"json config error": {
+			decode: func(s string) error { return json.Unmarshal([]byte(s), &Payload{}) },
+			data:   `{"config": [{"file": "nginx.conf", "errors": [{"line": 1, "error": "oops", "parseError": {"what": "oops"}}], "parsed": []}]}`,
+		},
+	}
+
+	for name, tc := range tcs {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			err := tc.decode(tc.data)
+			require.Error(t, err)
+			require.Contains(t, err.Error(), "unsupported error format version")
+		})
+	}
+}
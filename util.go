@@ -13,11 +13,6 @@ import (
 	"unicode"
 )
 
-type included struct {
-	directive *Directive
-	err       error
-}
-
 func contains(xs []string, x string) bool {
 	for _, s := range xs {
 		if s == x {
@@ -86,7 +81,7 @@ func prepareIfArgs(d *Directive) *Directive {
 }
 
 // combineConfigs combines config files into one by using include directives.
-func combineConfigs(old *Payload) (*Payload, error) {
+func combineConfigs(old *Payload, options CombineOptions) (*Payload, error) {
 	if len(old.Config) < 1 {
 		return old, nil
 	}
@@ -105,7 +100,6 @@ func combineConfigs(old *Payload) (*Payload, error) {
 		File:   old.Config[0].File,
 		Status: "ok",
 		Errors: []ConfigError{},
-		Parsed: Directives{},
 	}
 
 	for _, config := range old.Config {
@@ -115,58 +109,93 @@ func combineConfigs(old *Payload) (*Payload, error) {
 		}
 	}
 
-	for incl := range performIncludes(old, combined.File, old.Config[0].Parsed) {
-		if incl.err != nil {
-			return nil, incl.err
-		}
-		combined.Parsed = append(combined.Parsed, incl.directive)
+	c := combiner{payload: old, options: options, including: map[int]bool{0: true}}
+	parsed, err := c.performIncludes(combined.File, old.Config[0].Parsed)
+	if err != nil {
+		return nil, err
 	}
+	combined.Parsed = parsed
 
 	return &Payload{
 		Status: status,
 		Errors: errors,
 		Config: []Config{combined},
+		arenas: old.arenas,
 	}, nil
 }
 
-func performIncludes(old *Payload, fromfile string, block Directives) chan included {
-	c := make(chan included)
-	go func() {
-		defer close(c)
-		for _, d := range block {
-			dir := *d
-			if dir.IsBlock() {
-				nblock := Directives{}
-				for incl := range performIncludes(old, fromfile, dir.Block) {
-					if incl.err != nil {
-						c <- incl
-						return
-					}
-					nblock = append(nblock, incl.directive)
-				}
-				dir.Block = nblock
+// combiner replaces the include directives of the configs of a payload with the
+// directives of the configs they include.
+type combiner struct {
+	payload *Payload
+	options CombineOptions
+	// including holds the indices of the configs being included, to catch cycles
+	including map[int]bool
+}
+
+// performIncludes returns a copy of block, a block of fromfile, where include
+// directives are replaced with the directives of the files they include.
+func (c *combiner) performIncludes(fromfile string, block Directives) (Directives, error) {
+	combined := Directives{}
+	for _, d := range block {
+		dir := *d
+		if dir.IsBlock() {
+			nblock, err := c.performIncludes(fromfile, dir.Block)
+			if err != nil {
+				return nil, err
 			}
-			if !dir.IsInclude() {
-				c <- included{directive: &dir}
-				continue
+			dir.Block = nblock
+		}
+		if !dir.IsInclude() {
+			combined = append(combined, &dir)
+			continue
+		}
+		for _, idx := range dir.Includes {
+			var what string
+			switch {
+			case idx < 0 || idx >= len(c.payload.Config):
+				what = fmt.Sprintf("include config with index: %d", idx)
+			case c.including[idx]:
+				what = fmt.Sprintf("include cycle with config index: %d", idx)
 			}
-			for _, idx := range dir.Includes {
-				if idx >= len(old.Config) {
-					c <- included{
-						err: &ParseError{
-							What:      fmt.Sprintf("include config with index: %d", idx),
-							File:      &fromfile,
-							Line:      &dir.Line,
-							Statement: dir.String(),
-						},
-					}
-					return
-				}
-				for incl := range performIncludes(old, old.Config[idx].File, old.Config[idx].Parsed) {
-					c <- incl
+			if what != "" {
+				return nil, &ParseError{
+					What:      what,
+					File:      &fromfile,
+					Line:      &dir.Line,
+					Statement: dir.String(),
 				}
 			}
+
+			config := c.payload.Config[idx]
+			c.including[idx] = true
+			included, err := c.performIncludes(config.File, config.Parsed)
+			delete(c.including, idx)
+			if err != nil {
+				return nil, err
+			}
+
+			if c.options.MarkIncludes {
+				combined = append(combined, includeMarker(fromfile, &dir, config.File, "begin"))
+			}
+			combined = append(combined, included...)
+			if c.options.MarkIncludes {
+				combined = append(combined, includeMarker(fromfile, &dir, config.File, "end"))
+			}
 		}
-	}()
-	return c
+	}
+	return combined, nil
+}
+
+// includeMarker returns the comment that marks where the file included by dir, a
+// directive of fromfile, begins or ends. It's placed at dir in fromfile.
+func includeMarker(fromfile string, dir *Directive, file string, where string) *Directive {
+	comment := fmt.Sprintf(" %s of %s", where, file)
+	return &Directive{
+		Directive: "#",
+		Line:      dir.Line,
+		Args:      []string{},
+		File:      fromfile,
+		Comment:   &comment,
+	}
 }
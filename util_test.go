@@ -12,6 +12,7 @@ import (
 	"testing"
 
 	. "github.com/nginxinc/nginx-go-crossplane" //nolint: revive
+	"github.com/stretchr/testify/require"
 )
 
 //nolint:funlen
@@ -82,4 +83,83 @@ func TestPayload(t *testing.T) {
 			t.Fatalf("expected: %s\nbut got: %s", b1, b2)
 		}
 	})
+	t.Run("combine with markers", func(t *testing.T) {
+		t.Parallel()
+		payload := Payload{
+			Config: []Config{
+				{
+					File: "nginx.conf",
+					Parsed: Directives{
+						{Directive: "http", Args: []string{}, Line: 1, Block: Directives{
+							{Directive: "include", Args: []string{"conf.d/*.conf"}, Line: 2, Includes: []int{1, 2}},
+						}},
+					},
+				},
+				{
+					File: "conf.d/a.conf",
+					Parsed: Directives{
+						{Directive: "include", Args: []string{"b.conf"}, Line: 1, Includes: []int{2}},
+					},
+				},
+				{
+					File: "conf.d/b.conf",
+					Parsed: Directives{
+						{Directive: "server", Args: []string{}, Line: 1, Block: Directives{}},
+					},
+				},
+			},
+		}
+		marker := func(line int, file, comment string) *Directive {
+			return &Directive{Directive: "#", Args: []string{}, Line: line, File: file, Comment: &comment}
+		}
+		server := &Directive{Directive: "server", Args: []string{}, Line: 1, Block: Directives{}}
+
+		combined, err := payload.CombinedWithOptions(CombineOptions{MarkIncludes: true})
+		require.NoError(t, err)
+		require.Equal(t, Directives{
+			{Directive: "http", Args: []string{}, Line: 1, Block: Directives{
+				marker(2, "nginx.conf", " begin of conf.d/a.conf"),
+				marker(1, "conf.d/a.conf", " begin of conf.d/b.conf"),
+				server,
+				marker(1, "conf.d/a.conf", " end of conf.d/b.conf"),
+				marker(2, "nginx.conf", " end of conf.d/a.conf"),
+				marker(2, "nginx.conf", " begin of conf.d/b.conf"),
+				server,
+				marker(2, "nginx.conf", " end of conf.d/b.conf"),
+			}},
+		}, combined.Config[0].Parsed)
+
+		// the payload is left as it is
+		require.Len(t, payload.Config, 3)
+		require.Equal(t, []int{1, 2}, payload.Config[0].Parsed[0].Block[0].Includes)
+	})
+	t.Run("combine errors", func(t *testing.T) {
+		t.Parallel()
+		payload := Payload{
+			Config: []Config{
+				{
+					File: "nginx.conf",
+					Parsed: Directives{
+						{Directive: "include", Args: []string{"a.conf"}, Line: 1, Includes: []int{1}},
+					},
+				},
+				{
+					File: "a.conf",
+					Parsed: Directives{
+						{Directive: "include", Args: []string{"nginx.conf"}, Line: 3, Includes: []int{0}},
+					},
+				},
+			},
+		}
+		_, err := payload.Combined()
+		require.EqualError(t, err, "include cycle with config index: 0 in a.conf:3")
+
+		payload.Config[1].Parsed[0].Includes = []int{2}
+		_, err = payload.Combined()
+		require.EqualError(t, err, "include config with index: 2 in a.conf:3")
+
+		payload.Config[1].Parsed[0].Includes = []int{-1}
+		_, err = payload.Combined()
+		require.EqualError(t, err, "include config with index: -1 in a.conf:3")
+	})
 }